@@ -0,0 +1,57 @@
+// kubecop-replay runs the rules against recorded events, without a cluster or eBPF.
+//
+// It reads a JSON-lines file of tracing events and YAML files describing the containers, their
+// application profiles, the rule bindings and the custom rules, drives the events through an engine
+// bound to fake Kubernetes clients and prints the alerts as JSON lines.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	config := ReplayConfig{}
+	flag.StringVar(&config.EventsFile, "events", "", "JSON-lines file of the events to replay (mandatory)")
+	flag.StringVar(&config.ContainersFile, "containers", "", "YAML file describing the containers of the events (mandatory)")
+	flag.StringVar(&config.ProfilesFile, "profiles", "", "YAML file of ApplicationProfile objects")
+	flag.StringVar(&config.BindingsFile, "bindings", "", "YAML file of RuntimeRuleAlertBinding objects")
+	flag.StringVar(&config.RuntimeRulesFile, "rules", "", "YAML file of RuntimeRule objects")
+	flag.DurationVar(&config.AlertSuppressionWindow, "suppression-window", 0, "window of the suppression of repeated alerts, disabled if zero")
+	output := flag.String("output", "", "file the alerts are written to, standard output if not set")
+	verbose := flag.Bool("v", false, "log the engine activity")
+	flag.Parse()
+
+	if config.EventsFile == "" || config.ContainersFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetLevel(log.WarnLevel)
+	if *verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	alerts, err := Replay(config)
+	if err != nil {
+		log.Fatalf("Replay failed: %v\n", err)
+	}
+
+	outputFile := os.Stdout
+	if *output != "" {
+		if outputFile, err = os.Create(*output); err != nil {
+			log.Fatalf("Failed to create output file: %v\n", err)
+		}
+		defer outputFile.Close()
+	}
+	encoder := json.NewEncoder(outputFile)
+	for _, alert := range alerts {
+		if err := encoder.Encode(alert); err != nil {
+			log.Fatalf("Failed to write alert: %v\n", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d alerts\n", len(alerts))
+}
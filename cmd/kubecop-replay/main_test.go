@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplay(t *testing.T) {
+	alerts, err := Replay(ReplayConfig{
+		EventsFile:       "testdata/events.jsonl",
+		ContainersFile:   "testdata/containers.yaml",
+		ProfilesFile:     "testdata/profiles.yaml",
+		BindingsFile:     "testdata/bindings.yaml",
+		RuntimeRulesFile: "testdata/rules.yaml",
+	})
+	assert.NoError(t, err)
+	if !assert.Len(t, alerts, 3) {
+		return
+	}
+
+	// the shell is not in the application profile of nginx, the nginx exec and open are
+	assert.Equal(t, rule.R0001UnexpectedProcessLaunchedRuleName, alerts[0].RuleName)
+	assert.Equal(t, "/bin/sh", alerts[0].Fingerprint)
+	assert.Equal(t, uint32(20), alerts[0].Pid)
+	// the shell was spawned by nginx, which was started by pid 1
+	if assert.Len(t, alerts[0].ProcessAncestry, 3) {
+		assert.Equal(t, "/bin/sh", alerts[0].ProcessAncestry[0].Path)
+		assert.Equal(t, "/usr/sbin/nginx", alerts[0].ProcessAncestry[1].Path)
+		assert.Equal(t, uint32(1), alerts[0].ProcessAncestry[2].Pid)
+	}
+
+	// the custom rule is bound by its tag
+	assert.Equal(t, "Curl executed in payments", alerts[1].RuleName)
+	assert.Equal(t, "curl executed by bash in payments-api:2.1", alerts[1].Message)
+	assert.Equal(t, rule.RulePriorityHigh, alerts[1].Priority)
+
+	// the severity of the binding applies
+	assert.Equal(t, rule.R1002LoadKernelModuleRuleName, alerts[2].RuleName)
+	assert.Equal(t, rule.RulePriorityMed, alerts[2].Priority)
+
+	// the custom rules are unregistered after the replay
+	assert.Nil(t, rule.CreateRuleByID("C0001"))
+}
+
+func TestReplayErrors(t *testing.T) {
+	_, err := Replay(ReplayConfig{EventsFile: "testdata/events.jsonl", ContainersFile: "testdata/missing.yaml"})
+	assert.Error(t, err)
+	_, err = Replay(ReplayConfig{EventsFile: "testdata/events.jsonl", ContainersFile: "testdata/events.jsonl"})
+	assert.Error(t, err)
+	_, err = Replay(ReplayConfig{EventsFile: "testdata/containers.yaml", ContainersFile: "testdata/containers.yaml"})
+	assert.Error(t, err)
+}
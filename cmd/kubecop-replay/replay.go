@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/approfilecache"
+	"github.com/armosec/kubecop/pkg/engine"
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/armosec/kubecop/pkg/runtimerulestore"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/kubescape/kapprofiler/pkg/collector"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	dfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+)
+
+// Name of the node the replayed pods run on.
+const replayNodeName = "kubecop-replay"
+
+// ContainersFile describes the containers the events come from and the cluster around them.
+type ContainersFile struct {
+	// ClusterIP of the kubernetes service, used by the rules detecting API server connections
+	ApiServerAddress string      `json:"apiServerAddress"`
+	Containers       []Container `json:"containers"`
+}
+
+type Container struct {
+	ContainerID        string            `json:"containerID"`
+	ContainerName      string            `json:"containerName"`
+	PodName            string            `json:"podName"`
+	Namespace          string            `json:"namespace"`
+	Image              string            `json:"image"`
+	PodLabels          map[string]string `json:"podLabels"`
+	NamespaceLabels    map[string]string `json:"namespaceLabels"`
+	ServiceAccountName string            `json:"serviceAccountName"`
+	// Top level owner of the pod, the pod itself if not set
+	Owner *ContainerOwner `json:"owner"`
+}
+
+type ContainerOwner struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// ReplayEvent is a line of the events file.
+type ReplayEvent struct {
+	// One of exec, open, network, dns, capabilities, syscall, randomx
+	Type string `json:"type"`
+	// Fields of the tracing event of the type, like {"ContainerID": "...", "Comm": "sh", "PathName": "/bin/sh"}
+	Event json.RawMessage `json:"event"`
+}
+
+// Alert is an alert printed by the replay.
+type Alert struct {
+	RuleName      string `json:"ruleName"`
+	Priority      int    `json:"priority"`
+	Message       string `json:"message"`
+	FixSuggestion string `json:"fixSuggestion"`
+	Fingerprint   string `json:"fingerprint"`
+	ContainerID   string `json:"containerID"`
+	ContainerName string `json:"containerName"`
+	PodName       string `json:"podName"`
+	Namespace     string `json:"namespace"`
+	Pid           uint32 `json:"pid"`
+	Ppid          uint32 `json:"ppid"`
+	Comm          string `json:"comm"`
+	Timestamp     int64  `json:"timestamp"`
+	// The process of the alert, then its parent, up to the oldest known ancestor
+	ProcessAncestry []rule.ProcessInfo `json:"processAncestry,omitempty"`
+}
+
+type ReplayConfig struct {
+	EventsFile     string
+	ContainersFile string
+	// Optional files of ApplicationProfile, RuntimeAlertRuleBinding and RuntimeRule objects
+	ProfilesFile     string
+	BindingsFile     string
+	RuntimeRulesFile string
+	// Window of the alert suppression, disabled if zero
+	AlertSuppressionWindow time.Duration
+}
+
+// collectingExporter keeps the alerts of the engine in the order they are sent.
+type collectingExporter struct {
+	mutex  sync.Mutex
+	alerts []Alert
+}
+
+func (exporter *collectingExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	event := failedRule.Event()
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.alerts = append(exporter.alerts, Alert{
+		RuleName:        failedRule.Name(),
+		Priority:        failedRule.Priority(),
+		Message:         failedRule.Error(),
+		FixSuggestion:   failedRule.FixSuggestion(),
+		Fingerprint:     failedRule.Fingerprint(),
+		ContainerID:     event.ContainerID,
+		ContainerName:   event.ContainerName,
+		PodName:         event.PodName,
+		Namespace:       event.Namespace,
+		Pid:             event.Pid,
+		Ppid:            event.Ppid,
+		Comm:            event.Comm,
+		Timestamp:       event.Timestamp,
+		ProcessAncestry: rule.GetProcessAncestry(failedRule),
+	})
+}
+
+func (exporter *collectingExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
+}
+
+// Replay drives the events through an engine bound to fake Kubernetes clients and returns the alerts.
+func Replay(config ReplayConfig) ([]Alert, error) {
+	containersFile, err := loadContainersFile(config.ContainersFile)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := loadObjects(config.ProfilesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load application profiles: %v", err)
+	}
+	bindings, err := loadObjects(config.BindingsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rule bindings: %v", err)
+	}
+	runtimeRules, err := loadObjects(config.RuntimeRulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load runtime rules: %v", err)
+	}
+	events, err := os.Open(config.EventsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open events file: %v", err)
+	}
+	defer events.Close()
+
+	unregisterRules, err := registerRuntimeRules(runtimeRules)
+	if err != nil {
+		return nil, err
+	}
+	defer unregisterRules()
+
+	clientset, err := newFakeClientset(containersFile)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient := dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		collector.AppProfileGvr:                 "ApplicationProfileList",
+		rulebindingstore.RuleBindingAlertGvr:    rulebindingstore.RuntimeRuleBindingAlertPlural + "List",
+		runtimerulestore.RuntimeRuleGvr:         "RuntimeRuleList",
+		{Version: "v1", Resource: "namespaces"}: "NamespaceList",
+	}, append(profiles, bindings...)...)
+
+	appProfileCache, err := approfilecache.NewApplicationProfileK8sCache(dynamicClient, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create application profile cache: %v", err)
+	}
+	defer appProfileCache.Destroy()
+	ruleBindingStore, err := rulebindingstore.NewRuleBindingK8sStore(dynamicClient, clientset.CoreV1(), replayNodeName, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rule binding store: %v", err)
+	}
+	defer ruleBindingStore.Destroy()
+
+	// A single worker processes the events in the order of the file, so the alerts are reproducible
+	exporter := &collectingExporter{}
+	replayEngine := engine.NewEngine(clientset, appProfileCache, nil, exporter, 1, replayNodeName)
+	replayEngine.SetGetRulesForContainerFunc(ruleBindingStore.GetRulesForContainer)
+	replayEngine.SetAlertSuppressionWindow(config.AlertSuppressionWindow)
+
+	for _, container := range containersFile.Containers {
+		replayEngine.OnContainerActivityEvent(&tracing.ContainerActivityEvent{
+			Activity:      tracing.ContainerActivityEventStart,
+			ContainerName: container.ContainerName,
+			ContainerID:   container.ContainerID,
+			PodName:       container.PodName,
+			Namespace:     container.Namespace,
+		})
+	}
+
+	replayErr := replayEvents(events, replayEngine)
+	// Wait for the events to be processed and the suppressed alerts to be summarized
+	replayEngine.Delete()
+	// The containers are removed from the cache shared by the engines before returning, so they do not
+	// outlive the replay and are not removed while a later replay uses the same container IDs
+	for _, container := range containersFile.Containers {
+		replayEngine.StopContainer(&tracing.ContainerActivityEvent{
+			Activity:    tracing.ContainerActivityEventStop,
+			ContainerID: container.ContainerID,
+		})
+	}
+	if replayErr != nil {
+		return nil, replayErr
+	}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	return exporter.alerts, nil
+}
+
+func replayEvents(events io.Reader, replayEngine *engine.Engine) error {
+	scanner := bufio.NewScanner(events)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var replayEvent ReplayEvent
+		if err := json.Unmarshal(scanner.Bytes(), &replayEvent); err != nil {
+			return fmt.Errorf("failed to parse event at line %d: %v", lineNumber, err)
+		}
+		if err := sendEvent(replayEngine, replayEvent); err != nil {
+			return fmt.Errorf("failed to replay event at line %d: %v", lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func sendEvent(replayEngine *engine.Engine, replayEvent ReplayEvent) error {
+	switch replayEvent.Type {
+	case "exec":
+		event := &tracing.ExecveEvent{}
+		if err := json.Unmarshal(replayEvent.Event, event); err != nil {
+			return err
+		}
+		event.EventType = tracing.ExecveEventType
+		replayEngine.SendExecveEvent(event)
+	case "open":
+		event := &tracing.OpenEvent{}
+		if err := json.Unmarshal(replayEvent.Event, event); err != nil {
+			return err
+		}
+		event.EventType = tracing.OpenEventType
+		replayEngine.SendOpenEvent(event)
+	case "network":
+		event := &tracing.NetworkEvent{}
+		if err := json.Unmarshal(replayEvent.Event, event); err != nil {
+			return err
+		}
+		event.EventType = tracing.NetworkEventType
+		replayEngine.SendNetworkEvent(event)
+	case "dns":
+		event := &tracing.DnsEvent{}
+		if err := json.Unmarshal(replayEvent.Event, event); err != nil {
+			return err
+		}
+		event.EventType = tracing.DnsEventType
+		replayEngine.SendDnsEvent(event)
+	case "capabilities":
+		event := &tracing.CapabilitiesEvent{}
+		if err := json.Unmarshal(replayEvent.Event, event); err != nil {
+			return err
+		}
+		event.EventType = tracing.CapabilitiesEventType
+		replayEngine.SendCapabilitiesEvent(event)
+	case "syscall":
+		event := &tracing.SyscallEvent{}
+		if err := json.Unmarshal(replayEvent.Event, event); err != nil {
+			return err
+		}
+		event.EventType = tracing.SyscallEventType
+		replayEngine.SendSyscallEvent(event)
+	case "randomx":
+		event := &tracing.RandomXEvent{}
+		if err := json.Unmarshal(replayEvent.Event, event); err != nil {
+			return err
+		}
+		event.EventType = tracing.RandomXEventType
+		replayEngine.SendRandomXEvent(event)
+	default:
+		return fmt.Errorf("unknown event type %q", replayEvent.Type)
+	}
+	return nil
+}
+
+func loadContainersFile(path string) (*ContainersFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read containers file: %v", err)
+	}
+	var containersFile ContainersFile
+	if err := yaml.UnmarshalStrict(content, &containersFile); err != nil {
+		return nil, fmt.Errorf("failed to parse containers file: %v", err)
+	}
+	for _, container := range containersFile.Containers {
+		if container.ContainerID == "" || container.ContainerName == "" || container.PodName == "" || container.Namespace == "" {
+			return nil, fmt.Errorf("container %+v must have a containerID, containerName, podName and namespace", container)
+		}
+	}
+	return &containersFile, nil
+}
+
+// loadObjects reads the Kubernetes objects of a multi-document YAML or JSON file, lists are flattened.
+func loadObjects(path string) ([]runtime.Object, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var objects []runtime.Object
+	decoder := k8syaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		object := &unstructured.Unstructured{}
+		if err := decoder.Decode(&object.Object); err == io.EOF {
+			return objects, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(object.Object) == 0 {
+			continue
+		}
+		if object.IsList() {
+			err := object.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, item)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		objects = append(objects, object)
+	}
+}
+
+// registerRuntimeRules registers the rules of the RuntimeRule objects and returns a function unregistering them.
+func registerRuntimeRules(objects []runtime.Object) (func(), error) {
+	registeredIDs := []string{}
+	unregister := func() {
+		for _, id := range registeredIDs {
+			rule.UnregisterRuleDescriptor(id)
+		}
+	}
+	for _, object := range objects {
+		content, err := json.Marshal(object)
+		if err != nil {
+			unregister()
+			return nil, err
+		}
+		var runtimeRule runtimerulestore.RuntimeRule
+		if err := json.Unmarshal(content, &runtimeRule); err != nil {
+			unregister()
+			return nil, fmt.Errorf("failed to parse runtime rule: %v", err)
+		}
+		descriptor, err := runtimerulestore.NewRuleDescriptor(&runtimeRule)
+		if err == nil {
+			err = rule.RegisterRuleDescriptor(descriptor)
+		}
+		if err != nil {
+			unregister()
+			return nil, fmt.Errorf("failed to register runtime rule %s: %v", runtimeRule.Name, err)
+		}
+		registeredIDs = append(registeredIDs, descriptor.ID)
+	}
+	return unregister, nil
+}
+
+// newFakeClientset creates the namespaces, pods and owners of the containers.
+func newFakeClientset(containersFile *ContainersFile) (*fake.Clientset, error) {
+	objects := []runtime.Object{}
+	namespaces := map[string]*corev1.Namespace{}
+	pods := map[string]*corev1.Pod{}
+	owners := map[string]struct{}{}
+	for _, container := range containersFile.Containers {
+		namespace, ok := namespaces[container.Namespace]
+		if !ok {
+			namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   container.Namespace,
+				Labels: map[string]string{"kubernetes.io/metadata.name": container.Namespace},
+			}}
+			namespaces[container.Namespace] = namespace
+			objects = append(objects, namespace)
+		}
+		for key, value := range container.NamespaceLabels {
+			namespace.Labels[key] = value
+		}
+
+		podKey := container.Namespace + "/" + container.PodName
+		pod, ok := pods[podKey]
+		if !ok {
+			pod = &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: container.PodName, Namespace: container.Namespace, Labels: map[string]string{}},
+				Spec:       corev1.PodSpec{NodeName: replayNodeName},
+			}
+			pods[podKey] = pod
+			objects = append(objects, pod)
+		}
+		for key, value := range container.PodLabels {
+			pod.Labels[key] = value
+		}
+		if container.ServiceAccountName != "" {
+			pod.Spec.ServiceAccountName = container.ServiceAccountName
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container.ContainerName, Image: container.Image})
+
+		if container.Owner == nil || len(pod.OwnerReferences) > 0 {
+			continue
+		}
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: container.Owner.Kind, Name: container.Owner.Name}}
+		ownerKey := container.Namespace + "/" + container.Owner.Kind + "/" + container.Owner.Name
+		if _, ok := owners[ownerKey]; ok {
+			continue
+		}
+		owners[ownerKey] = struct{}{}
+		ownerMeta := metav1.ObjectMeta{Name: container.Owner.Name, Namespace: container.Namespace}
+		switch container.Owner.Kind {
+		case "ReplicaSet":
+			objects = append(objects, &appsv1.ReplicaSet{ObjectMeta: ownerMeta})
+		case "Deployment":
+			objects = append(objects, &appsv1.Deployment{ObjectMeta: ownerMeta})
+		case "StatefulSet":
+			objects = append(objects, &appsv1.StatefulSet{ObjectMeta: ownerMeta})
+		case "DaemonSet":
+			objects = append(objects, &appsv1.DaemonSet{ObjectMeta: ownerMeta})
+		case "Job":
+			objects = append(objects, &batchv1.Job{ObjectMeta: ownerMeta})
+		case "CronJob":
+			objects = append(objects, &batchv1.CronJob{ObjectMeta: ownerMeta})
+		}
+	}
+	if containersFile.ApiServerAddress != "" {
+		objects = append(objects, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{ClusterIPs: []string{containersFile.ApiServerAddress}},
+		})
+	}
+
+	clientset := fake.NewSimpleClientset()
+	for _, object := range objects {
+		if err := clientset.Tracker().Add(object); err != nil {
+			return nil, fmt.Errorf("failed to create %T: %v", object, err)
+		}
+	}
+	return clientset, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/armosec/kubecop/pkg/adminapi"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Port of the metrics server of the node agent, which serves the admin API
+const adminAPIPort = "9090"
+
+// Labels of the pods of the node agent and of the controller
+const kubecopPodSelector = "app.kubernetes.io/name=kubecop"
+
+// adminClient gets a path of the admin API of a node agent.
+type adminClient interface {
+	get(ctx context.Context, path string, params map[string]string) ([]byte, error)
+}
+
+// urlClient gets the admin API from a URL, like a port-forward of the node agent.
+type urlClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func (client *urlClient) get(ctx context.Context, path string, params map[string]string) ([]byte, error) {
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	requestURL := strings.TrimSuffix(client.baseURL, "/") + adminapi.PathPrefix + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// proxyClient gets the admin API of a node agent pod through the pod proxy of the API server.
+type proxyClient struct {
+	clientset kubernetes.Interface
+	namespace string
+	podName   string
+}
+
+func (client *proxyClient) get(ctx context.Context, path string, params map[string]string) ([]byte, error) {
+	return client.clientset.CoreV1().Pods(client.namespace).ProxyGet("http", client.podName, adminAPIPort, adminapi.PathPrefix+path, params).DoRaw(ctx)
+}
+
+// findNodeAgentPod returns the name of the node agent pod running on the node, the pods of the controller are
+// not owned by a DaemonSet.
+func findNodeAgentPod(ctx context.Context, clientset kubernetes.Interface, namespace, nodeName string) (string, error) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: kubecopPodSelector,
+		FieldSelector: "spec.nodeName=" + nodeName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list the KubeCop pods in namespace %s: %v", namespace, err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != nodeName {
+			continue
+		}
+		for _, owner := range pod.OwnerReferences {
+			if owner.Kind == "DaemonSet" {
+				return pod.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no KubeCop node agent found on node %s in namespace %s", nodeName, namespace)
+}
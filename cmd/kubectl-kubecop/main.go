@@ -0,0 +1,163 @@
+// kubectl-kubecop inspects the state of a KubeCop node agent through its read-only admin API.
+//
+// Installed in the PATH, it runs as a kubectl plugin: kubectl kubecop --node <node> containers. It reaches the
+// node agent of the node through the pod proxy of the API server, or directly with --url, like a port-forward.
+// The node agent serves the admin API when it runs with ADMIN_API=true.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine"
+	"github.com/armosec/kubecop/pkg/exporters"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const usage = `Usage: kubectl kubecop [flags] <command>
+
+Commands:
+  containers            list the containers followed by the node agent
+  container <id>        show a container, its application profile and its bound rules (the ID may be a prefix)
+  exporters             show the health of the exporters
+  alerts                show the recent alerts, newest first
+
+Flags:
+`
+
+type options struct {
+	node       string
+	namespace  string
+	url        string
+	kubeconfig string
+	context    string
+	output     string
+	limit      int
+	timeout    time.Duration
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	opts := options{}
+	flags := flag.NewFlagSet("kubectl-kubecop", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.node, "node", "", "node whose KubeCop node agent is inspected (mandatory without --url)")
+	flags.StringVar(&opts.namespace, "namespace", "kubescape", "namespace KubeCop is installed in")
+	flags.StringVar(&opts.url, "url", "", "URL of the metrics server of a node agent, like http://localhost:9090 with a port-forward")
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "path to the kubeconfig file, $KUBECONFIG or ~/.kube/config if not set")
+	flags.StringVar(&opts.context, "context", "", "kubeconfig context to use")
+	flags.StringVar(&opts.output, "o", "table", "output format: table or json")
+	flags.IntVar(&opts.limit, "limit", 0, "maximum number of alerts shown, the node agent default if not set")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout of the request")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	// the flags are accepted before and after the command and its arguments
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		return fmt.Errorf("missing command")
+	}
+	command, commandArgs := positional[0], positional[1:]
+	if opts.output != "table" && opts.output != "json" {
+		return fmt.Errorf("unknown output format %s, must be table or json", opts.output)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	client, err := newAdminClient(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "containers":
+		var containers []engine.ContainerState
+		return getAndPrint(ctx, client, "containers", nil, &containers, opts.output, stdout, func() error { return printContainers(stdout, containers) })
+	case "container":
+		if len(commandArgs) != 1 {
+			return fmt.Errorf("the container command needs a container ID")
+		}
+		var container engine.ContainerState
+		return getAndPrint(ctx, client, "containers/"+commandArgs[0], nil, &container, opts.output, stdout, func() error { return printContainer(stdout, container) })
+	case "exporters":
+		var exportersHealth []exporters.ExporterHealth
+		return getAndPrint(ctx, client, "exporters", nil, &exportersHealth, opts.output, stdout, func() error { return printExporters(stdout, exportersHealth) })
+	case "alerts":
+		var params map[string]string
+		if opts.limit > 0 {
+			params = map[string]string{"limit": strconv.Itoa(opts.limit)}
+		}
+		var alerts []exporters.RecentAlert
+		return getAndPrint(ctx, client, "alerts", params, &alerts, opts.output, stdout, func() error { return printAlerts(stdout, alerts) })
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown command %s", command)
+	}
+}
+
+func newAdminClient(ctx context.Context, opts options) (adminClient, error) {
+	if opts.url != "" {
+		return &urlClient{baseURL: opts.url, httpClient: http.DefaultClient}, nil
+	}
+	if opts.node == "" {
+		return nil, fmt.Errorf("--node or --url must be set")
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: opts.context}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the kubeconfig: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	podName, err := findNodeAgentPod(ctx, clientset, opts.namespace, opts.node)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyClient{clientset: clientset, namespace: opts.namespace, podName: podName}, nil
+}
+
+// getAndPrint gets the path and prints the raw JSON, or decodes it into value and prints it as a table.
+func getAndPrint(ctx context.Context, client adminClient, path string, params map[string]string, value interface{}, output string, stdout io.Writer, printTable func() error) error {
+	body, err := client.get(ctx, path, params)
+	if err != nil {
+		return fmt.Errorf("failed to get %s from the admin API: %v", path, err)
+	}
+	if output == "json" {
+		_, err := stdout.Write(body)
+		return err
+	}
+	if err := json.Unmarshal(body, value); err != nil {
+		return fmt.Errorf("failed to decode the response of the admin API: %v", err)
+	}
+	return printTable()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/adminapi"
+	"github.com/armosec/kubecop/pkg/approfilecache"
+	"github.com/armosec/kubecop/pkg/engine"
+	"github.com/armosec/kubecop/pkg/exporters"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type testEngineState struct{}
+
+var testContainer = engine.ContainerState{
+	ContainerID:              "0123456789abcdef",
+	ContainerName:            "server",
+	PodName:                  "web",
+	Namespace:                "default",
+	StartedAt:                time.Now().Add(-2 * time.Hour),
+	ApplicationProfileStatus: approfilecache.ApplicationProfileStatusLoaded,
+	ApplicationProfileName:   "deployment-web",
+	Rules: []engine.BoundRuleState{
+		{ID: "R1007", Name: "Crypto Miner detected", Priority: 8, Parameters: map[string]interface{}{"b": 2, "a": "x"}, Actions: []string{"killProcess"}},
+	},
+}
+
+func (testEngineState) ContainersState() []engine.ContainerState {
+	return []engine.ContainerState{testContainer}
+}
+
+func (testEngineState) ContainerState(containerID string) (engine.ContainerState, bool) {
+	return testContainer, strings.HasPrefix(testContainer.ContainerID, containerID)
+}
+
+type testExportersState struct{}
+
+func (testExportersState) Health() []exporters.ExporterHealth {
+	return []exporters.ExporterHealth{{Name: "stdout", QueueDepth: 1, QueueSize: 100, SentAlerts: 5, Healthy: true}}
+}
+
+func (testExportersState) RecentAlerts() []exporters.RecentAlert {
+	return []exporters.RecentAlert{
+		{Time: time.Now(), RuleName: "Crypto Miner detected", Priority: 8, PodName: "web", Message: "xmrig"},
+		{Time: time.Now(), MalwareName: "eicar", PodName: "web"},
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(adminapi.NewHandler(testEngineState{}, testExportersState{}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+		err      string
+	}{
+		{"containers", []string{"--url", server.URL, "containers"}, []string{"CONTAINER ID", "0123456789ab", "loaded", "2h"}, ""},
+		{"container with trailing flags", []string{"container", "0123", "--url", server.URL}, []string{"deployment-web", "R1007", "a=x,b=2", "killProcess"}, ""},
+		{"unknown container", []string{"--url", server.URL, "container", "fed"}, nil, "404 Not Found"},
+		{"exporters", []string{"--url", server.URL, "exporters"}, []string{"stdout", "true", "1/100"}, ""},
+		{"alerts", []string{"--url", server.URL, "alerts", "--limit", "1"}, []string{"Crypto Miner detected", "xmrig"}, ""},
+		{"json", []string{"--url", server.URL, "-o", "json", "exporters"}, []string{`"queueSize":100`}, ""},
+		{"missing node", []string{"containers"}, nil, "--node or --url must be set"},
+		{"unknown command", []string{"--url", server.URL, "rules"}, nil, "unknown command rules"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			err := run(test.args, stdout, stderr)
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			for _, expected := range test.expected {
+				assert.Contains(t, stdout.String(), expected)
+			}
+		})
+	}
+}
+
+func TestRunAlertsLimit(t *testing.T) {
+	server := httptest.NewServer(adminapi.NewHandler(testEngineState{}, testExportersState{}))
+	defer server.Close()
+	stdout := &bytes.Buffer{}
+	assert.NoError(t, run([]string{"--url", server.URL, "--limit", "1", "alerts"}, stdout, &bytes.Buffer{}))
+	assert.NotContains(t, stdout.String(), "eicar")
+}
+
+func TestFindNodeAgentPod(t *testing.T) {
+	labels := map[string]string{"app.kubernetes.io/name": "kubecop"}
+	clientset := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "kubecop-controller-0", Namespace: "kubescape", Labels: labels,
+			OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "kubecop-controller"}}}, Spec: corev1.PodSpec{NodeName: "node-1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "kubecop-abcde", Namespace: "kubescape", Labels: labels,
+			OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "kubecop"}}}, Spec: corev1.PodSpec{NodeName: "node-1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "kubecop-fghij", Namespace: "kubescape", Labels: labels,
+			OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "kubecop"}}}, Spec: corev1.PodSpec{NodeName: "node-2"}},
+	)
+
+	podName, err := findNodeAgentPod(context.Background(), clientset, "kubescape", "node-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "kubecop-abcde", podName)
+	_, err = findNodeAgentPod(context.Background(), clientset, "kubescape", "node-3")
+	assert.Error(t, err)
+}
+
+// The URL client reports the errors of the admin API
+func TestURLClientError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not enabled", http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := &urlClient{baseURL: server.URL + "/", httpClient: http.DefaultClient}
+	_, err := client.get(context.Background(), "containers", nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not enabled")
+	}
+}
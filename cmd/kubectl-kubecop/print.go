@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine"
+	"github.com/armosec/kubecop/pkg/exporters"
+)
+
+func printContainers(out io.Writer, containers []engine.ContainerState) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "NAMESPACE\tPOD\tCONTAINER\tCONTAINER ID\tPROFILE\tRULES\tAGE")
+	for _, container := range containers {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", container.Namespace, container.PodName, container.ContainerName,
+			shortID(container.ContainerID), container.ApplicationProfileStatus, len(container.Rules), age(container.StartedAt))
+	}
+	return writer.Flush()
+}
+
+func printContainer(out io.Writer, container engine.ContainerState) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(writer, "Container:\t%s\n", container.ContainerName)
+	fmt.Fprintf(writer, "Container ID:\t%s\n", container.ContainerID)
+	fmt.Fprintf(writer, "Pod:\t%s/%s\n", container.Namespace, container.PodName)
+	if container.OwnerKind != "" {
+		fmt.Fprintf(writer, "Owner:\t%s/%s\n", container.OwnerKind, container.OwnerName)
+	}
+	fmt.Fprintf(writer, "Image:\t%s\n", container.Image)
+	fmt.Fprintf(writer, "Started:\t%s (%s ago)\n", container.StartedAt.Format(time.RFC3339), age(container.StartedAt))
+	profile := string(container.ApplicationProfileStatus)
+	if container.ApplicationProfileName != "" {
+		profile += " (" + container.ApplicationProfileName + ")"
+	}
+	fmt.Fprintf(writer, "Application profile:\t%s\n", profile)
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Rules:")
+	writer = tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "  ID\tNAME\tPRIORITY\tPARAMETERS\tACTIONS")
+	for _, rule := range container.Rules {
+		fmt.Fprintf(writer, "  %s\t%s\t%d\t%s\t%s\n", rule.ID, rule.Name, rule.Priority, formatParameters(rule.Parameters), strings.Join(rule.Actions, ","))
+	}
+	return writer.Flush()
+}
+
+func printExporters(out io.Writer, exportersHealth []exporters.ExporterHealth) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "EXPORTER\tHEALTHY\tQUEUE\tSENT\tDROPPED\tFAILED\tLAST SENT\tLAST FAILURE")
+	for _, health := range exportersHealth {
+		lastFailure := "-"
+		if health.LastFailure != nil {
+			lastFailure = age(*health.LastFailure) + " ago (" + health.LastFailureReason + ")"
+		}
+		lastSent := "-"
+		if health.LastSent != nil {
+			lastSent = age(*health.LastSent) + " ago"
+		}
+		fmt.Fprintf(writer, "%s\t%t\t%d/%d\t%d\t%d\t%d\t%s\t%s\n", health.Name, health.Healthy, health.QueueDepth, health.QueueSize,
+			health.SentAlerts, health.DroppedAlerts, health.FailedAlerts, lastSent, lastFailure)
+	}
+	return writer.Flush()
+}
+
+func printAlerts(out io.Writer, alerts []exporters.RecentAlert) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, "AGE\tRULE\tPRIORITY\tNAMESPACE\tPOD\tCONTAINER\tMESSAGE")
+	for _, alert := range alerts {
+		name := alert.RuleName
+		if alert.MalwareName != "" {
+			name = "Malware: " + alert.MalwareName
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", age(alert.Time), name, alert.Priority, alert.Namespace, alert.PodName,
+			alert.ContainerName, alert.Message)
+	}
+	return writer.Flush()
+}
+
+// shortID returns the first 12 characters of the container ID, like docker and crictl.
+func shortID(containerID string) string {
+	if len(containerID) > 12 {
+		return containerID[:12]
+	}
+	return containerID
+}
+
+// age returns the time since t, rounded like kubectl get.
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	duration := time.Since(t)
+	switch {
+	case duration < time.Minute:
+		return fmt.Sprintf("%ds", int(duration.Seconds()))
+	case duration < time.Hour:
+		return fmt.Sprintf("%dm", int(duration.Minutes()))
+	case duration < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(duration.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(duration.Hours()/24))
+	}
+}
+
+func formatParameters(parameters map[string]interface{}) string {
+	if len(parameters) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(parameters))
+	for key := range parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	formatted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, fmt.Sprintf("%s=%v", key, parameters[key]))
+	}
+	return strings.Join(formatted, ",")
+}
@@ -14,10 +14,13 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 
+	"github.com/armosec/kubecop/pkg/adminapi"
+	"github.com/armosec/kubecop/pkg/alertexceptionstore"
 	"github.com/armosec/kubecop/pkg/approfilecache"
 	"github.com/armosec/kubecop/pkg/engine"
 	"github.com/armosec/kubecop/pkg/exporters"
 	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/armosec/kubecop/pkg/runtimerulestore"
 	scan "github.com/armosec/kubecop/pkg/scan/clamav"
 	"github.com/cilium/ebpf/rlimit"
 	"github.com/kubescape/kapprofiler/pkg/collector"
@@ -39,9 +42,21 @@ var dynamicClientGlobal dynamic.Interface
 var FinalizationDurationInSeconds int64 = 120
 var FinalizationJitterInSeconds int64 = 30
 var SamplingIntervalInSeconds int64 = 60
+var AlertSuppressionWindowInSeconds int64 = 0
+var ResponseActionsMode string = ResponseActionsDisabled
+var ResponseActionsPerMinute int = engine.DefaultResponseActionsPerMinute
+var AdminAPIEnabled bool = false
+var MetricsMaxWorkloads int = engine.DefaultMetricsMaxWorkloads
 var ClamAVRetryDelay time.Duration = 10 * time.Second
 var ClamAVMaxRetries int = 5
 
+// Modes of the response actions of the rule bindings
+const (
+	ResponseActionsDisabled = "disabled"
+	ResponseActionsDryRun   = "dryRun"
+	ResponseActionsEnabled  = "enabled"
+)
+
 func checkKubernetesConnection() (*rest.Config, error) {
 	// Check if the Kubernetes cluster is reachable
 	// Load the Kubernetes configuration from the default location
@@ -126,6 +141,52 @@ func serviceInitNChecks(modeEbpf bool) error {
 		}
 	}
 
+	// Get alert suppression window from environment variable
+	if alertSuppressionWindow := os.Getenv("ALERT_SUPPRESSION_WINDOW"); alertSuppressionWindow != "" {
+		if alertSuppressionWindowInt, err := parseTimeToSeconds(alertSuppressionWindow); err != nil {
+			return fmt.Errorf("ALERT_SUPPRESSION_WINDOW environment variable is not in format <number><unit> like 20s, 5m, 1h")
+		} else {
+			AlertSuppressionWindowInSeconds = int64(alertSuppressionWindowInt)
+		}
+	}
+
+	// Get the mode of the response actions from environment variable
+	if responseActionsMode := os.Getenv("RESPONSE_ACTIONS"); responseActionsMode != "" {
+		switch responseActionsMode {
+		case ResponseActionsDisabled, ResponseActionsDryRun, ResponseActionsEnabled:
+			ResponseActionsMode = responseActionsMode
+		default:
+			return fmt.Errorf("RESPONSE_ACTIONS environment variable must be one of %s, %s or %s", ResponseActionsDisabled, ResponseActionsDryRun, ResponseActionsEnabled)
+		}
+	}
+
+	// Get the rate limit of the response actions from environment variable
+	if responseActionsPerMinute := os.Getenv("RESPONSE_ACTIONS_PER_MINUTE"); responseActionsPerMinute != "" {
+		if responseActionsPerMinuteInt, err := strconv.Atoi(responseActionsPerMinute); err != nil || responseActionsPerMinuteInt <= 0 {
+			return fmt.Errorf("RESPONSE_ACTIONS_PER_MINUTE environment variable must be a positive number")
+		} else {
+			ResponseActionsPerMinute = responseActionsPerMinuteInt
+		}
+	}
+
+	// Get the maximum number of workloads in the labels of the alert metrics from environment variable
+	if metricsMaxWorkloads := os.Getenv("METRICS_MAX_WORKLOADS"); metricsMaxWorkloads != "" {
+		if metricsMaxWorkloadsInt, err := strconv.Atoi(metricsMaxWorkloads); err != nil || metricsMaxWorkloadsInt < 0 {
+			return fmt.Errorf("METRICS_MAX_WORKLOADS environment variable must be a number greater than or equal to 0")
+		} else {
+			MetricsMaxWorkloads = metricsMaxWorkloadsInt
+		}
+	}
+
+	// Get whether to serve the admin API from environment variable
+	if adminAPI := os.Getenv("ADMIN_API"); adminAPI != "" {
+		if adminAPIEnabled, err := strconv.ParseBool(adminAPI); err != nil {
+			return fmt.Errorf("ADMIN_API environment variable must be true or false")
+		} else {
+			AdminAPIEnabled = adminAPIEnabled
+		}
+	}
+
 	return nil
 }
 
@@ -187,8 +248,28 @@ func main() {
 	}
 
 	if nodeAgentMode {
-		// TODO: support exporters config from file/crd
-		exporterBus := exporters.InitExporters(exporters.ExportersConfig{})
+		// Load the exporters config from file if given, otherwise the exporters are configured from environment variables
+		exportersConfig := exporters.ExportersConfig{}
+		exportersConfigPath := os.Getenv("EXPORTERS_CONFIG_PATH")
+		if exportersConfigPath != "" {
+			config, err := exporters.LoadExportersConfig(exportersConfigPath)
+			if err != nil {
+				log.Fatalf("Failed to load exporters config: %v\n", err)
+			}
+			exportersConfig = config
+		}
+		// Create Kubernetes clientset from the configuration
+		clientset, err := kubernetes.NewForConfig(k8sConfig)
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v\n", err)
+		}
+		exporterBus := exporters.InitExporters(exportersConfig, clientset, dynamicClientGlobal)
+		if exportersConfigPath != "" {
+			// Re-apply the exporters config when the file changes
+			exportersConfigWatcherStop := make(chan struct{})
+			defer close(exportersConfigWatcherStop)
+			go exporterBus.WatchExportersConfigFile(exportersConfigPath, exporters.DefaultExportersConfigReloadInterval, exportersConfigWatcherStop)
+		}
 		// Create tracer (without sink for now)
 		tracer := tracing.NewTracer(NodeName, k8sConfig, []tracing.EventSink{}, false)
 		// Create application profile cache
@@ -234,11 +315,6 @@ func main() {
 		//////////////////////////////////////////////////////////////////////////////
 		// Recording subsystem is ready, start the rule engine
 
-		// Create Kubernetes clientset from the configuration
-		clientset, err := kubernetes.NewForConfig(k8sConfig)
-		if err != nil {
-			log.Fatalf("Failed to create Kubernetes client: %v\n", err)
-		}
 		dynamicClient, err := dynamic.NewForConfig(k8sConfig)
 		if err != nil {
 			log.Fatalf("Failed to create Kubernetes dynamic client: %v\n", err)
@@ -246,6 +322,11 @@ func main() {
 
 		// Create the "Rule Engine" and start it
 		engine := engine.NewEngine(clientset, appProfileCache, tracer, &exporterBus, 4, NodeName)
+		engine.SetAlertSuppressionWindow(time.Duration(AlertSuppressionWindowInSeconds) * time.Second)
+		engine.SetMetricsMaxWorkloads(MetricsMaxWorkloads)
+		if ResponseActionsMode != ResponseActionsDisabled {
+			engine.SetResponseActions(ResponseActionsMode == ResponseActionsDryRun, ResponseActionsPerMinute)
+		}
 
 		// Create the rule binding store and start it
 		ruleBindingStore, err := rulebindingstore.NewRuleBindingK8sStore(dynamicClient, clientset.CoreV1(), NodeName, storeNamespace)
@@ -254,9 +335,31 @@ func main() {
 		}
 		defer ruleBindingStore.Destroy()
 		// set mutual callbacks between engine and rulebindingstore
-		engine.SetGetRulesForPodFunc(ruleBindingStore.GetRulesForPod)
+		engine.SetGetRulesForContainerFunc(ruleBindingStore.GetRulesForContainer)
 		ruleBindingStore.SetRuleBindingChangedHandlers([]rulebindingstore.RuleBindingChangedHandler{engine.OnRuleBindingChanged})
 
+		// Create the runtime rule store, it registers the custom rules in the rule factory
+		runtimeRuleStore, err := runtimerulestore.NewRuntimeRuleK8sStore(dynamicClient, storeNamespace)
+		if err != nil {
+			log.Fatalf("Failed to create runtime rule store: %v\n", err)
+		}
+		defer runtimeRuleStore.Destroy()
+		runtimeRuleStore.SetRuntimeRuleChangedHandlers([]runtimerulestore.RuntimeRuleChangedHandler{engine.OnRuntimeRuleChanged})
+
+		// Create the alert exception store, the engine does not send the alerts it excepts
+		alertExceptionStore, err := alertexceptionstore.NewAlertExceptionK8sStore(dynamicClient, ruleBindingStore, storeNamespace)
+		if err != nil {
+			log.Fatalf("Failed to create alert exception store: %v\n", err)
+		}
+		defer alertExceptionStore.Destroy()
+		engine.SetAlertExceptionFunc(alertExceptionStore.IsExcepted)
+
+		// Serve the read-only admin API on the metrics server
+		if AdminAPIEnabled {
+			http.Handle(adminapi.PathPrefix, adminapi.NewHandler(engine, &exporterBus))
+			log.Printf("Admin API enabled at %s\n", adminapi.PathPrefix)
+		}
+
 		// Add the engine to the tracer
 		tracer.AddContainerActivityListener(engine)
 		defer tracer.RemoveContainerActivityListener(engine)
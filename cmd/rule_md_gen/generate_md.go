@@ -3,11 +3,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/armosec/kubecop/pkg/runtimerulestore"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	// "k8s.io/apimachinery/pkg/util/yaml"
@@ -17,30 +21,73 @@ import (
 // Main
 func main() {
 	// Print out a markdown table containing all the rules
-	fmt.Printf("| ID | Rule | Description | Tags | Priority | Application profile |\n")
-	fmt.Printf("|----|------|-------------|------|----------|---------------------|\n")
+	fmt.Printf("| ID | Rule | Description | Tags | MITRE ATT&CK | Priority | Application profile |\n")
+	fmt.Printf("|----|------|-------------|------|--------------|----------|---------------------|\n")
 	idsList := []string{}
 	namesList := []string{}
 	tagsList := []string{}
 	tagsMap := make(map[string]struct{})
+	tacticsMap := make(map[string]struct{})
+	techniquesMap := make(map[string]struct{})
 
-	for _, rule := range rule.GetAllRuleDescriptors() {
-		fmt.Printf("| %s | %s | %s | %s | %d | %v |\n", rule.ID, rule.Name, rule.Description, rule.Tags, rule.Priority, rule.Requirements.NeedApplicationProfile)
+	for _, rule := range rule.GetBuiltinRuleDescriptors() {
+		mitre := strings.Join(append(slices.Clone(rule.Mitre.Tactics), rule.Mitre.Techniques...), " ")
+		fmt.Printf("| %s | %s | %s | %s | %s | %d | %v |\n", rule.ID, rule.Name, rule.Description, rule.Tags, mitre, rule.Priority, rule.Requirements.NeedApplicationProfile)
 		idsList = append(idsList, rule.ID)
 		namesList = append(namesList, rule.Name)
 		for _, tag := range rule.Tags {
 			tagsMap[tag] = struct{}{}
 		}
+		for _, tactic := range rule.Mitre.Tactics {
+			tacticsMap[tactic] = struct{}{}
+		}
+		for _, technique := range rule.Mitre.Techniques {
+			techniquesMap[technique] = struct{}{}
+		}
 	}
 	for tag := range tagsMap {
 		tagsList = append(tagsList, tag)
 	}
 	slices.Sort(tagsList)
-	fillListsInCRD(idsList, namesList, tagsList, "chart/kubecop/charts/namespaced-crds/crds/runtime-rule-binding.crd.yaml")
-	fillListsInCRD(idsList, namesList, tagsList, "chart/kubecop/charts/clustered-crds/crds/runtime-rule-binding.crd.yaml")
+	tacticsList := sortedKeys(tacticsMap)
+	techniquesList := sortedKeys(techniquesMap)
+	fillListsInCRD(idsList, namesList, tagsList, tacticsList, techniquesList, "chart/kubecop/charts/namespaced-crds/crds/runtime-rule-binding.crd.yaml")
+	fillListsInCRD(idsList, namesList, tagsList, tacticsList, techniquesList, "chart/kubecop/charts/clustered-crds/crds/runtime-rule-binding.crd.yaml")
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// stringArraySchema returns the schema of an array of strings, documented with the builtin values.
+func stringArraySchema(description string) apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{
+		Type:        "array",
+		Description: description,
+		Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+			Schema: &apiextensionsv1.JSONSchemaProps{Type: "string"},
+		},
+	}
+}
+
+// containerGlobsSchema returns the schema of the include and exclude glob lists selecting containers.
+func containerGlobsSchema(description string) apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{
+		Type:        "object",
+		Description: description + ", with glob patterns where * matches any sequence of characters and ? any character",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"include": stringArraySchema("The container is selected if it matches one of the patterns, any container if empty"),
+			"exclude": stringArraySchema("The container is not selected if it matches one of the patterns"),
+		},
+	}
 }
 
-func fillListsInCRD(idsList []string, namesList []string, tagsList []string, crdFilePath string) {
+func fillListsInCRD(idsList []string, namesList []string, tagsList []string, tacticsList []string, techniquesList []string, crdFilePath string) {
 	gitRoot := "../../"
 	pwd, err := os.Getwd()
 	if err == nil {
@@ -59,13 +106,10 @@ func fillListsInCRD(idsList []string, namesList []string, tagsList []string, crd
 
 	// read CRD YAML file into struct
 	crDef := apiextensionsv1.CustomResourceDefinition{}
-	originalFileContent := make([]byte, 4096)
-	if nBytes, err := crdFile.Read(originalFileContent); err != nil {
+	originalFileContent, err := io.ReadAll(crdFile)
+	if err != nil {
 		fmt.Printf("Error reading CRD file: %v\n", err)
 		return
-	} else {
-		// trim to actual length to avoid unmarshalling errors like: error converting YAML to JSON: yaml: control characters are not allowed
-		originalFileContent = originalFileContent[:nBytes]
 	}
 
 	if err := yaml.Unmarshal(originalFileContent, &crDef); err != nil {
@@ -74,21 +118,25 @@ func fillListsInCRD(idsList []string, namesList []string, tagsList []string, crd
 	}
 	spec := crDef.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
 	specRules := spec.Properties["rules"]
-	// handle ruleIDs
-	specRuleIDs := specRules.Items.Schema.Properties["ruleID"]
-	enumVals := []apiextensionsv1.JSON{}
+	// custom rules are registered at runtime from RuntimeRule objects, so the builtin names and tags are documented
+	// instead of being enforced with an enum
+	// handle ruleIDs, the IDs of the custom rules have their own pattern
+	idsEnum := []apiextensionsv1.JSON{}
 	for _, id := range idsList {
-		enumVals = append(enumVals, apiextensionsv1.JSON{Raw: []byte("\"" + id + "\"")})
+		idsEnum = append(idsEnum, apiextensionsv1.JSON{Raw: []byte("\"" + id + "\"")})
+	}
+	specRules.Items.Schema.Properties["ruleID"] = apiextensionsv1.JSONSchemaProps{
+		Type:        "string",
+		Description: "ID of a builtin rule or of a RuntimeRule (" + runtimerulestore.RuntimeRuleIDPattern + ")",
+		AnyOf: []apiextensionsv1.JSONSchemaProps{
+			{Enum: idsEnum},
+			{Pattern: runtimerulestore.RuntimeRuleIDPattern},
+		},
 	}
-	specRuleIDs.Enum = enumVals
-	specRules.Items.Schema.Properties["ruleID"] = specRuleIDs
 	// handle ruleNames
 	specRuleNames := specRules.Items.Schema.Properties["ruleName"]
-	enumVals = []apiextensionsv1.JSON{}
-	for _, name := range namesList {
-		enumVals = append(enumVals, apiextensionsv1.JSON{Raw: []byte("\"" + name + "\"")})
-	}
-	specRuleNames.Enum = enumVals
+	specRuleNames.Enum = nil
+	specRuleNames.Description = "Name of a builtin rule (" + strings.Join(namesList, ", ") + ") or of a RuntimeRule"
 	specRules.Items.Schema.Properties["ruleName"] = specRuleNames
 	// handle ruleTags
 	specRuleTags := specRules.Items.Schema.Properties["ruleTags"]
@@ -100,18 +148,46 @@ func fillListsInCRD(idsList []string, namesList []string, tagsList []string, crd
 		specRuleTags.Items.Schema = &apiextensionsv1.JSONSchemaProps{}
 		specRuleTags.Items.Schema.Type = "string"
 	}
-
-	enumVals = []apiextensionsv1.JSON{}
-	for _, tag := range tagsList {
-		enumVals = append(enumVals, apiextensionsv1.JSON{Raw: []byte("\"" + tag + "\"")})
-	}
-	specRuleTags.Items.Schema.Enum = enumVals
+	specRuleTags.Items.Schema.Enum = nil
+	specRuleTags.Description = "Tags of builtin rules (" + strings.Join(tagsList, ", ") + ") or of RuntimeRules"
 	specRules.Items.Schema.Properties["ruleTags"] = specRuleTags
+	// handle MITRE ATT&CK, a technique also selects its sub-techniques
+	specRules.Items.Schema.Properties["mitreTactics"] = stringArraySchema("MITRE ATT&CK tactic IDs of builtin rules (" + strings.Join(tacticsList, ", ") + ") or of RuntimeRules")
+	specRules.Items.Schema.Properties["mitreTechniques"] = stringArraySchema("MITRE ATT&CK technique IDs of builtin rules (" + strings.Join(techniquesList, ", ") + ") or of RuntimeRules, a technique also selects its sub-techniques")
 	specRules.Items.Schema.Properties["severity"] = apiextensionsv1.JSONSchemaProps{Type: "string"}
+	actionsEnum := []apiextensionsv1.JSON{}
+	for _, action := range rulebindingstore.ResponseActions {
+		actionsEnum = append(actionsEnum, apiextensionsv1.JSON{Raw: []byte("\"" + action + "\"")})
+	}
+	specRules.Items.Schema.Properties["actions"] = apiextensionsv1.JSONSchemaProps{
+		Type:        "array",
+		Description: "Response actions taken on every alert of the rule, an empty list disables the actions of the bindings with a lower priority",
+		Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+			Schema: &apiextensionsv1.JSONSchemaProps{Type: "string", Enum: actionsEnum},
+		},
+	}
 	specRules.Items.Schema.Type = "object"
 	specRules.Type = "array"
 	// write back
 	spec.Properties["rules"] = specRules
+	spec.Properties["priority"] = apiextensionsv1.JSONSchemaProps{Type: "integer"}
+	spec.Properties["missingApplicationProfile"] = apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"policy": {
+				Type: "string",
+				Enum: []apiextensionsv1.JSON{
+					{Raw: []byte("\"" + rulebindingstore.MissingApplicationProfilePolicyIgnore + "\"")},
+					{Raw: []byte("\"" + rulebindingstore.MissingApplicationProfilePolicyAlertOnce + "\"")},
+					{Raw: []byte("\"" + rulebindingstore.MissingApplicationProfilePolicyAlertAfter + "\"")},
+				},
+			},
+			"after": {Type: "string"},
+		},
+		Required: []string{"policy"},
+	}
+	spec.Properties["containerNames"] = containerGlobsSchema("Containers of the selected pods the rules apply to, by container name")
+	spec.Properties["containerImages"] = containerGlobsSchema("Containers of the selected pods the rules apply to, by container image")
 	spec.Type = "object"
 	crDef.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"] = spec
 	crDef.Spec.Versions[0].Schema.OpenAPIV3Schema.Type = "object"
@@ -120,6 +196,10 @@ func fillListsInCRD(idsList []string, namesList []string, tagsList []string, crd
 
 	// write CRD YAML file
 	crdFile.Seek(0, 0)
+	if err := crdFile.Truncate(0); err != nil {
+		fmt.Printf("Error truncating CRD file: %v\n", err)
+		return
+	}
 	var jbytes []byte
 	if jbytes, err = json.Marshal(crDef); err != nil {
 		fmt.Printf("Error encoding CRD file to JSON: %v\n", err)
@@ -9,11 +9,17 @@ require (
 	github.com/dutchcoders/go-clamd v0.0.0-20170520113014-b970184f4d9e
 	github.com/gammazero/workerpool v1.1.3
 	github.com/go-openapi/strfmt v0.21.7
+	github.com/google/cel-go v0.17.7
 	github.com/inspektor-gadget/inspektor-gadget v0.26.0
 	github.com/kubescape/kapprofiler v0.0.59
 	github.com/prometheus/alertmanager v0.26.0
 	github.com/prometheus/client_golang v1.19.0
 	github.com/stretchr/testify v1.9.0
+	github.com/twmb/franz-go v1.16.1
+	github.com/twmb/franz-go/pkg/kfake v0.0.0-20240207010543-c5207aab16d0
+	go.opentelemetry.io/proto/otlp v1.0.0
+	google.golang.org/grpc v1.62.0
+	google.golang.org/protobuf v1.32.0
 	k8s.io/api v0.29.2
 	k8s.io/apiextensions-apiserver v0.29.2
 	k8s.io/apimachinery v0.29.2
@@ -22,11 +28,18 @@ require (
 )
 
 require (
+	github.com/antlr/antlr4/runtime/Go/antlr/v4 v4.0.0-20230305170008-8188dc5388df // indirect
 	github.com/felixge/httpsnoop v1.0.3 // indirect
 	github.com/google/gopacket v1.1.19 // indirect
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.16.0 // indirect
 	github.com/moby/sys/user v0.1.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.19 // indirect
+	github.com/stoewer/go-strcase v1.2.0 // indirect
+	github.com/twmb/franz-go/pkg/kmsg v1.7.0 // indirect
 	github.com/vishvananda/netlink v1.2.1-beta.2 // indirect
 	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.45.0 // indirect
+	golang.org/x/crypto v0.19.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20240123012728-ef4313101c80 // indirect
 )
 
 require (
@@ -127,8 +140,6 @@ require (
 	google.golang.org/appengine v1.6.8 // indirect
 	google.golang.org/genproto v0.0.0-20240123012728-ef4313101c80 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80 // indirect
-	google.golang.org/grpc v1.62.0 // indirect
-	google.golang.org/protobuf v1.32.0 // indirect
 	gopkg.in/inf.v0 v0.9.1 // indirect
 	gopkg.in/mcuadros/go-syslog.v2 v2.3.0
 	gopkg.in/yaml.v2 v2.4.0 // indirect
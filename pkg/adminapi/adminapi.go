@@ -0,0 +1,97 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine"
+	"github.com/armosec/kubecop/pkg/exporters"
+)
+
+// Path prefix of the admin API on the metrics server
+const PathPrefix = "/admin/v1/"
+
+// Default number of recent alerts returned
+const DefaultAlertsLimit = 20
+
+// EngineState gives the state of the containers followed by the engine.
+type EngineState interface {
+	ContainersState() []engine.ContainerState
+	ContainerState(containerID string) (engine.ContainerState, bool)
+}
+
+// ExportersState gives the health of the exporters and the recent alerts.
+type ExportersState interface {
+	Health() []exporters.ExporterHealth
+	RecentAlerts() []exporters.RecentAlert
+}
+
+// Handler serves the read-only admin API:
+//   - GET /admin/v1/containers - the containers with their bound rules and profile status
+//   - GET /admin/v1/containers/<container ID or unique prefix> - a single container
+//   - GET /admin/v1/exporters - the health of the exporters
+//   - GET /admin/v1/alerts?limit=<n> - the recent alerts, newest first
+type Handler struct {
+	engineState    EngineState
+	exportersState ExportersState
+}
+
+func NewHandler(engineState EngineState, exportersState ExportersState) *Handler {
+	return &Handler{engineState: engineState, exportersState: exportersState}
+}
+
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "the admin API is read-only")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, PathPrefix), "/")
+	switch {
+	case path == "containers":
+		writeJSON(w, http.StatusOK, handler.engineState.ContainersState())
+	case strings.HasPrefix(path, "containers/"):
+		containerID := strings.TrimPrefix(path, "containers/")
+		container, ok := handler.engineState.ContainerState(containerID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "container "+containerID+" not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, container)
+	case path == "exporters":
+		writeJSON(w, http.StatusOK, handler.exportersState.Health())
+	case path == "alerts":
+		limit := DefaultAlertsLimit
+		if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+			var err error
+			if limit, err = strconv.Atoi(limitParam); err != nil || limit <= 0 {
+				writeError(w, http.StatusBadRequest, "limit must be a positive number")
+				return
+			}
+		}
+		alerts := handler.exportersState.RecentAlerts()
+		if len(alerts) > limit {
+			alerts = alerts[:limit]
+		}
+		writeJSON(w, http.StatusOK, alerts)
+	default:
+		writeError(w, http.StatusNotFound, "unknown path "+r.URL.Path)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Errorf("Failed to write admin API response: %v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
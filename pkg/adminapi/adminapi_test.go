@@ -0,0 +1,84 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armosec/kubecop/pkg/engine"
+	"github.com/armosec/kubecop/pkg/exporters"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockEngineState struct {
+	containers []engine.ContainerState
+}
+
+func (m *mockEngineState) ContainersState() []engine.ContainerState {
+	return m.containers
+}
+
+func (m *mockEngineState) ContainerState(containerID string) (engine.ContainerState, bool) {
+	for _, container := range m.containers {
+		if container.ContainerID == containerID {
+			return container, true
+		}
+	}
+	return engine.ContainerState{}, false
+}
+
+type mockExportersState struct {
+	alerts []exporters.RecentAlert
+}
+
+func (m *mockExportersState) Health() []exporters.ExporterHealth {
+	return []exporters.ExporterHealth{{Name: "alertmanager", QueueSize: 100, Healthy: true}}
+}
+
+func (m *mockExportersState) RecentAlerts() []exporters.RecentAlert {
+	return m.alerts
+}
+
+func TestHandler(t *testing.T) {
+	handler := NewHandler(
+		&mockEngineState{containers: []engine.ContainerState{{ContainerID: "abc", PodName: "web", Namespace: "default"}}},
+		&mockExportersState{alerts: []exporters.RecentAlert{{RuleName: "R1"}, {RuleName: "R2"}, {RuleName: "R3"}}},
+	)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		status   int
+		expected interface{}
+		decoded  interface{}
+	}{
+		{"containers", http.MethodGet, "/admin/v1/containers", http.StatusOK,
+			&[]engine.ContainerState{{ContainerID: "abc", PodName: "web", Namespace: "default"}}, &[]engine.ContainerState{}},
+		{"container", http.MethodGet, "/admin/v1/containers/abc", http.StatusOK,
+			&engine.ContainerState{ContainerID: "abc", PodName: "web", Namespace: "default"}, &engine.ContainerState{}},
+		{"unknown container", http.MethodGet, "/admin/v1/containers/def", http.StatusNotFound,
+			&map[string]string{"error": "container def not found"}, &map[string]string{}},
+		{"exporters", http.MethodGet, "/admin/v1/exporters", http.StatusOK,
+			&[]exporters.ExporterHealth{{Name: "alertmanager", QueueSize: 100, Healthy: true}}, &[]exporters.ExporterHealth{}},
+		{"alerts with limit", http.MethodGet, "/admin/v1/alerts?limit=2", http.StatusOK,
+			&[]exporters.RecentAlert{{RuleName: "R1"}, {RuleName: "R2"}}, &[]exporters.RecentAlert{}},
+		{"invalid limit", http.MethodGet, "/admin/v1/alerts?limit=0", http.StatusBadRequest,
+			&map[string]string{"error": "limit must be a positive number"}, &map[string]string{}},
+		{"read-only", http.MethodPost, "/admin/v1/containers", http.StatusMethodNotAllowed,
+			&map[string]string{"error": "the admin API is read-only"}, &map[string]string{}},
+		{"unknown path", http.MethodGet, "/admin/v1/rules", http.StatusNotFound,
+			&map[string]string{"error": "unknown path /admin/v1/rules"}, &map[string]string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+			assert.Equal(t, test.status, recorder.Code)
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), test.decoded))
+			assert.Equal(t, test.expected, test.decoded)
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package alertexceptionstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/kubescape/kapprofiler/pkg/collector"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const RuntimeAlertExceptionPlural = "runtimealertexceptions"
+
+var RuntimeAlertExceptionGvr schema.GroupVersionResource = schema.GroupVersionResource{
+	Group:    collector.ApplicationProfileGroup,
+	Version:  collector.ApplicationProfileVersion,
+	Resource: RuntimeAlertExceptionPlural,
+}
+
+type dynClient interface {
+	Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface
+}
+
+// PodLabelsGetter returns the labels of a pod of the node and the labels of its namespace.
+type PodLabelsGetter interface {
+	GetPodLabels(podName, namespace string) (labels.Set, labels.Set, error)
+}
+
+// alertException is a RuntimeAlertException object with its selectors parsed.
+type alertException struct {
+	name              string
+	spec              RuntimeAlertExceptionSpec
+	namespaceSelector labels.Selector
+	podSelector       labels.Selector
+}
+
+// alertEvent holds the attributes of the event of an alert the exceptions match on.
+type alertEvent struct {
+	general    *tracing.GeneralEvent
+	execPath   string
+	filePath   string
+	domain     string
+	capability string
+}
+
+// AlertExceptionK8sStore watches the RuntimeAlertException objects and tells which alerts they except.
+type AlertExceptionK8sStore struct {
+	dynamicClient       dynClient
+	podLabelsGetter     PodLabelsGetter
+	informerStopChannel chan struct{}
+	storeNamespace      string
+	// Exceptions by namespace/name of their object
+	exceptions     map[string]*alertException
+	exceptionsLock sync.RWMutex
+}
+
+func NewAlertExceptionK8sStore(dynamicClient dynClient, podLabelsGetter PodLabelsGetter, storeNamespace string) (*AlertExceptionK8sStore, error) {
+	if storeNamespace == "" {
+		storeNamespace = metav1.NamespaceNone
+	}
+
+	alertExceptionStore := AlertExceptionK8sStore{
+		dynamicClient:       dynamicClient,
+		podLabelsGetter:     podLabelsGetter,
+		informerStopChannel: make(chan struct{}),
+		storeNamespace:      storeNamespace,
+		exceptions:          make(map[string]*alertException),
+	}
+	alertExceptionStore.StartController()
+	return &alertExceptionStore, nil
+}
+
+func (store *AlertExceptionK8sStore) Destroy() {
+	close(store.informerStopChannel)
+}
+
+func (store *AlertExceptionK8sStore) StartController() {
+	informer := dynamicinformer.NewFilteredDynamicSharedInformerFactory(store.dynamicClient, 0, store.storeNamespace, nil).ForResource(RuntimeAlertExceptionGvr).Informer()
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    store.alertExceptionAddedHandler,
+		UpdateFunc: store.alertExceptionUpdatedHandler,
+		DeleteFunc: store.alertExceptionDeletedHandler,
+	})
+
+	go informer.Run(store.informerStopChannel)
+}
+
+func (store *AlertExceptionK8sStore) alertExceptionAddedHandler(obj interface{}) {
+	exceptionObj, err := getAlertExceptionFromObj(obj)
+	if err != nil {
+		log.Errorf("Error getting alert exception from obj: %v\n", err)
+		return
+	}
+	exception, err := newAlertException(exceptionObj)
+	if err != nil {
+		// an invalid update removes the previous version of the exception, it must not except more than intended
+		store.deleteAlertException(alertExceptionName(exceptionObj))
+		log.Errorf("Ignoring alert exception %s: %v\n", alertExceptionName(exceptionObj), err)
+		return
+	}
+	store.exceptionsLock.Lock()
+	store.exceptions[exception.name] = exception
+	store.exceptionsLock.Unlock()
+	log.Infof("Alert exception %s added\n", exception.name)
+}
+
+func (store *AlertExceptionK8sStore) alertExceptionUpdatedHandler(oldObj, newObj interface{}) {
+	store.alertExceptionAddedHandler(newObj)
+}
+
+func (store *AlertExceptionK8sStore) alertExceptionDeletedHandler(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	exceptionObj, err := getAlertExceptionFromObj(obj)
+	if err != nil {
+		log.Errorf("Error getting alert exception from obj: %v\n", err)
+		return
+	}
+	store.deleteAlertException(alertExceptionName(exceptionObj))
+	log.Infof("Alert exception %s deleted\n", alertExceptionName(exceptionObj))
+}
+
+func (store *AlertExceptionK8sStore) deleteAlertException(name string) {
+	store.exceptionsLock.Lock()
+	defer store.exceptionsLock.Unlock()
+	delete(store.exceptions, name)
+}
+
+// IsExcepted returns the name of the first exception, by name, which excepts the alert of the rule on the event,
+// and false if no exception excepts it.
+func (store *AlertExceptionK8sStore) IsExcepted(ruleName string, eventType tracing.EventType, event interface{}) (string, bool) {
+	store.exceptionsLock.RLock()
+	exceptions := make([]*alertException, 0, len(store.exceptions))
+	for _, exception := range store.exceptions {
+		exceptions = append(exceptions, exception)
+	}
+	store.exceptionsLock.RUnlock()
+	if len(exceptions) == 0 {
+		return "", false
+	}
+	sort.Slice(exceptions, func(i, j int) bool { return exceptions[i].name < exceptions[j].name })
+
+	alert, err := getAlertEvent(eventType, event)
+	if err != nil {
+		log.Errorf("Failed to match the alert exceptions: %v\n", err)
+		return "", false
+	}
+	// the ID of the rule and the labels are looked up once, when an exception needs them
+	ruleID, ruleIDFetched := "", false
+	var podLabels, nsLabels labels.Set
+	labelsFetched := false
+	now := time.Now()
+	for _, exception := range exceptions {
+		if exception.spec.ExpiresAt != nil && !now.Before(exception.spec.ExpiresAt.Time) {
+			continue
+		}
+		if len(exception.spec.RuleIDs) > 0 && !ruleIDFetched {
+			ruleID = rule.GetRuleIDByName(ruleName)
+			ruleIDFetched = true
+		}
+		if !exception.matchesRule(ruleID, ruleName) || !exception.matchesEvent(alert) {
+			continue
+		}
+		if !exception.namespaceSelector.Empty() || !exception.podSelector.Empty() {
+			if !labelsFetched {
+				if podLabels, nsLabels, err = store.podLabelsGetter.GetPodLabels(alert.general.PodName, alert.general.Namespace); err != nil {
+					log.Errorf("Failed to match the alert exceptions: %v\n", err)
+					return "", false
+				}
+				labelsFetched = true
+			}
+			if !exception.matchesLabels(podLabels, nsLabels) {
+				continue
+			}
+		}
+		return exception.name, true
+	}
+	return "", false
+}
+
+func newAlertException(exceptionObj *RuntimeAlertException) (*alertException, error) {
+	namespaceSelector, err := metav1.LabelSelectorAsSelector(&exceptionObj.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse namespace selector: %v", err)
+	}
+	podSelector, err := metav1.LabelSelectorAsSelector(&exceptionObj.Spec.PodSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pod selector: %v", err)
+	}
+	for _, pattern := range exceptionObj.Spec.ContainerNames {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid container name pattern %q: %v", pattern, err)
+		}
+	}
+	return &alertException{
+		name:              alertExceptionName(exceptionObj),
+		spec:              exceptionObj.Spec,
+		namespaceSelector: namespaceSelector,
+		podSelector:       podSelector,
+	}, nil
+}
+
+func (exception *alertException) matchesRule(ruleID, ruleName string) bool {
+	if len(exception.spec.RuleIDs) == 0 && len(exception.spec.RuleNames) == 0 {
+		return true
+	}
+	return contains(exception.spec.RuleNames, ruleName) || (ruleID != "" && contains(exception.spec.RuleIDs, ruleID))
+}
+
+func (exception *alertException) matchesEvent(alert *alertEvent) bool {
+	if len(exception.spec.ContainerNames) > 0 && !matchesAny(exception.spec.ContainerNames, alert.general.ContainerName, func(pattern, name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}) {
+		return false
+	}
+	match := exception.spec.Match
+	if len(match.ExecPaths) > 0 && (alert.execPath == "" || !contains(match.ExecPaths, alert.execPath)) {
+		return false
+	}
+	if len(match.FilePathPrefixes) > 0 && (alert.filePath == "" || !matchesAny(match.FilePathPrefixes, alert.filePath, func(prefix, filePath string) bool {
+		return strings.HasPrefix(filePath, prefix)
+	})) {
+		return false
+	}
+	if len(match.Domains) > 0 && (alert.domain == "" || !matchesAny(match.Domains, alert.domain, domainMatch)) {
+		return false
+	}
+	if len(match.Capabilities) > 0 && (alert.capability == "" || !matchesAny(match.Capabilities, alert.capability, capabilityMatch)) {
+		return false
+	}
+	if len(match.Comms) > 0 && !contains(match.Comms, alert.general.Comm) {
+		return false
+	}
+	return true
+}
+
+func (exception *alertException) matchesLabels(podLabels, nsLabels labels.Set) bool {
+	// the non empty selectors do not select a pod or a namespace which is gone
+	if !exception.namespaceSelector.Empty() && (nsLabels == nil || !exception.namespaceSelector.Matches(nsLabels)) {
+		return false
+	}
+	if !exception.podSelector.Empty() && (podLabels == nil || !exception.podSelector.Matches(podLabels)) {
+		return false
+	}
+	return true
+}
+
+// domainMatch matches the domain names without their trailing dot, a pattern starting with *. also matches the
+// subdomains of the rest of the pattern.
+func domainMatch(pattern, domain string) bool {
+	pattern = strings.TrimSuffix(pattern, ".")
+	domain = strings.TrimSuffix(domain, ".")
+	if parent, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.EqualFold(domain, parent) || strings.HasSuffix(strings.ToLower(domain), "."+strings.ToLower(parent))
+	}
+	return strings.EqualFold(domain, pattern)
+}
+
+// capabilityMatch matches the capability names ignoring their case and their CAP_ prefix.
+func capabilityMatch(pattern, capability string) bool {
+	trim := func(name string) string { return strings.TrimPrefix(strings.ToUpper(name), "CAP_") }
+	return trim(pattern) == trim(capability)
+}
+
+func getAlertEvent(eventType tracing.EventType, event interface{}) (*alertEvent, error) {
+	switch typedEvent := event.(type) {
+	case *tracing.ExecveEvent:
+		return &alertEvent{general: &typedEvent.GeneralEvent, execPath: typedEvent.PathName}, nil
+	case *tracing.OpenEvent:
+		return &alertEvent{general: &typedEvent.GeneralEvent, filePath: typedEvent.PathName}, nil
+	case *tracing.DnsEvent:
+		return &alertEvent{general: &typedEvent.GeneralEvent, domain: typedEvent.DnsName}, nil
+	case *tracing.CapabilitiesEvent:
+		return &alertEvent{general: &typedEvent.GeneralEvent, capability: typedEvent.CapabilityName}, nil
+	case *tracing.NetworkEvent:
+		return &alertEvent{general: &typedEvent.GeneralEvent}, nil
+	case *tracing.SyscallEvent:
+		return &alertEvent{general: &typedEvent.GeneralEvent}, nil
+	case *tracing.RandomXEvent:
+		return &alertEvent{general: &typedEvent.GeneralEvent}, nil
+	default:
+		return nil, fmt.Errorf("unexpected event type %d: %T", eventType, event)
+	}
+}
+
+func contains(values []string, value string) bool {
+	return matchesAny(values, value, func(a, b string) bool { return a == b })
+}
+
+func matchesAny(patterns []string, value string, match func(pattern, value string) bool) bool {
+	for _, pattern := range patterns {
+		if match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func getAlertExceptionFromObj(obj interface{}) (*RuntimeAlertException, error) {
+	typedObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+	bytes, err := typedObj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var exceptionObj *RuntimeAlertException
+	if err := json.Unmarshal(bytes, &exceptionObj); err != nil {
+		return nil, err
+	}
+	return exceptionObj, nil
+}
+
+func alertExceptionName(exceptionObj *RuntimeAlertException) string {
+	if exceptionObj.Namespace != "" {
+		return exceptionObj.Namespace + "/" + exceptionObj.Name
+	}
+	return exceptionObj.Name
+}
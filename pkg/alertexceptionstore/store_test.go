@@ -0,0 +1,159 @@
+package alertexceptionstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dfake "k8s.io/client-go/dynamic/fake"
+)
+
+type fakePodLabelsGetter map[string]labels.Set
+
+func (getter fakePodLabelsGetter) GetPodLabels(podName, namespace string) (labels.Set, labels.Set, error) {
+	return getter[namespace+"/"+podName], labels.Set{"kubernetes.io/metadata.name": namespace}, nil
+}
+
+func alertExceptionObject(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": RuntimeAlertExceptionGvr.GroupVersion().String(),
+		"kind":       "RuntimeAlertException",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       spec,
+	}}
+}
+
+func generalEvent(podName, containerName, comm string) tracing.GeneralEvent {
+	return tracing.GeneralEvent{
+		ProcessDetails: tracing.ProcessDetails{Comm: comm},
+		PodName:        podName,
+		Namespace:      "default",
+		ContainerName:  containerName,
+	}
+}
+
+func TestAlertExceptionK8sStore(t *testing.T) {
+	dynamicClient := dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{RuntimeAlertExceptionGvr: "RuntimeAlertExceptionList"},
+		alertExceptionObject("nginx-reload", map[string]interface{}{
+			"ruleNames":      []interface{}{rule.R0001UnexpectedProcessLaunchedRuleName},
+			"podSelector":    map[string]interface{}{"matchLabels": map[string]interface{}{"app": "nginx"}},
+			"containerNames": []interface{}{"nginx*"},
+			"match":          map[string]interface{}{"execPaths": []interface{}{"/usr/sbin/nginx"}},
+		}),
+		alertExceptionObject("miner-benchmark", map[string]interface{}{
+			"ruleIDs":           []interface{}{rule.R1007ID},
+			"namespaceSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"kubernetes.io/metadata.name": "default"}},
+			"match":             map[string]interface{}{"domains": []interface{}{"*.example.com"}},
+		}),
+		alertExceptionObject("expired", map[string]interface{}{
+			"match":     map[string]interface{}{"comms": []interface{}{"cron"}},
+			"expiresAt": time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		}),
+		alertExceptionObject("invalid", map[string]interface{}{
+			"podSelector": map[string]interface{}{"matchExpressions": []interface{}{map[string]interface{}{"key": "app", "operator": "Bad"}}},
+		}))
+
+	store, err := NewAlertExceptionK8sStore(dynamicClient, fakePodLabelsGetter{"default/nginx": labels.Set{"app": "nginx"}}, "")
+	assert.NoError(t, err)
+	defer store.Destroy()
+	assert.Eventually(t, func() bool {
+		store.exceptionsLock.RLock()
+		defer store.exceptionsLock.RUnlock()
+		return len(store.exceptions) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	nginxExec := &tracing.ExecveEvent{GeneralEvent: generalEvent("nginx", "nginx-main", "nginx"), PathName: "/usr/sbin/nginx"}
+	tests := []struct {
+		name      string
+		ruleName  string
+		eventType tracing.EventType
+		event     interface{}
+		exception string
+	}{
+		{"excepted by rule name, pod labels, container name and exec path", rule.R0001UnexpectedProcessLaunchedRuleName, tracing.ExecveEventType, nginxExec, "nginx-reload"},
+		{"other rule", rule.R1001ExecBinaryNotInBaseImageRuleName, tracing.ExecveEventType, nginxExec, ""},
+		{"other exec path", rule.R0001UnexpectedProcessLaunchedRuleName, tracing.ExecveEventType,
+			&tracing.ExecveEvent{GeneralEvent: generalEvent("nginx", "nginx-main", "sh"), PathName: "/bin/sh"}, ""},
+		{"other pod", rule.R0001UnexpectedProcessLaunchedRuleName, tracing.ExecveEventType,
+			&tracing.ExecveEvent{GeneralEvent: generalEvent("redis", "nginx-main", "nginx"), PathName: "/usr/sbin/nginx"}, ""},
+		{"other container", rule.R0001UnexpectedProcessLaunchedRuleName, tracing.ExecveEventType,
+			&tracing.ExecveEvent{GeneralEvent: generalEvent("nginx", "sidecar", "nginx"), PathName: "/usr/sbin/nginx"}, ""},
+		{"excepted by rule ID, namespace and subdomain", rule.R1007CryptoMinersRuleName, tracing.DnsEventType,
+			&tracing.DnsEvent{GeneralEvent: generalEvent("bench", "bench", "xmrig"), DnsName: "pool.example.com."}, "miner-benchmark"},
+		{"event without the attribute", rule.R1007CryptoMinersRuleName, tracing.RandomXEventType,
+			&tracing.RandomXEvent{GeneralEvent: generalEvent("bench", "bench", "xmrig")}, ""},
+		{"expired", rule.R0001UnexpectedProcessLaunchedRuleName, tracing.ExecveEventType,
+			&tracing.ExecveEvent{GeneralEvent: generalEvent("cron", "cron", "cron"), PathName: "/usr/sbin/cron"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exception, excepted := store.IsExcepted(test.ruleName, test.eventType, test.event)
+			assert.Equal(t, test.exception != "", excepted)
+			assert.Equal(t, test.exception, exception)
+		})
+	}
+
+	// an invalid update removes the exception
+	_, err = dynamicClient.Resource(RuntimeAlertExceptionGvr).Update(context.Background(), alertExceptionObject("nginx-reload", map[string]interface{}{
+		"containerNames": []interface{}{"[nginx"},
+	}), metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, excepted := store.IsExcepted(rule.R0001UnexpectedProcessLaunchedRuleName, tracing.ExecveEventType, nginxExec)
+		return !excepted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// deleting the object removes the exception
+	err = dynamicClient.Resource(RuntimeAlertExceptionGvr).Delete(context.Background(), "miner-benchmark", metav1.DeleteOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, excepted := store.IsExcepted(rule.R1007CryptoMinersRuleName, tracing.DnsEventType,
+			&tracing.DnsEvent{GeneralEvent: generalEvent("bench", "bench", "xmrig"), DnsName: "pool.example.com."})
+		return !excepted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestEventMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		match     EventMatch
+		eventType tracing.EventType
+		event     interface{}
+		expected  bool
+	}{
+		{"file path prefix", EventMatch{FilePathPrefixes: []string{"/var/cache/"}}, tracing.OpenEventType,
+			&tracing.OpenEvent{PathName: "/var/cache/nginx/tmp"}, true},
+		{"other file path", EventMatch{FilePathPrefixes: []string{"/var/cache/"}}, tracing.OpenEventType,
+			&tracing.OpenEvent{PathName: "/etc/shadow"}, false},
+		{"capability", EventMatch{Capabilities: []string{"CAP_NET_ADMIN"}}, tracing.CapabilitiesEventType,
+			&tracing.CapabilitiesEvent{CapabilityName: "net_admin"}, true},
+		{"exact domain", EventMatch{Domains: []string{"example.com"}}, tracing.DnsEventType,
+			&tracing.DnsEvent{DnsName: "example.com."}, true},
+		{"subdomain of an exact domain", EventMatch{Domains: []string{"example.com"}}, tracing.DnsEventType,
+			&tracing.DnsEvent{DnsName: "www.example.com."}, false},
+		{"wildcard domain", EventMatch{Domains: []string{"*.example.com"}}, tracing.DnsEventType,
+			&tracing.DnsEvent{DnsName: "example.com."}, true},
+		{"comm and exec path", EventMatch{Comms: []string{"sh"}, ExecPaths: []string{"/bin/sh"}}, tracing.ExecveEventType,
+			&tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Comm: "sh"}}, PathName: "/bin/sh"}, true},
+		{"comm without exec path", EventMatch{Comms: []string{"sh"}, ExecPaths: []string{"/bin/sh"}}, tracing.NetworkEventType,
+			&tracing.NetworkEvent{GeneralEvent: tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Comm: "sh"}}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exception, err := newAlertException(&RuntimeAlertException{Spec: RuntimeAlertExceptionSpec{Match: test.match}})
+			assert.NoError(t, err)
+			alert, err := getAlertEvent(test.eventType, test.event)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, exception.matchesEvent(alert))
+		})
+	}
+}
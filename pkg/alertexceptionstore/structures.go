@@ -0,0 +1,51 @@
+package alertexceptionstore
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type RuntimeAlertExceptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// Items is the list of RuntimeAlertException
+	Items []RuntimeAlertException `json:"items"`
+}
+
+type RuntimeAlertException struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the exception
+	Spec RuntimeAlertExceptionSpec `json:"spec,omitempty"`
+}
+
+type RuntimeAlertExceptionSpec struct {
+	// IDs and names of the rules whose alerts are excepted, the alerts of all the rules if both are empty
+	RuleIDs   []string `json:"ruleIDs,omitempty" yaml:"ruleIDs,omitempty"`
+	RuleNames []string `json:"ruleNames,omitempty" yaml:"ruleNames,omitempty"`
+	// Selectors of the namespace and the pod of the alerts, the empty selectors select everything
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector,omitempty" yaml:"namespaceSelector,omitempty"`
+	PodSelector       metav1.LabelSelector `json:"podSelector,omitempty" yaml:"podSelector,omitempty"`
+	// Glob patterns of the names of the containers, all the containers if empty
+	ContainerNames []string `json:"containerNames,omitempty" yaml:"containerNames,omitempty"`
+	// Attributes of the event of the alert
+	Match EventMatch `json:"match,omitempty" yaml:"match,omitempty"`
+	// The exception does not apply anymore after this time, it never expires if not set
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	// Why the alerts are benign
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// EventMatch matches the attributes of the event of an alert. An attribute matches if the event has one of its
+// values, every attribute set must match and an event without the attribute does not match it.
+type EventMatch struct {
+	// Paths of the executed files, for the exec events
+	ExecPaths []string `json:"execPaths,omitempty" yaml:"execPaths,omitempty"`
+	// Prefixes of the paths of the opened files, for the open events
+	FilePathPrefixes []string `json:"filePathPrefixes,omitempty" yaml:"filePathPrefixes,omitempty"`
+	// Domain names, for the dns events, a name starting with *. also matches the subdomains
+	Domains []string `json:"domains,omitempty" yaml:"domains,omitempty"`
+	// Capability names, like SYS_ADMIN or CAP_SYS_ADMIN, for the capabilities events
+	Capabilities []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	// Names of the processes, for all the events
+	Comms []string `json:"comms,omitempty" yaml:"comms,omitempty"`
+}
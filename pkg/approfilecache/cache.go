@@ -178,6 +178,19 @@ func (cache *ApplicationProfileK8sCache) DeleteApplicationProfile(containerID st
 	return nil
 }
 
+func (cache *ApplicationProfileK8sCache) GetApplicationProfileStatus(containerID string) (ApplicationProfileStatus, string) {
+	cache.cacheLock.RLock()
+	defer cache.cacheLock.RUnlock()
+	applicationProfile, ok := cache.cache[containerID]
+	if !ok {
+		return ApplicationProfileStatusMissing, ""
+	}
+	if applicationProfile.ApplicationProfile == nil {
+		return ApplicationProfileStatusAnticipated, ""
+	}
+	return ApplicationProfileStatusLoaded, applicationProfile.ApplicationProfile.GetName()
+}
+
 func (cache *ApplicationProfileK8sCache) GetApplicationProfileAccess(containerName, containerID string) (SingleApplicationProfileAccess, error) {
 	cache.cacheLock.RLock()
 	defer cache.cacheLock.RUnlock()
@@ -152,6 +152,9 @@ func TestCacheBasicAnticipateProfile(t *testing.T) {
 		t.Errorf("Failed to anticipate container profile: %v", err)
 		return
 	}
+	if status, _ := cache.GetApplicationProfileStatus("00000000000000000000000000000000"); status != ApplicationProfileStatusAnticipated {
+		t.Errorf("Expected the application profile to be anticipated, got %s", status)
+	}
 
 	// Add the ApplicationProfile to the fake dynamic client
 	_, err = dynamicClient.Resource(collector.AppProfileGvr).Namespace("default").Create(context.Background(), &unstructured.Unstructured{Object: appProfileUnstructured}, v1.CreateOptions{})
@@ -173,4 +176,10 @@ func TestCacheBasicAnticipateProfile(t *testing.T) {
 		t.Errorf("Failed to get container profile: %v", err)
 		return
 	}
+	if status, name := cache.GetApplicationProfileStatus("00000000000000000000000000000000"); status != ApplicationProfileStatusLoaded || name != "deployment-nginx" {
+		t.Errorf("Expected the application profile deployment-nginx to be loaded, got %s %s", status, name)
+	}
+	if status, _ := cache.GetApplicationProfileStatus("unknown"); status != ApplicationProfileStatusMissing {
+		t.Errorf("Expected the application profile to be missing, got %s", status)
+	}
 }
@@ -23,6 +23,18 @@ type SingleApplicationProfileAccess interface {
 	GetDNS() (*[]collector.DnsCalls, error)
 }
 
+// Status of the application profile of a container in the cache
+type ApplicationProfileStatus string
+
+const (
+	// The container has no application profile in the cache
+	ApplicationProfileStatusMissing ApplicationProfileStatus = "missing"
+	// The application profile of the container is expected but is not final yet
+	ApplicationProfileStatusAnticipated ApplicationProfileStatus = "anticipated"
+	// The application profile of the container is loaded
+	ApplicationProfileStatusLoaded ApplicationProfileStatus = "loaded"
+)
+
 type ApplicationProfileCache interface {
 	// Load an application profile to the cache
 	LoadApplicationProfile(namespace, kind, workloadName, ownerKind, ownerName, containerName, containerID string, acceptPartial bool) error
@@ -38,4 +50,7 @@ type ApplicationProfileCache interface {
 
 	// Get application profile access for the given container in Kubernetes workload (identified by container name and ID in the cache)
 	GetApplicationProfileAccess(containerName, containerID string) (SingleApplicationProfileAccess, error)
+
+	// Get the status of the application profile of the container and the name of the profile once loaded
+	GetApplicationProfileStatus(containerID string) (ApplicationProfileStatus, string)
 }
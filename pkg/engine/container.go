@@ -3,64 +3,41 @@ package engine
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"slices"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/armosec/kubecop/pkg/engine/rule"
 	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/armosec/kubecop/pkg/runtimerulestore"
 	"github.com/kubescape/kapprofiler/pkg/tracing"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func fullPodName(namespace, podName string) string {
-	return namespace + "/" + podName
-}
-
+// OnRuleBindingChanged rebinds the rules of the containers whose rules changed. The rules of the containers are
+// resolved from the caches of the rule binding store, so checking all the containers of the node makes no API calls.
 func (engine *Engine) OnRuleBindingChanged(ruleBinding rulebindingstore.RuntimeAlertRuleBinding) {
 	log.Printf("OnRuleBindingChanged: %s\n", ruleBinding.Name)
-	// list all namespaces which match the rule binding selectors
-	selectorString := metav1.FormatLabelSelector(&ruleBinding.Spec.NamespaceSelector)
-	if selectorString == "<none>" {
-		selectorString = ""
-	} else if selectorString == "<error>" {
-		log.Errorf("Failed to parse namespace selector for rule binding %s\n", ruleBinding.Name)
-		return
-	}
-	nsList, err := engine.k8sClientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{
-		LabelSelector: selectorString,
-	})
-	if err != nil {
-		log.Errorf("Failed to list namespaces: %v\n", err)
-		return
-	}
-	podsMap := make(map[string]struct{})
-	podSelectorString := metav1.FormatLabelSelector(&ruleBinding.Spec.PodSelector)
-	if podSelectorString == "<none>" {
-		podSelectorString = ""
-	} else if podSelectorString == "<error>" {
-		log.Errorf("Failed to parse pod selector for rule binding %s\n", ruleBinding.Name)
-		return
-	}
-	for _, ns := range nsList.Items {
-		// list all pods in the namespace which match the rule binding selectors
-		podList, err := engine.k8sClientset.CoreV1().Pods(ns.Name).List(context.TODO(), metav1.ListOptions{
-			LabelSelector: podSelectorString,
-			FieldSelector: "spec.nodeName=" + engine.nodeName,
-		})
+	for _, det := range getcontainerIdToDetailsCacheCopy() {
+		rules, err := engine.getRulesForContainerFunc(det.PodName, det.Namespace, det.container())
 		if err != nil {
-			log.Errorf("Failed to list pods in namespace %s: %v\n", ns.Name, err)
+			log.Errorf("Failed to get rules for container %s of pod %s/%s: %v\n", det.ContainerName, det.Namespace, det.PodName, err)
 			continue
 		}
-		for _, pod := range podList.Items {
-			podsMap[fullPodName(ns.Name, pod.Name)] = struct{}{}
+		if !reflect.DeepEqual(rules, det.BindingRules) {
+			go engine.associateRulesWithContainerInCache(det, true)
 		}
 	}
+}
 
+// OnRuntimeRuleChanged rebinds the rules of all the containers, since any binding may select the rule by name, ID or tag.
+func (engine *Engine) OnRuntimeRuleChanged(runtimeRule runtimerulestore.RuntimeRule) {
+	log.Printf("OnRuntimeRuleChanged: %s (%s)\n", runtimeRule.Name, runtimeRule.Spec.RuleID)
 	for _, det := range getcontainerIdToDetailsCacheCopy() {
-		if _, ok := podsMap[fullPodName(det.Namespace, det.PodName)]; ok {
-			go engine.associateRulesWithContainerInCache(det, true)
-		}
+		go engine.associateRulesWithContainerInCache(det, true)
 	}
 }
 
@@ -101,6 +78,7 @@ func (engine *Engine) OnContainerActivityEvent(event *tracing.ContainerActivityE
 			NsMntId:       event.NsMntId,
 			AttachedLate:  event.Activity == tracing.ContainerActivityEventAttached,
 			PodSpec:       podSpec,
+			StartedAt:     time.Now(),
 		}
 
 		err = engine.associateRulesWithContainerInCache(contEntry, false)
@@ -114,8 +92,8 @@ func (engine *Engine) OnContainerActivityEvent(event *tracing.ContainerActivityE
 			return
 		}
 
-		// Start tracing the container
-		neededEvents := map[tracing.EventType]bool{}
+		// Start tracing the container, the execve events are always needed by the process table
+		neededEvents := map[tracing.EventType]bool{tracing.ExecveEventType: true}
 		for _, rule := range appliedContainerEntry.BoundRules {
 			for _, needEvent := range rule.Requirements().EventTypes {
 				neededEvents[needEvent] = true
@@ -128,27 +106,40 @@ func (engine *Engine) OnContainerActivityEvent(event *tracing.ContainerActivityE
 		}
 
 	} else if event.Activity == tracing.ContainerActivityEventStop {
-		go func() {
-			containerIdToDetailsCacheLock.RLock()
-			eventsInUse := GetRequiredEventsFromRules(containerIdToDetailsCache[event.ContainerID].BoundRules)
-			containerIdToDetailsCacheLock.RUnlock()
-
-			// Stop tracing the container
-			for _, eventInUse := range eventsInUse {
-				if engine.tracer != nil {
-					_ = engine.tracer.StopTraceContainer(event.NsMntId, event.Pid, eventInUse)
-				}
-			}
+		go engine.StopContainer(event)
+	}
+}
+
+// StopContainer stops tracing the container of the stop event and removes it from the cache.
+// OnContainerActivityEvent runs it in the background, it returns once the container is removed.
+func (engine *Engine) StopContainer(event *tracing.ContainerActivityEvent) {
+	containerIdToDetailsCacheLock.RLock()
+	eventsInUse := GetRequiredEventsFromRules(containerIdToDetailsCache[event.ContainerID].BoundRules)
+	containerIdToDetailsCacheLock.RUnlock()
+	if !slices.Contains(eventsInUse, tracing.ExecveEventType) {
+		eventsInUse = append(eventsInUse, tracing.ExecveEventType)
+	}
 
-			// Remove the container from the cache
-			deleteContainerDetails(event.ContainerID)
+	// Stop tracing the container
+	for _, eventInUse := range eventsInUse {
+		if engine.tracer != nil {
+			_ = engine.tracer.StopTraceContainer(event.NsMntId, event.Pid, eventInUse)
+		}
+	}
+
+	engine.processTable.deleteContainer(event.ContainerID)
+
+	// Send the summaries of the alerts suppressed in the container
+	if engine.alertSuppressor != nil {
+		engine.alertSuppressor.flushContainer(event.ContainerID)
+	}
 
-			// Remove the container from the cache
-			containerIdToDetailsCacheLock.Lock()
-			delete(containerIdToDetailsCache, event.ContainerID)
-			containerIdToDetailsCacheLock.Unlock()
-		}()
+	// Remove the container from the cache and free the state of its rules
+	for _, boundRule := range deleteContainerDetails(event.ContainerID) {
+		boundRule.DeleteRule()
 	}
+	// After the container is removed from the cache, so no missing profile check is scheduled again
+	engine.forgetMissingProfileAlert(event.ContainerID)
 }
 
 func (engine *Engine) GetPodSpec(podName, namespace, containerID string) (*corev1.PodSpec, error) {
@@ -186,54 +177,105 @@ func GetRequiredEventsFromRules(rules []rule.Rule) []tracing.EventType {
 
 func (engine *Engine) associateRulesWithContainerInCache(contEntry containerEntry, exists bool) error {
 	// Get the rules that are bound to the container
-	ruleParamsSlc, err := engine.getRulesForPodFunc(contEntry.PodName, contEntry.Namespace)
+	ruleParamsSlc, err := engine.getRulesForContainerFunc(contEntry.PodName, contEntry.Namespace, contEntry.container())
 	if err != nil {
-		return fmt.Errorf("failed to get rules for pod %s/%s: %v", contEntry.Namespace, contEntry.PodName, err)
+		return fmt.Errorf("failed to get rules for container %s of pod %s/%s: %v", contEntry.ContainerName, contEntry.Namespace, contEntry.PodName, err)
 	}
+	contEntry.BindingRules = ruleParamsSlc
 
+	contEntry.AlertOnMissingProfile, contEntry.MissingProfileAlertAfter = false, 0
+	contEntry.RuleActions = nil
 	ruleDescs := make([]rule.Rule, 0, len(ruleParamsSlc))
+	// Rules are merged by the rule binding store, a rule selected twice is bound only once
+	boundRuleNames := map[string]struct{}{}
 	for _, ruleParams := range ruleParamsSlc {
+		var boundRules []rule.Rule
 		if ruleParams.RuleName != "" {
-			ruleDesc := rule.CreateRuleByName(ruleParams.RuleName)
-			if ruleDesc != nil {
-				if ruleParams.Parameters != nil {
-					ruleDesc.SetParameters(ruleParams.Parameters)
-				}
-				ruleDescs = append(ruleDescs, ruleDesc)
+			if ruleDesc := rule.CreateRuleByName(ruleParams.RuleName); ruleDesc != nil {
+				boundRules = append(boundRules, ruleDesc)
+			}
+		} else if ruleParams.RuleID != "" {
+			if ruleDesc := rule.CreateRuleByID(ruleParams.RuleID); ruleDesc != nil {
+				boundRules = append(boundRules, ruleDesc)
 			}
+		} else if len(ruleParams.RuleTags) > 0 {
+			boundRules = rule.CreateRulesByTags(ruleParams.RuleTags)
+		} else if len(ruleParams.MitreTactics) > 0 || len(ruleParams.MitreTechniques) > 0 {
+			boundRules = rule.CreateRulesByMitre(ruleParams.MitreTactics, ruleParams.MitreTechniques)
+		} else {
+			log.Printf("No rule name, id, tags or MITRE ATT&CK specified for rule binding \n")
 			continue
 		}
-		if ruleParams.RuleID != "" {
-			ruleDesc := rule.CreateRuleByID(ruleParams.RuleID)
-			if ruleDesc != nil {
-				if ruleParams.Parameters != nil {
-					ruleDesc.SetParameters(ruleParams.Parameters)
-				}
-				ruleDescs = append(ruleDescs, ruleDesc)
+
+		priority, hasPriority := 0, false
+		if ruleParams.Severity != "" {
+			if priority, err = rule.ParsePriority(ruleParams.Severity); err != nil {
+				log.Errorf("Ignoring severity of rule binding for pod %s/%s: %v\n", contEntry.Namespace, contEntry.PodName, err)
+			} else {
+				hasPriority = true
 			}
-			continue
 		}
-		if len(ruleParams.RuleTags) > 0 {
-			ruleTagsDescs := rule.CreateRulesByTags(ruleParams.RuleTags)
-			if ruleDescs != nil {
-				for _, ruleDesc := range ruleTagsDescs {
-					if ruleParams.Parameters != nil {
-						ruleDesc.SetParameters(ruleParams.Parameters)
-					}
+
+		for _, ruleDesc := range boundRules {
+			if _, ok := boundRuleNames[ruleDesc.Name()]; ok {
+				log.Debugf("Rule %s is already bound to container %s, ignoring duplicate\n", ruleDesc.Name(), contEntry.ContainerID)
+				continue
+			}
+			boundRuleNames[ruleDesc.Name()] = struct{}{}
+			if ruleDesc.Requirements().NeedApplicationProfile && ruleParams.MissingApplicationProfile != nil {
+				engine.applyMissingProfilePolicy(&contEntry, ruleParams.MissingApplicationProfile)
+			}
+			if ruleParams.Parameters != nil {
+				ruleDesc.SetParameters(ruleParams.Parameters)
+			}
+			if hasPriority {
+				ruleDesc = rule.NewPriorityOverrideRule(ruleDesc, priority)
+			}
+			if len(ruleParams.Actions) > 0 {
+				if contEntry.RuleActions == nil {
+					contEntry.RuleActions = make(map[string][]string)
 				}
-				ruleDescs = append(ruleDescs, ruleTagsDescs...)
+				contEntry.RuleActions[ruleDesc.Name()] = ruleParams.Actions
 			}
-			continue
+			ruleDescs = append(ruleDescs, ruleDesc)
 		}
-		log.Printf("No rule name, id or tags specified for rule binding \n")
 	}
 
 	contEntry.BoundRules = ruleDescs
-	// Add the container to the cache
-	setContainerDetails(contEntry.ContainerID, contEntry, exists)
+	contEntry.RequiredEventTypes = newEventTypesMask(ruleDescs)
+	// Add the container to the cache, the rules bound before are replaced by new instances
+	for _, previousRule := range setContainerDetails(contEntry.ContainerID, contEntry, exists) {
+		previousRule.DeleteRule()
+	}
+	if _, ok := getContainerDetails(contEntry.ContainerID); ok {
+		engine.scheduleMissingProfileCheck(contEntry)
+	}
 	return nil
 }
 
+// container returns the container the rule bindings select, with its image from the pod spec.
+func (contEntry *containerEntry) container() rulebindingstore.ContainerInfo {
+	return rulebindingstore.ContainerInfo{Name: contEntry.ContainerName, Image: containerImage(contEntry.PodSpec, contEntry.ContainerName)}
+}
+
+// containerImage returns the image of the container in the pod spec, empty if the container is not found.
+func containerImage(podSpec *corev1.PodSpec, containerName string) string {
+	if podSpec == nil {
+		return ""
+	}
+	for _, container := range append(podSpec.InitContainers, podSpec.Containers...) {
+		if container.Name == containerName {
+			return container.Image
+		}
+	}
+	for _, container := range podSpec.EphemeralContainers {
+		if container.Name == containerName {
+			return container.Image
+		}
+	}
+	return ""
+}
+
 func (engine *Engine) GetWorkloadOwnerKindAndName(event *tracing.GeneralEvent) (string, string, error) {
 	eventContainerId := event.ContainerID
 	if eventContainerId == "" {
@@ -2,10 +2,13 @@ package engine
 
 import (
 	"testing"
+	"time"
 
 	"github.com/armosec/kubecop/pkg/engine/rule"
 	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestAssociateRulesWithContainerInCache(t *testing.T) {
@@ -18,8 +21,8 @@ func TestAssociateRulesWithContainerInCache(t *testing.T) {
 		ContainerID: "test-container",
 	}
 
-	// Mock the getRulesForPodFunc function
-	engine.getRulesForPodFunc = func(podName, namespace string) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
+	// Mock the getRulesForContainerFunc function
+	engine.getRulesForContainerFunc = func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
 		// Return some mock rule parameters
 		return []rulebindingstore.RuntimeAlertRuleBindingRule{
 			{
@@ -69,7 +72,12 @@ func TestAssociateRulesWithContainerInCache(t *testing.T) {
 		rule.CreateRuleByName(rule.R0001UnexpectedProcessLaunchedRuleDescriptor.Name),
 		rule.CreateRuleByID(rule.R0002UnexpectedFileAccessRuleDescriptor.ID),
 	}
-	expectedRuleDescs = append(expectedRuleDescs, rule.CreateRulesByTags(rule.R0003UnexpectedSystemCallRuleDescriptor.Tags)...)
+	// Rules selected by name or ID as well as by tags are bound only once
+	for _, ruleDesc := range rule.CreateRulesByTags(rule.R0003UnexpectedSystemCallRuleDescriptor.Tags) {
+		if ruleDesc.Name() != rule.R0001UnexpectedProcessLaunchedRuleName && ruleDesc.Name() != rule.R0002UnexpectedFileAccessRuleName {
+			expectedRuleDescs = append(expectedRuleDescs, ruleDesc)
+		}
+	}
 	assert.Equal(t, expectedRuleDescs, contDetFromCache.BoundRules)
 
 	// delete from cache
@@ -79,3 +87,142 @@ func TestAssociateRulesWithContainerInCache(t *testing.T) {
 		t.Errorf("Container details should not found in cache after deletion")
 	}
 }
+
+func TestAssociateRulesWithContainerInCacheSeverity(t *testing.T) {
+	engine := &Engine{}
+	contEntry := containerEntry{
+		PodName:     "test-pod",
+		Namespace:   "test-namespace",
+		ContainerID: "test-container-severity",
+	}
+	engine.getRulesForContainerFunc = func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
+		return []rulebindingstore.RuntimeAlertRuleBindingRule{
+			{
+				RuleName: rule.R0001UnexpectedProcessLaunchedRuleDescriptor.Name,
+				Severity: "low",
+			},
+			{
+				RuleID:   rule.R0002UnexpectedFileAccessRuleDescriptor.ID,
+				Severity: "not-a-severity",
+			},
+		}, nil
+	}
+
+	err := engine.associateRulesWithContainerInCache(contEntry, false)
+	assert.NoError(t, err)
+	defer deleteContainerDetails(contEntry.ContainerID)
+	contDetFromCache, ok := getContainerDetails(contEntry.ContainerID)
+	assert.True(t, ok)
+	assert.Len(t, contDetFromCache.BoundRules, 2)
+
+	// The severity of the binding overrides the priority of the rule
+	assert.Equal(t, rule.NewPriorityOverrideRule(rule.CreateRuleByName(rule.R0001UnexpectedProcessLaunchedRuleDescriptor.Name), rule.RulePriorityLow), contDetFromCache.BoundRules[0])
+	// An invalid severity is ignored
+	assert.Equal(t, rule.CreateRuleByID(rule.R0002UnexpectedFileAccessRuleDescriptor.ID), contDetFromCache.BoundRules[1])
+}
+
+func TestAssociateRulesWithContainerInCacheDeletesReplacedRules(t *testing.T) {
+	engine := &Engine{}
+	contEntry := containerEntry{
+		PodName:     "test-pod",
+		Namespace:   "test-namespace",
+		ContainerID: "test-container-delete",
+	}
+	engine.getRulesForContainerFunc = func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
+		return []rulebindingstore.RuntimeAlertRuleBindingRule{{RuleID: rule.R1003ID}}, nil
+	}
+	assert.NoError(t, engine.associateRulesWithContainerInCache(contEntry, false))
+
+	// Start an ssh sequence in the bound rule
+	contDetFromCache, ok := getContainerDetails(contEntry.ContainerID)
+	assert.True(t, ok)
+	boundRule := contDetFromCache.BoundRules[0]
+	generalEvent := tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Pid: 1}, ContainerID: contEntry.ContainerID, Timestamp: 1}
+	openEvent := &tracing.OpenEvent{GeneralEvent: generalEvent, PathName: "/root/.ssh/id_rsa"}
+	networkEvent := &tracing.NetworkEvent{GeneralEvent: generalEvent, PacketType: "OUTGOING", Protocol: "TCP", Port: 2222}
+	boundRule.ProcessEvent(tracing.OpenEventType, openEvent, nil, nil)
+
+	// Rebinding the rules frees the state of the replaced rules
+	assert.NoError(t, engine.associateRulesWithContainerInCache(contEntry, true))
+	assert.Nil(t, boundRule.ProcessEvent(tracing.NetworkEventType, networkEvent, nil, nil))
+
+	// Stopping the container frees the state of its rules
+	contDetFromCache, _ = getContainerDetails(contEntry.ContainerID)
+	boundRule = contDetFromCache.BoundRules[0]
+	boundRule.ProcessEvent(tracing.OpenEventType, openEvent, nil, nil)
+	for _, deletedRule := range deleteContainerDetails(contEntry.ContainerID) {
+		deletedRule.DeleteRule()
+	}
+	assert.Nil(t, boundRule.ProcessEvent(tracing.NetworkEventType, networkEvent, nil, nil))
+}
+
+func TestAssociateRulesWithContainerInCacheContainerSelection(t *testing.T) {
+	engine := &Engine{}
+	contEntry := containerEntry{
+		PodName:       "test-pod",
+		Namespace:     "test-namespace",
+		ContainerID:   "test-container-selection",
+		ContainerName: "istio-proxy",
+		PodSpec: &corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "istio-init", Image: "docker.io/istio/proxyv2:1.20.0"}},
+			Containers: []corev1.Container{
+				{Name: "app", Image: "payments-api:2.1"},
+				{Name: "istio-proxy", Image: "docker.io/istio/proxyv2:1.20.0"},
+			},
+		},
+	}
+	// Only the application container is bound to rules
+	var containers []rulebindingstore.ContainerInfo
+	engine.getRulesForContainerFunc = func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
+		containers = append(containers, container)
+		if container.Name != "app" {
+			return nil, nil
+		}
+		return []rulebindingstore.RuntimeAlertRuleBindingRule{{RuleID: rule.R1003ID}}, nil
+	}
+
+	assert.NoError(t, engine.associateRulesWithContainerInCache(contEntry, false))
+	defer deleteContainerDetails(contEntry.ContainerID)
+	contDetFromCache, ok := getContainerDetails(contEntry.ContainerID)
+	assert.True(t, ok)
+	assert.Empty(t, contDetFromCache.BoundRules)
+
+	contEntry.ContainerName = "app"
+	assert.NoError(t, engine.associateRulesWithContainerInCache(contEntry, true))
+	contDetFromCache, _ = getContainerDetails(contEntry.ContainerID)
+	assert.Len(t, contDetFromCache.BoundRules, 1)
+
+	// The image of the container is taken from the pod spec
+	assert.Equal(t, []rulebindingstore.ContainerInfo{
+		{Name: "istio-proxy", Image: "docker.io/istio/proxyv2:1.20.0"},
+		{Name: "app", Image: "payments-api:2.1"},
+	}, containers)
+}
+
+func TestOnRuleBindingChangedRebindsChangedContainers(t *testing.T) {
+	engine := &Engine{}
+	changed := containerEntry{PodName: "changed-pod", Namespace: "test-namespace", ContainerID: "test-container-binding-changed"}
+	unchanged := containerEntry{PodName: "unchanged-pod", Namespace: "test-namespace", ContainerID: "test-container-binding-unchanged"}
+	severity := ""
+	engine.getRulesForContainerFunc = func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
+		if podName == changed.PodName {
+			return []rulebindingstore.RuntimeAlertRuleBindingRule{{RuleID: rule.R1003ID, Severity: severity}}, nil
+		}
+		return []rulebindingstore.RuntimeAlertRuleBindingRule{{RuleID: rule.R1003ID}}, nil
+	}
+	for _, contEntry := range []containerEntry{changed, unchanged} {
+		assert.NoError(t, engine.associateRulesWithContainerInCache(contEntry, false))
+		defer deleteContainerDetails(contEntry.ContainerID)
+	}
+	unchangedDetails, _ := getContainerDetails(unchanged.ContainerID)
+
+	// Only the container whose rules changed gets new rules
+	severity = "low"
+	engine.OnRuleBindingChanged(rulebindingstore.RuntimeAlertRuleBinding{})
+	assert.Eventually(t, func() bool {
+		changedDetails, _ := getContainerDetails(changed.ContainerID)
+		return len(changedDetails.BindingRules) == 1 && changedDetails.BindingRules[0].Severity == "low"
+	}, time.Second, 10*time.Millisecond)
+	details, _ := getContainerDetails(unchanged.ContainerID)
+	assert.Same(t, unchangedDetails.BoundRules[0], details.BoundRules[0])
+}
@@ -2,8 +2,11 @@ package engine
 
 import (
 	"sync"
+	"time"
 
 	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -23,24 +26,53 @@ type containerEntry struct {
 	// Pod spec
 	PodSpec *corev1.PodSpec
 
-	// Add rules here
-	BoundRules []rule.Rule
+	// Rules of the bindings selecting the container, and the rules created from them
+	BindingRules []rulebindingstore.RuntimeAlertRuleBindingRule
+	BoundRules   []rule.Rule
+	// Response actions of the bound rules, by rule name
+	RuleActions map[string][]string
+	// Event types needed by the bound rules
+	RequiredEventTypes eventTypesMask
+
+	// When the container was started (or attached)
+	StartedAt time.Time
+	// Whether to alert when the application profile is missing, and after how long
+	AlertOnMissingProfile    bool
+	MissingProfileAlertAfter time.Duration
+}
+
+// eventTypesMask is a bitmap of event types, bit n is set for tracing.EventType n.
+type eventTypesMask uint64
+
+func newEventTypesMask(rules []rule.Rule) eventTypesMask {
+	var mask eventTypesMask
+	for _, rule := range rules {
+		for _, eventType := range rule.Requirements().EventTypes {
+			mask |= 1 << uint(eventType)
+		}
+	}
+	return mask
+}
+
+func (mask eventTypesMask) has(eventType tracing.EventType) bool {
+	return mask&(1<<uint(eventType)) != 0
 }
 
 // Container ID to details cache
 var containerIdToDetailsCache = make(map[string]containerEntry)
 var containerIdToDetailsCacheLock = sync.RWMutex{}
 
-func setContainerDetails(containerId string, containerDetails containerEntry, exists bool) {
+// setContainerDetails sets the details of the container and returns the rules which were bound to it before.
+func setContainerDetails(containerId string, containerDetails containerEntry, exists bool) []rule.Rule {
 	containerIdToDetailsCacheLock.Lock()
 	defer containerIdToDetailsCacheLock.Unlock()
-	if exists {
-		// If the container used to be exist and it's not in the cache, don't add it again
-		if _, ok := containerIdToDetailsCache[containerId]; !ok {
-			return
-		}
+	previousDetails, ok := containerIdToDetailsCache[containerId]
+	// If the container used to be exist and it's not in the cache, don't add it again
+	if exists && !ok {
+		return nil
 	}
 	containerIdToDetailsCache[containerId] = containerDetails
+	return previousDetails.BoundRules
 }
 
 func getContainerDetails(containerId string) (containerEntry, bool) {
@@ -50,10 +82,21 @@ func getContainerDetails(containerId string) (containerEntry, bool) {
 	return containerDetails, ok
 }
 
-func deleteContainerDetails(containerId string) {
+// getContainerRequiredEventTypes returns the event types needed by the rules bound to the container.
+func getContainerRequiredEventTypes(containerId string) (eventTypesMask, bool) {
+	containerIdToDetailsCacheLock.RLock()
+	defer containerIdToDetailsCacheLock.RUnlock()
+	containerDetails, ok := containerIdToDetailsCache[containerId]
+	return containerDetails.RequiredEventTypes, ok
+}
+
+// deleteContainerDetails removes the container from the cache and returns the rules which were bound to it.
+func deleteContainerDetails(containerId string) []rule.Rule {
 	containerIdToDetailsCacheLock.Lock()
 	defer containerIdToDetailsCacheLock.Unlock()
+	containerDetails := containerIdToDetailsCache[containerId]
 	delete(containerIdToDetailsCache, containerId)
+	return containerDetails.BoundRules
 }
 
 func getcontainerIdToDetailsCacheCopy() map[string]containerEntry {
@@ -1,6 +1,9 @@
 package engine
 
 import (
+	"sync"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/armosec/kubecop/pkg/approfilecache"
@@ -12,6 +15,7 @@ import (
 	appsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
 	batchv1 "k8s.io/client-go/kubernetes/typed/batch/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	networkingv1 "k8s.io/client-go/kubernetes/typed/networking/v1"
 )
 
 type ClientSetInterface interface {
@@ -19,6 +23,7 @@ type ClientSetInterface interface {
 	Discovery() discovery.DiscoveryInterface
 	AppsV1() appsv1.AppsV1Interface
 	BatchV1() batchv1.BatchV1Interface
+	NetworkingV1() networkingv1.NetworkingV1Interface
 }
 
 type Engine struct {
@@ -26,13 +31,26 @@ type Engine struct {
 	tracer                  *tracing.Tracer
 	exporter                exporters.Exporter
 	// Event processing worker pool
-	eventProcessingPool   *workerpool.WorkerPool
-	k8sClientset          ClientSetInterface
-	pollLoopRunning       bool
-	pollLoopCancelChannel chan struct{}
-	promCollector         *prometheusMetric
-	getRulesForPodFunc    func(podName, namespace string) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error)
-	nodeName              string
+	eventProcessingPool      *workerpool.WorkerPool
+	k8sClientset             ClientSetInterface
+	pollLoopRunning          bool
+	pollLoopCancelChannel    chan struct{}
+	promCollector            *prometheusMetric
+	getRulesForContainerFunc func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error)
+	nodeName                 string
+	// Suppression of repeated alerts, nil when disabled
+	alertSuppressor *alertSuppressor
+	// Returns the name of the exception excepting the alert of a rule on an event, nil when there are no exceptions
+	alertExceptionFunc func(ruleName string, eventType tracing.EventType, event interface{}) (string, bool)
+	// Containers already alerted for a missing application profile
+	missingProfileAlerts map[string]struct{}
+	// Timers checking the application profile of the containers once their policy allows to alert
+	missingProfileTimers     map[string]*time.Timer
+	missingProfileAlertsLock sync.Mutex
+	// Processes of the containers, to attach their ancestry to the alerts
+	processTable *processTable
+	// Response actions on the alerts, nil when disabled
+	responder *responder
 }
 
 func NewEngine(k8sClientset ClientSetInterface,
@@ -49,18 +67,53 @@ func NewEngine(k8sClientset ClientSetInterface,
 		exporter:                exporter,
 		promCollector:           createPrometheusMetric(),
 		nodeName:                nodeName,
+		processTable:            newProcessTable(),
 	}
 	log.Print("Engine created")
 	engine.StartPullComponent()
 	return &engine
 }
 
-func (e *Engine) SetGetRulesForPodFunc(getRulesForPodFunc func(podName, namespace string) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error)) {
-	e.getRulesForPodFunc = getRulesForPodFunc
+func (e *Engine) SetGetRulesForContainerFunc(getRulesForContainerFunc func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error)) {
+	e.getRulesForContainerFunc = getRulesForContainerFunc
+}
+
+// SetAlertExceptionFunc sets the function telling if the alert of a rule on an event is excepted, the excepted
+// alerts are counted but not sent.
+func (e *Engine) SetAlertExceptionFunc(alertExceptionFunc func(ruleName string, eventType tracing.EventType, event interface{}) (string, bool)) {
+	e.alertExceptionFunc = alertExceptionFunc
+}
+
+// SetResponseActions enables the response actions of the rule bindings, at most actionsPerMinute actions are
+// taken on the node per minute. In dry run mode the actions are audited but not taken.
+func (e *Engine) SetResponseActions(dryRun bool, actionsPerMinute int) {
+	e.responder = newResponder(e.k8sClientset, e.nodeName, dryRun, actionsPerMinute, e.sendRuleAlert)
+}
+
+// SetMetricsMaxWorkloads sets the maximum number of workloads in the namespace and workload labels of the alert
+// metrics, the alerts of the workloads seen after the limit is reached are counted with the "_overflow" labels.
+func (e *Engine) SetMetricsMaxWorkloads(maxWorkloads int) {
+	e.promCollector.setMaxWorkloads(maxWorkloads)
+}
+
+// SetAlertSuppressionWindow enables the suppression of repeated alerts: after an alert is sent,
+// the same alert (same rule, container and fingerprint) is suppressed until the window is over,
+// then a summary with the number of suppressed alerts is sent. A zero window disables the suppression.
+func (e *Engine) SetAlertSuppressionWindow(window time.Duration) {
+	if e.alertSuppressor != nil {
+		e.alertSuppressor.stop()
+		e.alertSuppressor = nil
+	}
+	if window > 0 {
+		e.alertSuppressor = newAlertSuppressor(window, e.sendRuleAlert)
+	}
 }
 
 func (e *Engine) Delete() {
 	e.StopPullComponent()
 	e.eventProcessingPool.StopWait()
+	if e.alertSuppressor != nil {
+		e.alertSuppressor.stop()
+	}
 	e.promCollector.destroy()
 }
@@ -166,6 +166,15 @@ func (apc *ApplicationProfileCacheMock) GetApplicationProfileAccess(containerNam
 	return apc.MockAppProfileAccess, nil
 }
 
+// GetApplicationProfileStatus mocks getting the status of the application profile of the container.
+func (apc *ApplicationProfileCacheMock) GetApplicationProfileStatus(containerID string) (approfilecache.ApplicationProfileStatus, string) {
+	// Mock implementation, the profile is loaded when there is a profile access
+	if apc.MockAppProfileAccess == nil {
+		return approfilecache.ApplicationProfileStatusMissing, ""
+	}
+	return approfilecache.ApplicationProfileStatusLoaded, apc.MockAppProfileAccess.GetName()
+}
+
 func TestNewEngine(t *testing.T) {
 	// Create a new engine
 	e := NewEngine(nil, nil, nil, nil, 0, "localhost")
@@ -201,7 +210,7 @@ func TestEngine_ContainerStartStop(t *testing.T) {
 
 	// Create a new engine
 	e := NewEngine(fakeclientset, NewApplicationProfileCacheMock(nil), nil, &MockExporter{}, 0, "localhost")
-	e.SetGetRulesForPodFunc(func(podName, namespace string) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
+	e.SetGetRulesForContainerFunc(func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
 		return []rulebindingstore.RuntimeAlertRuleBindingRule{{RuleName: "testrule"}}, nil
 	})
 	// Assert e is not nil
@@ -244,6 +253,14 @@ func TestEngine_ContainerStartStop(t *testing.T) {
 		NsMntId:       0,
 	})
 
+	// The container is removed from the cache in the background
+	for i := 0; i < 100; i++ {
+		if _, ok := getContainerDetails("test"); !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected container to be removed from the cache after stop")
 }
 
 func TestEngine_LoadEngineWithEvents(t *testing.T) {
@@ -303,7 +320,7 @@ func TestEngine_LoadEngineWithEvents(t *testing.T) {
 
 	// Create a new engine
 	e := NewEngine(fakeclientset, NewApplicationProfileCacheMock(mockAppProfile), nil, &mockExporter, 0, "localhost")
-	e.SetGetRulesForPodFunc(func(podName, namespace string) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
+	e.SetGetRulesForContainerFunc(func(podName, namespace string, container rulebindingstore.ContainerInfo) ([]rulebindingstore.RuntimeAlertRuleBindingRule, error) {
 		// Get all rules
 		var allRules []rulebindingstore.RuntimeAlertRuleBindingRule
 		for _, rule := range rule.GetAllRuleDescriptors() {
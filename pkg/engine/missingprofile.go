@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+const MissingApplicationProfileRuleName = "Missing application profile"
+
+// MissingApplicationProfileFailure is a system alert, sent when rules which need an application profile
+// run in a container without one, according to the missing application profile policy of the rule binding.
+type MissingApplicationProfileFailure struct {
+	Err              string
+	FixSuggestionMsg string
+	FailureEvent     *tracing.GeneralEvent
+}
+
+func (failure *MissingApplicationProfileFailure) Name() string {
+	return MissingApplicationProfileRuleName
+}
+
+func (failure *MissingApplicationProfileFailure) Error() string {
+	return failure.Err
+}
+
+func (failure *MissingApplicationProfileFailure) Event() tracing.GeneralEvent {
+	return *failure.FailureEvent
+}
+
+func (failure *MissingApplicationProfileFailure) Priority() int {
+	return rule.RulePrioritySystemIssue
+}
+
+func (failure *MissingApplicationProfileFailure) FixSuggestion() string {
+	return failure.FixSuggestionMsg
+}
+
+func (failure *MissingApplicationProfileFailure) Fingerprint() string {
+	return ""
+}
+
+func (failure *MissingApplicationProfileFailure) Evidence() rule.Evidence {
+	return rule.Evidence{}
+}
+
+// applyMissingProfilePolicy merges the policy of a bound rule into the container policy,
+// the policy which alerts the soonest wins.
+func (engine *Engine) applyMissingProfilePolicy(contEntry *containerEntry, policy *rulebindingstore.MissingApplicationProfilePolicy) {
+	alert, after, err := policy.AlertDelay()
+	if err != nil {
+		log.Errorf("Ignoring missing application profile policy for pod %s/%s: %v\n", contEntry.Namespace, contEntry.PodName, err)
+		return
+	}
+	if !alert {
+		return
+	}
+	if !contEntry.AlertOnMissingProfile || after < contEntry.MissingProfileAlertAfter {
+		contEntry.AlertOnMissingProfile = true
+		contEntry.MissingProfileAlertAfter = after
+	}
+}
+
+// scheduleMissingProfileCheck starts a timer checking the application profile of the container once it
+// has been running for the delay of its policy, so containers which send no events are reported as well.
+// It replaces the timer of the previous rule bindings of the container.
+func (engine *Engine) scheduleMissingProfileCheck(contEntry containerEntry) {
+	engine.missingProfileAlertsLock.Lock()
+	defer engine.missingProfileAlertsLock.Unlock()
+	if timer, ok := engine.missingProfileTimers[contEntry.ContainerID]; ok {
+		timer.Stop()
+		delete(engine.missingProfileTimers, contEntry.ContainerID)
+	}
+	if !contEntry.AlertOnMissingProfile {
+		return
+	}
+	if _, alerted := engine.missingProfileAlerts[contEntry.ContainerID]; alerted {
+		return
+	}
+	if engine.missingProfileTimers == nil {
+		engine.missingProfileTimers = make(map[string]*time.Timer)
+	}
+	containerID := contEntry.ContainerID
+	delay := time.Until(contEntry.StartedAt.Add(contEntry.MissingProfileAlertAfter))
+	engine.missingProfileTimers[containerID] = time.AfterFunc(delay, func() {
+		engine.checkMissingApplicationProfile(containerID)
+	})
+}
+
+// checkMissingApplicationProfile reports the container if it has no finalized application profile,
+// the cache only holds finalized profiles.
+func (engine *Engine) checkMissingApplicationProfile(containerID string) {
+	contEntry, ok := getContainerDetails(containerID)
+	if !ok {
+		return
+	}
+	if engine.applicationProfileCache != nil {
+		if appProfile, err := engine.applicationProfileCache.GetApplicationProfileAccess(contEntry.ContainerName, containerID); err == nil && appProfile != nil {
+			return
+		}
+	}
+	engine.reportMissingApplicationProfile(&tracing.GeneralEvent{
+		ContainerID: containerID,
+		Timestamp:   time.Now().UnixNano(),
+	})
+}
+
+// reportMissingApplicationProfile is called when an event is not checked by the rules which need an application
+// profile, because the container has none. It sends an alert once per container, if the policy asks for it.
+func (engine *Engine) reportMissingApplicationProfile(event *tracing.GeneralEvent) {
+	contEntry, ok := getContainerDetails(event.ContainerID)
+	if !ok || !contEntry.AlertOnMissingProfile {
+		return
+	}
+	runningFor := time.Since(contEntry.StartedAt)
+	if runningFor < contEntry.MissingProfileAlertAfter {
+		return
+	}
+
+	engine.missingProfileAlertsLock.Lock()
+	if engine.missingProfileAlerts == nil {
+		engine.missingProfileAlerts = make(map[string]struct{})
+	}
+	_, alerted := engine.missingProfileAlerts[event.ContainerID]
+	engine.missingProfileAlerts[event.ContainerID] = struct{}{}
+	engine.missingProfileAlertsLock.Unlock()
+	if alerted {
+		return
+	}
+
+	failureEvent := *event
+	failureEvent.ContainerName = contEntry.ContainerName
+	failureEvent.PodName = contEntry.PodName
+	failureEvent.Namespace = contEntry.Namespace
+	engine.sendRuleAlert(&MissingApplicationProfileFailure{
+		Err: fmt.Sprintf("Container %s of %s %s/%s has been running for %s without a finalized application profile, rules which need it are not applied",
+			contEntry.ContainerName, contEntry.OwnerKind, contEntry.Namespace, contEntry.OwnerName, runningFor.Round(time.Second)),
+		FixSuggestionMsg: fmt.Sprintf("Make sure an application profile is recorded for the workload %s/%s, or set the missingApplicationProfile policy of the rule binding to ignore", contEntry.Namespace, contEntry.OwnerName),
+		FailureEvent:     &failureEvent,
+	})
+}
+
+// forgetMissingProfileAlert is called when the container stops.
+func (engine *Engine) forgetMissingProfileAlert(containerID string) {
+	engine.missingProfileAlertsLock.Lock()
+	defer engine.missingProfileAlertsLock.Unlock()
+	delete(engine.missingProfileAlerts, containerID)
+	if timer, ok := engine.missingProfileTimers[containerID]; ok {
+		timer.Stop()
+		delete(engine.missingProfileTimers, containerID)
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func TestMissingApplicationProfileAlert(t *testing.T) {
+	mockExporter := MockExporter{}
+	engine := &Engine{exporter: &mockExporter, promCollector: createPrometheusMetric()}
+	defer engine.promCollector.destroy()
+
+	contEntry := containerEntry{
+		ContainerID:   "test-missing-profile",
+		ContainerName: "test",
+		PodName:       "test",
+		Namespace:     "test",
+		StartedAt:     time.Now(),
+	}
+	boundRules := []rule.Rule{rule.CreateRuleR0001UnexpectedProcessLaunched()}
+	event := &tracing.ExecveEvent{
+		GeneralEvent: tracing.GeneralEvent{ContainerID: contEntry.ContainerID},
+		PathName:     "/bin/sh",
+	}
+	defer deleteContainerDetails(contEntry.ContainerID)
+
+	// Test case: no policy, no alert
+	setContainerDetails(contEntry.ContainerID, contEntry, false)
+	engine.ProcessEvent(tracing.ExecveEventType, event, nil, boundRules)
+	if len(mockExporter.Alerts) != 0 {
+		t.Errorf("Expected alerts to be 0, got %v", len(mockExporter.Alerts))
+	}
+
+	// Test case: the container did not run long enough, no alert
+	engine.applyMissingProfilePolicy(&contEntry, &rulebindingstore.MissingApplicationProfilePolicy{Policy: rulebindingstore.MissingApplicationProfilePolicyAlertAfter, After: "1h"})
+	setContainerDetails(contEntry.ContainerID, contEntry, false)
+	engine.ProcessEvent(tracing.ExecveEventType, event, nil, boundRules)
+	if len(mockExporter.Alerts) != 0 {
+		t.Errorf("Expected alerts to be 0, got %v", len(mockExporter.Alerts))
+	}
+
+	// Test case: the policy which alerts the soonest wins, a single alert is sent per container
+	engine.applyMissingProfilePolicy(&contEntry, &rulebindingstore.MissingApplicationProfilePolicy{Policy: rulebindingstore.MissingApplicationProfilePolicyAlertOnce})
+	setContainerDetails(contEntry.ContainerID, contEntry, false)
+	engine.ProcessEvent(tracing.ExecveEventType, event, nil, boundRules)
+	engine.ProcessEvent(tracing.ExecveEventType, event, nil, boundRules)
+	if len(mockExporter.Alerts) != 1 {
+		t.Fatalf("Expected alerts to be 1, got %v", len(mockExporter.Alerts))
+	}
+	if mockExporter.Alerts[0].Priority() != rule.RulePrioritySystemIssue {
+		t.Errorf("Expected a system issue alert, got priority %v", mockExporter.Alerts[0].Priority())
+	}
+	if mockExporter.Alerts[0].Event().PodName != "test" {
+		t.Errorf("Expected the alert to have the pod name, got %v", mockExporter.Alerts[0].Event().PodName)
+	}
+
+	// Test case: the container is alerted again after it restarts
+	engine.forgetMissingProfileAlert(contEntry.ContainerID)
+	engine.ProcessEvent(tracing.ExecveEventType, event, nil, boundRules)
+	if len(mockExporter.Alerts) != 2 {
+		t.Errorf("Expected alerts to be 2, got %v", len(mockExporter.Alerts))
+	}
+}
+
+// chanExporter forwards the rule alerts to a channel, for alerts sent from other goroutines.
+type chanExporter struct {
+	alerts chan rule.RuleFailure
+}
+
+func (exporter *chanExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	exporter.alerts <- failedRule
+}
+
+func (exporter *chanExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
+}
+
+func TestMissingApplicationProfileQuietContainer(t *testing.T) {
+	exporter := &chanExporter{alerts: make(chan rule.RuleFailure, 10)}
+	engine := &Engine{exporter: exporter, promCollector: createPrometheusMetric()}
+	defer engine.promCollector.destroy()
+
+	contEntry := containerEntry{
+		ContainerID:   "test-missing-profile-quiet",
+		ContainerName: "test",
+		PodName:       "test",
+		Namespace:     "test",
+		StartedAt:     time.Now(),
+	}
+	engine.applyMissingProfilePolicy(&contEntry, &rulebindingstore.MissingApplicationProfilePolicy{Policy: rulebindingstore.MissingApplicationProfilePolicyAlertAfter, After: "50ms"})
+	setContainerDetails(contEntry.ContainerID, contEntry, false)
+	defer deleteContainerDetails(contEntry.ContainerID)
+
+	// Test case: the container sends no events, it is reported once the delay of the policy is over
+	scheduled := time.Now()
+	engine.scheduleMissingProfileCheck(contEntry)
+	select {
+	case alert := <-exporter.alerts:
+		if alert.Name() != MissingApplicationProfileRuleName || alert.Event().PodName != "test" {
+			t.Errorf("Expected a missing application profile alert for the pod, got %v for %v", alert.Name(), alert.Event().PodName)
+		}
+		// event timestamps are in nanoseconds
+		if timestamp := time.Unix(0, alert.Event().Timestamp); timestamp.Before(scheduled) || timestamp.After(time.Now()) {
+			t.Errorf("Expected the alert timestamp to be the time of the check, got %v", timestamp)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for the missing application profile alert")
+	}
+
+	// Test case: the container is not reported again when its rules are bound again
+	engine.scheduleMissingProfileCheck(contEntry)
+	select {
+	case <-exporter.alerts:
+		t.Errorf("Expected a single alert per container")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Test case: a container with a finalized profile is not reported
+	engineWithProfile := &Engine{exporter: exporter, applicationProfileCache: NewApplicationProfileCacheMock(nil)}
+	engineWithProfile.scheduleMissingProfileCheck(contEntry)
+	select {
+	case <-exporter.alerts:
+		t.Errorf("Expected no alert for a container with an application profile")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Test case: the check is cancelled when the container stops
+	stoppedEngine := &Engine{exporter: exporter}
+	contEntry.StartedAt = time.Now()
+	stoppedEngine.scheduleMissingProfileCheck(contEntry)
+	stoppedEngine.forgetMissingProfileAlert(contEntry.ContainerID)
+	select {
+	case <-exporter.alerts:
+		t.Errorf("Expected no alert for a stopped container")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
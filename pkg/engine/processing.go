@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/armosec/kubecop/pkg/approfilecache"
@@ -16,18 +18,63 @@ func (engine *Engine) ProcessEvent(eventType tracing.EventType, event interface{
 	}
 
 	// Loop over the boundRules
+	missingProfileReported := false
 	for _, rule := range boundRules {
-		// TODO if no app profile and one of the rules must have it then fire alert!
 		if appProfile == nil && rule.Requirements().NeedApplicationProfile {
 			log.Debugf("%v - warning missing app profile", e)
-			continue // TODO - check with the RuleBinding if alert should be fired or not
+			// The rule binding policy decides if an alert should be fired
+			if !missingProfileReported && e != nil {
+				engine.reportMissingApplicationProfile(e)
+				missingProfileReported = true
+			}
+			continue
 		}
 
+		processingStart := time.Now()
 		ruleFailure := rule.ProcessEvent(eventType, event, appProfile, engine)
+		processingDuration := time.Since(processingStart)
 		if ruleFailure != nil {
-			engine.exporter.SendRuleAlert(ruleFailure)
-			engine.promCollector.reportRuleAlereted(rule.Name())
+			ruleFailure = engine.attachProcessAncestry(ruleFailure)
+			if exception, excepted := engine.isAlertExcepted(rule.Name(), eventType, event); excepted {
+				log.Debugf("Alert of rule %s excepted by %s: %s\n", rule.Name(), exception, ruleFailure.Error())
+				engine.promCollector.reportRuleAlertExcepted(ruleFailure)
+			} else {
+				if engine.alertSuppressor != nil && !engine.alertSuppressor.shouldSend(ruleFailure) {
+					engine.promCollector.reportRuleAlertSuppressed(ruleFailure)
+				} else {
+					engine.sendRuleAlert(ruleFailure)
+				}
+				// the repeats of a suppressed alert may come from new processes, the actions are taken on them too
+				engine.respond(rule.Name(), ruleFailure)
+			}
 		}
-		engine.promCollector.reportRuleProcessed(rule.Name())
+		engine.promCollector.reportRuleProcessed(rule.Name(), processingDuration)
+	}
+}
+
+// sendRuleAlert hands the alert to the exporters and counts it, every alert of the engine is sent through it:
+// the alerts of the rules, the summaries of the suppressed alerts and the system alerts.
+func (engine *Engine) sendRuleAlert(failure rule.RuleFailure) {
+	engine.exporter.SendRuleAlert(failure)
+	engine.promCollector.reportRuleAlerted(failure)
+}
+
+// isAlertExcepted returns the name of the exception excepting the alert of the rule on the event.
+func (engine *Engine) isAlertExcepted(ruleName string, eventType tracing.EventType, event interface{}) (string, bool) {
+	if engine.alertExceptionFunc == nil {
+		return "", false
+	}
+	return engine.alertExceptionFunc(ruleName, eventType, event)
+}
+
+// respond takes the response actions of the rule in the container of the failure, in the background.
+func (engine *Engine) respond(ruleName string, failure rule.RuleFailure) {
+	if engine.responder == nil {
+		return
+	}
+	contEntry, ok := getContainerDetails(failure.Event().ContainerID)
+	if !ok || len(contEntry.RuleActions[ruleName]) == 0 {
+		return
 	}
+	go engine.responder.respond(failure, contEntry.RuleActions[ruleName])
 }
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func TestProcessEventAlertException(t *testing.T) {
+	mockExporter := MockExporter{}
+	engine := &Engine{exporter: &mockExporter, promCollector: createPrometheusMetric(), processTable: newProcessTable()}
+	defer engine.promCollector.destroy()
+
+	boundRules := []rule.Rule{rule.CreateRuleR1007CryptoMiners()}
+	event := &tracing.RandomXEvent{GeneralEvent: tracing.GeneralEvent{ContainerID: "test-alert-exception", ProcessDetails: tracing.ProcessDetails{Comm: "xmrig"}}}
+
+	// Test case: no exceptions, the alert is sent
+	engine.ProcessEvent(tracing.RandomXEventType, event, nil, boundRules)
+	if len(mockExporter.Alerts) != 1 {
+		t.Fatalf("Expected alerts to be 1, got %v", len(mockExporter.Alerts))
+	}
+
+	// Test case: the excepted alert is not sent
+	exceptedRules := []string{}
+	engine.SetAlertExceptionFunc(func(ruleName string, eventType tracing.EventType, event interface{}) (string, bool) {
+		exceptedRules = append(exceptedRules, ruleName)
+		return "miner-benchmark", true
+	})
+	engine.ProcessEvent(tracing.RandomXEventType, event, nil, boundRules)
+	if len(mockExporter.Alerts) != 1 {
+		t.Errorf("Expected alerts to be 1, got %v", len(mockExporter.Alerts))
+	}
+	if len(exceptedRules) != 1 || exceptedRules[0] != rule.R1007CryptoMinersRuleName {
+		t.Errorf("Expected the exception to be checked for %s, got %v", rule.R1007CryptoMinersRuleName, exceptedRules)
+	}
+}
+
+func TestProcessEventResponseActions(t *testing.T) {
+	exporter := &chanExporter{alerts: make(chan rule.RuleFailure, 10)}
+	engine := &Engine{exporter: exporter, promCollector: createPrometheusMetric(), processTable: newProcessTable()}
+	defer engine.promCollector.destroy()
+	engine.SetResponseActions(true, 0)
+
+	contEntry := containerEntry{
+		ContainerID: "test-response-actions",
+		PodName:     "miner",
+		Namespace:   "default",
+		RuleActions: map[string][]string{rule.R1007CryptoMinersRuleName: {rulebindingstore.ResponseActionKillProcess}},
+	}
+	setContainerDetails(contEntry.ContainerID, contEntry, false)
+	defer deleteContainerDetails(contEntry.ContainerID)
+	boundRules := []rule.Rule{rule.CreateRuleR1007CryptoMiners()}
+	event := &tracing.RandomXEvent{GeneralEvent: tracing.GeneralEvent{ContainerID: contEntry.ContainerID, PodName: "miner", Namespace: "default",
+		ProcessDetails: tracing.ProcessDetails{Pid: 1234, Comm: "xmrig"}}}
+
+	// Test case: the alert is sent, then the audit alert of the action
+	engine.ProcessEvent(tracing.RandomXEventType, event, nil, boundRules)
+	for _, expected := range []string{rule.R1007CryptoMinersRuleName, ResponseActionRuleName} {
+		select {
+		case alert := <-exporter.alerts:
+			if alert.Name() != expected {
+				t.Errorf("Expected alert %s, got %s", expected, alert.Name())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected alert %s", expected)
+		}
+	}
+
+	// Test case: no action is taken on an excepted alert
+	engine.SetAlertExceptionFunc(func(ruleName string, eventType tracing.EventType, event interface{}) (string, bool) {
+		return "miner-benchmark", true
+	})
+	event.Pid = 1235
+	engine.ProcessEvent(tracing.RandomXEventType, event, nil, boundRules)
+	select {
+	case alert := <-exporter.alerts:
+		t.Errorf("Expected no alert, got %s", alert.Name())
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+const (
+	// Maximum number of processes kept per container, the oldest are forgotten first
+	maxProcessesPerContainer = 4096
+	// Maximum number of processes kept per pid, older ones are the exited processes the pid was reused from
+	maxProcessesPerPid = 2
+	// Maximum length of the ancestry of a process
+	maxProcessAncestryDepth = 32
+)
+
+// processTable keeps the processes of each container from the execve events, to build the ancestry of the alerts.
+// The tracer reports no process exits, a process is known to have exited when its pid is reused by a process
+// with another parent.
+type processTable struct {
+	mutex      sync.Mutex
+	containers map[string]map[uint32][]*rule.ProcessInfo
+}
+
+func newProcessTable() *processTable {
+	return &processTable{containers: make(map[string]map[uint32][]*rule.ProcessInfo)}
+}
+
+// addExec records the process of an execve event.
+func (table *processTable) addExec(event *tracing.ExecveEvent) {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+	processes, ok := table.containers[event.ContainerID]
+	if !ok {
+		processes = make(map[uint32][]*rule.ProcessInfo)
+		table.containers[event.ContainerID] = processes
+	}
+
+	history := processes[event.Pid]
+	if len(history) > 0 {
+		current := history[len(history)-1]
+		if current.ExitTime == 0 && current.Ppid == event.Ppid {
+			// The process executes a new program, it keeps its pid, parent and start time
+			current.Comm = event.Comm
+			current.Path = event.PathName
+			current.Args = event.Args
+			return
+		}
+		// The pid is reused, the previous process has exited
+		current.ExitTime = event.Timestamp
+	} else if len(processes) >= maxProcessesPerContainer {
+		forgetOldestProcess(processes)
+	}
+
+	history = append(history, &rule.ProcessInfo{
+		Pid:       event.Pid,
+		Ppid:      event.Ppid,
+		Comm:      event.Comm,
+		Path:      event.PathName,
+		Args:      event.Args,
+		StartTime: event.Timestamp,
+	})
+	if len(history) > maxProcessesPerPid {
+		history = history[len(history)-maxProcessesPerPid:]
+	}
+	processes[event.Pid] = history
+}
+
+// ancestry returns the process of the event followed by its known ancestors. The chain ends with the
+// first ancestor which was not seen starting, with only its pid set.
+func (table *processTable) ancestry(event *tracing.GeneralEvent) []rule.ProcessInfo {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+	processes := table.containers[event.ContainerID]
+
+	process := findProcess(processes, event.Pid, event.Timestamp)
+	if process == nil {
+		process = &rule.ProcessInfo{Pid: event.Pid, Ppid: event.Ppid, Comm: event.Comm}
+	}
+	ancestry := []rule.ProcessInfo{copyProcessInfo(process)}
+	visited := map[uint32]struct{}{process.Pid: {}}
+	for len(ancestry) < maxProcessAncestryDepth && process.Ppid != 0 {
+		if _, ok := visited[process.Ppid]; ok {
+			break
+		}
+		visited[process.Ppid] = struct{}{}
+		parent := findProcess(processes, process.Ppid, process.StartTime)
+		if parent == nil {
+			ancestry = append(ancestry, rule.ProcessInfo{Pid: process.Ppid})
+			break
+		}
+		ancestry = append(ancestry, copyProcessInfo(parent))
+		process = parent
+	}
+	return ancestry
+}
+
+// deleteContainer forgets the processes of a stopped container.
+func (table *processTable) deleteContainer(containerID string) {
+	table.mutex.Lock()
+	defer table.mutex.Unlock()
+	delete(table.containers, containerID)
+}
+
+// attachProcessAncestry attaches the ancestry of the process of the failure to the failure.
+func (engine *Engine) attachProcessAncestry(failure rule.RuleFailure) rule.RuleFailure {
+	event := failure.Event()
+	return rule.WithProcessAncestry(failure, engine.processTable.ancestry(&event))
+}
+
+// findProcess returns the latest process with the pid started before the time, any if the time is unknown.
+func findProcess(processes map[uint32][]*rule.ProcessInfo, pid uint32, before int64) *rule.ProcessInfo {
+	history := processes[pid]
+	for i := len(history) - 1; i >= 0; i-- {
+		if before == 0 || history[i].StartTime <= before {
+			return history[i]
+		}
+	}
+	return nil
+}
+
+func forgetOldestProcess(processes map[uint32][]*rule.ProcessInfo) {
+	var oldestPid uint32
+	var oldestStartTime int64
+	found := false
+	for pid, history := range processes {
+		startTime := history[len(history)-1].StartTime
+		if !found || startTime < oldestStartTime {
+			oldestPid, oldestStartTime, found = pid, startTime, true
+		}
+	}
+	delete(processes, oldestPid)
+}
+
+func copyProcessInfo(process *rule.ProcessInfo) rule.ProcessInfo {
+	processCopy := *process
+	if process.Args != nil {
+		processCopy.Args = append([]string{}, process.Args...)
+	}
+	return processCopy
+}
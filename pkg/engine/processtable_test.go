@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func execEvent(containerID string, pid, ppid uint32, path string, timestamp int64) *tracing.ExecveEvent {
+	return &tracing.ExecveEvent{
+		GeneralEvent: tracing.GeneralEvent{
+			ProcessDetails: tracing.ProcessDetails{Pid: pid, Ppid: ppid, Comm: path[len(path)-2:]},
+			ContainerID:    containerID,
+			Timestamp:      timestamp,
+		},
+		PathName: path,
+		Args:     []string{path},
+	}
+}
+
+func TestProcessTableAncestry(t *testing.T) {
+	table := newProcessTable()
+	table.addExec(execEvent("test", 10, 1, "/usr/sbin/nginx", 100))
+	table.addExec(execEvent("test", 20, 10, "/bin/sh", 200))
+	table.addExec(execEvent("test", 30, 20, "/usr/bin/id", 300))
+
+	// Test case: the chain goes up to the first unknown ancestor
+	ancestry := table.ancestry(&tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Pid: 30, Ppid: 20}, ContainerID: "test", Timestamp: 350})
+	expectedPids := []uint32{30, 20, 10, 1}
+	if len(ancestry) != len(expectedPids) {
+		t.Fatalf("Expected %d processes, got %+v", len(expectedPids), ancestry)
+	}
+	for i, pid := range expectedPids {
+		if ancestry[i].Pid != pid {
+			t.Errorf("Expected pid %d at %d, got %d", pid, i, ancestry[i].Pid)
+		}
+	}
+	if ancestry[1].Path != "/bin/sh" || ancestry[1].StartTime != 200 || ancestry[3].Path != "" {
+		t.Errorf("Unexpected ancestry %+v", ancestry)
+	}
+
+	// Test case: an unknown process of the event is taken from the event
+	ancestry = table.ancestry(&tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Pid: 40, Ppid: 20, Comm: "cat"}, ContainerID: "test"})
+	if len(ancestry) != 4 || ancestry[0].Comm != "cat" || ancestry[1].Pid != 20 {
+		t.Errorf("Unexpected ancestry %+v", ancestry)
+	}
+
+	// Test case: a process executing a new program keeps its start time
+	table.addExec(execEvent("test", 30, 20, "/usr/bin/ls", 400))
+	ancestry = table.ancestry(&tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Pid: 30}, ContainerID: "test"})
+	if ancestry[0].Path != "/usr/bin/ls" || ancestry[0].StartTime != 300 {
+		t.Errorf("Expected the new program with the original start time, got %+v", ancestry[0])
+	}
+
+	// Test case: a reused pid marks the previous process as exited, its children still see it
+	table.addExec(execEvent("test", 20, 1, "/usr/bin/sleep", 500))
+	ancestry = table.ancestry(&tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Pid: 30}, ContainerID: "test"})
+	if ancestry[1].Path != "/bin/sh" || ancestry[1].ExitTime != 500 {
+		t.Errorf("Expected the exited shell as parent, got %+v", ancestry[1])
+	}
+	ancestry = table.ancestry(&tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Pid: 20}, ContainerID: "test", Timestamp: 600})
+	if ancestry[0].Path != "/usr/bin/sleep" || len(ancestry) != 2 {
+		t.Errorf("Expected the new process of the reused pid, got %+v", ancestry)
+	}
+
+	// Test case: the processes of a stopped container are forgotten
+	table.deleteContainer("test")
+	ancestry = table.ancestry(&tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Pid: 30, Ppid: 20}, ContainerID: "test"})
+	if len(ancestry) != 2 || ancestry[0].Path != "" {
+		t.Errorf("Expected only the event process and its parent pid, got %+v", ancestry)
+	}
+}
+
+func TestProcessTableLimits(t *testing.T) {
+	table := newProcessTable()
+	// Test case: a parent loop does not hang
+	table.addExec(execEvent("test", 10, 20, "/bin/aa", 0))
+	table.addExec(execEvent("test", 20, 10, "/bin/bb", 0))
+	if ancestry := table.ancestry(&tracing.GeneralEvent{ProcessDetails: tracing.ProcessDetails{Pid: 10}, ContainerID: "test"}); len(ancestry) != 2 {
+		t.Errorf("Expected the loop to stop after 2 processes, got %+v", ancestry)
+	}
+
+	// Test case: the oldest processes are forgotten when the container has too many
+	for pid := uint32(100); pid < 100+maxProcessesPerContainer; pid++ {
+		table.addExec(execEvent("test", pid, 1, "/bin/cc", int64(pid)))
+	}
+	if len(table.containers["test"]) != maxProcessesPerContainer {
+		t.Errorf("Expected %d processes, got %d", maxProcessesPerContainer, len(table.containers["test"]))
+	}
+	if _, ok := table.containers["test"][10]; ok {
+		t.Errorf("Expected the oldest process to be forgotten")
+	}
+}
@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const ResponseActionRuleName = "Response action"
+
+const (
+	// Label set on the pods by the labelPod action
+	CompromisedPodLabel = "kubecop.kubescape.io/compromised"
+	// Label set on the pods by the isolatePod action, the isolation NetworkPolicy selects the pods with it
+	IsolatedPodLabel = "kubecop.kubescape.io/isolated"
+	// NetworkPolicy denying the traffic of the isolated pods, created in their namespace by the isolatePod action
+	IsolationNetworkPolicyName = "kubecop-isolation"
+)
+
+const (
+	// Default maximum number of response actions taken per minute on the node
+	DefaultResponseActionsPerMinute = 10
+	// An action is not taken again on the same target during this time
+	responseActionCooldown = 1 * time.Minute
+)
+
+// ResponseActionFailure is a system alert, sent for every response action taken after an alert, or which
+// would have been taken in dry run mode.
+type ResponseActionFailure struct {
+	Action       string
+	DryRun       bool
+	Err          string
+	FailureEvent *tracing.GeneralEvent
+}
+
+func (failure *ResponseActionFailure) Name() string {
+	return ResponseActionRuleName
+}
+
+func (failure *ResponseActionFailure) Error() string {
+	return failure.Err
+}
+
+func (failure *ResponseActionFailure) Event() tracing.GeneralEvent {
+	return *failure.FailureEvent
+}
+
+func (failure *ResponseActionFailure) Priority() int {
+	return rule.RulePrioritySystemIssue
+}
+
+func (failure *ResponseActionFailure) FixSuggestion() string {
+	return "Check the workload the action was taken on, remove the action from the rule binding if it is not wanted"
+}
+
+func (failure *ResponseActionFailure) Fingerprint() string {
+	return failure.Action
+}
+
+func (failure *ResponseActionFailure) Evidence() rule.Evidence {
+	return rule.Evidence{rule.EvidenceAction: failure.Action}
+}
+
+// responder takes the response actions of the rule bindings on the alerts of their rules.
+type responder struct {
+	k8sClientset     ClientSetInterface
+	nodeName         string
+	dryRun           bool
+	actionsPerMinute int
+	sendAlert        func(rule.RuleFailure)
+	// Sends a signal to a process of the node, replaced in tests
+	signalProcess func(pid int, signal syscall.Signal) error
+	// Returns the mount namespace of a process of the node, replaced in tests
+	processMountNamespace func(pid int) (uint64, error)
+	mutex                 sync.Mutex
+	// Times of the actions taken during the last minute, for the rate limit
+	recentActions []time.Time
+	// Last time of each action on each target, for the cooldown
+	lastActions map[string]time.Time
+}
+
+func newResponder(k8sClientset ClientSetInterface, nodeName string, dryRun bool, actionsPerMinute int, sendAlert func(rule.RuleFailure)) *responder {
+	if actionsPerMinute <= 0 {
+		actionsPerMinute = DefaultResponseActionsPerMinute
+	}
+	return &responder{
+		k8sClientset:          k8sClientset,
+		nodeName:              nodeName,
+		dryRun:                dryRun,
+		actionsPerMinute:      actionsPerMinute,
+		sendAlert:             sendAlert,
+		signalProcess:         syscall.Kill,
+		processMountNamespace: processMountNamespace,
+		lastActions:           make(map[string]time.Time),
+	}
+}
+
+// processMountNamespace returns the inode of the mount namespace of the process, the id found in the events.
+func processMountNamespace(pid int) (uint64, error) {
+	stat := syscall.Stat_t{}
+	if err := syscall.Stat(fmt.Sprintf("/proc/%d/ns/mnt", pid), &stat); err != nil {
+		return 0, err
+	}
+	return stat.Ino, nil
+}
+
+// respond takes the actions on the alert in order, and sends an audit alert for each of them.
+func (responder *responder) respond(failure rule.RuleFailure, actions []string) {
+	event := failure.Event()
+	for _, action := range actions {
+		target, err := responder.actionTarget(action, &event)
+		if err != nil {
+			log.Errorf("Response action %s after alert of rule %s: %v\n", action, failure.Name(), err)
+			continue
+		}
+		if !responder.allow(action + "/" + target) {
+			log.Warnf("Response action %s on %s after alert of rule %s is rate limited\n", action, target, failure.Name())
+			continue
+		}
+
+		result := "taken"
+		if responder.dryRun {
+			result = "not taken, dry run"
+		} else if err := responder.takeAction(action, &event); err != nil {
+			result = fmt.Sprintf("failed: %v", err)
+		}
+		log.Infof("Response action %s on %s after alert of rule %s: %s\n", action, target, failure.Name(), result)
+		responder.sendAlert(&ResponseActionFailure{
+			Action:       action,
+			DryRun:       responder.dryRun,
+			Err:          fmt.Sprintf("Response action %s on %s after alert of rule %s (%s): %s", action, target, failure.Name(), failure.Error(), result),
+			FailureEvent: &event,
+		})
+	}
+}
+
+// actionTarget returns the description of what the action is taken on.
+func (responder *responder) actionTarget(action string, event *tracing.GeneralEvent) (string, error) {
+	switch action {
+	case rulebindingstore.ResponseActionKillProcess, rulebindingstore.ResponseActionStopProcess:
+		// the pid 1 of the node and unknown processes are never signaled
+		if event.Pid <= 1 {
+			return "", fmt.Errorf("no process to signal (pid %d)", event.Pid)
+		}
+		return fmt.Sprintf("process %s (pid %d) of pod %s/%s", event.Comm, event.Pid, event.Namespace, event.PodName), nil
+	case rulebindingstore.ResponseActionDeletePod, rulebindingstore.ResponseActionEvictPod,
+		rulebindingstore.ResponseActionLabelPod, rulebindingstore.ResponseActionIsolatePod:
+		if event.PodName == "" || event.Namespace == "" {
+			return "", fmt.Errorf("the alert has no pod")
+		}
+		return fmt.Sprintf("pod %s/%s", event.Namespace, event.PodName), nil
+	case rulebindingstore.ResponseActionCordonNode:
+		return fmt.Sprintf("node %s", responder.nodeName), nil
+	default:
+		return "", fmt.Errorf("unknown response action")
+	}
+}
+
+// allow returns true if the action on the target is within the rate limit and is not in its cooldown.
+func (responder *responder) allow(key string) bool {
+	now := time.Now()
+	responder.mutex.Lock()
+	defer responder.mutex.Unlock()
+	for len(responder.recentActions) > 0 && now.Sub(responder.recentActions[0]) >= time.Minute {
+		responder.recentActions = responder.recentActions[1:]
+	}
+	for otherKey, last := range responder.lastActions {
+		if now.Sub(last) >= responseActionCooldown {
+			delete(responder.lastActions, otherKey)
+		}
+	}
+	if _, ok := responder.lastActions[key]; ok || len(responder.recentActions) >= responder.actionsPerMinute {
+		return false
+	}
+	responder.recentActions = append(responder.recentActions, now)
+	responder.lastActions[key] = now
+	return true
+}
+
+func (responder *responder) takeAction(action string, event *tracing.GeneralEvent) error {
+	ctx := context.Background()
+	pods := responder.k8sClientset.CoreV1().Pods(event.Namespace)
+	switch action {
+	case rulebindingstore.ResponseActionKillProcess:
+		return responder.signalEventProcess(event, syscall.SIGKILL)
+	case rulebindingstore.ResponseActionStopProcess:
+		return responder.signalEventProcess(event, syscall.SIGSTOP)
+	case rulebindingstore.ResponseActionDeletePod:
+		return pods.Delete(ctx, event.PodName, metav1.DeleteOptions{})
+	case rulebindingstore.ResponseActionEvictPod:
+		return pods.EvictV1(ctx, &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: event.PodName, Namespace: event.Namespace}})
+	case rulebindingstore.ResponseActionLabelPod:
+		return responder.labelPod(ctx, event, CompromisedPodLabel)
+	case rulebindingstore.ResponseActionCordonNode:
+		_, err := responder.k8sClientset.CoreV1().Nodes().Patch(ctx, responder.nodeName, types.MergePatchType, []byte(`{"spec":{"unschedulable":true}}`), metav1.PatchOptions{})
+		return err
+	case rulebindingstore.ResponseActionIsolatePod:
+		if err := responder.ensureIsolationNetworkPolicy(ctx, event.Namespace); err != nil {
+			return err
+		}
+		return responder.labelPod(ctx, event, IsolatedPodLabel)
+	default:
+		return fmt.Errorf("unknown response action %s", action)
+	}
+}
+
+// signalEventProcess signals the process of the event, if its pid still belongs to the container of the event.
+// The process may have exited since the alert and its pid reused by another process of the node.
+func (responder *responder) signalEventProcess(event *tracing.GeneralEvent, signal syscall.Signal) error {
+	if event.MountNsID == 0 {
+		return fmt.Errorf("the alert has no mount namespace to check pid %d against", event.Pid)
+	}
+	mountNsID, err := responder.processMountNamespace(int(event.Pid))
+	if err != nil {
+		return fmt.Errorf("failed to read the mount namespace of pid %d: %w", event.Pid, err)
+	}
+	if mountNsID != event.MountNsID {
+		return fmt.Errorf("pid %d is no longer in the mount namespace %d of the alert, not signaled", event.Pid, event.MountNsID)
+	}
+	return responder.signalProcess(int(event.Pid), signal)
+}
+
+func (responder *responder) labelPod(ctx context.Context, event *tracing.GeneralEvent, label string) error {
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:"true"}}}`, label)
+	_, err := responder.k8sClientset.CoreV1().Pods(event.Namespace).Patch(ctx, event.PodName, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// ensureIsolationNetworkPolicy creates the NetworkPolicy denying all the traffic of the isolated pods of the namespace.
+func (responder *responder) ensureIsolationNetworkPolicy(ctx context.Context, namespace string) error {
+	networkPolicy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: IsolationNetworkPolicyName, Namespace: namespace},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{IsolatedPodLabel: "true"}},
+			// no ingress nor egress rule, all the traffic is denied
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		},
+	}
+	_, err := responder.k8sClientset.NetworkingV1().NetworkPolicies(namespace).Create(ctx, networkPolicy, metav1.CreateOptions{})
+	if k8serrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const minerMountNsID = 4026532000
+
+func newTestResponder(dryRun bool, actionsPerMinute int) (*responder, *fake.Clientset, *MockExporter, map[int]syscall.Signal) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "miner", Namespace: "default"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}},
+	)
+	mockExporter := &MockExporter{}
+	signals := map[int]syscall.Signal{}
+	responder := newResponder(clientset, "test-node", dryRun, actionsPerMinute, mockExporter.SendRuleAlert)
+	responder.signalProcess = func(pid int, signal syscall.Signal) error {
+		signals[pid] = signal
+		return nil
+	}
+	responder.processMountNamespace = func(pid int) (uint64, error) {
+		return minerMountNsID, nil
+	}
+	return responder, clientset, mockExporter, signals
+}
+
+func minerFailure(pid uint32) rule.RuleFailure {
+	return &rule.R1007CryptoMinersFailure{
+		RuleName: rule.R1007CryptoMinersRuleName,
+		Err:      "Possible Crypto Miner detected",
+		FailureEvent: &tracing.GeneralEvent{
+			ProcessDetails: tracing.ProcessDetails{Pid: pid, Comm: "xmrig"},
+			PodName:        "miner",
+			Namespace:      "default",
+			MountNsID:      minerMountNsID,
+		},
+	}
+}
+
+func TestResponderActions(t *testing.T) {
+	responder, clientset, mockExporter, signals := newTestResponder(false, 0)
+	ctx := context.Background()
+
+	responder.respond(minerFailure(1234), []string{
+		rulebindingstore.ResponseActionKillProcess,
+		rulebindingstore.ResponseActionLabelPod,
+		rulebindingstore.ResponseActionIsolatePod,
+		rulebindingstore.ResponseActionCordonNode,
+		rulebindingstore.ResponseActionDeletePod,
+	})
+	assert.Equal(t, syscall.SIGKILL, signals[1234])
+	_, err := clientset.NetworkingV1().NetworkPolicies("default").Get(ctx, IsolationNetworkPolicyName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	node, err := clientset.CoreV1().Nodes().Get(ctx, "test-node", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, node.Spec.Unschedulable)
+	_, err = clientset.CoreV1().Pods("default").Get(ctx, "miner", metav1.GetOptions{})
+	assert.Error(t, err)
+	// the pod was labeled before it was deleted
+	patches := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "patch" && action.GetResource().Resource == "pods" {
+			patches++
+		}
+	}
+	assert.Equal(t, 2, patches)
+
+	// an audit alert is sent for every action
+	assert.Len(t, mockExporter.Alerts, 5)
+	for _, alert := range mockExporter.Alerts {
+		assert.Equal(t, ResponseActionRuleName, alert.Name())
+		assert.Equal(t, "miner", alert.Event().PodName)
+		assert.False(t, alert.(*ResponseActionFailure).DryRun)
+	}
+
+	// the pid 1 is never signaled
+	responder.respond(minerFailure(1), []string{rulebindingstore.ResponseActionStopProcess})
+	assert.NotContains(t, signals, 1)
+	assert.Len(t, mockExporter.Alerts, 5)
+}
+
+func TestResponderReusedPid(t *testing.T) {
+	responder, _, mockExporter, signals := newTestResponder(false, 0)
+	// the process exited and its pid belongs to a process of the host
+	responder.processMountNamespace = func(pid int) (uint64, error) {
+		return 4026531841, nil
+	}
+
+	responder.respond(minerFailure(1234), []string{rulebindingstore.ResponseActionKillProcess})
+	assert.Empty(t, signals)
+	assert.Len(t, mockExporter.Alerts, 1)
+	assert.Contains(t, mockExporter.Alerts[0].Error(), "failed: pid 1234 is no longer in the mount namespace")
+
+	// nor is a process signaled when the alert has no mount namespace
+	failure := minerFailure(1235)
+	failure.(*rule.R1007CryptoMinersFailure).FailureEvent.MountNsID = 0
+	responder.respond(failure, []string{rulebindingstore.ResponseActionStopProcess})
+	assert.Empty(t, signals)
+	assert.Contains(t, mockExporter.Alerts[1].Error(), "failed: the alert has no mount namespace")
+}
+
+func TestResponderDryRun(t *testing.T) {
+	responder, clientset, mockExporter, signals := newTestResponder(true, 0)
+
+	responder.respond(minerFailure(1234), []string{rulebindingstore.ResponseActionKillProcess, rulebindingstore.ResponseActionDeletePod})
+	assert.Empty(t, signals)
+	_, err := clientset.CoreV1().Pods("default").Get(context.Background(), "miner", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, mockExporter.Alerts, 2)
+	assert.True(t, mockExporter.Alerts[0].(*ResponseActionFailure).DryRun)
+}
+
+func TestResponderRateLimit(t *testing.T) {
+	responder, _, mockExporter, signals := newTestResponder(false, 2)
+
+	// the same action on the same target is taken once during the cooldown
+	responder.respond(minerFailure(1234), []string{rulebindingstore.ResponseActionKillProcess})
+	responder.respond(minerFailure(1234), []string{rulebindingstore.ResponseActionKillProcess})
+	assert.Len(t, mockExporter.Alerts, 1)
+
+	// at most actionsPerMinute actions are taken per minute
+	responder.respond(minerFailure(1235), []string{rulebindingstore.ResponseActionKillProcess})
+	responder.respond(minerFailure(1236), []string{rulebindingstore.ResponseActionKillProcess})
+	assert.Len(t, mockExporter.Alerts, 2)
+	assert.Contains(t, signals, 1235)
+	assert.NotContains(t, signals, 1236)
+}
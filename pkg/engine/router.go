@@ -9,11 +9,17 @@ import (
 )
 
 // This function enables prefiltering of events before they are processed by the engine.
+// Events of containers which are not in the cache, or which no rule bound to the container needs, are dropped.
 func (engine *Engine) eventNeedsProcessing(containerId string, eventType tracing.EventType, event interface{}) bool {
-	// TODO - implement
-	// check if there are any rules that are bound to this event
-
-	return engine.IsContainerIDInCache(containerId)
+	requiredEventTypes, ok := getContainerRequiredEventTypes(containerId)
+	if !ok {
+		return false
+	}
+	if !requiredEventTypes.has(eventType) {
+		engine.promCollector.reportEventFiltered(eventType)
+		return false
+	}
+	return true
 }
 
 // submitEventForProcessing submits an event for processing by the engine through the event processing pool.
@@ -25,7 +31,9 @@ func (engine *Engine) submitEventForProcessing(containerId string, eventType tra
 	}
 
 	// Add the event to a working group
+	reportEventDequeued := engine.promCollector.reportEventQueued()
 	engine.eventProcessingPool.Submit(func() {
+		reportEventDequeued()
 		// Convert the event to a generic event
 		e, err := convertEventInterfaceToGenericEvent(eventType, event)
 		if err != nil {
@@ -117,6 +125,10 @@ func convertEventInterfaceToGenericEvent(eventType tracing.EventType, event inte
 
 func (engine *Engine) SendExecveEvent(event *tracing.ExecveEvent) {
 	engine.promCollector.reportEbpfEvent(tracing.ExecveEventType)
+	// The process table is kept for all the containers, even if no bound rule needs the execve events
+	if engine.IsContainerIDInCache(event.ContainerID) {
+		engine.processTable.addExec(event)
+	}
 	engine.submitEventForProcessing(event.ContainerID, tracing.ExecveEventType, event)
 }
 
@@ -140,6 +152,12 @@ func (engine *Engine) SendDnsEvent(event *tracing.DnsEvent) {
 	engine.submitEventForProcessing(event.ContainerID, tracing.DnsEventType, event)
 }
 
+// SendSyscallEvent processes the system calls of a container, the tracer reports them through the poll loop.
+func (engine *Engine) SendSyscallEvent(event *tracing.SyscallEvent) {
+	engine.promCollector.reportEbpfEvent(tracing.SyscallEventType)
+	engine.submitEventForProcessing(event.ContainerID, tracing.SyscallEventType, event)
+}
+
 func (engine *Engine) SendRandomXEvent(event *tracing.RandomXEvent) {
 	engine.promCollector.reportEbpfEvent(tracing.RandomXEventType)
 	engine.submitEventForProcessing(event.ContainerID, tracing.RandomXEventType, event)
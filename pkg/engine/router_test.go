@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEventNeedsProcessing(t *testing.T) {
+	engine := &Engine{promCollector: createPrometheusMetric()}
+	defer engine.promCollector.destroy()
+
+	boundRules := []rule.Rule{
+		rule.CreateRuleR0001UnexpectedProcessLaunched(),
+		rule.CreateRuleR0005UnexpectedDomainRequest(),
+	}
+	contEntry := containerEntry{
+		ContainerID:        "test-prefilter",
+		BoundRules:         boundRules,
+		RequiredEventTypes: newEventTypesMask(boundRules),
+	}
+	setContainerDetails(contEntry.ContainerID, contEntry, false)
+	defer deleteContainerDetails(contEntry.ContainerID)
+
+	// Test case: events needed by the bound rules are processed
+	for _, eventType := range []tracing.EventType{tracing.ExecveEventType, tracing.DnsEventType} {
+		if !engine.eventNeedsProcessing(contEntry.ContainerID, eventType, nil) {
+			t.Errorf("Expected event type %v to need processing", eventType)
+		}
+	}
+
+	// Test case: events not needed by the bound rules are filtered and counted
+	for _, eventType := range []tracing.EventType{tracing.OpenEventType, tracing.OpenEventType, tracing.NetworkEventType} {
+		if engine.eventNeedsProcessing(contEntry.ContainerID, eventType, nil) {
+			t.Errorf("Expected event type %v to be filtered", eventType)
+		}
+	}
+	if filtered := testutil.ToFloat64(engine.promCollector.filteredEventCounter.WithLabelValues("open")); filtered != 2 {
+		t.Errorf("Expected 2 filtered open events, got %v", filtered)
+	}
+	if filtered := testutil.ToFloat64(engine.promCollector.filteredEventCounter.WithLabelValues("network")); filtered != 1 {
+		t.Errorf("Expected 1 filtered network event, got %v", filtered)
+	}
+
+	// Test case: events of containers which are not in the cache are dropped
+	if engine.eventNeedsProcessing("unknown-container", tracing.ExecveEventType, nil) {
+		t.Errorf("Expected event of unknown container to be dropped")
+	}
+}
@@ -0,0 +1,46 @@
+package rule
+
+// ProcessInfo describes a process of a container, as seen by its execve events.
+type ProcessInfo struct {
+	Pid  uint32   `json:"pid"`
+	Ppid uint32   `json:"ppid"`
+	Comm string   `json:"comm,omitempty"`
+	Path string   `json:"path,omitempty"`
+	Args []string `json:"args,omitempty"`
+	// Timestamp of the execve event which started the process, 0 if the process was not seen starting
+	StartTime int64 `json:"startTime,omitempty"`
+	// Timestamp the exit of the process was noticed at, 0 while it runs
+	ExitTime int64 `json:"exitTime,omitempty"`
+}
+
+// ProcessAncestryRuleFailure is a failure with the ancestry of its process attached by the engine.
+type ProcessAncestryRuleFailure struct {
+	RuleFailure
+	// The process of the failure first, then its parent, up to the oldest known ancestor
+	Ancestry []ProcessInfo
+}
+
+// WithProcessAncestry attaches the ancestry of its process to the failure.
+func WithProcessAncestry(failure RuleFailure, ancestry []ProcessInfo) RuleFailure {
+	if len(ancestry) == 0 {
+		return failure
+	}
+	return &ProcessAncestryRuleFailure{RuleFailure: failure, Ancestry: ancestry}
+}
+
+// GetProcessAncestry returns the process ancestry attached to the failure, nil if there is none.
+func GetProcessAncestry(failure RuleFailure) []ProcessInfo {
+	for failure != nil {
+		switch wrapped := failure.(type) {
+		case *ProcessAncestryRuleFailure:
+			return wrapped.Ancestry
+		case *SuppressedRuleFailure:
+			failure = wrapped.RuleFailure
+		case *PriorityOverrideRuleFailure:
+			failure = wrapped.RuleFailure
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func TestGetProcessAncestry(t *testing.T) {
+	failure := &R1002LoadKernelModuleFailure{RuleName: R1002LoadKernelModuleRuleName, FailureEvent: &tracing.SyscallEvent{}}
+	if GetProcessAncestry(failure) != nil {
+		t.Errorf("Expected no ancestry")
+	}
+	if WithProcessAncestry(failure, nil) != failure {
+		t.Errorf("Expected an empty ancestry to leave the failure as is")
+	}
+
+	ancestry := []ProcessInfo{{Pid: 20, Ppid: 10, Path: "/bin/sh"}, {Pid: 10}}
+	withAncestry := WithProcessAncestry(failure, ancestry)
+	if withAncestry.Name() != R1002LoadKernelModuleRuleName {
+		t.Errorf("Expected the failure to keep its name, got %v", withAncestry.Name())
+	}
+
+	// the ancestry is found through the wrappers of the engine
+	wrapped := &SuppressedRuleFailure{RuleFailure: &PriorityOverrideRuleFailure{RuleFailure: withAncestry, priority: RulePriorityLow}}
+	if got := GetProcessAncestry(wrapped); len(got) != 2 || got[0].Path != "/bin/sh" {
+		t.Errorf("Expected the ancestry through the wrappers, got %+v", got)
+	}
+}
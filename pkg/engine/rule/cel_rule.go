@@ -0,0 +1,363 @@
+package rule
+
+import (
+	"fmt"
+
+	"github.com/armosec/kubecop/pkg/approfilecache"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common/types"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+// Names of the event types in the custom rules and in the "type" field of the event variable.
+var celEventTypeNames = map[string]tracing.EventType{
+	"exec":         tracing.ExecveEventType,
+	"open":         tracing.OpenEventType,
+	"capabilities": tracing.CapabilitiesEventType,
+	"dns":          tracing.DnsEventType,
+	"network":      tracing.NetworkEventType,
+	"syscall":      tracing.SyscallEventType,
+}
+
+const (
+	// Maximal cost of an expression, both estimated when the rule is compiled and tracked when it is evaluated
+	celCostLimit = 10000000
+	// Size assumed for the strings, lists and maps of the variables when estimating the cost of an expression
+	celInputSizeLimit = 4096
+)
+
+// CELRuleDefinition defines a custom rule matching the events with a CEL expression.
+type CELRuleDefinition struct {
+	ID          string
+	Name        string
+	Description string
+	Priority    int
+	Tags        []string
+	Mitre       MitreAttack
+	// Event types the rule is evaluated on: exec, open, capabilities, dns, network, syscall
+	EventTypes []string
+	// Boolean CEL expression over the event, pod and params variables, the rule fails when it is true
+	Expression string
+	// Optional CEL expression building the alert message as a string
+	Message       string
+	FixSuggestion string
+}
+
+// celRuleProgram is the compiled form of a CEL rule definition, it is shared by all the instances of the rule.
+type celRuleProgram struct {
+	definition CELRuleDefinition
+	eventTypes []tracing.EventType
+	condition  cel.Program
+	message    cel.Program
+}
+
+type CELRule struct {
+	BaseRule
+	program *celRuleProgram
+}
+
+type CELRuleFailure struct {
+	RuleName         string
+	RulePriority     int
+	Err              string
+	FixSuggestionMsg string
+	FailureEvent     tracing.GeneralEvent
+	// Evidence of the event the expression matched
+	FailureEvidence Evidence
+}
+
+func newCELEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("event", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("pod", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("params", cel.MapType(cel.StringType, cel.DynType)),
+	)
+}
+
+// NewCELRuleDescriptor compiles the CEL expressions of the definition and returns a descriptor which can be
+// registered in the rule factory.
+func NewCELRuleDescriptor(definition CELRuleDefinition) (RuleDesciptor, error) {
+	if definition.ID == "" || definition.Name == "" {
+		return RuleDesciptor{}, fmt.Errorf("rule ID and name must be set")
+	}
+	if len(definition.EventTypes) == 0 {
+		return RuleDesciptor{}, fmt.Errorf("rule %s has no event types", definition.ID)
+	}
+	program := &celRuleProgram{definition: definition}
+	for _, eventTypeName := range definition.EventTypes {
+		eventType, ok := celEventTypeNames[eventTypeName]
+		if !ok {
+			return RuleDesciptor{}, fmt.Errorf("rule %s has an unknown event type %q", definition.ID, eventTypeName)
+		}
+		program.eventTypes = append(program.eventTypes, eventType)
+	}
+
+	env, err := newCELEnv()
+	if err != nil {
+		return RuleDesciptor{}, fmt.Errorf("failed to create CEL environment: %v", err)
+	}
+	if program.condition, err = compileCELExpression(env, definition.Expression, cel.BoolType); err != nil {
+		return RuleDesciptor{}, fmt.Errorf("rule %s has an invalid expression: %v", definition.ID, err)
+	}
+	if definition.Message != "" {
+		if program.message, err = compileCELExpression(env, definition.Message, cel.StringType); err != nil {
+			return RuleDesciptor{}, fmt.Errorf("rule %s has an invalid message: %v", definition.ID, err)
+		}
+	}
+
+	return RuleDesciptor{
+		ID:          definition.ID,
+		Name:        definition.Name,
+		Description: definition.Description,
+		Priority:    definition.Priority,
+		Tags:        definition.Tags,
+		Mitre:       definition.Mitre,
+		Requirements: RuleRequirements{
+			EventTypes:             program.eventTypes,
+			NeedApplicationProfile: false,
+		},
+		RuleCreationFunc: func() Rule {
+			return &CELRule{program: program}
+		},
+	}, nil
+}
+
+func compileCELExpression(env *cel.Env, expression string, outputType *cel.Type) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !ast.OutputType().IsExactType(outputType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression returns %v instead of %v", ast.OutputType(), outputType)
+	}
+	cost, err := env.EstimateCost(ast, celCostEstimator{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate the cost of the expression: %v", err)
+	}
+	if cost.Max > celCostLimit {
+		return nil, fmt.Errorf("expression has an estimated cost of %d, above the limit of %d", cost.Max, celCostLimit)
+	}
+	return env.Program(ast, cel.CostLimit(celCostLimit))
+}
+
+// celCostEstimator bounds the size of the strings, lists and maps of unknown size, which are all read from the
+// variables, without it the estimated cost of any expression iterating over them is unbounded.
+type celCostEstimator struct{}
+
+func (celCostEstimator) EstimateSize(element checker.AstNode) *checker.SizeEstimate {
+	return &checker.SizeEstimate{Min: 0, Max: celInputSizeLimit}
+}
+
+func (celCostEstimator) EstimateCallCost(function, overloadID string, target *checker.AstNode, args []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
+
+func (rule *CELRule) Name() string {
+	return rule.program.definition.Name
+}
+
+func (rule *CELRule) DeleteRule() {
+}
+
+func (rule *CELRule) ProcessEvent(eventType tracing.EventType, event interface{}, appProfileAccess approfilecache.SingleApplicationProfileAccess, engineAccess EngineAccess) RuleFailure {
+	if !rule.needsEventType(eventType) {
+		return nil
+	}
+	eventVariable, generalEvent, ok := celEventVariable(eventType, event)
+	if !ok {
+		return nil
+	}
+	variables := map[string]interface{}{
+		"event":  eventVariable,
+		"pod":    celPodVariable(generalEvent, engineAccess),
+		"params": rule.GetParameters(),
+	}
+
+	matched, _, err := rule.program.condition.Eval(variables)
+	if err != nil {
+		log.Debugf("Failed to evaluate the expression of rule %s: %v\n", rule.Name(), err)
+		return nil
+	}
+	if matched != types.True {
+		return nil
+	}
+
+	message := fmt.Sprintf("%s: process %s", rule.Name(), generalEvent.Comm)
+	if rule.program.message != nil {
+		if value, _, err := rule.program.message.Eval(variables); err != nil {
+			log.Debugf("Failed to evaluate the message of rule %s: %v\n", rule.Name(), err)
+		} else if text, ok := value.Value().(string); ok {
+			message = text
+		}
+	}
+	fixSuggestion := rule.program.definition.FixSuggestion
+	if fixSuggestion == "" {
+		fixSuggestion = "If this is a legitimate action, please consider removing this workload from the binding of this rule."
+	}
+
+	return &CELRuleFailure{
+		RuleName:         rule.Name(),
+		RulePriority:     rule.program.definition.Priority,
+		Err:              message,
+		FixSuggestionMsg: fixSuggestion,
+		FailureEvent:     *generalEvent,
+		FailureEvidence:  eventEvidence(event),
+	}
+}
+
+func (rule *CELRule) needsEventType(eventType tracing.EventType) bool {
+	for _, neededEventType := range rule.program.eventTypes {
+		if neededEventType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *CELRule) Requirements() RuleRequirements {
+	return RuleRequirements{
+		EventTypes:             rule.program.eventTypes,
+		NeedApplicationProfile: false,
+	}
+}
+
+// celEventVariable converts the event to the event variable of the expressions. All the fields are always set,
+// the fields which do not belong to the event type have their zero value.
+func celEventVariable(eventType tracing.EventType, event interface{}) (map[string]interface{}, *tracing.GeneralEvent, bool) {
+	variable := map[string]interface{}{
+		"path":        "",
+		"args":        []string{},
+		"flags":       []string{},
+		"capability":  "",
+		"syscall":     "",
+		"syscalls":    []string{},
+		"dnsName":     "",
+		"addresses":   []string{},
+		"packetType":  "",
+		"protocol":    "",
+		"port":        int64(0),
+		"dstEndpoint": "",
+	}
+	var generalEvent *tracing.GeneralEvent
+	switch typedEvent := event.(type) {
+	case *tracing.ExecveEvent:
+		generalEvent = &typedEvent.GeneralEvent
+		variable["path"] = typedEvent.PathName
+		variable["args"] = nonNilStrings(typedEvent.Args)
+	case *tracing.OpenEvent:
+		generalEvent = &typedEvent.GeneralEvent
+		variable["path"] = typedEvent.PathName
+		variable["flags"] = nonNilStrings(typedEvent.Flags)
+	case *tracing.CapabilitiesEvent:
+		generalEvent = &typedEvent.GeneralEvent
+		variable["capability"] = typedEvent.CapabilityName
+		variable["syscall"] = typedEvent.Syscall
+	case *tracing.DnsEvent:
+		generalEvent = &typedEvent.GeneralEvent
+		variable["dnsName"] = typedEvent.DnsName
+		variable["addresses"] = nonNilStrings(typedEvent.Addresses)
+	case *tracing.NetworkEvent:
+		generalEvent = &typedEvent.GeneralEvent
+		variable["packetType"] = typedEvent.PacketType
+		variable["protocol"] = typedEvent.Protocol
+		variable["port"] = int64(typedEvent.Port)
+		variable["dstEndpoint"] = typedEvent.DstEndpoint
+	case *tracing.SyscallEvent:
+		generalEvent = &typedEvent.GeneralEvent
+		variable["syscalls"] = nonNilStrings(typedEvent.Syscalls)
+	default:
+		return nil, nil, false
+	}
+
+	for name, celEventType := range celEventTypeNames {
+		if celEventType == eventType {
+			variable["type"] = name
+		}
+	}
+	variable["pid"] = int64(generalEvent.Pid)
+	variable["ppid"] = int64(generalEvent.Ppid)
+	variable["comm"] = generalEvent.Comm
+	variable["cwd"] = generalEvent.Cwd
+	variable["uid"] = int64(generalEvent.Uid)
+	variable["gid"] = int64(generalEvent.Gid)
+	variable["timestamp"] = generalEvent.Timestamp
+	return variable, generalEvent, true
+}
+
+// celPodVariable returns the pod metadata of the event, the pod spec fields are empty if the spec is not available.
+func celPodVariable(event *tracing.GeneralEvent, engineAccess EngineAccess) map[string]interface{} {
+	variable := map[string]interface{}{
+		"name":               event.PodName,
+		"namespace":          event.Namespace,
+		"containerName":      event.ContainerName,
+		"containerID":        event.ContainerID,
+		"image":              "",
+		"serviceAccountName": "",
+		"nodeName":           "",
+		"hostNetwork":        false,
+		"hostPID":            false,
+		"hostIPC":            false,
+		"privileged":         false,
+	}
+	if engineAccess == nil {
+		return variable
+	}
+	podSpec, err := engineAccess.GetPodSpec(event.PodName, event.Namespace, event.ContainerID)
+	if err != nil || podSpec == nil {
+		return variable
+	}
+	variable["serviceAccountName"] = podSpec.ServiceAccountName
+	variable["nodeName"] = podSpec.NodeName
+	variable["hostNetwork"] = podSpec.HostNetwork
+	variable["hostPID"] = podSpec.HostPID
+	variable["hostIPC"] = podSpec.HostIPC
+	for _, container := range append(podSpec.InitContainers, podSpec.Containers...) {
+		if container.Name != event.ContainerName {
+			continue
+		}
+		variable["image"] = container.Image
+		if container.SecurityContext != nil && container.SecurityContext.Privileged != nil {
+			variable["privileged"] = *container.SecurityContext.Privileged
+		}
+	}
+	return variable
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func (rule *CELRuleFailure) Name() string {
+	return rule.RuleName
+}
+
+func (rule *CELRuleFailure) Error() string {
+	return rule.Err
+}
+
+func (rule *CELRuleFailure) Event() tracing.GeneralEvent {
+	return rule.FailureEvent
+}
+
+// The message is built from the event, so it tells the repeated failures apart.
+func (rule *CELRuleFailure) Fingerprint() string {
+	return rule.Err
+}
+
+func (rule *CELRuleFailure) Evidence() Evidence {
+	return rule.FailureEvidence
+}
+
+func (rule *CELRuleFailure) Priority() int {
+	return rule.RulePriority
+}
+
+func (rule *CELRuleFailure) FixSuggestion() string {
+	return rule.FixSuggestionMsg
+}
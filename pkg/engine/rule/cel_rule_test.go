@@ -0,0 +1,113 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func TestCELRule(t *testing.T) {
+	descriptor, err := NewCELRuleDescriptor(CELRuleDefinition{
+		ID:         "C0001",
+		Name:       "Shell in image",
+		Priority:   RulePriorityHigh,
+		Tags:       []string{"custom", "exec"},
+		EventTypes: []string{"exec"},
+		Expression: `event.path in ["/bin/sh", "/bin/bash"] && pod.image == "test" && !(has(params.allowed) && event.comm in params.allowed)`,
+		Message:    `"Shell " + event.path + " executed in " + pod.namespace + "/" + pod.name`,
+	})
+	if err != nil {
+		t.Fatalf("Expected the rule to compile, got %v", err)
+	}
+	if len(descriptor.Requirements.EventTypes) != 1 || descriptor.Requirements.EventTypes[0] != tracing.ExecveEventType {
+		t.Errorf("Expected the rule to need exec events, got %v", descriptor.Requirements.EventTypes)
+	}
+	r := descriptor.RuleCreationFunc()
+
+	e := &tracing.ExecveEvent{
+		GeneralEvent: tracing.GeneralEvent{
+			ProcessDetails: tracing.ProcessDetails{Pid: 10, Comm: "sh"},
+			ContainerID:    "test",
+			ContainerName:  "test",
+			PodName:        "test-pod",
+			Namespace:      "default",
+		},
+		PathName: "/bin/ls",
+	}
+	// Test case: the expression does not match
+	if ruleResult := r.ProcessEvent(tracing.ExecveEventType, e, nil, &EngineAccessMock{}); ruleResult != nil {
+		t.Errorf("Expected ruleResult to be nil since /bin/ls is not a shell, got %v", ruleResult)
+	}
+
+	// Test case: the expression matches
+	e.PathName = "/bin/sh"
+	ruleResult := r.ProcessEvent(tracing.ExecveEventType, e, nil, &EngineAccessMock{})
+	if ruleResult == nil {
+		t.Fatalf("Expected ruleResult to be a failure since /bin/sh is a shell")
+	}
+	if ruleResult.Error() != "Shell /bin/sh executed in default/test-pod" {
+		t.Errorf("Unexpected message %q", ruleResult.Error())
+	}
+	if ruleResult.Priority() != RulePriorityHigh || ruleResult.Name() != "Shell in image" || ruleResult.Event().Pid != 10 {
+		t.Errorf("Unexpected failure %+v", ruleResult)
+	}
+
+	// Test case: the parameters of the binding are available
+	r.SetParameters(map[string]interface{}{"allowed": []interface{}{"sh"}})
+	if ruleResult := r.ProcessEvent(tracing.ExecveEventType, e, nil, &EngineAccessMock{}); ruleResult != nil {
+		t.Errorf("Expected ruleResult to be nil since sh is allowed, got %v", ruleResult)
+	}
+
+	// Test case: other event types are ignored
+	if ruleResult := r.ProcessEvent(tracing.OpenEventType, &tracing.OpenEvent{PathName: "/bin/sh"}, nil, &EngineAccessMock{}); ruleResult != nil {
+		t.Errorf("Expected ruleResult to be nil for an open event, got %v", ruleResult)
+	}
+}
+
+func TestCELRuleEventFields(t *testing.T) {
+	testCases := []struct {
+		eventType  tracing.EventType
+		eventName  string
+		event      interface{}
+		expression string
+	}{
+		{tracing.ExecveEventType, "exec", &tracing.ExecveEvent{PathName: "/usr/bin/nc", Args: []string{"nc", "-e", "/bin/sh"}}, `event.args.exists(arg, arg.endsWith("sh"))`},
+		{tracing.OpenEventType, "open", &tracing.OpenEvent{PathName: "/etc/shadow", Flags: []string{"O_RDONLY"}}, `event.path == "/etc/shadow" && "O_RDONLY" in event.flags`},
+		{tracing.NetworkEventType, "network", &tracing.NetworkEvent{Port: 4444, DstEndpoint: "1.2.3.4", Protocol: "TCP"}, `event.port == 4444 && event.dstEndpoint == "1.2.3.4" && event.protocol == "TCP"`},
+		{tracing.DnsEventType, "dns", &tracing.DnsEvent{DnsName: "pool.example.com."}, `event.dnsName.startsWith("pool.")`},
+		{tracing.CapabilitiesEventType, "capabilities", &tracing.CapabilitiesEvent{CapabilityName: "SYS_ADMIN", Syscall: "mount"}, `event.capability == "SYS_ADMIN" && event.syscall == "mount"`},
+		{tracing.SyscallEventType, "syscall", &tracing.SyscallEvent{Syscalls: []string{"ptrace"}}, `"ptrace" in event.syscalls && event.type == "syscall"`},
+	}
+	for _, testCase := range testCases {
+		descriptor, err := NewCELRuleDescriptor(CELRuleDefinition{
+			ID:         "C0002",
+			Name:       "Custom " + testCase.eventName,
+			EventTypes: []string{testCase.eventName},
+			Expression: testCase.expression,
+		})
+		if err != nil {
+			t.Fatalf("Expected the %s rule to compile, got %v", testCase.eventName, err)
+		}
+		if ruleResult := descriptor.RuleCreationFunc().ProcessEvent(testCase.eventType, testCase.event, nil, nil); ruleResult == nil {
+			t.Errorf("Expected the %s rule to fail", testCase.eventName)
+		}
+	}
+}
+
+func TestNewCELRuleDescriptorErrors(t *testing.T) {
+	testCases := []CELRuleDefinition{
+		{ID: "C0003", Name: "No event types", Expression: "true"},
+		{ID: "C0003", Name: "Unknown event type", EventTypes: []string{"randomx"}, Expression: "true"},
+		{ID: "C0003", Name: "Syntax error", EventTypes: []string{"exec"}, Expression: "event.path =="},
+		{ID: "C0003", Name: "Not a boolean", EventTypes: []string{"exec"}, Expression: "event.pid + 1"},
+		{ID: "C0003", Name: "Unknown variable", EventTypes: []string{"exec"}, Expression: "process.pid == 1"},
+		{ID: "C0003", Name: "Message not a string", EventTypes: []string{"exec"}, Expression: "true", Message: "1 + 1"},
+		{Name: "No ID", EventTypes: []string{"exec"}, Expression: "true"},
+		{ID: "C0003", Name: "Too expensive", EventTypes: []string{"exec"}, Expression: `event.args.exists(a, event.args.exists(b, a + b == event.path))`},
+	}
+	for _, testCase := range testCases {
+		if _, err := NewCELRuleDescriptor(testCase); err == nil {
+			t.Errorf("Expected an error for %q", testCase.Name)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package rule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+// CorrelationKey identifies a process of a container in the correlation state of a rule.
+type CorrelationKey struct {
+	ContainerID string
+	Pid         uint32
+}
+
+// NewCorrelationKey returns the key of the process of the event.
+func NewCorrelationKey(event *tracing.GeneralEvent) CorrelationKey {
+	return CorrelationKey{ContainerID: event.ContainerID, Pid: event.Pid}
+}
+
+type correlationEntry[T any] struct {
+	value     T
+	timestamp int64
+}
+
+// CorrelationState keeps a value per process of a container for the rules correlating several events.
+// The entries expire after the TTL, measured with the timestamps of the events since the events are not processed
+// in order, and the oldest entries are evicted above the maximum number of entries.
+// Rules keeping a CorrelationState should clear it in DeleteRule, which the engine calls when the container stops.
+type CorrelationState[T any] struct {
+	mutex      sync.Mutex
+	ttl        int64
+	maxEntries int
+	containers map[string]map[uint32]correlationEntry[T]
+	size       int
+	// Latest event timestamp seen and the timestamp of the last sweep of the expired entries
+	latest    int64
+	lastSweep int64
+}
+
+func NewCorrelationState[T any](ttl time.Duration, maxEntries int) *CorrelationState[T] {
+	return &CorrelationState[T]{
+		ttl:        int64(ttl),
+		maxEntries: maxEntries,
+		containers: make(map[string]map[uint32]correlationEntry[T]),
+	}
+}
+
+// Set sets the value of the process at the timestamp of the event.
+func (state *CorrelationState[T]) Set(key CorrelationKey, value T, timestamp int64) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if timestamp > state.latest {
+		state.latest = timestamp
+	}
+	if state.latest-state.lastSweep > state.ttl {
+		state.forgetExpired()
+	}
+
+	if _, ok := state.containers[key.ContainerID][key.Pid]; !ok {
+		if state.size >= state.maxEntries {
+			state.forgetExpired()
+		}
+		if state.size >= state.maxEntries {
+			state.forgetOldest()
+		}
+		state.size++
+	}
+	processes, ok := state.containers[key.ContainerID]
+	if !ok {
+		processes = make(map[uint32]correlationEntry[T])
+		state.containers[key.ContainerID] = processes
+	}
+	processes[key.Pid] = correlationEntry[T]{value: value, timestamp: timestamp}
+}
+
+// Get returns the value of the process if it was set within the TTL of the timestamp, before or after it.
+func (state *CorrelationState[T]) Get(key CorrelationKey, timestamp int64) (T, bool) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	entry, ok := state.lookup(key, timestamp, false)
+	return entry.value, ok
+}
+
+// Take returns the value of the process like Get and removes it.
+func (state *CorrelationState[T]) Take(key CorrelationKey, timestamp int64) (T, bool) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	entry, ok := state.lookup(key, timestamp, false)
+	if ok {
+		state.delete(key)
+	}
+	return entry.value, ok
+}
+
+// Delete removes the value of the process.
+func (state *CorrelationState[T]) Delete(key CorrelationKey) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.delete(key)
+}
+
+// Clear removes all the values.
+func (state *CorrelationState[T]) Clear() {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.containers = make(map[string]map[uint32]correlationEntry[T])
+	state.size = 0
+}
+
+// Len returns the number of values kept, including the expired values which were not evicted yet.
+func (state *CorrelationState[T]) Len() int {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	return state.size
+}
+
+// lookup returns the entry of the process if it is within the TTL of the timestamp, only if it is not after the
+// timestamp when ordered is set.
+func (state *CorrelationState[T]) lookup(key CorrelationKey, timestamp int64, ordered bool) (correlationEntry[T], bool) {
+	entry, ok := state.containers[key.ContainerID][key.Pid]
+	if !ok {
+		return entry, false
+	}
+	diff := timestamp - entry.timestamp
+	if ordered && diff < 0 {
+		return entry, false
+	}
+	if diff < 0 {
+		diff = -diff
+	}
+	return entry, diff <= state.ttl
+}
+
+func (state *CorrelationState[T]) delete(key CorrelationKey) {
+	processes, ok := state.containers[key.ContainerID]
+	if !ok {
+		return
+	}
+	if _, ok := processes[key.Pid]; !ok {
+		return
+	}
+	delete(processes, key.Pid)
+	state.size--
+	if len(processes) == 0 {
+		delete(state.containers, key.ContainerID)
+	}
+}
+
+func (state *CorrelationState[T]) forgetExpired() {
+	for containerID, processes := range state.containers {
+		for pid, entry := range processes {
+			if state.latest-entry.timestamp > state.ttl {
+				delete(processes, pid)
+				state.size--
+			}
+		}
+		if len(processes) == 0 {
+			delete(state.containers, containerID)
+		}
+	}
+	state.lastSweep = state.latest
+}
+
+func (state *CorrelationState[T]) forgetOldest() {
+	var oldestKey CorrelationKey
+	var oldestTimestamp int64
+	found := false
+	for containerID, processes := range state.containers {
+		for pid, entry := range processes {
+			if !found || entry.timestamp < oldestTimestamp {
+				oldestKey, oldestTimestamp, found = CorrelationKey{ContainerID: containerID, Pid: pid}, entry.timestamp, true
+			}
+		}
+	}
+	if found {
+		state.delete(oldestKey)
+	}
+}
+
+// CorrelationSequence matches the sequences of two events of a process: a first event followed by a second event
+// within a window. The value kept for the first event is returned when the second event matches it.
+type CorrelationSequence[T any] struct {
+	state *CorrelationState[T]
+}
+
+func NewCorrelationSequence[T any](window time.Duration, maxEntries int) *CorrelationSequence[T] {
+	return &CorrelationSequence[T]{state: NewCorrelationState[T](window, maxEntries)}
+}
+
+// Start records the first event of the sequence of the process, replacing a previous first event.
+func (sequence *CorrelationSequence[T]) Start(key CorrelationKey, value T, timestamp int64) {
+	sequence.state.Set(key, value, timestamp)
+}
+
+// Match returns the value of the first event of the sequence of the process if it happened within the window before
+// the timestamp of the second event. A matched sequence is complete and is removed.
+func (sequence *CorrelationSequence[T]) Match(key CorrelationKey, timestamp int64) (T, bool) {
+	state := sequence.state
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	entry, ok := state.lookup(key, timestamp, true)
+	if ok {
+		state.delete(key)
+	}
+	return entry.value, ok
+}
+
+// Clear removes all the started sequences.
+func (sequence *CorrelationSequence[T]) Clear() {
+	sequence.state.Clear()
+}
+
+// Len returns the number of started sequences.
+func (sequence *CorrelationSequence[T]) Len() int {
+	return sequence.state.Len()
+}
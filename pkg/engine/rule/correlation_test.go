@@ -0,0 +1,93 @@
+package rule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCorrelationState(t *testing.T) {
+	second := int64(time.Second)
+	state := NewCorrelationState[string](2*time.Second, 3)
+	first := CorrelationKey{ContainerID: "a", Pid: 1}
+
+	state.Set(first, "first", 10*second)
+	// Within the TTL, before or after the value
+	for _, timestamp := range []int64{9 * second, 12 * second} {
+		if value, ok := state.Get(first, timestamp); !ok || value != "first" {
+			t.Errorf("Get at %d = %q, %v, expected first", timestamp, value, ok)
+		}
+	}
+	if _, ok := state.Get(first, 13*second); ok {
+		t.Errorf("Expected the value to be expired")
+	}
+	// Same pid in another container
+	if _, ok := state.Get(CorrelationKey{ContainerID: "b", Pid: 1}, 10*second); ok {
+		t.Errorf("Expected no value for another container")
+	}
+
+	if value, ok := state.Take(first, 10*second); !ok || value != "first" {
+		t.Errorf("Take = %q, %v, expected first", value, ok)
+	}
+	if _, ok := state.Get(first, 10*second); ok || state.Len() != 0 {
+		t.Errorf("Expected the value to be taken, %d values left", state.Len())
+	}
+
+	// The oldest value is evicted above the maximum
+	for pid := uint32(1); pid <= 4; pid++ {
+		state.Set(CorrelationKey{ContainerID: "a", Pid: pid}, "value", 20*second+int64(pid)*second/10)
+	}
+	if state.Len() != 3 {
+		t.Errorf("Expected 3 values, got %d", state.Len())
+	}
+	if _, ok := state.Get(first, 20*second); ok {
+		t.Errorf("Expected the oldest value to be evicted")
+	}
+
+	// The expired values are evicted when values are set later
+	state.Set(CorrelationKey{ContainerID: "b", Pid: 1}, "late", 60*second)
+	if state.Len() != 1 {
+		t.Errorf("Expected the expired values to be evicted, %d values left", state.Len())
+	}
+
+	state.Set(CorrelationKey{ContainerID: "a", Pid: 1}, "value", 60*second)
+	state.Delete(CorrelationKey{ContainerID: "b", Pid: 1})
+	if state.Len() != 1 {
+		t.Errorf("Expected 1 value after deleting the value, got %d", state.Len())
+	}
+	state.Clear()
+	if state.Len() != 0 {
+		t.Errorf("Expected no values after clear, got %d", state.Len())
+	}
+}
+
+func TestCorrelationSequence(t *testing.T) {
+	second := int64(time.Second)
+	sequence := NewCorrelationSequence[string](2*time.Second, 10)
+	key := CorrelationKey{ContainerID: "a", Pid: 1}
+
+	sequence.Start(key, "open", 10*second)
+	// The second event must follow the first one
+	if _, ok := sequence.Match(key, 9*second); ok {
+		t.Errorf("Expected no match before the first event")
+	}
+	// Another process
+	if _, ok := sequence.Match(CorrelationKey{ContainerID: "a", Pid: 2}, 11*second); ok {
+		t.Errorf("Expected no match for another process")
+	}
+	if value, ok := sequence.Match(key, 11*second); !ok || value != "open" {
+		t.Errorf("Match = %q, %v, expected open", value, ok)
+	}
+	// A matched sequence is complete
+	if _, ok := sequence.Match(key, 11*second); ok {
+		t.Errorf("Expected the sequence to be complete")
+	}
+
+	sequence.Start(key, "open", 10*second)
+	if _, ok := sequence.Match(key, 13*second); ok {
+		t.Errorf("Expected no match out of the window")
+	}
+	sequence.Clear()
+	if sequence.Len() != 0 {
+		t.Errorf("Expected no sequences after clear, got %d", sequence.Len())
+	}
+}
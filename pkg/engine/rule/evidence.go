@@ -0,0 +1,88 @@
+package rule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+// Keys of the evidence of the failures
+const (
+	// Path of the executed or opened file
+	EvidencePath = "path"
+	// Arguments of the executed file
+	EvidenceArgs = "args"
+	// Flags of the opened file
+	EvidenceFlags = "flags"
+	// Requested domain name and its resolved addresses
+	EvidenceDomain    = "domain"
+	EvidenceAddresses = "addresses"
+	// Used capability and the system call using it
+	EvidenceCapability = "capability"
+	EvidenceSyscall    = "syscall"
+	// System calls of the container
+	EvidenceSyscalls = "syscalls"
+	// Remote endpoint of a connection
+	EvidenceDstEndpoint = "dstEndpoint"
+	EvidencePort        = "port"
+	EvidenceProtocol    = "protocol"
+	EvidencePacketType  = "packetType"
+	// Response action taken after an alert
+	EvidenceAction = "action"
+)
+
+// EvidenceKeys lists the keys of the evidence in a stable order, for the exporters with fixed fields.
+var EvidenceKeys = []string{
+	EvidencePath, EvidenceArgs, EvidenceFlags, EvidenceDomain, EvidenceAddresses, EvidenceCapability, EvidenceSyscall,
+	EvidenceSyscalls, EvidenceDstEndpoint, EvidencePort, EvidenceProtocol, EvidencePacketType, EvidenceAction,
+}
+
+// Evidence is the structured data a failure was raised on, like the path of an opened file or a requested domain.
+// The values are strings, lists of strings or numbers.
+type Evidence map[string]interface{}
+
+// Keys returns the keys of the evidence, sorted.
+func (evidence Evidence) Keys() []string {
+	keys := make([]string, 0, len(evidence))
+	for key := range evidence {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String returns the value of the key as a string, the lists are joined with commas.
+func (evidence Evidence) String(key string) string {
+	switch value := evidence[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []string:
+		return strings.Join(value, ",")
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// eventEvidence returns the evidence of the fields of the event, it is empty for the events without specific fields.
+func eventEvidence(event interface{}) Evidence {
+	switch event := event.(type) {
+	case *tracing.ExecveEvent:
+		return Evidence{EvidencePath: event.PathName, EvidenceArgs: event.Args}
+	case *tracing.OpenEvent:
+		return Evidence{EvidencePath: event.PathName, EvidenceFlags: event.Flags}
+	case *tracing.CapabilitiesEvent:
+		return Evidence{EvidenceCapability: event.CapabilityName, EvidenceSyscall: event.Syscall}
+	case *tracing.DnsEvent:
+		return Evidence{EvidenceDomain: event.DnsName, EvidenceAddresses: event.Addresses}
+	case *tracing.NetworkEvent:
+		return Evidence{EvidenceDstEndpoint: event.DstEndpoint, EvidencePort: event.Port, EvidenceProtocol: event.Protocol, EvidencePacketType: event.PacketType}
+	case *tracing.SyscallEvent:
+		return Evidence{EvidenceSyscalls: event.Syscalls}
+	default:
+		return Evidence{}
+	}
+}
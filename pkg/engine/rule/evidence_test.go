@@ -0,0 +1,83 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func TestEventEvidence(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    interface{}
+		expected Evidence
+	}{
+		{
+			name:     "exec",
+			event:    &tracing.ExecveEvent{PathName: "/bin/sh", Args: []string{"sh", "-c", "id"}},
+			expected: Evidence{EvidencePath: "/bin/sh", EvidenceArgs: []string{"sh", "-c", "id"}},
+		},
+		{
+			name:     "open",
+			event:    &tracing.OpenEvent{PathName: "/etc/shadow", Flags: []string{"O_RDONLY"}},
+			expected: Evidence{EvidencePath: "/etc/shadow", EvidenceFlags: []string{"O_RDONLY"}},
+		},
+		{
+			name:     "capabilities",
+			event:    &tracing.CapabilitiesEvent{CapabilityName: "SYS_ADMIN", Syscall: "mount"},
+			expected: Evidence{EvidenceCapability: "SYS_ADMIN", EvidenceSyscall: "mount"},
+		},
+		{
+			name:     "dns",
+			event:    &tracing.DnsEvent{DnsName: "example.com", Addresses: []string{"1.2.3.4"}},
+			expected: Evidence{EvidenceDomain: "example.com", EvidenceAddresses: []string{"1.2.3.4"}},
+		},
+		{
+			name:     "network",
+			event:    &tracing.NetworkEvent{DstEndpoint: "1.2.3.4", Port: 4444, Protocol: "TCP", PacketType: "OUTGOING"},
+			expected: Evidence{EvidenceDstEndpoint: "1.2.3.4", EvidencePort: uint16(4444), EvidenceProtocol: "TCP", EvidencePacketType: "OUTGOING"},
+		},
+		{
+			name:     "no specific fields",
+			event:    &tracing.RandomXEvent{},
+			expected: Evidence{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if evidence := eventEvidence(test.event); !reflect.DeepEqual(evidence, test.expected) {
+				t.Errorf("Expected evidence %v, got %v", test.expected, evidence)
+			}
+		})
+	}
+}
+
+func TestEvidenceString(t *testing.T) {
+	evidence := Evidence{EvidencePath: "/bin/sh", EvidenceArgs: []string{"sh", "-c", "id"}, EvidencePort: uint16(22)}
+	if value := evidence.String(EvidencePath); value != "/bin/sh" {
+		t.Errorf("Expected /bin/sh, got %s", value)
+	}
+	if value := evidence.String(EvidenceArgs); value != "sh,-c,id" {
+		t.Errorf("Expected sh,-c,id, got %s", value)
+	}
+	if value := evidence.String(EvidencePort); value != "22" {
+		t.Errorf("Expected 22, got %s", value)
+	}
+	if value := evidence.String(EvidenceDomain); value != "" {
+		t.Errorf("Expected no domain, got %s", value)
+	}
+	if keys := evidence.Keys(); !reflect.DeepEqual(keys, []string{EvidenceArgs, EvidencePath, EvidencePort}) {
+		t.Errorf("Expected sorted keys, got %v", keys)
+	}
+}
+
+func TestR0003UnexpectedSystemCallEvidence(t *testing.T) {
+	failure := &R0003UnexpectedSystemCallFailure{
+		FailureEvent:       &tracing.SyscallEvent{Syscalls: []string{"read", "unshare"}},
+		UnexpectedSyscalls: []string{"unshare"},
+	}
+	if syscalls := failure.Evidence().String(EvidenceSyscalls); syscalls != "unshare" {
+		t.Errorf("Expected the unexpected system calls only, got %s", syscalls)
+	}
+}
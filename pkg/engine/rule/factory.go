@@ -1,5 +1,10 @@
 package rule
 
+import (
+	"fmt"
+	"sync"
+)
+
 // List of all rules descriptions.
 var ruleDescriptions []RuleDesciptor = []RuleDesciptor{
 	R0001UnexpectedProcessLaunchedRuleDescriptor,
@@ -16,15 +21,95 @@ var ruleDescriptions []RuleDesciptor = []RuleDesciptor{
 	R1004ExecFromMountRuleDescriptor,
 	R1006UnshareSyscallRuleDescriptor,
 	R1007CryptoMinersRuleDescriptor,
+	R1008ReverseShellRuleDescriptor,
 }
 
-func GetAllRuleDescriptors() []RuleDesciptor {
+// Rules registered at runtime, like the custom rules defined in RuntimeRule objects.
+var (
+	registeredRuleDescriptionsLock sync.RWMutex
+	registeredRuleDescriptions     []RuleDesciptor
+)
+
+// GetBuiltinRuleDescriptors returns the descriptors of the rules compiled into kubecop.
+func GetBuiltinRuleDescriptors() []RuleDesciptor {
 	return ruleDescriptions
 }
 
+// GetAllRuleDescriptors returns the descriptors of the builtin rules followed by the rules registered at runtime.
+func GetAllRuleDescriptors() []RuleDesciptor {
+	registeredRuleDescriptionsLock.RLock()
+	defer registeredRuleDescriptionsLock.RUnlock()
+	allRuleDescriptions := make([]RuleDesciptor, 0, len(ruleDescriptions)+len(registeredRuleDescriptions))
+	allRuleDescriptions = append(allRuleDescriptions, ruleDescriptions...)
+	return append(allRuleDescriptions, registeredRuleDescriptions...)
+}
+
+// RegisterRuleDescriptor adds a rule to the factory, replacing the registered rule with the same ID.
+// The ID and the name of the rule must not be used by a builtin rule or by another registered rule.
+func RegisterRuleDescriptor(descriptor RuleDesciptor) error {
+	if descriptor.ID == "" || descriptor.Name == "" {
+		return fmt.Errorf("rule ID and name must be set")
+	}
+	if descriptor.RuleCreationFunc == nil {
+		return fmt.Errorf("rule %s has no creation function", descriptor.ID)
+	}
+	for _, ruleDesc := range ruleDescriptions {
+		if ruleDesc.ID == descriptor.ID || ruleDesc.Name == descriptor.Name {
+			return fmt.Errorf("rule %s (%s) conflicts with builtin rule %s (%s)", descriptor.ID, descriptor.Name, ruleDesc.ID, ruleDesc.Name)
+		}
+	}
+
+	registeredRuleDescriptionsLock.Lock()
+	defer registeredRuleDescriptionsLock.Unlock()
+	index := -1
+	for i, ruleDesc := range registeredRuleDescriptions {
+		if ruleDesc.ID == descriptor.ID {
+			index = i
+		} else if ruleDesc.Name == descriptor.Name {
+			return fmt.Errorf("rule %s (%s) conflicts with registered rule %s (%s)", descriptor.ID, descriptor.Name, ruleDesc.ID, ruleDesc.Name)
+		}
+	}
+	if index >= 0 {
+		registeredRuleDescriptions[index] = descriptor
+	} else {
+		registeredRuleDescriptions = append(registeredRuleDescriptions, descriptor)
+	}
+	return nil
+}
+
+// UnregisterRuleDescriptor removes a rule registered at runtime, it returns false if the rule is not registered.
+func UnregisterRuleDescriptor(id string) bool {
+	registeredRuleDescriptionsLock.Lock()
+	defer registeredRuleDescriptionsLock.Unlock()
+	for i, ruleDesc := range registeredRuleDescriptions {
+		if ruleDesc.ID == id {
+			registeredRuleDescriptions = append(registeredRuleDescriptions[:i:i], registeredRuleDescriptions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetRuleIDByName returns the ID of the rule, it is empty if the rule is not in the factory.
+func GetRuleIDByName(name string) string {
+	for _, rule := range ruleDescriptions {
+		if rule.Name == name {
+			return rule.ID
+		}
+	}
+	registeredRuleDescriptionsLock.RLock()
+	defer registeredRuleDescriptionsLock.RUnlock()
+	for _, rule := range registeredRuleDescriptions {
+		if rule.Name == name {
+			return rule.ID
+		}
+	}
+	return ""
+}
+
 func CreateRulesByTags(tags []string) []Rule {
 	var rules []Rule
-	for _, rule := range ruleDescriptions {
+	for _, rule := range GetAllRuleDescriptors() {
 		if rule.HasTags(tags) {
 			rules = append(rules, rule.RuleCreationFunc())
 		}
@@ -32,8 +117,19 @@ func CreateRulesByTags(tags []string) []Rule {
 	return rules
 }
 
+// CreateRulesByMitre creates the rules mapped to one of the MITRE ATT&CK tactics or techniques.
+func CreateRulesByMitre(tactics []string, techniques []string) []Rule {
+	var rules []Rule
+	for _, rule := range GetAllRuleDescriptors() {
+		if rule.Mitre.Matches(tactics, techniques) {
+			rules = append(rules, rule.RuleCreationFunc())
+		}
+	}
+	return rules
+}
+
 func CreateRuleByID(id string) Rule {
-	for _, rule := range ruleDescriptions {
+	for _, rule := range GetAllRuleDescriptors() {
 		if rule.ID == id {
 			return rule.RuleCreationFunc()
 		}
@@ -42,7 +138,7 @@ func CreateRuleByID(id string) Rule {
 }
 
 func CreateRuleByName(name string) Rule {
-	for _, rule := range ruleDescriptions {
+	for _, rule := range GetAllRuleDescriptors() {
 		if rule.Name == name {
 			return rule.RuleCreationFunc()
 		}
@@ -52,7 +148,7 @@ func CreateRuleByName(name string) Rule {
 
 func CreateRulesByNames(names []string) []Rule {
 	var rules []Rule
-	for _, rule := range ruleDescriptions {
+	for _, rule := range GetAllRuleDescriptors() {
 		for _, name := range names {
 			if rule.Name == name {
 				rules = append(rules, rule.RuleCreationFunc())
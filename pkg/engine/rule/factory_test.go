@@ -54,3 +54,58 @@ func TestCreateRuleByID(t *testing.T) {
 		t.Errorf("Expected rule to be nil")
 	}
 }
+
+// Test RegisterRuleDescriptor and UnregisterRuleDescriptor
+func TestRegisterRuleDescriptor(t *testing.T) {
+	descriptor, err := NewCELRuleDescriptor(CELRuleDefinition{
+		ID:         "C1000",
+		Name:       "Custom rule",
+		Tags:       []string{"custom-tag"},
+		EventTypes: []string{"exec"},
+		Expression: "true",
+	})
+	if err != nil {
+		t.Fatalf("Expected the rule to compile, got %v", err)
+	}
+	if err := RegisterRuleDescriptor(descriptor); err != nil {
+		t.Fatalf("Expected the rule to be registered, got %v", err)
+	}
+	defer UnregisterRuleDescriptor("C1000")
+
+	if CreateRuleByName("Custom rule") == nil || CreateRuleByID("C1000") == nil || len(CreateRulesByTags([]string{"custom-tag"})) != 1 {
+		t.Errorf("Expected the registered rule to be created by name, ID and tags")
+	}
+	if len(GetAllRuleDescriptors()) != len(GetBuiltinRuleDescriptors())+1 {
+		t.Errorf("Expected the registered rule to be listed")
+	}
+
+	// Test case: registering the same ID again replaces the rule
+	descriptor.Name = "Custom rule renamed"
+	if err := RegisterRuleDescriptor(descriptor); err != nil {
+		t.Errorf("Expected the rule to be replaced, got %v", err)
+	}
+	if CreateRuleByName("Custom rule") != nil || CreateRuleByName("Custom rule renamed") == nil {
+		t.Errorf("Expected the rule to be renamed")
+	}
+	if GetRuleIDByName("Custom rule renamed") != "C1000" || GetRuleIDByName(R0001UnexpectedProcessLaunchedRuleName) != R0001ID {
+		t.Errorf("Expected the IDs of the registered and builtin rules")
+	}
+
+	// Test case: builtin and registered IDs and names cannot be reused
+	conflicting := descriptor
+	conflicting.ID = R0001ID
+	if err := RegisterRuleDescriptor(conflicting); err == nil {
+		t.Errorf("Expected an error for a builtin rule ID")
+	}
+	conflicting.ID = "C1001"
+	if err := RegisterRuleDescriptor(conflicting); err == nil {
+		t.Errorf("Expected an error for a registered rule name")
+	}
+
+	if !UnregisterRuleDescriptor("C1000") || CreateRuleByID("C1000") != nil {
+		t.Errorf("Expected the rule to be unregistered")
+	}
+	if UnregisterRuleDescriptor("C1000") {
+		t.Errorf("Expected false when unregistering an unknown rule")
+	}
+}
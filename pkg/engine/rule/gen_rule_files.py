@@ -111,6 +111,10 @@ func (rule *{rule_id}{rule_abbrev}Failure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *{rule_id}{rule_abbrev}Failure) Fingerprint() string {
+	return rule.Err
+}
+
 func (rule *{rule_id}{rule_abbrev}Failure) Priority() int {
 	return rule.RulePriority
 }
@@ -0,0 +1,65 @@
+package rule
+
+import (
+	"strings"
+)
+
+// MITRE ATT&CK tactics used by the builtin rules.
+const (
+	MitreTacticInitialAccess       = "TA0001"
+	MitreTacticExecution           = "TA0002"
+	MitreTacticPersistence         = "TA0003"
+	MitreTacticPrivilegeEscalation = "TA0004"
+	MitreTacticDefenseEvasion      = "TA0005"
+	MitreTacticCredentialAccess    = "TA0006"
+	MitreTacticDiscovery           = "TA0007"
+	MitreTacticLateralMovement     = "TA0008"
+	MitreTacticCollection          = "TA0009"
+	MitreTacticCommandAndControl   = "TA0011"
+	MitreTacticImpact              = "TA0040"
+)
+
+// MitreAttack maps a rule to the MITRE ATT&CK taxonomy.
+type MitreAttack struct {
+	// Tactic IDs, like TA0002 (Execution)
+	Tactics []string `json:"tactics,omitempty"`
+	// Technique and sub-technique IDs, like T1059 (Command and Scripting Interpreter) or T1059.004 (Unix Shell)
+	Techniques []string `json:"techniques,omitempty"`
+}
+
+// IsEmpty returns true if the rule is not mapped to MITRE ATT&CK.
+func (mitre MitreAttack) IsEmpty() bool {
+	return len(mitre.Tactics) == 0 && len(mitre.Techniques) == 0
+}
+
+// Matches returns true if the mapping has one of the tactics or one of the techniques. A technique also matches
+// its sub-techniques.
+func (mitre MitreAttack) Matches(tactics []string, techniques []string) bool {
+	for _, tactic := range tactics {
+		for _, ruleTactic := range mitre.Tactics {
+			if strings.EqualFold(tactic, ruleTactic) {
+				return true
+			}
+		}
+	}
+	for _, technique := range techniques {
+		for _, ruleTechnique := range mitre.Techniques {
+			if strings.EqualFold(technique, ruleTechnique) || (len(ruleTechnique) > len(technique) &&
+				strings.EqualFold(technique+".", ruleTechnique[:len(technique)+1])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetMitreAttack returns the MITRE ATT&CK mapping of the rule of the failure, empty if the rule is unknown.
+func GetMitreAttack(failure RuleFailure) MitreAttack {
+	name := failure.Name()
+	for _, ruleDesc := range GetAllRuleDescriptors() {
+		if ruleDesc.Name == name {
+			return ruleDesc.Mitre
+		}
+	}
+	return MitreAttack{}
+}
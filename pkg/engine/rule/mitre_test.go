@@ -0,0 +1,59 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func TestMitreAttackMatches(t *testing.T) {
+	mitre := MitreAttack{
+		Tactics:    []string{MitreTacticExecution},
+		Techniques: []string{"T1059.004", "T1071"},
+	}
+	tests := []struct {
+		name       string
+		tactics    []string
+		techniques []string
+		expected   bool
+	}{
+		{name: "tactic", tactics: []string{"TA0002"}, expected: true},
+		{name: "tactic case insensitive", tactics: []string{"ta0002"}, expected: true},
+		{name: "other tactic", tactics: []string{"TA0040"}, expected: false},
+		{name: "technique", techniques: []string{"T1071"}, expected: true},
+		{name: "technique case insensitive", techniques: []string{"t1071"}, expected: true},
+		{name: "sub-technique", techniques: []string{"T1059.004"}, expected: true},
+		{name: "parent technique selects its sub-techniques", techniques: []string{"T1059"}, expected: true},
+		{name: "sub-technique does not select its parent", techniques: []string{"T1071.004"}, expected: false},
+		{name: "technique prefix is not a parent", techniques: []string{"T105"}, expected: false},
+		{name: "longer technique ID", techniques: []string{"T10590"}, expected: false},
+		{name: "one of the techniques", tactics: []string{"TA0040"}, techniques: []string{"T1496", "T1071"}, expected: true},
+		{name: "nothing", expected: false},
+	}
+	for _, tt := range tests {
+		if got := mitre.Matches(tt.tactics, tt.techniques); got != tt.expected {
+			t.Errorf("%s: Matches(%v, %v) = %v, expected %v", tt.name, tt.tactics, tt.techniques, got, tt.expected)
+		}
+	}
+
+	// a technique must not match a longer technique ID sharing its prefix
+	if (MitreAttack{Techniques: []string{"T10590"}}).Matches(nil, []string{"T1059"}) {
+		t.Errorf("Expected T1059 not to match T10590")
+	}
+	if !(MitreAttack{}).IsEmpty() || mitre.IsEmpty() {
+		t.Errorf("Expected only the mapping without tactics and techniques to be empty")
+	}
+}
+
+func TestGetMitreAttack(t *testing.T) {
+	failure := &R1002LoadKernelModuleFailure{RuleName: R1002LoadKernelModuleRuleName, FailureEvent: &tracing.SyscallEvent{}}
+	mitre := GetMitreAttack(&SuppressedRuleFailure{RuleFailure: failure})
+	if len(mitre.Techniques) != 1 || mitre.Techniques[0] != "T1547.006" {
+		t.Errorf("Expected the MITRE ATT&CK mapping of the rule, got %v", mitre)
+	}
+
+	failure.RuleName = "Unknown rule"
+	if !GetMitreAttack(failure).IsEmpty() {
+		t.Errorf("Expected no MITRE ATT&CK mapping for an unknown rule")
+	}
+}
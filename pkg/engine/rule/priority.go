@@ -0,0 +1,65 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/armosec/kubecop/pkg/approfilecache"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+// ParsePriority parses a severity set in a rule binding, either a name (none, low, medium, high, critical)
+// or a number between RulePriorityNone and RulePriorityCritical.
+func ParsePriority(severity string) (int, error) {
+	switch strings.ToLower(severity) {
+	case "none":
+		return RulePriorityNone, nil
+	case "low":
+		return RulePriorityLow, nil
+	case "medium":
+		return RulePriorityMed, nil
+	case "high":
+		return RulePriorityHigh, nil
+	case "critical":
+		return RulePriorityCritical, nil
+	}
+	priority, err := strconv.Atoi(severity)
+	if err != nil || priority < RulePriorityNone || priority > RulePriorityCritical {
+		return 0, fmt.Errorf("invalid severity %q, must be none, low, medium, high, critical or a number between %d and %d", severity, RulePriorityNone, RulePriorityCritical)
+	}
+	return priority, nil
+}
+
+// PriorityOverrideRule reports the failures of the wrapped rule with the priority set in the rule binding.
+type PriorityOverrideRule struct {
+	Rule
+	priority int
+}
+
+func NewPriorityOverrideRule(rule Rule, priority int) *PriorityOverrideRule {
+	return &PriorityOverrideRule{Rule: rule, priority: priority}
+}
+
+// Priority returns the priority set in the rule binding.
+func (rule *PriorityOverrideRule) Priority() int {
+	return rule.priority
+}
+
+func (rule *PriorityOverrideRule) ProcessEvent(eventType tracing.EventType, event interface{}, appProfileAccess approfilecache.SingleApplicationProfileAccess, engineAccess EngineAccess) RuleFailure {
+	ruleFailure := rule.Rule.ProcessEvent(eventType, event, appProfileAccess, engineAccess)
+	if ruleFailure == nil {
+		return nil
+	}
+	return &PriorityOverrideRuleFailure{RuleFailure: ruleFailure, priority: rule.priority}
+}
+
+// PriorityOverrideRuleFailure is a failure of a rule whose priority was overridden in the rule binding.
+type PriorityOverrideRuleFailure struct {
+	RuleFailure
+	priority int
+}
+
+func (failure *PriorityOverrideRuleFailure) Priority() int {
+	return failure.priority
+}
@@ -0,0 +1,69 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		severity string
+		expected int
+		wantErr  bool
+	}{
+		{severity: "none", expected: RulePriorityNone},
+		{severity: "low", expected: RulePriorityLow},
+		{severity: "Medium", expected: RulePriorityMed},
+		{severity: "high", expected: RulePriorityHigh},
+		{severity: "critical", expected: RulePriorityCritical},
+		{severity: "3", expected: 3},
+		{severity: "11", wantErr: true},
+		{severity: "-1", wantErr: true},
+		{severity: "urgent", wantErr: true},
+	}
+	for _, tt := range tests {
+		priority, err := ParsePriority(tt.severity)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePriority(%q) error = %v, wantErr %v", tt.severity, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && priority != tt.expected {
+			t.Errorf("ParsePriority(%q) = %v, expected %v", tt.severity, priority, tt.expected)
+		}
+	}
+}
+
+func TestPriorityOverrideRule(t *testing.T) {
+	r := NewPriorityOverrideRule(CreateRuleR0001UnexpectedProcessLaunched(), RulePriorityLow)
+	if r.Name() != R0001UnexpectedProcessLaunchedRuleName {
+		t.Errorf("Expected rule name to be %v, got %v", R0001UnexpectedProcessLaunchedRuleName, r.Name())
+	}
+
+	e := &tracing.ExecveEvent{
+		GeneralEvent: tracing.GeneralEvent{
+			ContainerID: "test",
+			PodName:     "test",
+			Namespace:   "test",
+		},
+		PathName: "/test",
+	}
+
+	// Test with not whitelisted exec, the failure should have the overridden priority
+	ruleResult := r.ProcessEvent(tracing.ExecveEventType, e, &MockAppProfileAccess{}, nil)
+	if ruleResult == nil {
+		t.Fatalf("Expected ruleResult since exec is not whitelisted")
+	}
+	if ruleResult.Priority() != RulePriorityLow {
+		t.Errorf("Expected priority to be %v, got %v", RulePriorityLow, ruleResult.Priority())
+	}
+	if ruleResult.Event().ContainerID != "test" {
+		t.Errorf("Expected the failure event to be kept")
+	}
+
+	// Test with another event type, no failure
+	ruleResult = r.ProcessEvent(tracing.OpenEventType, &tracing.OpenEvent{}, &MockAppProfileAccess{}, nil)
+	if ruleResult != nil {
+		t.Errorf("Expected ruleResult to be nil, got %v", ruleResult)
+	}
+}
@@ -17,7 +17,11 @@ var R0001UnexpectedProcessLaunchedRuleDescriptor = RuleDesciptor{
 	Name:        R0001UnexpectedProcessLaunchedRuleName,
 	Description: "Detecting exec calls that are not whitelisted by application profile",
 	Tags:        []string{"exec", "whitelisted"},
-	Priority:    RulePriorityCritical,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticExecution},
+		Techniques: []string{"T1059"},
+	},
+	Priority: RulePriorityCritical,
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.ExecveEventType},
 		NeedApplicationProfile: true,
@@ -130,6 +134,14 @@ func (rule *R0001UnexpectedProcessLaunchedFailure) Event() tracing.GeneralEvent
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R0001UnexpectedProcessLaunchedFailure) Fingerprint() string {
+	return rule.FailureEvent.PathName
+}
+
+func (rule *R0001UnexpectedProcessLaunchedFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R0001UnexpectedProcessLaunchedFailure) Priority() int {
 	return rule.RulePriority
 }
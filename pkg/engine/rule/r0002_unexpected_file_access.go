@@ -21,7 +21,11 @@ var R0002UnexpectedFileAccessRuleDescriptor = RuleDesciptor{
 	Name:        R0002UnexpectedFileAccessRuleName,
 	Description: "Detecting file access that are not whitelisted by application profile. File access is defined by the combination of path and flags",
 	Tags:        []string{"open", "whitelisted"},
-	Priority:    RulePriorityMed,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticCollection},
+		Techniques: []string{"T1005"},
+	},
+	Priority: RulePriorityMed,
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.OpenEventType},
 		NeedApplicationProfile: true,
@@ -245,6 +249,14 @@ func (rule *R0002UnexpectedFileAccessFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R0002UnexpectedFileAccessFailure) Fingerprint() string {
+	return rule.FailureEvent.PathName
+}
+
+func (rule *R0002UnexpectedFileAccessFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R0002UnexpectedFileAccessFailure) Priority() int {
 	return rule.RulePriority
 }
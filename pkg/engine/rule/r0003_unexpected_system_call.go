@@ -19,7 +19,11 @@ var R0003UnexpectedSystemCallRuleDescriptor = RuleDesciptor{
 	Name:        R0003UnexpectedSystemCallRuleName,
 	Description: "Detecting unexpected system calls that are not whitelisted by application profile. Every unexpected system call will be alerted only once.",
 	Tags:        []string{"syscall", "whitelisted"},
-	Priority:    RulePriorityMed,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticExecution},
+		Techniques: []string{"T1106"},
+	},
+	Priority: RulePriorityMed,
 	Requirements: RuleRequirements{
 		EventTypes: []tracing.EventType{
 			tracing.SyscallEventType,
@@ -42,6 +46,8 @@ type R0003UnexpectedSystemCallFailure struct {
 	Err              string
 	FixSuggestionMsg string
 	FailureEvent     *tracing.SyscallEvent
+	// System calls of the event which are not in the application profile
+	UnexpectedSyscalls []string
 }
 
 func (rule *R0003UnexpectedSystemCall) Name() string {
@@ -124,11 +130,12 @@ func (rule *R0003UnexpectedSystemCall) ProcessEvent(eventType tracing.EventType,
 
 	if len(unexpectedSyscalls) > 0 {
 		return &R0003UnexpectedSystemCallFailure{
-			RuleName:         rule.Name(),
-			Err:              "Unexpected system calls: " + strings.Join(unexpectedSyscalls, ", "),
-			FixSuggestionMsg: fmt.Sprintf("If this is a valid behavior, please add the system call(s) \"%s\" to the whitelist in the application profile for the Pod \"%s\". You can use the following command: %s", strings.Join(unexpectedSyscalls, ", "), syscallEvent.PodName, rule.generatePatchCommand(syscallEvent, unexpectedSyscalls, appProfileAccess)),
-			FailureEvent:     syscallEvent,
-			RulePriority:     R0003UnexpectedSystemCallRuleDescriptor.Priority,
+			RuleName:           rule.Name(),
+			Err:                "Unexpected system calls: " + strings.Join(unexpectedSyscalls, ", "),
+			FixSuggestionMsg:   fmt.Sprintf("If this is a valid behavior, please add the system call(s) \"%s\" to the whitelist in the application profile for the Pod \"%s\". You can use the following command: %s", strings.Join(unexpectedSyscalls, ", "), syscallEvent.PodName, rule.generatePatchCommand(syscallEvent, unexpectedSyscalls, appProfileAccess)),
+			FailureEvent:       syscallEvent,
+			RulePriority:       R0003UnexpectedSystemCallRuleDescriptor.Priority,
+			UnexpectedSyscalls: unexpectedSyscalls,
 		}
 	}
 
@@ -154,6 +161,18 @@ func (rule *R0003UnexpectedSystemCallFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+// The unexpected system calls are listed in the error.
+func (rule *R0003UnexpectedSystemCallFailure) Fingerprint() string {
+	return rule.Err
+}
+
+func (rule *R0003UnexpectedSystemCallFailure) Evidence() Evidence {
+	if rule.UnexpectedSyscalls != nil {
+		return Evidence{EvidenceSyscalls: rule.UnexpectedSyscalls}
+	}
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R0003UnexpectedSystemCallFailure) Priority() int {
 	return rule.RulePriority
 }
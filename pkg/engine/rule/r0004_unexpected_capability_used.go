@@ -17,7 +17,11 @@ var R0004UnexpectedCapabilityUsedRuleDescriptor = RuleDesciptor{
 	Name:        R0004UnexpectedCapabilityUsedRuleName,
 	Description: "Detecting unexpected capabilities that are not whitelisted by application profile. Every unexpected capability is identified in context of a syscall and will be alerted only once per container.",
 	Tags:        []string{"capabilities", "whitelisted"},
-	Priority:    RulePriorityHigh,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticPrivilegeEscalation},
+		Techniques: []string{"T1548"},
+	},
+	Priority: RulePriorityHigh,
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.CapabilitiesEventType},
 		NeedApplicationProfile: true,
@@ -131,6 +135,14 @@ func (rule *R0004UnexpectedCapabilityUsedFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R0004UnexpectedCapabilityUsedFailure) Fingerprint() string {
+	return rule.FailureEvent.CapabilityName + "/" + rule.FailureEvent.Syscall
+}
+
+func (rule *R0004UnexpectedCapabilityUsedFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R0004UnexpectedCapabilityUsedFailure) Priority() int {
 	return rule.RulePriority
 }
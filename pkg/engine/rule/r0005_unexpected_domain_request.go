@@ -17,7 +17,11 @@ var R0005UnexpectedDomainRequestRuleDescriptor = RuleDesciptor{
 	Name:        R0005UnexpectedDomainRequestRuleName,
 	Description: "Detecting unexpected domain requests that are not whitelisted by application profile.",
 	Tags:        []string{"dns", "whitelisted"},
-	Priority:    RulePriorityMed,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticCommandAndControl},
+		Techniques: []string{"T1071.004"},
+	},
+	Priority: RulePriorityMed,
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.DnsEventType},
 		NeedApplicationProfile: true,
@@ -131,6 +135,14 @@ func (rule *R0005UnexpectedDomainRequestFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R0005UnexpectedDomainRequestFailure) Fingerprint() string {
+	return rule.FailureEvent.DnsName
+}
+
+func (rule *R0005UnexpectedDomainRequestFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R0005UnexpectedDomainRequestFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -39,6 +39,8 @@ func TestR0005UnexpectedDomainRequest(t *testing.T) {
 	ruleResult = r.ProcessEvent(tracing.DnsEventType, e, &MockAppProfileAccess{}, nil)
 	if ruleResult == nil {
 		t.Errorf("Expected ruleResult to not be nil since domain is not whitelisted")
+	} else if domain := ruleResult.Evidence().String(EvidenceDomain); domain != "test.com" {
+		t.Errorf("Expected the evidence domain to be test.com, got %s", domain)
 	}
 
 	// Test with whitelisted domain
@@ -26,7 +26,11 @@ var R0006UnexpectedServiceAccountTokenAccessRuleDescriptor = RuleDesciptor{
 	Name:        R0006UnexpectedServiceAccountTokenAccessRuleName,
 	Description: "Detecting unexpected access to service account token.",
 	Tags:        []string{"token", "malicious", "whitelisted"},
-	Priority:    RulePriorityHigh,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticCredentialAccess},
+		Techniques: []string{"T1528"},
+	},
+	Priority: RulePriorityHigh,
 	Requirements: RuleRequirements{
 		EventTypes: []tracing.EventType{
 			tracing.OpenEventType,
@@ -156,6 +160,14 @@ func (rule *R0006UnexpectedServiceAccountTokenAccessFailure) Event() tracing.Gen
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R0006UnexpectedServiceAccountTokenAccessFailure) Fingerprint() string {
+	return rule.FailureEvent.PathName
+}
+
+func (rule *R0006UnexpectedServiceAccountTokenAccessFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R0006UnexpectedServiceAccountTokenAccessFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -42,6 +42,10 @@ var R0007KubernetesClientExecutedDescriptor = RuleDesciptor{
 	Description: "Detecting exececution of kubernetes client",
 	Priority:    RulePriorityCritical,
 	Tags:        []string{"exec", "malicious", "whitelisted"},
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticExecution, MitreTacticDiscovery},
+		Techniques: []string{"T1609", "T1613"},
+	},
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.ExecveEventType, tracing.NetworkEventType},
 		NeedApplicationProfile: true,
@@ -61,6 +65,8 @@ type R0007KubernetesClientExecutedFailure struct {
 	FixSuggestionMsg string
 	Err              string
 	FailureEvent     *tracing.GeneralEvent
+	// Evidence of the exec or network event
+	FailureEvidence Evidence
 }
 
 func (rule *R0007KubernetesClientExecuted) Name() string {
@@ -99,6 +105,7 @@ func (rule *R0007KubernetesClientExecuted) handleNetworkEvent(event *tracing.Net
 			Err:              fmt.Sprintf("Kubernetes client executed: %s", event.Comm),
 			FixSuggestionMsg: "If this is a legitimate action, please consider removing this workload from the binding of this rule.",
 			FailureEvent:     &event.GeneralEvent,
+			FailureEvidence:  eventEvidence(event),
 			RulePriority:     R0007KubernetesClientExecutedDescriptor.Priority,
 		}
 	}
@@ -125,6 +132,7 @@ func (rule *R0007KubernetesClientExecuted) handleExecEvent(event *tracing.Execve
 			Err:              fmt.Sprintf("Kubernetes client executed: %s", event.PathName),
 			FixSuggestionMsg: "If this is a legitimate action, please consider removing this workload from the binding of this rule.",
 			FailureEvent:     &event.GeneralEvent,
+			FailureEvidence:  eventEvidence(event),
 			RulePriority:     R0007KubernetesClientExecutedDescriptor.Priority,
 		}
 	}
@@ -191,6 +199,15 @@ func (rule *R0007KubernetesClientExecutedFailure) Event() tracing.GeneralEvent {
 	return *rule.FailureEvent
 }
 
+// The client is either the process name or the path of the executable, both are in the error.
+func (rule *R0007KubernetesClientExecutedFailure) Fingerprint() string {
+	return rule.Err
+}
+
+func (rule *R0007KubernetesClientExecutedFailure) Evidence() Evidence {
+	return rule.FailureEvidence
+}
+
 func (rule *R0007KubernetesClientExecutedFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -19,6 +19,10 @@ var R1000ExecFromMaliciousSourceDescriptor = RuleDesciptor{
 	Description: "Detecting exec calls that are from malicious source like: /dev/shm, /run, /var/run, /proc/self",
 	Priority:    RulePriorityCritical,
 	Tags:        []string{"exec", "signature"},
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticExecution, MitreTacticDefenseEvasion},
+		Techniques: []string{"T1036"},
+	},
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.ExecveEventType},
 		NeedApplicationProfile: false,
@@ -109,6 +113,14 @@ func (rule *R1000ExecFromMaliciousSourceFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R1000ExecFromMaliciousSourceFailure) Fingerprint() string {
+	return rule.FailureEvent.PathName
+}
+
+func (rule *R1000ExecFromMaliciousSourceFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R1000ExecFromMaliciousSourceFailure) Priority() int {
 	return rule.RulePriority
 }
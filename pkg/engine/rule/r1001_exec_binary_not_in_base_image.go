@@ -23,7 +23,11 @@ var R1001ExecBinaryNotInBaseImageRuleDescriptor = RuleDesciptor{
 	Name:        R1001ExecBinaryNotInBaseImageRuleName,
 	Description: "Detecting exec calls of binaries that are not included in the base image",
 	Tags:        []string{"exec", "malicious", "binary", "base image"},
-	Priority:    RulePriorityCritical,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticExecution, MitreTacticCommandAndControl},
+		Techniques: []string{"T1105"},
+	},
+	Priority: RulePriorityCritical,
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.ExecveEventType},
 		NeedApplicationProfile: false,
@@ -173,6 +177,14 @@ func (rule *R1001ExecBinaryNotInBaseImageFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R1001ExecBinaryNotInBaseImageFailure) Fingerprint() string {
+	return rule.FailureEvent.PathName
+}
+
+func (rule *R1001ExecBinaryNotInBaseImageFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R1001ExecBinaryNotInBaseImageFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -17,7 +17,11 @@ var R1002LoadKernelModuleRuleDescriptor = RuleDesciptor{
 	Name:        R1002LoadKernelModuleRuleName,
 	Description: "Detecting Kernel Module Load.",
 	Tags:        []string{"syscall", "kernel", "module", "load"},
-	Priority:    RulePriorityCritical,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticPersistence, MitreTacticPrivilegeEscalation},
+		Techniques: []string{"T1547.006"},
+	},
+	Priority: RulePriorityCritical,
 	Requirements: RuleRequirements{
 		EventTypes: []tracing.EventType{
 			tracing.SyscallEventType,
@@ -93,6 +97,14 @@ func (rule *R1002LoadKernelModuleFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R1002LoadKernelModuleFailure) Fingerprint() string {
+	return rule.FailureEvent.Comm
+}
+
+func (rule *R1002LoadKernelModuleFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R1002LoadKernelModuleFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -47,7 +47,11 @@ var R1003MaliciousSSHConnectionRuleDescriptor = RuleDesciptor{
 	Name:        R1003MaliciousSSHConnectionRuleName,
 	Description: "Detecting ssh connection to disallowed port",
 	Tags:        []string{"ssh", "connection", "port", "malicious"},
-	Priority:    RulePriorityHigh,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticLateralMovement},
+		Techniques: []string{"T1021.004"},
+	},
+	Priority: RulePriorityHigh,
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.OpenEventType, tracing.NetworkEventType},
 		NeedApplicationProfile: false,
@@ -57,12 +61,19 @@ var R1003MaliciousSSHConnectionRuleDescriptor = RuleDesciptor{
 	},
 }
 
+const (
+	// Maximum number of processes which accessed ssh files kept by the rule
+	R1003MaxTrackedProcesses = 256
+	// Window between the access to the ssh files and the connection. The time difference is compared in whole
+	// seconds to MaxTimeDiffInSeconds, so connections up to one second later, minus a nanosecond, still match.
+	R1003MaxTimeDiff = (MaxTimeDiffInSeconds+1)*time.Second - 1
+)
+
 type R1003MaliciousSSHConnection struct {
 	BaseRule
-	accessRelatedFiles        bool
-	sshInitiatorPid           uint32
-	configFileAccessTimeStamp int64
-	allowedPorts              []uint16
+	// Processes which accessed ssh related files, followed by their connections
+	sshFileAccesses *CorrelationSequence[string]
+	allowedPorts    []uint16
 }
 
 type R1003MaliciousSSHConnectionFailure struct {
@@ -78,10 +89,9 @@ func (rule *R1003MaliciousSSHConnection) Name() string {
 }
 
 func CreateRuleR1003MaliciousSSHConnection() *R1003MaliciousSSHConnection {
-	return &R1003MaliciousSSHConnection{accessRelatedFiles: false,
-		sshInitiatorPid:           0,
-		configFileAccessTimeStamp: 0,
-		allowedPorts:              []uint16{22},
+	return &R1003MaliciousSSHConnection{
+		sshFileAccesses: NewCorrelationSequence[string](R1003MaxTimeDiff, R1003MaxTrackedProcesses),
+		allowedPorts:    []uint16{22},
 	}
 }
 
@@ -109,6 +119,7 @@ func (rule *R1003MaliciousSSHConnection) SetParameters(params map[string]interfa
 }
 
 func (rule *R1003MaliciousSSHConnection) DeleteRule() {
+	rule.sshFileAccesses.Clear()
 }
 
 func (rule *R1003MaliciousSSHConnection) ProcessEvent(eventType tracing.EventType, event interface{}, appProfileAccess approfilecache.SingleApplicationProfileAccess, engineAccess EngineAccess) RuleFailure {
@@ -116,51 +127,28 @@ func (rule *R1003MaliciousSSHConnection) ProcessEvent(eventType tracing.EventTyp
 		return nil
 	}
 
-	if eventType == tracing.OpenEventType && !rule.accessRelatedFiles {
+	if eventType == tracing.OpenEventType {
 		openEvent, ok := event.(*tracing.OpenEvent)
-		if !ok {
-			return nil
-		} else {
-			if IsSSHConfigFile(openEvent.PathName) {
-				rule.accessRelatedFiles = true
-				rule.sshInitiatorPid = openEvent.Pid
-				rule.configFileAccessTimeStamp = openEvent.Timestamp
-			}
-
-			return nil
-		}
-	} else if eventType == tracing.NetworkEventType && rule.accessRelatedFiles {
-		networkEvent, ok := event.(*tracing.NetworkEvent)
-		if !ok {
-			return nil
-		}
-
-		timestampDiffInSeconds := calculateTimestampDiffInSeconds(networkEvent.Timestamp, rule.configFileAccessTimeStamp)
-		if timestampDiffInSeconds > MaxTimeDiffInSeconds {
-			rule.accessRelatedFiles = false
-			rule.sshInitiatorPid = 0
-			rule.configFileAccessTimeStamp = 0
-			return nil
-		}
-		if networkEvent.Pid == rule.sshInitiatorPid && networkEvent.PacketType == "OUTGOING" && networkEvent.Protocol == "TCP" && !slices.Contains(rule.allowedPorts, networkEvent.Port) {
-			rule.accessRelatedFiles = false
-			rule.sshInitiatorPid = 0
-			rule.configFileAccessTimeStamp = 0
-			return &R1003MaliciousSSHConnectionFailure{
-				RuleName:         rule.Name(),
-				Err:              fmt.Sprintf("ssh connection to port %d is not allowed", networkEvent.Port),
-				FixSuggestionMsg: "If this is a legitimate action, please add the port as a parameter to the binding of this rule",
-				FailureEvent:     networkEvent,
-				RulePriority:     R1003MaliciousSSHConnectionRuleDescriptor.Priority,
-			}
+		if ok && IsSSHConfigFile(openEvent.PathName) {
+			rule.sshFileAccesses.Start(NewCorrelationKey(&openEvent.GeneralEvent), openEvent.PathName, openEvent.Timestamp)
 		}
+		return nil
 	}
 
-	return nil
-}
-
-func calculateTimestampDiffInSeconds(timestamp1 int64, timestamp2 int64) int64 {
-	return (timestamp1 - timestamp2) / int64(time.Second)
+	networkEvent, ok := event.(*tracing.NetworkEvent)
+	if !ok || networkEvent.PacketType != "OUTGOING" || networkEvent.Protocol != "TCP" || slices.Contains(rule.allowedPorts, networkEvent.Port) {
+		return nil
+	}
+	if _, ok := rule.sshFileAccesses.Match(NewCorrelationKey(&networkEvent.GeneralEvent), networkEvent.Timestamp); !ok {
+		return nil
+	}
+	return &R1003MaliciousSSHConnectionFailure{
+		RuleName:         rule.Name(),
+		Err:              fmt.Sprintf("ssh connection to port %d is not allowed", networkEvent.Port),
+		FixSuggestionMsg: "If this is a legitimate action, please add the port as a parameter to the binding of this rule",
+		FailureEvent:     networkEvent,
+		RulePriority:     R1003MaliciousSSHConnectionRuleDescriptor.Priority,
+	}
 }
 
 func IsSSHConfigFile(path string) bool {
@@ -192,6 +180,14 @@ func (rule *R1003MaliciousSSHConnectionFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R1003MaliciousSSHConnectionFailure) Fingerprint() string {
+	return fmt.Sprintf("%s:%d", rule.FailureEvent.DstEndpoint, rule.FailureEvent.Port)
+}
+
+func (rule *R1003MaliciousSSHConnectionFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R1003MaliciousSSHConnectionFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -2,6 +2,7 @@ package rule
 
 import (
 	"testing"
+	"time"
 
 	"github.com/kubescape/kapprofiler/pkg/tracing"
 )
@@ -76,4 +77,19 @@ func TestR1003DisallowedSSHConnectionPort_ProcessEvent(t *testing.T) {
 	if failure != nil {
 		t.Errorf("Expected failure to be nil, but got %v", failure)
 	}
+
+	// Test case 6: the time diff is compared to MaxTimeDiffInSeconds in whole seconds
+	rule = CreateRuleR1003MaliciousSSHConnection()
+	openEvent.Timestamp = 0
+	rule.ProcessEvent(tracing.OpenEventType, openEvent, nil, &EngineAccessMock{})
+	networkEvent.Pid = openEvent.Pid
+	networkEvent.Timestamp = int64(2900 * time.Millisecond)
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, networkEvent, nil, &EngineAccessMock{}); failure == nil {
+		t.Errorf("Expected failure 2.9s after the access, but got nil")
+	}
+	rule.ProcessEvent(tracing.OpenEventType, openEvent, nil, &EngineAccessMock{})
+	networkEvent.Timestamp = int64(3 * time.Second)
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, networkEvent, nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil 3s after the access, but got %v", failure)
+	}
 }
@@ -21,7 +21,11 @@ var R1004ExecFromMountRuleDescriptor = RuleDesciptor{
 	Name:        R1004ExecFromMountRuleName,
 	Description: "Detecting exec calls from mounted paths.",
 	Tags:        []string{"exec", "mount"},
-	Priority:    RulePriorityMed,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticExecution, MitreTacticPrivilegeEscalation},
+		Techniques: []string{"T1611"},
+	},
+	Priority: RulePriorityMed,
 	Requirements: RuleRequirements{
 		EventTypes:             []tracing.EventType{tracing.ExecveEventType},
 		NeedApplicationProfile: false,
@@ -146,6 +150,14 @@ func (rule *R1004ExecFromMountFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R1004ExecFromMountFailure) Fingerprint() string {
+	return rule.FailureEvent.PathName
+}
+
+func (rule *R1004ExecFromMountFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R1004ExecFromMountFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -17,7 +17,11 @@ var R1006UnshareSyscallRuleDescriptor = RuleDesciptor{
 	Name:        R1006UnshareSyscallRuleName,
 	Description: "Detecting Unshare System Call usage, which can be used to escape container.",
 	Tags:        []string{"syscall", "escape", "unshare"},
-	Priority:    RulePriorityHigh,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticPrivilegeEscalation},
+		Techniques: []string{"T1611"},
+	},
+	Priority: RulePriorityHigh,
 	Requirements: RuleRequirements{
 		EventTypes: []tracing.EventType{
 			tracing.SyscallEventType,
@@ -99,6 +103,14 @@ func (rule *R1006UnshareSyscallFailure) Event() tracing.GeneralEvent {
 	return rule.FailureEvent.GeneralEvent
 }
 
+func (rule *R1006UnshareSyscallFailure) Fingerprint() string {
+	return rule.FailureEvent.Comm
+}
+
+func (rule *R1006UnshareSyscallFailure) Evidence() Evidence {
+	return eventEvidence(rule.FailureEvent)
+}
+
 func (rule *R1006UnshareSyscallFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -135,7 +135,11 @@ var R1007CryptoMinersRuleDescriptor = RuleDesciptor{
 	Name:        R1007CryptoMinersRuleName,
 	Description: "Detecting Crypto Miners by port, domain and randomx event.",
 	Tags:        []string{"network", "crypto", "miners", "malicious", "dns"},
-	Priority:    RulePriorityHigh,
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticImpact},
+		Techniques: []string{"T1496"},
+	},
+	Priority: RulePriorityHigh,
 	Requirements: RuleRequirements{
 		EventTypes: []tracing.EventType{
 			tracing.NetworkEventType,
@@ -159,6 +163,8 @@ type R1007CryptoMinersFailure struct {
 	Err              string
 	FixSuggestionMsg string
 	FailureEvent     *tracing.GeneralEvent
+	// Evidence of the RandomX, network or DNS event
+	FailureEvidence Evidence
 }
 
 func (rule *R1007CryptoMiners) Name() string {
@@ -182,6 +188,7 @@ func (rule *R1007CryptoMiners) ProcessEvent(eventType tracing.EventType, event i
 			RuleName:         rule.Name(),
 			Err:              "Possible Crypto Miner detected",
 			FailureEvent:     &randomXEvent.GeneralEvent,
+			FailureEvidence:  eventEvidence(randomXEvent),
 			FixSuggestionMsg: "If this is a legitimate action, please consider removing this workload from the binding of this rule.",
 			RulePriority:     R1007CryptoMinersRuleDescriptor.Priority,
 		}
@@ -191,6 +198,7 @@ func (rule *R1007CryptoMiners) ProcessEvent(eventType tracing.EventType, event i
 				RuleName:         rule.Name(),
 				Err:              "Possible Crypto Miner port detected",
 				FailureEvent:     &networkEvent.GeneralEvent,
+				FailureEvidence:  eventEvidence(networkEvent),
 				FixSuggestionMsg: "If this is a legitimate action, please consider removing this workload from the binding of this rule.",
 				RulePriority:     R1007CryptoMinersRuleDescriptor.Priority,
 			}
@@ -201,6 +209,7 @@ func (rule *R1007CryptoMiners) ProcessEvent(eventType tracing.EventType, event i
 				RuleName:         rule.Name(),
 				Err:              "Possible Crypto Miner domain detected",
 				FailureEvent:     &dnsEvent.GeneralEvent,
+				FailureEvidence:  eventEvidence(dnsEvent),
 				FixSuggestionMsg: "If this is a legitimate action, please consider removing this workload from the binding of this rule.",
 				RulePriority:     R1007CryptoMinersRuleDescriptor.Priority,
 			}
@@ -229,6 +238,15 @@ func (rule *R1007CryptoMinersFailure) Event() tracing.GeneralEvent {
 	return *rule.FailureEvent
 }
 
+// The error tells whether the miner was detected by RandomX, port or domain.
+func (rule *R1007CryptoMinersFailure) Fingerprint() string {
+	return rule.Err + "/" + rule.FailureEvent.Comm
+}
+
+func (rule *R1007CryptoMinersFailure) Evidence() Evidence {
+	return rule.FailureEvidence
+}
+
 func (rule *R1007CryptoMinersFailure) Priority() int {
 	return rule.RulePriority
 }
@@ -0,0 +1,281 @@
+package rule
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/approfilecache"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+// Current rule:
+// Detecting reverse shells by correlating the exec of a shell with an outgoing TCP connection of the shell itself
+// within a short window. With the correlateParent parameter, the connections of the parent of the shell are also
+// correlated (e.g. python -c connecting a socket and spawning /bin/sh on it). The events do not tell whether the shell
+// inherited the socket, so this is opt-in: any networked process spawning sh -c would otherwise be reported.
+// The events of a container are not processed in order, so both the shells and the connections are kept for the window.
+
+const (
+	R1008ID                   = "R1008"
+	R1008ReverseShellRuleName = "Reverse Shell"
+	// Maximum time between the exec of the shell and the connection
+	R1008MaxTimeDiffInSeconds = 2
+	// Maximum number of shells and of connections kept by the rule
+	R1008MaxTrackedEvents = 256
+)
+
+// Binaries which are shells, or which can attach a shell to a connection, whenever they are executed.
+var ReverseShellBinaries = []string{
+	"sh",
+	"bash",
+	"dash",
+	"ash",
+	"zsh",
+	"ksh",
+	"mksh",
+	"csh",
+	"tcsh",
+	"fish",
+	"busybox",
+	"nc",
+	"ncat",
+	"netcat",
+	"nc.traditional",
+	"nc.openbsd",
+	"socat",
+	"telnet",
+}
+
+// Interpreters which are tracked only when they run inline code, with the flag running it.
+var ReverseShellInterpreters = map[string]string{
+	"python": "-c",
+	"perl":   "-e",
+	"ruby":   "-e",
+	"php":    "-r",
+	"lua":    "-e",
+	"node":   "-e",
+}
+
+var R1008ReverseShellRuleDescriptor = RuleDesciptor{
+	ID:          R1008ID,
+	Name:        R1008ReverseShellRuleName,
+	Description: "Detecting shells connected to an outgoing TCP connection of the shell, or optionally of its parent.",
+	Tags:        []string{"exec", "network", "shell", "malicious"},
+	Mitre: MitreAttack{
+		Tactics:    []string{MitreTacticExecution, MitreTacticCommandAndControl},
+		Techniques: []string{"T1059.004", "T1071"},
+	},
+	Priority: RulePriorityCritical,
+	Requirements: RuleRequirements{
+		EventTypes:             []tracing.EventType{tracing.ExecveEventType, tracing.NetworkEventType},
+		NeedApplicationProfile: false,
+	},
+	RuleCreationFunc: func() Rule {
+		return CreateRuleR1008ReverseShell()
+	},
+}
+
+type R1008ReverseShell struct {
+	BaseRule
+	allowedInterpreters []string
+	allowedDestinations []string
+	allowedNetworks     []*net.IPNet
+	// Whether the connections of the parent of a shell are correlated with the shell
+	correlateParent bool
+	// Shells by their pid and by the pid of their parent
+	shells       *CorrelationState[*tracing.ExecveEvent]
+	shellParents *CorrelationState[*tracing.ExecveEvent]
+	// Outgoing connections by the pid of the process
+	connections *CorrelationState[*tracing.NetworkEvent]
+}
+
+type R1008ReverseShellFailure struct {
+	RuleName         string
+	RulePriority     int
+	Err              string
+	FixSuggestionMsg string
+	FailureEvent     *tracing.ExecveEvent
+	Connection       *tracing.NetworkEvent
+}
+
+func (rule *R1008ReverseShell) Name() string {
+	return R1008ReverseShellRuleName
+}
+
+func CreateRuleR1008ReverseShell() *R1008ReverseShell {
+	return &R1008ReverseShell{
+		allowedInterpreters: []string{},
+		allowedDestinations: []string{},
+		shells:              NewCorrelationState[*tracing.ExecveEvent](R1008MaxTimeDiffInSeconds*time.Second, R1008MaxTrackedEvents),
+		shellParents:        NewCorrelationState[*tracing.ExecveEvent](R1008MaxTimeDiffInSeconds*time.Second, R1008MaxTrackedEvents),
+		connections:         NewCorrelationState[*tracing.NetworkEvent](R1008MaxTimeDiffInSeconds*time.Second, R1008MaxTrackedEvents),
+	}
+}
+
+func (rule *R1008ReverseShell) SetParameters(parameters map[string]interface{}) {
+	rule.BaseRule.SetParameters(parameters)
+
+	rule.correlateParent = fmt.Sprintf("%v", rule.GetParameters()["correlateParent"]) == "true"
+	if allowedInterpreters, ok := interfaceToStringSlice(rule.GetParameters()["allowedInterpreters"]); ok {
+		rule.allowedInterpreters = allowedInterpreters
+	}
+	if allowedDestinations, ok := interfaceToStringSlice(rule.GetParameters()["allowedDestinations"]); ok {
+		rule.allowedDestinations = allowedDestinations
+		rule.allowedNetworks = nil
+		for _, destination := range allowedDestinations {
+			if _, network, err := net.ParseCIDR(destination); err == nil {
+				rule.allowedNetworks = append(rule.allowedNetworks, network)
+			}
+		}
+	}
+}
+
+func (rule *R1008ReverseShell) DeleteRule() {
+	rule.shells.Clear()
+	rule.shellParents.Clear()
+	rule.connections.Clear()
+}
+
+func (rule *R1008ReverseShell) ProcessEvent(eventType tracing.EventType, event interface{}, appProfileAccess approfilecache.SingleApplicationProfileAccess, engineAccess EngineAccess) RuleFailure {
+	switch eventType {
+	case tracing.ExecveEventType:
+		execEvent, ok := event.(*tracing.ExecveEvent)
+		if !ok || !rule.isShell(execEvent) {
+			return nil
+		}
+		shellKey := NewCorrelationKey(&execEvent.GeneralEvent)
+		parentKey := CorrelationKey{ContainerID: execEvent.ContainerID, Pid: execEvent.Ppid}
+		keys := []CorrelationKey{shellKey}
+		if rule.correlateParent {
+			keys = append(keys, parentKey)
+		}
+		for _, key := range keys {
+			if connection, ok := rule.connections.Take(key, execEvent.Timestamp); ok {
+				return rule.failure(execEvent, connection)
+			}
+		}
+		rule.shells.Set(shellKey, execEvent, execEvent.Timestamp)
+		if rule.correlateParent {
+			rule.shellParents.Set(parentKey, execEvent, execEvent.Timestamp)
+		}
+	case tracing.NetworkEventType:
+		networkEvent, ok := event.(*tracing.NetworkEvent)
+		if !ok || networkEvent.PacketType != "OUTGOING" || networkEvent.Protocol != "TCP" || rule.isAllowedDestination(networkEvent.DstEndpoint) {
+			return nil
+		}
+		key := NewCorrelationKey(&networkEvent.GeneralEvent)
+		for _, shells := range []*CorrelationState[*tracing.ExecveEvent]{rule.shells, rule.shellParents} {
+			if shell, ok := shells.Take(key, networkEvent.Timestamp); ok {
+				// The shell is reported once
+				rule.shells.Delete(NewCorrelationKey(&shell.GeneralEvent))
+				rule.shellParents.Delete(CorrelationKey{ContainerID: shell.ContainerID, Pid: shell.Ppid})
+				return rule.failure(shell, networkEvent)
+			}
+		}
+		rule.connections.Set(key, networkEvent, networkEvent.Timestamp)
+	}
+
+	return nil
+}
+
+func (rule *R1008ReverseShell) failure(shell *tracing.ExecveEvent, connection *tracing.NetworkEvent) RuleFailure {
+	return &R1008ReverseShellFailure{
+		RuleName:         rule.Name(),
+		RulePriority:     R1008ReverseShellRuleDescriptor.Priority,
+		Err:              fmt.Sprintf("Reverse shell: %s (pid %d) is connected to %s:%d", shell.PathName, shell.Pid, connection.DstEndpoint, connection.Port),
+		FixSuggestionMsg: "If this is a legitimate action, please add the interpreter or the destination as a parameter to the binding of this rule",
+		FailureEvent:     shell,
+		Connection:       connection,
+	}
+}
+
+// isShell returns true if the exec starts a shell, or an interpreter running inline code, which is not allowed.
+func (rule *R1008ReverseShell) isShell(event *tracing.ExecveEvent) bool {
+	names := []string{filepath.Base(event.PathName), event.Comm}
+	for _, name := range names {
+		if slices.Contains(rule.allowedInterpreters, name) || slices.Contains(rule.allowedInterpreters, event.PathName) {
+			return false
+		}
+	}
+	for _, name := range names {
+		if slices.Contains(ReverseShellBinaries, name) {
+			return true
+		}
+		// python3, python3.11...
+		if inlineFlag, ok := ReverseShellInterpreters[strings.TrimRight(name, "0123456789.")]; ok {
+			for _, arg := range event.Args {
+				if strings.HasPrefix(arg, inlineFlag) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isAllowedDestination returns true for the loopback addresses and the allowed destinations. The destinations are
+// matched against the endpoint, its address or, for CIDRs, its IP.
+func (rule *R1008ReverseShell) isAllowedDestination(endpoint string) bool {
+	address := strings.Trim(strings.TrimPrefix(endpoint, "r/"), "[]")
+	if slices.Contains(rule.allowedDestinations, endpoint) || slices.Contains(rule.allowedDestinations, address) {
+		return true
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, network := range rule.allowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *R1008ReverseShell) Requirements() RuleRequirements {
+	return RuleRequirements{
+		EventTypes:             []tracing.EventType{tracing.ExecveEventType, tracing.NetworkEventType},
+		NeedApplicationProfile: false,
+	}
+}
+
+func (rule *R1008ReverseShellFailure) Name() string {
+	return rule.RuleName
+}
+
+func (rule *R1008ReverseShellFailure) Error() string {
+	return rule.Err
+}
+
+func (rule *R1008ReverseShellFailure) Event() tracing.GeneralEvent {
+	return rule.FailureEvent.GeneralEvent
+}
+
+func (rule *R1008ReverseShellFailure) Fingerprint() string {
+	return fmt.Sprintf("%s:%s:%d", rule.FailureEvent.PathName, rule.Connection.DstEndpoint, rule.Connection.Port)
+}
+
+func (rule *R1008ReverseShellFailure) Evidence() Evidence {
+	evidence := eventEvidence(rule.FailureEvent)
+	if rule.Connection != nil {
+		for key, value := range eventEvidence(rule.Connection) {
+			evidence[key] = value
+		}
+	}
+	return evidence
+}
+
+func (rule *R1008ReverseShellFailure) Priority() int {
+	return rule.RulePriority
+}
+
+func (rule *R1008ReverseShellFailure) FixSuggestion() string {
+	return rule.FixSuggestionMsg
+}
@@ -0,0 +1,160 @@
+package rule
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func createR1008ExecEvent(pid, ppid uint32, path string, args []string, timestamp int64) *tracing.ExecveEvent {
+	return &tracing.ExecveEvent{
+		GeneralEvent: tracing.GeneralEvent{
+			ProcessDetails: tracing.ProcessDetails{
+				Pid:  pid,
+				Ppid: ppid,
+				Comm: filepath.Base(path),
+			},
+			ContainerID: "test",
+			PodName:     "test",
+			Namespace:   "test",
+			Timestamp:   timestamp,
+		},
+		PathName: path,
+		Args:     args,
+	}
+}
+
+func createR1008NetworkEvent(pid uint32, dstEndpoint string, timestamp int64) *tracing.NetworkEvent {
+	return &tracing.NetworkEvent{
+		GeneralEvent: tracing.GeneralEvent{
+			ProcessDetails: tracing.ProcessDetails{
+				Pid: pid,
+			},
+			ContainerID: "test",
+			PodName:     "test",
+			Namespace:   "test",
+			Timestamp:   timestamp,
+		},
+		PacketType:  "OUTGOING",
+		Protocol:    "TCP",
+		Port:        4444,
+		DstEndpoint: dstEndpoint,
+	}
+}
+
+func TestR1008ReverseShell(t *testing.T) {
+	second := int64(time.Second)
+
+	// Test case 1: the shell connects after its exec
+	rule := CreateRuleR1008ReverseShell()
+	if failure := rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(20, 10, "/bin/bash", []string{"bash", "-i"}, 1*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+	failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(20, "1.1.1.1", 2*second), nil, &EngineAccessMock{})
+	if failure == nil {
+		t.Fatalf("Expected failure, but got nil")
+	}
+	if failure.Event().Pid != 20 || failure.Fingerprint() != "/bin/bash:1.1.1.1:4444" {
+		t.Errorf("Unexpected failure %v with fingerprint %s", failure, failure.Fingerprint())
+	}
+	// The shell is reported once
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(20, "1.1.1.1", 2*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+
+	// Test case 2: the parent connects before the exec of the shell, when the parent is correlated
+	rule = CreateRuleR1008ReverseShell()
+	rule.SetParameters(map[string]interface{}{"correlateParent": true})
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(10, "r/1.1.1.1", 1*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+	if failure := rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(20, 10, "/bin/sh", []string{"/bin/sh"}, 2*second), nil, &EngineAccessMock{}); failure == nil {
+		t.Errorf("Expected failure, but got nil")
+	}
+
+	// Test case 3: the connection is not made by the shell or its parent
+	rule = CreateRuleR1008ReverseShell()
+	rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(20, 10, "/bin/sh", nil, 1*second), nil, &EngineAccessMock{})
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(30, "1.1.1.1", 1*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+
+	// Test case 4: the connection is out of the window
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(20, "1.1.1.1", 10*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+
+	// Test case 5: incoming and loopback connections are ignored
+	rule = CreateRuleR1008ReverseShell()
+	rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(20, 10, "/bin/sh", nil, 1*second), nil, &EngineAccessMock{})
+	incomingEvent := createR1008NetworkEvent(20, "1.1.1.1", 1*second)
+	incomingEvent.PacketType = "HOST"
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, incomingEvent, nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(20, "127.0.0.1", 1*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+
+	// Test case 6: interpreters are tracked only when running inline code
+	rule = CreateRuleR1008ReverseShell()
+	rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(20, 10, "/usr/bin/python3", []string{"python3", "app.py"}, 1*second), nil, &EngineAccessMock{})
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(20, "1.1.1.1", 1*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+	rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(21, 10, "/usr/bin/python3", []string{"python3", "-c", "import socket"}, 1*second), nil, &EngineAccessMock{})
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(21, "1.1.1.1", 1*second), nil, &EngineAccessMock{}); failure == nil {
+		t.Errorf("Expected failure, but got nil")
+	}
+}
+
+func TestR1008ReverseShellNetworkedParent(t *testing.T) {
+	second := int64(time.Second)
+
+	// A server connecting to its backend and running sh -c is not a reverse shell by default
+	rule := CreateRuleR1008ReverseShell()
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(10, "1.1.1.1", 1*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+	if failure := rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(20, 10, "/bin/sh", []string{"sh", "-c", "date"}, 1*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(10, "1.1.1.1", 2*second), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+
+	// The parent is correlated in both orders when enabled
+	rule = CreateRuleR1008ReverseShell()
+	rule.SetParameters(map[string]interface{}{"correlateParent": true})
+	rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(20, 10, "/bin/sh", []string{"sh", "-c", "date"}, 1*second), nil, &EngineAccessMock{})
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(10, "1.1.1.1", 2*second), nil, &EngineAccessMock{}); failure == nil {
+		t.Errorf("Expected failure, but got nil")
+	}
+}
+
+func TestR1008ReverseShellParameters(t *testing.T) {
+	rule := CreateRuleR1008ReverseShell()
+	rule.SetParameters(map[string]interface{}{
+		"allowedInterpreters": []interface{}{"bash"},
+		"allowedDestinations": []interface{}{"10.0.0.0/8", "p/default/backend"},
+	})
+
+	// Allowed interpreter
+	rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(20, 10, "/bin/bash", nil, 1), nil, &EngineAccessMock{})
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(20, "1.1.1.1", 1), nil, &EngineAccessMock{}); failure != nil {
+		t.Errorf("Expected failure to be nil, but got %v", failure)
+	}
+
+	// Allowed destinations
+	rule.ProcessEvent(tracing.ExecveEventType, createR1008ExecEvent(30, 10, "/bin/sh", nil, 1), nil, &EngineAccessMock{})
+	for _, destination := range []string{"10.1.2.3", "r/10.1.2.3", "p/default/backend"} {
+		if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(30, destination, 1), nil, &EngineAccessMock{}); failure != nil {
+			t.Errorf("Expected failure to be nil for %s, but got %v", destination, failure)
+		}
+	}
+	if failure := rule.ProcessEvent(tracing.NetworkEventType, createR1008NetworkEvent(30, "11.1.2.3", 1), nil, &EngineAccessMock{}); failure == nil {
+		t.Errorf("Expected failure, but got nil")
+	}
+}
@@ -27,6 +27,8 @@ type RuleDesciptor struct {
 	Priority int
 	// Tags
 	Tags []string
+	// MITRE ATT&CK tactics and techniques.
+	Mitre MitreAttack
 	// Rule requirements.
 	Requirements RuleRequirements
 	// Create a rule function.
@@ -44,6 +46,10 @@ type RuleFailure interface {
 	FixSuggestion() string
 	// Generic event
 	Event() tracing.GeneralEvent
+	// Fingerprint identifies repeated failures of the rule in a container (path, process name, domain...).
+	Fingerprint() string
+	// Evidence is the structured data of the failure, keyed by the Evidence* keys.
+	Evidence() Evidence
 }
 
 type RuleRequirements struct {
@@ -0,0 +1,39 @@
+package rule
+
+import (
+	"fmt"
+	"time"
+)
+
+// SuppressedRuleFailure summarizes the repeats of a rule failure which were suppressed by the engine
+// during the suppression window. It carries the last suppressed failure.
+type SuppressedRuleFailure struct {
+	RuleFailure
+	// Number of suppressed failures.
+	SuppressedCount int
+	// Time of the first and the last suppressed failures.
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+func (failure *SuppressedRuleFailure) Error() string {
+	return fmt.Sprintf("%s (%d similar alerts suppressed between %s and %s)", failure.RuleFailure.Error(), failure.SuppressedCount,
+		failure.FirstSeen.UTC().Format(time.RFC3339), failure.LastSeen.UTC().Format(time.RFC3339))
+}
+
+// GetSuppressedRuleFailure returns the summary of suppressed failures the failure carries, nil if it is not one.
+func GetSuppressedRuleFailure(failure RuleFailure) *SuppressedRuleFailure {
+	for failure != nil {
+		switch wrapped := failure.(type) {
+		case *SuppressedRuleFailure:
+			return wrapped
+		case *ProcessAncestryRuleFailure:
+			failure = wrapped.RuleFailure
+		case *PriorityOverrideRuleFailure:
+			failure = wrapped.RuleFailure
+		default:
+			return nil
+		}
+	}
+	return nil
+}
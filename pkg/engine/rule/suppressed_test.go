@@ -0,0 +1,27 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func TestGetSuppressedRuleFailure(t *testing.T) {
+	failure := &R1002LoadKernelModuleFailure{RuleName: R1002LoadKernelModuleRuleName, FailureEvent: &tracing.SyscallEvent{}}
+	if GetSuppressedRuleFailure(failure) != nil {
+		t.Errorf("Expected no suppression summary")
+	}
+
+	firstSeen := time.Unix(1700000000, 0)
+	suppressed := &SuppressedRuleFailure{RuleFailure: failure, SuppressedCount: 3, FirstSeen: firstSeen, LastSeen: firstSeen.Add(time.Minute)}
+	if GetSuppressedRuleFailure(suppressed) != suppressed {
+		t.Errorf("Expected the suppression summary")
+	}
+
+	// the summary is found through the wrappers of the engine
+	wrapped := &PriorityOverrideRuleFailure{RuleFailure: WithProcessAncestry(suppressed, []ProcessInfo{{Pid: 20}}), priority: RulePriorityLow}
+	if got := GetSuppressedRuleFailure(wrapped); got == nil || got.SuppressedCount != 3 || !got.FirstSeen.Equal(firstSeen) {
+		t.Errorf("Expected the suppression summary through the wrappers, got %+v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/approfilecache"
+	"github.com/armosec/kubecop/pkg/engine/rule"
+)
+
+// ContainerState is the state of a container followed by the engine, for the admin API.
+type ContainerState struct {
+	ContainerID   string    `json:"containerID"`
+	ContainerName string    `json:"containerName"`
+	PodName       string    `json:"podName"`
+	Namespace     string    `json:"namespace"`
+	OwnerKind     string    `json:"ownerKind,omitempty"`
+	OwnerName     string    `json:"ownerName,omitempty"`
+	Image         string    `json:"image,omitempty"`
+	StartedAt     time.Time `json:"startedAt"`
+	AttachedLate  bool      `json:"attachedLate,omitempty"`
+	// Status of the application profile of the container, and its name once loaded
+	ApplicationProfileStatus approfilecache.ApplicationProfileStatus `json:"applicationProfileStatus"`
+	ApplicationProfileName   string                                  `json:"applicationProfileName,omitempty"`
+	// Rules bound to the container, with their effective parameters
+	Rules []BoundRuleState `json:"rules"`
+}
+
+// BoundRuleState is a rule bound to a container.
+type BoundRuleState struct {
+	ID         string                 `json:"id,omitempty"`
+	Name       string                 `json:"name"`
+	Priority   int                    `json:"priority"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Actions    []string               `json:"actions,omitempty"`
+}
+
+// ContainersState returns the state of the containers followed by the engine, sorted by namespace, pod and container.
+func (engine *Engine) ContainersState() []ContainerState {
+	descriptors := ruleDescriptorsByName()
+	containers := make([]ContainerState, 0)
+	for _, contEntry := range getcontainerIdToDetailsCacheCopy() {
+		containers = append(containers, engine.containerState(contEntry, descriptors))
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].Namespace != containers[j].Namespace {
+			return containers[i].Namespace < containers[j].Namespace
+		}
+		if containers[i].PodName != containers[j].PodName {
+			return containers[i].PodName < containers[j].PodName
+		}
+		return containers[i].ContainerName < containers[j].ContainerName
+	})
+	return containers
+}
+
+// ContainerState returns the state of the container with the given ID, or with the given ID prefix if only one
+// container has it.
+func (engine *Engine) ContainerState(containerID string) (ContainerState, bool) {
+	contEntry, ok := getContainerDetails(containerID)
+	if !ok {
+		matches := 0
+		for id, entry := range getcontainerIdToDetailsCacheCopy() {
+			if containerID != "" && strings.HasPrefix(id, containerID) {
+				contEntry = entry
+				matches++
+			}
+		}
+		if matches != 1 {
+			return ContainerState{}, false
+		}
+	}
+	return engine.containerState(contEntry, ruleDescriptorsByName()), true
+}
+
+func (engine *Engine) containerState(contEntry containerEntry, descriptors map[string]rule.RuleDesciptor) ContainerState {
+	state := ContainerState{
+		ContainerID:              contEntry.ContainerID,
+		ContainerName:            contEntry.ContainerName,
+		PodName:                  contEntry.PodName,
+		Namespace:                contEntry.Namespace,
+		OwnerKind:                contEntry.OwnerKind,
+		OwnerName:                contEntry.OwnerName,
+		Image:                    containerImage(contEntry.PodSpec, contEntry.ContainerName),
+		StartedAt:                contEntry.StartedAt,
+		AttachedLate:             contEntry.AttachedLate,
+		ApplicationProfileStatus: approfilecache.ApplicationProfileStatusMissing,
+		Rules:                    make([]BoundRuleState, 0, len(contEntry.BoundRules)),
+	}
+	if engine.applicationProfileCache != nil {
+		state.ApplicationProfileStatus, state.ApplicationProfileName = engine.applicationProfileCache.GetApplicationProfileStatus(contEntry.ContainerID)
+	}
+	for _, boundRule := range contEntry.BoundRules {
+		descriptor := descriptors[boundRule.Name()]
+		ruleState := BoundRuleState{
+			ID:         descriptor.ID,
+			Name:       boundRule.Name(),
+			Priority:   descriptor.Priority,
+			Parameters: boundRule.GetParameters(),
+			Actions:    contEntry.RuleActions[boundRule.Name()],
+		}
+		if priorityOverride, ok := boundRule.(*rule.PriorityOverrideRule); ok {
+			ruleState.Priority = priorityOverride.Priority()
+		}
+		state.Rules = append(state.Rules, ruleState)
+	}
+	return state
+}
+
+func ruleDescriptorsByName() map[string]rule.RuleDesciptor {
+	descriptors := make(map[string]rule.RuleDesciptor)
+	for _, descriptor := range rule.GetAllRuleDescriptors() {
+		descriptors[descriptor.Name] = descriptor
+	}
+	return descriptors
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/armosec/kubecop/pkg/approfilecache"
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestContainersState(t *testing.T) {
+	engine := &Engine{applicationProfileCache: NewApplicationProfileCacheMock(nil)}
+
+	miners := rule.CreateRuleR1007CryptoMiners()
+	miners.SetParameters(map[string]interface{}{"threshold": 3})
+	contEntry := containerEntry{
+		ContainerID:   "test-containers-state-0123456789",
+		ContainerName: "server",
+		PodName:       "web",
+		Namespace:     "default",
+		PodSpec:       &corev1.PodSpec{Containers: []corev1.Container{{Name: "server", Image: "nginx:1.25"}}},
+		BoundRules:    []rule.Rule{rule.NewPriorityOverrideRule(miners, rule.RulePriorityLow), rule.CreateRuleR0001UnexpectedProcessLaunched()},
+		RuleActions:   map[string][]string{rule.R1007CryptoMinersRuleName: {rulebindingstore.ResponseActionKillProcess}},
+	}
+	setContainerDetails(contEntry.ContainerID, contEntry, false)
+	defer deleteContainerDetails(contEntry.ContainerID)
+
+	var state ContainerState
+	for _, container := range engine.ContainersState() {
+		if container.ContainerID == contEntry.ContainerID {
+			state = container
+		}
+	}
+	assert.Equal(t, "nginx:1.25", state.Image)
+	assert.Equal(t, approfilecache.ApplicationProfileStatusLoaded, state.ApplicationProfileStatus)
+	if assert.Len(t, state.Rules, 2) {
+		assert.Equal(t, BoundRuleState{
+			ID:         "R1007",
+			Name:       rule.R1007CryptoMinersRuleName,
+			Priority:   rule.RulePriorityLow,
+			Parameters: map[string]interface{}{"threshold": 3},
+			Actions:    []string{rulebindingstore.ResponseActionKillProcess},
+		}, state.Rules[0])
+		assert.Equal(t, "R0001", state.Rules[1].ID)
+		assert.Equal(t, rule.RulePriorityCritical, state.Rules[1].Priority)
+	}
+
+	// the container is found by a unique prefix of its ID
+	byPrefix, ok := engine.ContainerState("test-containers-state")
+	assert.True(t, ok)
+	assert.Equal(t, contEntry.ContainerID, byPrefix.ContainerID)
+	_, ok = engine.ContainerState("unknown")
+	assert.False(t, ok)
+}
@@ -1,10 +1,25 @@
 package engine
 
 import (
+	"sync"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/exporters"
 	"github.com/kubescape/kapprofiler/pkg/tracing"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+const (
+	// Default maximum number of workloads in the labels of the alert metrics
+	DefaultMetricsMaxWorkloads = 500
+	// Label value of the namespace and workload of the alerts of the workloads over the limit
+	overflowLabelValue = "_overflow"
+)
+
+// Labels of the alert metrics
+var alertLabelNames = []string{"rule_id", "severity", "namespace", "workload_kind", "workload_name"}
+
 type prometheusMetric struct {
 	ebpfExecCounter       prometheus.Counter
 	ebpfOpenCounter       prometheus.Counter
@@ -14,8 +29,24 @@ type prometheusMetric struct {
 	ebpfCapabilityCounter prometheus.Counter
 	ebpfRandomXCounter    prometheus.Counter
 	ebpfFailedCounter     prometheus.Counter
-	ruleCounter           prometheus.Counter
-	alertCounter          prometheus.Counter
+	ruleCounter           *prometheus.CounterVec
+	alertCounter          *prometheus.CounterVec
+	suppressedCounter     *prometheus.CounterVec
+	exceptedCounter       *prometheus.CounterVec
+	filteredEventCounter  *prometheus.CounterVec
+	ruleDuration          *prometheus.HistogramVec
+	workerPoolQueueDepth  prometheus.Gauge
+	workerPoolWaitTime    prometheus.Histogram
+	// Workloads with their own label values in the alert metrics, at most maxWorkloads
+	workloadsLock sync.Mutex
+	workloads     map[workloadKey]struct{}
+	maxWorkloads  int
+}
+
+type workloadKey struct {
+	namespace string
+	kind      string
+	name      string
 }
 
 func createPrometheusMetric() *prometheusMetric {
@@ -61,18 +92,56 @@ func createPrometheusMetric() *prometheusMetric {
 	})
 	prometheus.MustRegister(ebpfRandomXCounter)
 
-	ruleCounter := prometheus.NewCounter(prometheus.CounterOpts{
+	ruleCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "kubecop_rule_counter",
-		Help: "The total number of rules processed by the engine",
-	})
+		Help: "The total number of rules processed by the engine, by rule ID",
+	}, []string{"rule_id"})
 	prometheus.MustRegister(ruleCounter)
 
-	alertCounter := prometheus.NewCounter(prometheus.CounterOpts{
+	alertCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "kubecop_alert_counter",
-		Help: "The total number of alerts sent by the engine",
-	})
+		Help: "The total number of alerts sent by the engine, by rule ID, severity, namespace and workload",
+	}, alertLabelNames)
 	prometheus.MustRegister(alertCounter)
 
+	suppressedCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubecop_suppressed_alert_counter",
+		Help: "The total number of repeated alerts suppressed by the engine, by rule ID, severity, namespace and workload",
+	}, alertLabelNames)
+	prometheus.MustRegister(suppressedCounter)
+
+	exceptedCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubecop_excepted_alert_counter",
+		Help: "The total number of alerts not sent because a RuntimeAlertException excepts them, by rule ID, severity, namespace and workload",
+	}, alertLabelNames)
+	prometheus.MustRegister(exceptedCounter)
+
+	ruleDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kubecop_rule_processing_seconds",
+		Help:    "The time taken by the rules to process an event, by rule ID",
+		Buckets: prometheus.ExponentialBuckets(0.00001, 4, 8),
+	}, []string{"rule_id"})
+	prometheus.MustRegister(ruleDuration)
+
+	workerPoolQueueDepth := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kubecop_worker_pool_queue_depth",
+		Help: "The number of events waiting for a worker of the event processing pool",
+	})
+	prometheus.MustRegister(workerPoolQueueDepth)
+
+	workerPoolWaitTime := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "kubecop_worker_pool_wait_seconds",
+		Help:    "The time the events wait for a worker of the event processing pool",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+	})
+	prometheus.MustRegister(workerPoolWaitTime)
+
+	filteredEventCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubecop_filtered_event_counter",
+		Help: "The total number of events dropped before processing because no rule bound to the container needs them, by event type",
+	}, []string{"event_type"})
+	prometheus.MustRegister(filteredEventCounter)
+
 	ebpfFailedCounter := prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "kubecop_ebpf_event_failure_counter",
 		Help: "The total number of failed events received from the eBPF probe",
@@ -90,6 +159,14 @@ func createPrometheusMetric() *prometheusMetric {
 		ebpfFailedCounter:     ebpfFailedCounter,
 		ruleCounter:           ruleCounter,
 		alertCounter:          alertCounter,
+		suppressedCounter:     suppressedCounter,
+		exceptedCounter:       exceptedCounter,
+		filteredEventCounter:  filteredEventCounter,
+		ruleDuration:          ruleDuration,
+		workerPoolQueueDepth:  workerPoolQueueDepth,
+		workerPoolWaitTime:    workerPoolWaitTime,
+		workloads:             make(map[workloadKey]struct{}),
+		maxWorkloads:          DefaultMetricsMaxWorkloads,
 	}
 }
 
@@ -104,6 +181,12 @@ func (p *prometheusMetric) destroy() {
 	prometheus.Unregister(p.ebpfFailedCounter)
 	prometheus.Unregister(p.ruleCounter)
 	prometheus.Unregister(p.alertCounter)
+	prometheus.Unregister(p.suppressedCounter)
+	prometheus.Unregister(p.exceptedCounter)
+	prometheus.Unregister(p.filteredEventCounter)
+	prometheus.Unregister(p.ruleDuration)
+	prometheus.Unregister(p.workerPoolQueueDepth)
+	prometheus.Unregister(p.workerPoolWaitTime)
 }
 
 func (p *prometheusMetric) reportEbpfEvent(eventType tracing.EventType) {
@@ -129,10 +212,102 @@ func (p *prometheusMetric) reportEbpfFailedEvent() {
 	p.ebpfFailedCounter.Inc()
 }
 
-func (p *prometheusMetric) reportRuleProcessed(ruleID string) {
-	p.ruleCounter.Inc()
+func (p *prometheusMetric) reportRuleProcessed(ruleName string, duration time.Duration) {
+	ruleID := ruleIDLabel(ruleName)
+	p.ruleCounter.WithLabelValues(ruleID).Inc()
+	p.ruleDuration.WithLabelValues(ruleID).Observe(duration.Seconds())
+}
+
+func (p *prometheusMetric) reportRuleAlerted(ruleFailure rule.RuleFailure) {
+	p.alertCounter.WithLabelValues(p.alertLabelValues(ruleFailure)...).Inc()
+}
+
+func (p *prometheusMetric) reportRuleAlertSuppressed(ruleFailure rule.RuleFailure) {
+	p.suppressedCounter.WithLabelValues(p.alertLabelValues(ruleFailure)...).Inc()
+}
+
+func (p *prometheusMetric) reportRuleAlertExcepted(ruleFailure rule.RuleFailure) {
+	p.exceptedCounter.WithLabelValues(p.alertLabelValues(ruleFailure)...).Inc()
+}
+
+// reportEventQueued reports an event submitted to the event processing pool, the returned function reports
+// when a worker starts processing it.
+func (p *prometheusMetric) reportEventQueued() func() {
+	p.workerPoolQueueDepth.Inc()
+	queuedAt := time.Now()
+	return func() {
+		p.workerPoolQueueDepth.Dec()
+		p.workerPoolWaitTime.Observe(time.Since(queuedAt).Seconds())
+	}
 }
 
-func (p *prometheusMetric) reportRuleAlereted(ruleID string) {
-	p.alertCounter.Inc()
+// setMaxWorkloads sets the maximum number of workloads with their own label values in the alert metrics,
+// the alerts of the other workloads are counted with the overflow label values.
+func (p *prometheusMetric) setMaxWorkloads(maxWorkloads int) {
+	p.workloadsLock.Lock()
+	defer p.workloadsLock.Unlock()
+	p.maxWorkloads = maxWorkloads
+}
+
+// alertLabelValues returns the values of the alertLabelNames of the alert, the workload is the owner of the pod.
+func (p *prometheusMetric) alertLabelValues(ruleFailure rule.RuleFailure) []string {
+	event := ruleFailure.Event()
+	workload := workloadKey{namespace: event.Namespace}
+	if contEntry, ok := getContainerDetails(event.ContainerID); ok {
+		workload.kind, workload.name = contEntry.OwnerKind, contEntry.OwnerName
+	}
+	if !p.allowWorkload(workload) {
+		workload = workloadKey{namespace: overflowLabelValue, kind: overflowLabelValue, name: overflowLabelValue}
+	}
+	return []string{ruleIDLabel(ruleFailure.Name()), exporters.PriorityToStatus(ruleFailure.Priority()), workload.namespace, workload.kind, workload.name}
+}
+
+// allowWorkload returns true if the workload has its own label values, the first maxWorkloads workloads
+// seen have them.
+func (p *prometheusMetric) allowWorkload(workload workloadKey) bool {
+	p.workloadsLock.Lock()
+	defer p.workloadsLock.Unlock()
+	if _, ok := p.workloads[workload]; ok {
+		return true
+	}
+	if len(p.workloads) >= p.maxWorkloads {
+		return false
+	}
+	p.workloads[workload] = struct{}{}
+	return true
+}
+
+// ruleIDLabel returns the ID of the rule used in the metrics labels, the name of the rules which are not in the
+// rule factory, like the system alerts.
+func ruleIDLabel(ruleName string) string {
+	if ruleID := rule.GetRuleIDByName(ruleName); ruleID != "" {
+		return ruleID
+	}
+	return ruleName
+}
+
+func (p *prometheusMetric) reportEventFiltered(eventType tracing.EventType) {
+	p.filteredEventCounter.WithLabelValues(eventTypeLabel(eventType)).Inc()
+}
+
+// eventTypeLabel returns the name of the event type used in the metrics labels.
+func eventTypeLabel(eventType tracing.EventType) string {
+	switch eventType {
+	case tracing.ExecveEventType:
+		return "exec"
+	case tracing.OpenEventType:
+		return "open"
+	case tracing.NetworkEventType:
+		return "network"
+	case tracing.DnsEventType:
+		return "dns"
+	case tracing.SyscallEventType:
+		return "syscall"
+	case tracing.CapabilitiesEventType:
+		return "capability"
+	case tracing.RandomXEventType:
+		return "randomx"
+	default:
+		return "unknown"
+	}
 }
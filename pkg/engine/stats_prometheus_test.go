@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/rulebindingstore"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusMetricRuleLabels(t *testing.T) {
+	engine := &Engine{exporter: &MockExporter{}, promCollector: createPrometheusMetric(), processTable: newProcessTable()}
+	defer engine.promCollector.destroy()
+
+	contEntry := containerEntry{ContainerID: "test-rule-labels", PodName: "miner-5d9f", Namespace: "default", OwnerKind: "Deployment", OwnerName: "miner"}
+	setContainerDetails(contEntry.ContainerID, contEntry, false)
+	defer deleteContainerDetails(contEntry.ContainerID)
+	event := &tracing.RandomXEvent{GeneralEvent: tracing.GeneralEvent{ContainerID: contEntry.ContainerID, Namespace: "default", PodName: "miner-5d9f",
+		ProcessDetails: tracing.ProcessDetails{Comm: "xmrig"}}}
+
+	engine.ProcessEvent(tracing.RandomXEventType, event, nil, []rule.Rule{rule.CreateRuleR1007CryptoMiners()})
+	assert.Equal(t, float64(1), testutil.ToFloat64(engine.promCollector.ruleCounter.WithLabelValues("R1007")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(engine.promCollector.alertCounter.WithLabelValues("R1007", "high", "default", "Deployment", "miner")))
+	assert.Equal(t, 1, testutil.CollectAndCount(engine.promCollector.ruleDuration))
+
+	// the rules which are not in the factory are labeled by name
+	assert.Equal(t, ResponseActionRuleName, ruleIDLabel(ResponseActionRuleName))
+}
+
+func TestPrometheusMetricAllAlertsCounted(t *testing.T) {
+	mockExporter := &MockExporter{}
+	engine := &Engine{exporter: mockExporter, promCollector: createPrometheusMetric(), processTable: newProcessTable()}
+	defer engine.promCollector.destroy()
+	engine.SetAlertSuppressionWindow(time.Hour)
+	defer engine.SetAlertSuppressionWindow(0)
+	engine.SetResponseActions(true, 0)
+
+	event := &tracing.RandomXEvent{GeneralEvent: tracing.GeneralEvent{ContainerID: "test-all-alerts", Namespace: "default", PodName: "miner",
+		ProcessDetails: tracing.ProcessDetails{Pid: 1234, Comm: "xmrig"}}}
+	for i := 0; i < 3; i++ {
+		engine.ProcessEvent(tracing.RandomXEventType, event, nil, []rule.Rule{rule.CreateRuleR1007CryptoMiners()})
+	}
+	assert.Equal(t, float64(2), testutil.ToFloat64(engine.promCollector.suppressedCounter.WithLabelValues("R1007", "high", "default", "", "")))
+	// the summary of the suppressed alerts and the audit alert of a response action are counted like the alerts of the rules
+	engine.alertSuppressor.flushContainer(event.ContainerID)
+	engine.responder.respond(mockExporter.Alerts[0], []string{rulebindingstore.ResponseActionKillProcess})
+	assert.Len(t, mockExporter.Alerts, 3)
+	assert.Equal(t, float64(2), testutil.ToFloat64(engine.promCollector.alertCounter.WithLabelValues("R1007", "high", "default", "", "")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(engine.promCollector.alertCounter.WithLabelValues(ResponseActionRuleName, "system_issue", "default", "", "")))
+}
+
+func TestPrometheusMetricMaxWorkloads(t *testing.T) {
+	promCollector := createPrometheusMetric()
+	defer promCollector.destroy()
+	promCollector.setMaxWorkloads(1)
+
+	failure := func(namespace string) rule.RuleFailure {
+		return &rule.R1007CryptoMinersFailure{
+			RuleName:     rule.R1007CryptoMinersRuleName,
+			RulePriority: rule.RulePriorityCritical,
+			FailureEvent: &tracing.GeneralEvent{Namespace: namespace},
+		}
+	}
+	promCollector.reportRuleAlerted(failure("first"))
+	promCollector.reportRuleAlerted(failure("second"))
+	promCollector.reportRuleAlerted(failure("first"))
+	promCollector.reportRuleAlerted(failure("third"))
+	assert.Equal(t, float64(2), testutil.ToFloat64(promCollector.alertCounter.WithLabelValues("R1007", "critical", "first", "", "")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(promCollector.alertCounter.WithLabelValues("R1007", "critical", overflowLabelValue, overflowLabelValue, overflowLabelValue)))
+	assert.Equal(t, 2, testutil.CollectAndCount(promCollector.alertCounter))
+}
+
+func TestPrometheusMetricWorkerPool(t *testing.T) {
+	promCollector := createPrometheusMetric()
+	defer promCollector.destroy()
+
+	reportFirstDequeued := promCollector.reportEventQueued()
+	reportSecondDequeued := promCollector.reportEventQueued()
+	assert.Equal(t, float64(2), testutil.ToFloat64(promCollector.workerPoolQueueDepth))
+	time.Sleep(time.Millisecond)
+	reportFirstDequeued()
+	reportSecondDequeued()
+	assert.Equal(t, float64(0), testutil.ToFloat64(promCollector.workerPoolQueueDepth))
+	assert.Equal(t, 1, testutil.CollectAndCount(promCollector.workerPoolWaitTime))
+}
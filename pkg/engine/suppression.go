@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+)
+
+// Maximum interval between checks for expired suppression windows.
+const maxSuppressionCheckInterval = 1 * time.Second
+
+type alertSuppressionKey struct {
+	ruleName    string
+	containerID string
+	fingerprint string
+}
+
+type suppressionEntry struct {
+	// Start of the suppression window, when the first alert was sent
+	windowStart time.Time
+	// Last suppressed failure and the times of the suppressed failures
+	lastFailure     rule.RuleFailure
+	suppressedCount int
+	firstSeen       time.Time
+	lastSeen        time.Time
+}
+
+// alertSuppressor lets the first alert of a rule, container and fingerprint through
+// and suppresses its repeats until the window is over, then sends a summary of the suppressed alerts.
+type alertSuppressor struct {
+	window      time.Duration
+	sendAlert   func(rule.RuleFailure)
+	mutex       sync.Mutex
+	entries     map[alertSuppressionKey]*suppressionEntry
+	stopChannel chan struct{}
+	done        chan struct{}
+}
+
+func newAlertSuppressor(window time.Duration, sendAlert func(rule.RuleFailure)) *alertSuppressor {
+	suppressor := &alertSuppressor{
+		window:      window,
+		sendAlert:   sendAlert,
+		entries:     make(map[alertSuppressionKey]*suppressionEntry),
+		stopChannel: make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go suppressor.run()
+	return suppressor
+}
+
+// shouldSend records the failure and returns true if it should be sent, false if it is suppressed.
+func (suppressor *alertSuppressor) shouldSend(failure rule.RuleFailure) bool {
+	key := alertSuppressionKey{
+		ruleName:    failure.Name(),
+		containerID: failure.Event().ContainerID,
+		fingerprint: failure.Fingerprint(),
+	}
+	now := time.Now()
+
+	suppressor.mutex.Lock()
+	entry, ok := suppressor.entries[key]
+	if !ok || now.Sub(entry.windowStart) >= suppressor.window {
+		// The window of the previous entry is over, its summary goes out before the new alert
+		suppressor.entries[key] = &suppressionEntry{windowStart: now}
+		suppressor.mutex.Unlock()
+		if ok {
+			suppressor.sendSummary(entry)
+		}
+		return true
+	}
+	if entry.suppressedCount == 0 {
+		entry.firstSeen = now
+	}
+	entry.suppressedCount++
+	entry.lastSeen = now
+	entry.lastFailure = failure
+	suppressor.mutex.Unlock()
+	return false
+}
+
+// flushContainer sends the summaries of the container and forgets its entries.
+func (suppressor *alertSuppressor) flushContainer(containerID string) {
+	suppressor.flush(func(key alertSuppressionKey, _ *suppressionEntry) bool {
+		return key.containerID == containerID
+	})
+}
+
+// stop sends the pending summaries and stops checking for expired windows.
+func (suppressor *alertSuppressor) stop() {
+	close(suppressor.stopChannel)
+	<-suppressor.done
+	suppressor.flush(func(alertSuppressionKey, *suppressionEntry) bool {
+		return true
+	})
+}
+
+func (suppressor *alertSuppressor) run() {
+	defer close(suppressor.done)
+	interval := suppressor.window
+	if interval > maxSuppressionCheckInterval {
+		interval = maxSuppressionCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-suppressor.stopChannel:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			suppressor.flush(func(_ alertSuppressionKey, entry *suppressionEntry) bool {
+				return now.Sub(entry.windowStart) >= suppressor.window
+			})
+		}
+	}
+}
+
+// flush removes the entries matching the filter and sends their summaries.
+func (suppressor *alertSuppressor) flush(filter func(alertSuppressionKey, *suppressionEntry) bool) {
+	flushed := []*suppressionEntry{}
+	suppressor.mutex.Lock()
+	for key, entry := range suppressor.entries {
+		if filter(key, entry) {
+			flushed = append(flushed, entry)
+			delete(suppressor.entries, key)
+		}
+	}
+	suppressor.mutex.Unlock()
+
+	for _, entry := range flushed {
+		suppressor.sendSummary(entry)
+	}
+}
+
+func (suppressor *alertSuppressor) sendSummary(entry *suppressionEntry) {
+	if entry.suppressedCount == 0 {
+		return
+	}
+	suppressor.sendAlert(&rule.SuppressedRuleFailure{
+		RuleFailure:     entry.lastFailure,
+		SuppressedCount: entry.suppressedCount,
+		FirstSeen:       entry.firstSeen,
+		LastSeen:        entry.lastSeen,
+	})
+}
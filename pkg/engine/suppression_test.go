@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+)
+
+func openFailure(containerID, path string) rule.RuleFailure {
+	return &rule.R0002UnexpectedFileAccessFailure{
+		RuleName: rule.R0002UnexpectedFileAccessRuleName,
+		Err:      "Unexpected file access: " + path,
+		FailureEvent: &tracing.OpenEvent{
+			GeneralEvent: tracing.GeneralEvent{ContainerID: containerID},
+			PathName:     path,
+		},
+	}
+}
+
+func TestAlertSuppressor(t *testing.T) {
+	var mutex sync.Mutex
+	summaries := []*rule.SuppressedRuleFailure{}
+	suppressor := newAlertSuppressor(200*time.Millisecond, func(failure rule.RuleFailure) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		summaries = append(summaries, failure.(*rule.SuppressedRuleFailure))
+	})
+	getSummaries := func() []*rule.SuppressedRuleFailure {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]*rule.SuppressedRuleFailure{}, summaries...)
+	}
+
+	// Test case: the first alert is sent, the repeats are suppressed
+	if !suppressor.shouldSend(openFailure("test", "/etc/passwd")) {
+		t.Errorf("Expected the first alert to be sent")
+	}
+	for i := 0; i < 5; i++ {
+		if suppressor.shouldSend(openFailure("test", "/etc/passwd")) {
+			t.Errorf("Expected the repeated alert to be suppressed")
+		}
+	}
+
+	// Test case: a different fingerprint or container is not suppressed
+	if !suppressor.shouldSend(openFailure("test", "/etc/shadow")) {
+		t.Errorf("Expected an alert with a different fingerprint to be sent")
+	}
+	if !suppressor.shouldSend(openFailure("test2", "/etc/passwd")) {
+		t.Errorf("Expected an alert from a different container to be sent")
+	}
+
+	// Test case: a summary is sent when the window is over
+	time.Sleep(500 * time.Millisecond)
+	if len(getSummaries()) != 1 {
+		t.Fatalf("Expected 1 summary, got %v", len(getSummaries()))
+	}
+	summary := getSummaries()[0]
+	if summary.SuppressedCount != 5 {
+		t.Errorf("Expected 5 suppressed alerts, got %v", summary.SuppressedCount)
+	}
+	if summary.FirstSeen.After(summary.LastSeen) {
+		t.Errorf("Expected first seen %v to be before last seen %v", summary.FirstSeen, summary.LastSeen)
+	}
+	if summary.Event().ContainerID != "test" || summary.Name() != rule.R0002UnexpectedFileAccessRuleName {
+		t.Errorf("Expected the summary to carry the suppressed failure, got %v", summary.Error())
+	}
+
+	// Test case: the alert is sent again after the window
+	if !suppressor.shouldSend(openFailure("test", "/etc/passwd")) {
+		t.Errorf("Expected the alert to be sent after the window")
+	}
+
+	// Test case: the summaries of a stopped container are flushed
+	suppressor.shouldSend(openFailure("test", "/etc/passwd"))
+	suppressor.flushContainer("test")
+	if len(getSummaries()) != 2 {
+		t.Errorf("Expected 2 summaries, got %v", len(getSummaries()))
+	}
+
+	suppressor.stop()
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -82,6 +83,24 @@ func (ame *AlertManagerExporter) SendRuleAlert(failedRule rule.RuleFailure) {
 			},
 		},
 	}
+	if mitre := rule.GetMitreAttack(failedRule); !mitre.IsEmpty() {
+		myAlert.Labels["mitre_tactics"] = strings.Join(mitre.Tactics, ",")
+		myAlert.Labels["mitre_techniques"] = strings.Join(mitre.Techniques, ",")
+	}
+	evidence := failedRule.Evidence()
+	for _, key := range evidence.Keys() {
+		if value := evidence.String(key); value != "" {
+			myAlert.Labels["evidence_"+key] = value
+		}
+	}
+	if ancestry := rule.GetProcessAncestry(failedRule); len(ancestry) > 0 {
+		myAlert.Annotations["process_ancestry"] = FormatProcessAncestry(ancestry)
+	}
+	if suppressed := rule.GetSuppressedRuleFailure(failedRule); suppressed != nil {
+		myAlert.Annotations["suppressed_count"] = fmt.Sprintf("%d", suppressed.SuppressedCount)
+		myAlert.Annotations["first_seen"] = suppressed.FirstSeen.UTC().Format(time.RFC3339)
+		myAlert.Annotations["last_seen"] = suppressed.LastSeen.UTC().Format(time.RFC3339)
+	}
 
 	// Send the alert
 	params := alert.NewPostAlertsParams().WithContext(context.Background()).WithAlerts(models.PostableAlerts{&myAlert})
@@ -40,7 +40,8 @@ func TestSendAlert(t *testing.T) {
 		RuleName: "testrule",
 		Err:      "Application profile is missing",
 		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
-			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"}},
+			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"},
+			PathName: "/bin/sh"},
 	})
 	bytesData := <-recievedData
 
@@ -60,6 +61,8 @@ func TestSendAlert(t *testing.T) {
 	assert.Equal(t, "testpodname", alertLabels["pod_name"])
 	assert.Equal(t, "", alertLabels["node_name"])
 	assert.Equal(t, "none", alertLabels["severity"])
+	assert.Equal(t, "/bin/sh", alertLabels["evidence_path"])
+	assert.NotContains(t, alertLabels, "evidence_args")
 	assert.Equal(t, "Rule 'testrule' in 'testpodname' namespace 'testnamespace' failed", alert["annotations"].(map[string]interface{})["summary"])
 	assert.Equal(t, "Application profile is missing", alert["annotations"].(map[string]interface{})["message"])
 	assert.Equal(t, strings.HasPrefix(fmt.Sprint(alert["generatorURL"]), "https://armosec.github.io/kubecop/alertviewer/"), true)
@@ -0,0 +1,304 @@
+package exporters
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+)
+
+const (
+	// OverflowPolicyDropOldest drops the oldest queued alert to make room for the new one.
+	OverflowPolicyDropOldest = "drop-oldest"
+	// OverflowPolicyDropNewest drops the new alert.
+	OverflowPolicyDropNewest = "drop-newest"
+	// OverflowPolicyBlock blocks the caller until there is room in the queue.
+	OverflowPolicyBlock = "block"
+
+	// DefaultExporterQueueConfigKey is the key of the queue configuration applied to exporters without a specific one.
+	DefaultExporterQueueConfigKey = "default"
+
+	// Maximum number of timed out exporter calls which are left running in the background,
+	// when it is reached the exporter queue waits for them to return.
+	maxAbandonedExporterCalls = 10
+
+	// Reasons for failed alerts, reported in the metrics.
+	exporterFailureReasonTimeout = "timeout"
+	exporterFailureReasonPanic   = "panic"
+)
+
+// ExporterQueueConfig configures the queue in front of an exporter.
+type ExporterQueueConfig struct {
+	// QueueSize is the maximum number of alerts waiting to be sent by the exporter
+	QueueSize int `json:"queueSize" yaml:"queueSize"`
+	// OverflowPolicy is what to do when the queue is full: drop-oldest, drop-newest or block
+	OverflowPolicy string `json:"overflowPolicy" yaml:"overflowPolicy"`
+	// TimeoutSeconds is the maximum time an exporter may take to send a single alert
+	TimeoutSeconds int `json:"timeoutSeconds" yaml:"timeoutSeconds"`
+}
+
+func (config *ExporterQueueConfig) Validate() error {
+	if config.QueueSize == 0 {
+		config.QueueSize = 1000
+	}
+	if config.OverflowPolicy == "" {
+		config.OverflowPolicy = OverflowPolicyDropOldest
+	}
+	if config.TimeoutSeconds == 0 {
+		config.TimeoutSeconds = 10
+	}
+	if config.QueueSize < 0 {
+		return fmt.Errorf("queueSize must not be negative")
+	}
+	if config.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeoutSeconds must not be negative")
+	}
+	switch config.OverflowPolicy {
+	case OverflowPolicyDropOldest, OverflowPolicyDropNewest, OverflowPolicyBlock:
+	default:
+		return fmt.Errorf("overflowPolicy must be %s, %s or %s", OverflowPolicyDropOldest, OverflowPolicyDropNewest, OverflowPolicyBlock)
+	}
+	return nil
+}
+
+// getExporterQueueConfig returns the queue configuration of the exporter kind, falling back to the default one.
+func getExporterQueueConfig(queuesConfig map[string]ExporterQueueConfig, exporterKind string) ExporterQueueConfig {
+	config, ok := queuesConfig[exporterKind]
+	if !ok {
+		config = queuesConfig[DefaultExporterQueueConfigKey]
+	}
+	if err := config.Validate(); err != nil {
+		// Configurations are validated when loaded, this should not happen
+		log.Errorf("invalid queue config for exporter %s, using defaults: %v", exporterKind, err)
+		config = ExporterQueueConfig{}
+		_ = config.Validate()
+	}
+	return config
+}
+
+// exporterAlert is an alert waiting in an exporter queue, either a rule alert or a malware alert.
+type exporterAlert struct {
+	failedRule         rule.RuleFailure
+	malwareDescription *scan.MalwareDescription
+}
+
+// asyncExporter sends the alerts to an exporter from its own goroutine, behind a bounded queue,
+// so a slow exporter neither blocks the caller nor the other exporters.
+type asyncExporter struct {
+	name     string
+	exporter Exporter
+	config   ExporterQueueConfig
+	alerts   chan exporterAlert
+	// closing is closed first on Close, to release the callers blocked on a full queue
+	closing     chan struct{}
+	closingOnce sync.Once
+	closeOnce   sync.Once
+	// stopLock makes sure no alert is being enqueued once the queue is stopped, so every queued alert is sent
+	stopLock      sync.RWMutex
+	stopped       bool
+	stopChannel   chan struct{}
+	done          chan struct{}
+	abandoned     chan struct{}
+	promCollector *prometheusMetric
+	healthStats   exporterHealthStats
+}
+
+// newAsyncExporter wraps the exporter with a queue, the name identifies the exporter in logs and metrics.
+func newAsyncExporter(name string, exporter Exporter, config ExporterQueueConfig) *asyncExporter {
+	asyncExp := &asyncExporter{
+		name:          name,
+		exporter:      exporter,
+		config:        config,
+		alerts:        make(chan exporterAlert, config.QueueSize),
+		closing:       make(chan struct{}),
+		stopChannel:   make(chan struct{}),
+		done:          make(chan struct{}),
+		abandoned:     make(chan struct{}, maxAbandonedExporterCalls),
+		promCollector: getPrometheusMetric(),
+	}
+	go asyncExp.run()
+	return asyncExp
+}
+
+func (asyncExp *asyncExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	asyncExp.enqueue(exporterAlert{failedRule: failedRule})
+}
+
+func (asyncExp *asyncExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
+	asyncExp.enqueue(exporterAlert{malwareDescription: &malwareDescription})
+}
+
+// Close sends the queued alerts, then stops the queue and closes the wrapped exporter.
+// Alerts sent after Close are dropped.
+func (asyncExp *asyncExporter) Close() error {
+	asyncExp.releaseBlockedCallers()
+	asyncExp.closeOnce.Do(func() {
+		asyncExp.stopLock.Lock()
+		asyncExp.stopped = true
+		asyncExp.stopLock.Unlock()
+		close(asyncExp.stopChannel)
+	})
+	<-asyncExp.done
+	if closer, ok := asyncExp.exporter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// releaseBlockedCallers makes the callers blocked on a full queue, and the next ones, drop their alert instead of waiting.
+func (asyncExp *asyncExporter) releaseBlockedCallers() {
+	asyncExp.closingOnce.Do(func() {
+		close(asyncExp.closing)
+	})
+}
+
+func (asyncExp *asyncExporter) enqueue(alert exporterAlert) {
+	asyncExp.stopLock.RLock()
+	defer asyncExp.stopLock.RUnlock()
+	if asyncExp.stopped {
+		asyncExp.reportDropped()
+		return
+	}
+
+	select {
+	case asyncExp.alerts <- alert:
+		asyncExp.promCollector.reportExporterAlertQueued(asyncExp.name)
+		return
+	default:
+	}
+
+	// The queue is full
+	switch asyncExp.config.OverflowPolicy {
+	case OverflowPolicyBlock:
+		select {
+		case asyncExp.alerts <- alert:
+			asyncExp.promCollector.reportExporterAlertQueued(asyncExp.name)
+		case <-asyncExp.closing:
+			asyncExp.reportDropped()
+		}
+	case OverflowPolicyDropNewest:
+		asyncExp.reportDropped()
+	default:
+		// Make room by dropping the oldest alert, retry until the new alert fits
+		for {
+			select {
+			case <-asyncExp.alerts:
+				asyncExp.promCollector.reportExporterAlertDequeued(asyncExp.name)
+				asyncExp.reportDropped()
+			default:
+			}
+			select {
+			case asyncExp.alerts <- alert:
+				asyncExp.promCollector.reportExporterAlertQueued(asyncExp.name)
+				return
+			default:
+			}
+		}
+	}
+}
+
+func (asyncExp *asyncExporter) run() {
+	defer close(asyncExp.done)
+	for {
+		select {
+		case alert := <-asyncExp.alerts:
+			asyncExp.promCollector.reportExporterAlertDequeued(asyncExp.name)
+			asyncExp.send(alert)
+		case <-asyncExp.stopChannel:
+			// Send what is left in the queue, unless the exporter doesn't answer anymore
+			timedOut := false
+			for {
+				select {
+				case alert := <-asyncExp.alerts:
+					asyncExp.promCollector.reportExporterAlertDequeued(asyncExp.name)
+					if timedOut {
+						asyncExp.reportDropped()
+						continue
+					}
+					timedOut = asyncExp.send(alert)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send calls the exporter with a timeout and returns true if the call timed out. A call which times out keeps
+// running in the background, up to maxAbandonedExporterCalls of them, after which send waits for one of them
+// to return, or for the exporter to be closed.
+func (asyncExp *asyncExporter) send(alert exporterAlert) bool {
+	result := make(chan string, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Errorf("exporter %s panicked: %v", asyncExp.name, r)
+				result <- exporterFailureReasonPanic
+			}
+		}()
+		if alert.failedRule != nil {
+			asyncExp.exporter.SendRuleAlert(alert.failedRule)
+		} else if alert.malwareDescription != nil {
+			asyncExp.exporter.SendMalwareAlert(*alert.malwareDescription)
+		}
+		result <- ""
+	}()
+
+	timer := time.NewTimer(time.Duration(asyncExp.config.TimeoutSeconds) * time.Second)
+	defer timer.Stop()
+	select {
+	case failureReason := <-result:
+		if failureReason != "" {
+			asyncExp.reportFailed(failureReason)
+		} else {
+			asyncExp.reportSent()
+		}
+		return false
+	case <-timer.C:
+		log.Warnf("exporter %s did not send the alert within %d seconds", asyncExp.name, asyncExp.config.TimeoutSeconds)
+		asyncExp.reportFailed(exporterFailureReasonTimeout)
+		// Keep track of the abandoned call, blocks if there are too many of them
+		select {
+		case asyncExp.abandoned <- struct{}{}:
+			go func() {
+				<-result
+				<-asyncExp.abandoned
+			}()
+		case <-asyncExp.stopChannel:
+			// The exporter is being closed, don't wait for it
+		}
+		return true
+	}
+}
+
+func (asyncExp *asyncExporter) reportSent() {
+	asyncExp.promCollector.reportExporterAlertSent(asyncExp.name)
+	asyncExp.healthStats.reportSent()
+}
+
+func (asyncExp *asyncExporter) reportDropped() {
+	asyncExp.promCollector.reportExporterAlertDropped(asyncExp.name)
+	asyncExp.healthStats.reportDropped()
+}
+
+func (asyncExp *asyncExporter) reportFailed(reason string) {
+	asyncExp.promCollector.reportExporterAlertFailed(asyncExp.name, reason)
+	asyncExp.healthStats.reportFailed(reason)
+}
+
+// Health returns the health of the exporter and the state of its queue.
+func (asyncExp *asyncExporter) Health() ExporterHealth {
+	return asyncExp.healthStats.health(asyncExp.name, len(asyncExp.alerts), cap(asyncExp.alerts))
+}
+
+// exporterKind returns the kind of the exporter from its name (the part before ':').
+func exporterKind(name string) string {
+	kind, _, _ := strings.Cut(name, ":")
+	return kind
+}
@@ -0,0 +1,203 @@
+package exporters
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingExporter records the alerts it receives, each call blocks until release is closed.
+type blockingExporter struct {
+	release chan struct{}
+	mutex   sync.Mutex
+	rules   []string
+	malware []string
+}
+
+func newBlockingExporter() *blockingExporter {
+	return &blockingExporter{release: make(chan struct{})}
+}
+
+func (exporter *blockingExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	<-exporter.release
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.rules = append(exporter.rules, failedRule.Name())
+}
+
+func (exporter *blockingExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
+	<-exporter.release
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.malware = append(exporter.malware, malwareDescription.Name)
+}
+
+func (exporter *blockingExporter) getRules() []string {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	return append([]string{}, exporter.rules...)
+}
+
+func testRuleFailure(name string) rule.RuleFailure {
+	return &rule.R0001UnexpectedProcessLaunchedFailure{
+		RuleName:     name,
+		FailureEvent: &tracing.ExecveEvent{},
+	}
+}
+
+func TestAsyncExporterDoesNotBlockCaller(t *testing.T) {
+	exporter := newBlockingExporter()
+	asyncExp := newAsyncExporter("test-nonblocking", exporter, ExporterQueueConfig{QueueSize: 10, OverflowPolicy: OverflowPolicyDropNewest, TimeoutSeconds: 10})
+	sentAlerts := testutil.ToFloat64(asyncExp.promCollector.exporterSentAlerts.WithLabelValues("test-nonblocking"))
+
+	start := time.Now()
+	asyncExp.SendRuleAlert(testRuleFailure("first"))
+	asyncExp.SendMalwareAlert(scan.MalwareDescription{Name: "malware"})
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	close(exporter.release)
+	asyncExp.Close()
+	assert.Equal(t, []string{"first"}, exporter.getRules())
+	assert.Equal(t, []string{"malware"}, exporter.malware)
+	assert.Equal(t, sentAlerts+2, testutil.ToFloat64(asyncExp.promCollector.exporterSentAlerts.WithLabelValues("test-nonblocking")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(asyncExp.promCollector.exporterQueueDepth.WithLabelValues("test-nonblocking")))
+}
+
+func TestAsyncExporterOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy   string
+		expected []string
+	}{
+		{policy: OverflowPolicyDropNewest, expected: []string{"first", "second"}},
+		{policy: OverflowPolicyDropOldest, expected: []string{"first", "third"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			exporter := newBlockingExporter()
+			name := "test-" + tt.policy
+			asyncExp := newAsyncExporter(name, exporter, ExporterQueueConfig{QueueSize: 1, OverflowPolicy: tt.policy, TimeoutSeconds: 10})
+			dropped := testutil.ToFloat64(asyncExp.promCollector.exporterDroppedAlerts.WithLabelValues(name))
+
+			// The first alert is taken by the exporter goroutine, the second one waits in the queue
+			asyncExp.SendRuleAlert(testRuleFailure("first"))
+			assert.Eventually(t, func() bool { return len(asyncExp.alerts) == 0 }, 1*time.Second, 10*time.Millisecond)
+			asyncExp.SendRuleAlert(testRuleFailure("second"))
+			// The queue is full
+			asyncExp.SendRuleAlert(testRuleFailure("third"))
+
+			close(exporter.release)
+			asyncExp.Close()
+			assert.Equal(t, tt.expected, exporter.getRules())
+			assert.Equal(t, dropped+1, testutil.ToFloat64(asyncExp.promCollector.exporterDroppedAlerts.WithLabelValues(name)))
+		})
+	}
+}
+
+func TestAsyncExporterBlockPolicy(t *testing.T) {
+	exporter := newBlockingExporter()
+	asyncExp := newAsyncExporter("test-block", exporter, ExporterQueueConfig{QueueSize: 1, OverflowPolicy: OverflowPolicyBlock, TimeoutSeconds: 10})
+
+	asyncExp.SendRuleAlert(testRuleFailure("first"))
+	assert.Eventually(t, func() bool { return len(asyncExp.alerts) == 0 }, 1*time.Second, 10*time.Millisecond)
+	asyncExp.SendRuleAlert(testRuleFailure("second"))
+
+	// The queue is full, the caller should block until the exporter makes progress
+	sent := make(chan struct{})
+	go func() {
+		asyncExp.SendRuleAlert(testRuleFailure("third"))
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatalf("Expected the caller to block")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(exporter.release)
+	select {
+	case <-sent:
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for the caller to be released")
+	}
+	asyncExp.Close()
+	assert.Equal(t, []string{"first", "second", "third"}, exporter.getRules())
+}
+
+func TestAsyncExporterClose(t *testing.T) {
+	exporter := newBlockingExporter()
+	name := "test-close"
+	asyncExp := newAsyncExporter(name, exporter, ExporterQueueConfig{QueueSize: 1, OverflowPolicy: OverflowPolicyBlock, TimeoutSeconds: 10})
+	dropped := testutil.ToFloat64(asyncExp.promCollector.exporterDroppedAlerts.WithLabelValues(name))
+
+	asyncExp.SendRuleAlert(testRuleFailure("first"))
+	assert.Eventually(t, func() bool { return len(asyncExp.alerts) == 0 }, 1*time.Second, 10*time.Millisecond)
+	asyncExp.SendRuleAlert(testRuleFailure("second"))
+	sent := make(chan struct{})
+	go func() {
+		asyncExp.SendRuleAlert(testRuleFailure("blocked"))
+		close(sent)
+	}()
+
+	// Close releases the blocked caller, the queued alerts are still sent
+	closed := make(chan struct{})
+	go func() {
+		asyncExp.Close()
+		close(closed)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for the blocked caller to be released")
+	}
+	close(exporter.release)
+	<-closed
+	rules := exporter.getRules()
+	assert.Equal(t, []string{"first", "second"}, rules[:2])
+
+	// Alerts sent after close are dropped and counted
+	asyncExp.SendRuleAlert(testRuleFailure("after-close"))
+	assert.Equal(t, rules, exporter.getRules())
+	assert.Equal(t, dropped+float64(4-len(rules)), testutil.ToFloat64(asyncExp.promCollector.exporterDroppedAlerts.WithLabelValues(name)))
+}
+
+func TestAsyncExporterTimeout(t *testing.T) {
+	exporter := newBlockingExporter()
+	asyncExp := newAsyncExporter("test-timeout", exporter, ExporterQueueConfig{QueueSize: 10, OverflowPolicy: OverflowPolicyDropNewest, TimeoutSeconds: 1})
+	failed := testutil.ToFloat64(asyncExp.promCollector.exporterFailedAlerts.WithLabelValues("test-timeout", exporterFailureReasonTimeout))
+
+	asyncExp.SendRuleAlert(testRuleFailure("first"))
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(asyncExp.promCollector.exporterFailedAlerts.WithLabelValues("test-timeout", exporterFailureReasonTimeout)) == failed+1
+	}, 3*time.Second, 50*time.Millisecond)
+
+	close(exporter.release)
+	asyncExp.Close()
+}
+
+func TestExporterQueueConfigValidate(t *testing.T) {
+	// Test case: defaults
+	config := ExporterQueueConfig{}
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, 1000, config.QueueSize)
+	assert.Equal(t, OverflowPolicyDropOldest, config.OverflowPolicy)
+	assert.Equal(t, 10, config.TimeoutSeconds)
+
+	// Test case: unknown policy
+	config = ExporterQueueConfig{OverflowPolicy: "drop-all"}
+	assert.Error(t, config.Validate())
+
+	// Test case: per exporter config with default fallback
+	queuesConfig := map[string]ExporterQueueConfig{
+		DefaultExporterQueueConfigKey: {QueueSize: 5},
+		HTTPExporterKind:              {OverflowPolicy: OverflowPolicyBlock},
+	}
+	assert.Equal(t, 5, getExporterQueueConfig(queuesConfig, StdoutExporterKind).QueueSize)
+	assert.Equal(t, OverflowPolicyBlock, getExporterQueueConfig(queuesConfig, HTTPExporterKind).OverflowPolicy)
+	assert.Equal(t, AlertManagerExporterKind, exporterKind(AlertManagerExporterKind+":localhost:9093"))
+}
@@ -0,0 +1,141 @@
+package exporters
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultExportersConfigReloadInterval is the interval in which the exporters configuration file is checked for changes.
+	DefaultExportersConfigReloadInterval = 10 * time.Second
+)
+
+// LoadExportersConfig reads the exporters configuration from the given YAML file and validates it.
+func LoadExportersConfig(path string) (ExportersConfig, error) {
+	var config ExportersConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read exporters config file %s: %v", path, err)
+	}
+	if config, err = parseExportersConfig(data); err != nil {
+		return config, fmt.Errorf("invalid exporters config file %s: %v", path, err)
+	}
+	return config, nil
+}
+
+func parseExportersConfig(data []byte) (ExportersConfig, error) {
+	var config ExportersConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, err
+	}
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// Validate checks the fields which are set in the configuration.
+// Fields that are not set are taken from the environment variables when the exporters are created.
+func (config *ExportersConfig) Validate() error {
+	if config.AlertManagerExporterUrls != "" {
+		for _, url := range strings.Split(config.AlertManagerExporterUrls, AlertManagerSepartorDelimiter) {
+			if url == "" {
+				return fmt.Errorf("alertManagerExporterUrls: empty URL in %q", config.AlertManagerExporterUrls)
+			}
+			if strings.Contains(url, "://") {
+				return fmt.Errorf("alertManagerExporterUrls: %q must be in the form host:port (without scheme)", url)
+			}
+		}
+	}
+	if config.SyslogExporter != "" {
+		if _, _, err := net.SplitHostPort(config.SyslogExporter); err != nil {
+			return fmt.Errorf("syslogExporterURL: %q must be in the form host:port: %v", config.SyslogExporter, err)
+		}
+	}
+	if config.CsvMalwareExporterPath != "" && config.CsvRuleExporterPath == "" && os.Getenv("EXPORTER_CSV_RULE_PATH") == "" {
+		return fmt.Errorf("CsvMalwareExporterPath: CsvRuleExporterPath must be set as well")
+	}
+	for kind, queueConfig := range config.ExporterQueues {
+		switch kind {
+		case DefaultExporterQueueConfigKey, AlertManagerExporterKind, StdoutExporterKind, SyslogExporterKind, CsvExporterKind, HTTPExporterKind,
+			KubernetesEventsExporterKind, RuntimeAlertExporterKind, OTLPExporterKind, KafkaExporterKind:
+		default:
+			return fmt.Errorf("exporterQueues: unknown exporter %q", kind)
+		}
+		if err := queueConfig.Validate(); err != nil {
+			return fmt.Errorf("exporterQueues.%s: %v", kind, err)
+		}
+	}
+	if config.HTTPExporterConfig != nil {
+		// Validate a copy, so the defaults are applied only when the exporter is created
+		httpConfig := *config.HTTPExporterConfig
+		if err := httpConfig.Validate(); err != nil {
+			return fmt.Errorf("httpExporterConfig: %v", err)
+		}
+	}
+	if config.OTLPExporterConfig != nil {
+		otlpConfig := *config.OTLPExporterConfig
+		if err := otlpConfig.Validate(); err != nil {
+			return fmt.Errorf("otlpExporterConfig: %v", err)
+		}
+	}
+	if config.KafkaExporterConfig != nil {
+		kafkaConfig := *config.KafkaExporterConfig
+		if err := kafkaConfig.Validate(); err != nil {
+			return fmt.Errorf("kafkaExporterConfig: %v", err)
+		}
+	}
+	if config.RuntimeAlertExporterConfig != nil {
+		runtimeAlertConfig := *config.RuntimeAlertExporterConfig
+		if err := runtimeAlertConfig.Validate(); err != nil {
+			return fmt.Errorf("runtimeAlertExporterConfig: %v", err)
+		}
+	}
+	return nil
+}
+
+// WatchExportersConfigFile checks the exporters configuration file for changes every interval,
+// and reloads the exporters of the bus when it changes. Invalid configurations are reported and ignored.
+// Polling the file content (rather than watching inotify events) also catches the symlink swap
+// used by Kubernetes to update mounted ConfigMaps.
+func (e *ExporterBus) WatchExportersConfigFile(path string, interval time.Duration, stopChannel <-chan struct{}) {
+	lastContent, err := os.ReadFile(path)
+	if err != nil {
+		log.Errorf("Failed to read exporters config file %s: %v\n", path, err)
+	}
+	for {
+		select {
+		case <-stopChannel:
+			return
+		case <-time.After(interval):
+			content, err := os.ReadFile(path)
+			if err != nil {
+				log.Errorf("Failed to read exporters config file %s: %v\n", path, err)
+				continue
+			}
+			if bytes.Equal(content, lastContent) {
+				continue
+			}
+			lastContent = content
+
+			config, err := parseExportersConfig(content)
+			if err != nil {
+				log.Errorf("Invalid exporters config file %s, keeping the current exporters: %v\n", path, err)
+				continue
+			}
+			if err := e.Reload(config); err != nil {
+				log.Errorf("Failed to reload exporters, keeping the current exporters: %v\n", err)
+				continue
+			}
+			log.Infof("Exporters config reloaded from %s\n", path)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package exporters
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadExportersConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	// Test case: valid config
+	path := filepath.Join(dir, "exporters.yaml")
+	err := os.WriteFile(path, []byte(`
+stdoutExporter: false
+alertManagerExporterUrls: "localhost:9093,localhost:9094"
+syslogExporterURL: "localhost:514"
+httpExporterConfig:
+  url: "http://localhost:8080/alerts"
+  method: PUT
+  timeoutSeconds: 5
+  headers:
+    Authorization: "Bearer token"
+`), 0644)
+	assert.NoError(t, err)
+	config, err := LoadExportersConfig(path)
+	assert.NoError(t, err)
+	assert.NotNil(t, config.StdoutExporter)
+	assert.False(t, *config.StdoutExporter)
+	assert.Equal(t, "localhost:9093,localhost:9094", config.AlertManagerExporterUrls)
+	assert.Equal(t, "localhost:514", config.SyslogExporter)
+	assert.Equal(t, "http://localhost:8080/alerts", config.HTTPExporterConfig.URL)
+	assert.Equal(t, "PUT", config.HTTPExporterConfig.Method)
+	assert.Equal(t, 5, config.HTTPExporterConfig.TimeoutSeconds)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer token"}, config.HTTPExporterConfig.Headers)
+
+	// Test case: missing file
+	_, err = LoadExportersConfig(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+
+	// Test case: unknown field
+	err = os.WriteFile(path, []byte("stdoutExporters: true\n"), 0644)
+	assert.NoError(t, err)
+	_, err = LoadExportersConfig(path)
+	assert.ErrorContains(t, err, "stdoutExporters")
+
+	// Test case: invalid HTTP exporter method
+	err = os.WriteFile(path, []byte("httpExporterConfig:\n  url: http://localhost\n  method: DELETE\n"), 0644)
+	assert.NoError(t, err)
+	_, err = LoadExportersConfig(path)
+	assert.ErrorContains(t, err, "httpExporterConfig")
+
+	// Test case: invalid syslog address
+	err = os.WriteFile(path, []byte("syslogExporterURL: localhost\n"), 0644)
+	assert.NoError(t, err)
+	_, err = LoadExportersConfig(path)
+	assert.ErrorContains(t, err, "syslogExporterURL")
+
+	// Test case: invalid exporter queue
+	err = os.WriteFile(path, []byte("exporterQueues:\n  http:\n    overflowPolicy: drop-all\n"), 0644)
+	assert.NoError(t, err)
+	_, err = LoadExportersConfig(path)
+	assert.ErrorContains(t, err, "exporterQueues.http")
+
+	// Test case: unknown exporter queue
+	err = os.WriteFile(path, []byte("exporterQueues:\n  splunk:\n    queueSize: 10\n"), 0644)
+	assert.NoError(t, err)
+	_, err = LoadExportersConfig(path)
+	assert.ErrorContains(t, err, "splunk")
+
+	// Test case: Kafka exporter without brokers
+	err = os.WriteFile(path, []byte("kafkaExporterConfig:\n  ruleAlertsTopic: alerts\n"), 0644)
+	assert.NoError(t, err)
+	_, err = LoadExportersConfig(path)
+	assert.ErrorContains(t, err, "kafkaExporterConfig")
+
+	// Test case: alert manager URL with scheme
+	err = os.WriteFile(path, []byte("alertManagerExporterUrls: http://localhost:9093\n"), 0644)
+	assert.NoError(t, err)
+	_, err = LoadExportersConfig(path)
+	assert.ErrorContains(t, err, "alertManagerExporterUrls")
+}
+
+func TestExporterBusWatchExportersConfigFile(t *testing.T) {
+	// Make sure no other exporter is picked up from the environment
+	for _, env := range []string{"ALERTMANAGER_URLS", "SYSLOG_HOST", "EXPORTER_CSV_RULE_PATH", "HTTP_ENDPOINT_URL"} {
+		t.Setenv(env, "")
+	}
+
+	firstServerAlerts := make(chan HTTPAlertsList, 10)
+	secondServerAlerts := make(chan HTTPAlertsList, 10)
+	newServer := func(alerts chan HTTPAlertsList) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Errorf("Failed to read request body: %v", err)
+				return
+			}
+			alertsList := HTTPAlertsList{}
+			if err := json.Unmarshal(body, &alertsList); err != nil {
+				t.Errorf("Failed to unmarshal request body: %v", err)
+				return
+			}
+			alerts <- alertsList
+		}))
+	}
+	firstServer := newServer(firstServerAlerts)
+	defer firstServer.Close()
+	secondServer := newServer(secondServerAlerts)
+	defer secondServer.Close()
+
+	path := filepath.Join(t.TempDir(), "exporters.yaml")
+	writeConfig := func(url string) {
+		err := os.WriteFile(path, []byte("stdoutExporter: false\nhttpExporterConfig:\n  url: "+url+"\n"), 0644)
+		assert.NoError(t, err)
+	}
+	writeConfig(firstServer.URL)
+
+	config, err := LoadExportersConfig(path)
+	assert.NoError(t, err)
+	bus := InitExporters(config, nil, nil)
+	stop := make(chan struct{})
+	defer close(stop)
+	go bus.WatchExportersConfigFile(path, 10*time.Millisecond, stop)
+
+	failedRule := &rule.R0001UnexpectedProcessLaunchedFailure{
+		RuleName: "testrule",
+		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
+			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"}},
+	}
+
+	bus.SendRuleAlert(failedRule)
+	select {
+	case alertsList := <-firstServerAlerts:
+		assert.Equal(t, "testrule", alertsList.Spec.Alerts[0].RuleName)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for alert on the first server")
+	}
+
+	// An invalid config should keep the current exporters
+	err = os.WriteFile(path, []byte("httpExporterConfig:\n  method: DELETE\n"), 0644)
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	bus.SendRuleAlert(failedRule)
+	select {
+	case <-firstServerAlerts:
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for alert on the first server after invalid config")
+	}
+
+	// A valid config should replace the exporters
+	writeConfig(secondServer.URL)
+	assert.Eventually(t, func() bool {
+		exporters, release := bus.acquireExporters()
+		defer release()
+		return len(exporters) == 1 && exporters[0].(*asyncExporter).exporter.(*HTTPExporter).config.URL == secondServer.URL
+	}, 1*time.Second, 10*time.Millisecond)
+	bus.SendRuleAlert(failedRule)
+	select {
+	case alertsList := <-secondServerAlerts:
+		assert.Equal(t, "testrule", alertsList.Spec.Alerts[0].RuleName)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for alert on the second server")
+	}
+	assert.Len(t, firstServerAlerts, 0)
+}
+
+// closeRecordingExporter is a blockingExporter recording when it receives an alert and when it is closed.
+type closeRecordingExporter struct {
+	*blockingExporter
+	received chan struct{}
+	closed   chan struct{}
+}
+
+func (exporter *closeRecordingExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	exporter.received <- struct{}{}
+	exporter.blockingExporter.SendRuleAlert(failedRule)
+}
+
+func (exporter *closeRecordingExporter) Close() error {
+	close(exporter.closed)
+	return nil
+}
+
+func TestExporterBusClosesExportersAfterSenders(t *testing.T) {
+	exporter := &closeRecordingExporter{blockingExporter: newBlockingExporter(), received: make(chan struct{}, 1), closed: make(chan struct{})}
+	bus := ExporterBus{exporters: &exporterSet{exporters: []Exporter{exporter}}}
+
+	sent := make(chan struct{})
+	go func() {
+		bus.SendRuleAlert(testRuleFailure("inflight"))
+		close(sent)
+	}()
+	<-exporter.received
+
+	closed := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+	select {
+	case <-exporter.closed:
+		t.Fatalf("Expected the exporter not to be closed while an alert is being sent to it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(exporter.release)
+	<-sent
+	<-closed
+	assert.Equal(t, []string{"inflight"}, exporter.getRules())
+	select {
+	case <-exporter.closed:
+	default:
+		t.Fatalf("Expected the exporter to be closed")
+	}
+
+	// alerts sent after the bus is closed go nowhere
+	bus.SendRuleAlert(testRuleFailure("after-close"))
+	assert.Equal(t, []string{"inflight"}, exporter.getRules())
+}
+
+func TestExporterBusReloadKeepsExportersOnInitFailure(t *testing.T) {
+	for _, env := range []string{"ALERTMANAGER_URLS", "SYSLOG_HOST", "EXPORTER_CSV_RULE_PATH", "HTTP_ENDPOINT_URL"} {
+		t.Setenv(env, "")
+	}
+	stdout := false
+	bus := InitExporters(ExportersConfig{StdoutExporter: &stdout, HTTPExporterConfig: &HTTPExporterConfig{URL: "http://localhost:8080/first"}}, nil, nil)
+	defer bus.Close()
+
+	// The RuntimeAlert exporter can't be initialized without a Kubernetes client
+	err := bus.Reload(ExportersConfig{
+		StdoutExporter:             &stdout,
+		HTTPExporterConfig:         &HTTPExporterConfig{URL: "http://localhost:8080/second"},
+		RuntimeAlertExporterConfig: &RuntimeAlertExporterConfig{},
+	})
+	assert.ErrorContains(t, err, "RuntimeAlert")
+	exporters, release := bus.acquireExporters()
+	defer release()
+	assert.Len(t, exporters, 1)
+	assert.Equal(t, "http://localhost:8080/first", exporters[0].(*asyncExporter).exporter.(*HTTPExporter).config.URL)
+}
+
+func TestExporterBusReloadWithHungExporter(t *testing.T) {
+	for _, env := range []string{"ALERTMANAGER_URLS", "SYSLOG_HOST", "EXPORTER_CSV_RULE_PATH", "HTTP_ENDPOINT_URL"} {
+		t.Setenv(env, "")
+	}
+	hung := newBlockingExporter()
+	defer close(hung.release)
+	asyncExp := newAsyncExporter("test-hung", hung, ExporterQueueConfig{QueueSize: 1, OverflowPolicy: OverflowPolicyBlock, TimeoutSeconds: 1})
+	// The exporter already left the maximum number of calls running in the background
+	for i := 0; i < maxAbandonedExporterCalls; i++ {
+		asyncExp.abandoned <- struct{}{}
+	}
+	bus := ExporterBus{exporters: &exporterSet{exporters: []Exporter{asyncExp}}, recentAlerts: newRecentAlerts(DefaultRecentAlertsSize)}
+
+	bus.SendRuleAlert(testRuleFailure("first"))
+	assert.Eventually(t, func() bool { return len(asyncExp.alerts) == 0 }, 1*time.Second, 10*time.Millisecond)
+	bus.SendRuleAlert(testRuleFailure("second"))
+	sent := make(chan struct{})
+	go func() {
+		bus.SendRuleAlert(testRuleFailure("blocked"))
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatalf("Expected the caller to block on the full queue")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The reload releases the blocked caller and the hung exporter is closed, dropping its queued alerts
+	stdout := false
+	assert.NoError(t, bus.Reload(ExportersConfig{StdoutExporter: &stdout, HTTPExporterConfig: &HTTPExporterConfig{URL: "http://localhost:8080/alerts"}}))
+	defer bus.Close()
+	select {
+	case <-sent:
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for the blocked caller to be released")
+	}
+	select {
+	case <-asyncExp.done:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Timed out waiting for the hung exporter to be closed")
+	}
+	assert.Empty(t, hung.getRules())
+}
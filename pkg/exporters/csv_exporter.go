@@ -4,6 +4,8 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/armosec/kubecop/pkg/engine/rule"
 	"github.com/armosec/kubecop/pkg/scan"
@@ -56,9 +58,16 @@ func (ce *CsvExporter) SendRuleAlert(failedRule rule.RuleFailure) {
 	}
 	defer csvFile.Close()
 
+	mitre := rule.GetMitreAttack(failedRule)
+	suppressedCount, firstSeen, lastSeen := "", "", ""
+	if suppressed := rule.GetSuppressedRuleFailure(failedRule); suppressed != nil {
+		suppressedCount = fmt.Sprintf("%d", suppressed.SuppressedCount)
+		firstSeen = suppressed.FirstSeen.UTC().Format(time.RFC3339)
+		lastSeen = suppressed.LastSeen.UTC().Format(time.RFC3339)
+	}
 	csvWriter := csv.NewWriter(csvFile)
 	defer csvWriter.Flush()
-	csvWriter.Write([]string{
+	row := []string{
 		failedRule.Name(),
 		failedRule.Error(),
 		failedRule.FixSuggestion(),
@@ -74,7 +83,19 @@ func (ce *CsvExporter) SendRuleAlert(failedRule rule.RuleFailure) {
 		fmt.Sprintf("%d", failedRule.Event().Ppid),
 		fmt.Sprintf("%d", failedRule.Event().MountNsID),
 		fmt.Sprintf("%d", failedRule.Event().Timestamp),
-	})
+		FormatProcessAncestry(rule.GetProcessAncestry(failedRule)),
+		strings.Join(mitre.Tactics, ","),
+		strings.Join(mitre.Techniques, ","),
+		suppressedCount,
+		firstSeen,
+		lastSeen,
+	}
+	// one column per evidence key, empty if the rule has no such evidence
+	evidence := failedRule.Evidence()
+	for _, key := range rule.EvidenceKeys {
+		row = append(row, evidence.String(key))
+	}
+	csvWriter.Write(row)
 }
 
 func writeRuleHeaders(csvPath string) {
@@ -87,7 +108,7 @@ func writeRuleHeaders(csvPath string) {
 
 	csvWriter := csv.NewWriter(csvFile)
 	defer csvWriter.Flush()
-	csvWriter.Write([]string{
+	headers := []string{
 		"Rule Name",
 		"Alert Message",
 		"Fix Suggestion",
@@ -103,7 +124,17 @@ func writeRuleHeaders(csvPath string) {
 		"PPID",
 		"Mount Namespace ID",
 		"Timestamp",
-	})
+		"Process Ancestry",
+		"MITRE Tactics",
+		"MITRE Techniques",
+		"Suppressed Count",
+		"First Seen",
+		"Last Seen",
+	}
+	for _, key := range rule.EvidenceKeys {
+		headers = append(headers, "Evidence "+key)
+	}
+	csvWriter.Write(headers)
 }
 
 func (ce *CsvExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
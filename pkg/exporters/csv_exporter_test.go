@@ -4,10 +4,12 @@ import (
 	"encoding/csv"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/armosec/kubecop/pkg/engine/rule"
 	"github.com/armosec/kubecop/pkg/scan"
 	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -21,7 +23,19 @@ func TestCsvExporter(t *testing.T) {
 		RuleName: "testrule",
 		Err:      "Application profile is missing",
 		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
-			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"}},
+			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"},
+			PathName: "/bin/sh", Args: []string{"sh", "-c", "id"}},
+	})
+	firstSeen := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	csvExporter.SendRuleAlert(&rule.SuppressedRuleFailure{
+		RuleFailure: &rule.R0001UnexpectedProcessLaunchedFailure{
+			RuleName:     "testrule",
+			Err:          "Application profile is missing",
+			FailureEvent: &tracing.ExecveEvent{},
+		},
+		SuppressedCount: 4,
+		FirstSeen:       firstSeen,
+		LastSeen:        firstSeen.Add(time.Minute),
 	})
 
 	csvExporter.SendMalwareAlert(scan.MalwareDescription{
@@ -80,14 +94,27 @@ func TestCsvExporter(t *testing.T) {
 		t.Fatalf("Expected csv malware file to contain the malware name header")
 	}
 
-	if len(csvData) != 2 {
-		t.Fatalf("Expected csv file to contain 2 rows")
+	if len(csvData) != 3 {
+		t.Fatalf("Expected csv file to contain 3 rows")
 	}
 
 	if csvData[0][0] != "Rule Name" {
 		t.Fatalf("Expected csv file to contain the rule name header")
 	}
 
+	// the suppression summary columns are only set for the suppressed alert
+	evidenceColumns := len(rule.EvidenceKeys)
+	suppressedColumns := func(row []string) []string { return row[len(row)-evidenceColumns-3 : len(row)-evidenceColumns] }
+	assert.Equal(t, []string{"Suppressed Count", "First Seen", "Last Seen"}, suppressedColumns(csvData[0]))
+	assert.Equal(t, []string{"", "", ""}, suppressedColumns(csvData[1]))
+	assert.Equal(t, []string{"4", "2024-01-01T10:00:00Z", "2024-01-01T10:01:00Z"}, suppressedColumns(csvData[2]))
+
+	// the evidence columns follow, one per evidence key
+	evidence := func(row []string) []string { return row[len(row)-evidenceColumns : len(row)-evidenceColumns+2] }
+	assert.Equal(t, []string{"Evidence path", "Evidence args"}, evidence(csvData[0]))
+	assert.Equal(t, []string{"/bin/sh", "sh,-c,id"}, evidence(csvData[1]))
+	assert.Equal(t, len(csvData[0]), len(csvData[1]))
+
 	csvRuleFile.Close()
 	csvMalwareFile.Close()
 
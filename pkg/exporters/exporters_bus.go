@@ -1,22 +1,38 @@
 package exporters
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/armosec/kubecop/pkg/engine/rule"
 	"github.com/armosec/kubecop/pkg/scan"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
 type ExportersConfig struct {
-	StdoutExporter           *bool               `yaml:"stdoutExporter"`
-	AlertManagerExporterUrls string              `yaml:"alertManagerExporterUrls"`
-	SyslogExporter           string              `yaml:"syslogExporterURL"`
-	CsvRuleExporterPath      string              `yaml:"CsvRuleExporterPath"`
-	CsvMalwareExporterPath   string              `yaml:"CsvMalwareExporterPath"`
-	HTTPExporterConfig       *HTTPExporterConfig `yaml:"httpExporterConfig"`
+	StdoutExporter           *bool               `json:"stdoutExporter" yaml:"stdoutExporter"`
+	AlertManagerExporterUrls string              `json:"alertManagerExporterUrls" yaml:"alertManagerExporterUrls"`
+	SyslogExporter           string              `json:"syslogExporterURL" yaml:"syslogExporterURL"`
+	CsvRuleExporterPath      string              `json:"CsvRuleExporterPath" yaml:"CsvRuleExporterPath"`
+	CsvMalwareExporterPath   string              `json:"CsvMalwareExporterPath" yaml:"CsvMalwareExporterPath"`
+	HTTPExporterConfig       *HTTPExporterConfig `json:"httpExporterConfig" yaml:"httpExporterConfig"`
+	KubernetesEventsExporter *bool               `json:"kubernetesEventsExporter" yaml:"kubernetesEventsExporter"`
+	// OTLPExporterConfig enables the OpenTelemetry logs exporter
+	OTLPExporterConfig *OTLPExporterConfig `json:"otlpExporterConfig" yaml:"otlpExporterConfig"`
+	// KafkaExporterConfig enables the Kafka exporter
+	KafkaExporterConfig *KafkaExporterConfig `json:"kafkaExporterConfig" yaml:"kafkaExporterConfig"`
+	// RuntimeAlertExporterConfig enables the RuntimeAlert objects exporter
+	RuntimeAlertExporterConfig *RuntimeAlertExporterConfig `json:"runtimeAlertExporterConfig" yaml:"runtimeAlertExporterConfig"`
+	// ExporterQueues configures the queue in front of each exporter kind (alertmanager, stdout, syslog, csv, http,
+	// kubernetesevents, runtimealert, otlp, kafka), the "default" entry applies to the exporters without a specific entry.
+	ExporterQueues map[string]ExporterQueueConfig `json:"exporterQueues" yaml:"exporterQueues"`
 }
 
 // This file will contain the single point of contact for all exporters,
@@ -27,32 +43,104 @@ const (
 	AlertManagerSepartorDelimiter = ","
 )
 
+// Exporter kinds, used to name the exporters and to configure their queues.
+const (
+	AlertManagerExporterKind     = "alertmanager"
+	StdoutExporterKind           = "stdout"
+	SyslogExporterKind           = "syslog"
+	CsvExporterKind              = "csv"
+	HTTPExporterKind             = "http"
+	KubernetesEventsExporterKind = "kubernetesevents"
+	RuntimeAlertExporterKind     = "runtimealert"
+	OTLPExporterKind             = "otlp"
+	KafkaExporterKind            = "kafka"
+)
+
+// ExporterBus sends the alerts to all exporters, each exporter runs behind its own queue and goroutine.
 type ExporterBus struct {
-	// Exporters is a list of all exporters.
+	// exporters is the current set of exporters.
+	exporters *exporterSet
+	// exportersLock protects the exporters set, which is swapped on configuration reload.
+	exportersLock sync.RWMutex
+	// recentAlerts keeps the last alerts sent, for the admin API.
+	recentAlerts *recentAlerts
+	// Clients of the exporters which write to the Kubernetes API, they are kept for the reloads.
+	k8sClient     kubernetes.Interface
+	dynamicClient dynamic.Interface
+}
+
+// exporterSet is a list of exporters with the alerts being sent to them, so the exporters
+// are closed only once no alert is being sent to them anymore.
+type exporterSet struct {
 	exporters []Exporter
+	senders   sync.WaitGroup
+}
+
+// close waits for the alerts being sent to the exporters and closes them. The callers blocked on a full
+// exporter queue are released first, so an exporter which hangs doesn't keep the set from being closed.
+func (set *exporterSet) close() {
+	if set == nil {
+		return
+	}
+	for _, exporter := range set.exporters {
+		if asyncExp, ok := exporter.(*asyncExporter); ok {
+			asyncExp.releaseBlockedCallers()
+		}
+	}
+	set.senders.Wait()
+	closeExporters(set.exporters)
+}
+
+// InitExporters initializes all exporters, the Kubernetes clients may be nil if no exporter writes to the Kubernetes API.
+func InitExporters(exportersConfig ExportersConfig, k8sClient kubernetes.Interface, dynamicClient dynamic.Interface) ExporterBus {
+	exporters, err := createExporters(exportersConfig, k8sClient, dynamicClient)
+	if err != nil {
+		// Start with the exporters which could be initialized
+		log.WithError(err).Error("failed to initialize exporters")
+	}
+	if len(exporters) == 0 {
+		panic("no exporters were initialized")
+	}
+	log.Info("exporters initialized")
+
+	return ExporterBus{exporters: &exporterSet{exporters: exporters}, recentAlerts: newRecentAlerts(DefaultRecentAlertsSize),
+		k8sClient: k8sClient, dynamicClient: dynamicClient}
 }
 
-// InitExporters initializes all exporters.
-func InitExporters(exportersConfig ExportersConfig) ExporterBus {
+// createExporters creates the exporters described by the given configuration,
+// falling back to the environment variables for fields that are not set.
+// Each exporter is wrapped with its own queue. The exporters which could be initialized are returned
+// along with the errors of the configured exporters which could not.
+func createExporters(exportersConfig ExportersConfig, k8sClient kubernetes.Interface, dynamicClient dynamic.Interface) ([]Exporter, error) {
 	exporters := []Exporter{}
+	var errs []error
+	addExporter := func(name string, exporter Exporter) {
+		queueConfig := getExporterQueueConfig(exportersConfig.ExporterQueues, exporterKind(name))
+		exporters = append(exporters, newAsyncExporter(name, exporter, queueConfig))
+	}
+
 	alertManagerUrls := parseAlertManagerUrls(exportersConfig.AlertManagerExporterUrls)
 	for _, url := range alertManagerUrls {
 		alertMan := InitAlertManagerExporter(url)
-		if alertMan != nil {
-			exporters = append(exporters, alertMan)
+		if alertMan == nil {
+			errs = append(errs, fmt.Errorf("failed to initialize Alertmanager exporter %s", url))
+		} else {
+			addExporter(AlertManagerExporterKind+":"+url, alertMan)
 		}
 	}
 	stdoutExp := InitStdoutExporter(exportersConfig.StdoutExporter)
 	if stdoutExp != nil {
-		exporters = append(exporters, stdoutExp)
+		addExporter(StdoutExporterKind, stdoutExp)
 	}
 	syslogExp := InitSyslogExporter(exportersConfig.SyslogExporter)
 	if syslogExp != nil {
-		exporters = append(exporters, syslogExp)
+		addExporter(SyslogExporterKind, syslogExp)
+	} else if exportersConfig.SyslogExporter != "" || os.Getenv("SYSLOG_HOST") != "" {
+		errs = append(errs, fmt.Errorf("failed to initialize syslog exporter"))
 	}
 	csvExp := InitCsvExporter(exportersConfig.CsvRuleExporterPath, exportersConfig.CsvMalwareExporterPath)
 	if csvExp != nil {
-		exporters = append(exporters, csvExp)
+		addExporter(CsvExporterKind, csvExp)
 	}
 	if exportersConfig.HTTPExporterConfig == nil {
 		if httpURL := os.Getenv("HTTP_ENDPOINT_URL"); httpURL != "" {
@@ -63,17 +151,118 @@ func InitExporters(exportersConfig ExportersConfig) ExporterBus {
 	if exportersConfig.HTTPExporterConfig != nil {
 		httpExp, err := InitHTTPExporter(*exportersConfig.HTTPExporterConfig)
 		if err != nil {
-			log.WithError(err).Error("failed to initialize HTTP exporter")
+			errs = append(errs, fmt.Errorf("failed to initialize HTTP exporter: %w", err))
+		} else {
+			addExporter(HTTPExporterKind, httpExp)
+		}
+	}
+	kubernetesEventsExp := InitKubernetesEventsExporter(exportersConfig.KubernetesEventsExporter, k8sClient)
+	if kubernetesEventsExp != nil {
+		addExporter(KubernetesEventsExporterKind, kubernetesEventsExp)
+	}
+	if exportersConfig.RuntimeAlertExporterConfig == nil && os.Getenv("RUNTIME_ALERTS_ENABLED") == "true" {
+		exportersConfig.RuntimeAlertExporterConfig = &RuntimeAlertExporterConfig{}
+	}
+	if exportersConfig.RuntimeAlertExporterConfig != nil {
+		runtimeAlertExp, err := InitRuntimeAlertExporter(*exportersConfig.RuntimeAlertExporterConfig, dynamicClient)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to initialize RuntimeAlert exporter: %w", err))
+		} else {
+			addExporter(RuntimeAlertExporterKind, runtimeAlertExp)
+		}
+	}
+	if exportersConfig.OTLPExporterConfig == nil {
+		if otlpEndpoint := os.Getenv("OTLP_ENDPOINT"); otlpEndpoint != "" {
+			exportersConfig.OTLPExporterConfig = &OTLPExporterConfig{
+				Endpoint: otlpEndpoint,
+				Protocol: os.Getenv("OTLP_PROTOCOL"),
+				Insecure: os.Getenv("OTLP_INSECURE") == "true",
+			}
+		}
+	}
+	if exportersConfig.OTLPExporterConfig != nil {
+		otlpExp, err := InitOTLPExporter(*exportersConfig.OTLPExporterConfig)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to initialize OTLP exporter: %w", err))
+		} else {
+			addExporter(OTLPExporterKind, otlpExp)
+		}
+	}
+	if exportersConfig.KafkaExporterConfig == nil {
+		if kafkaBrokers := os.Getenv("KAFKA_BROKERS"); kafkaBrokers != "" {
+			exportersConfig.KafkaExporterConfig = &KafkaExporterConfig{
+				Brokers:            strings.Split(kafkaBrokers, ","),
+				RuleAlertsTopic:    os.Getenv("KAFKA_RULE_ALERTS_TOPIC"),
+				MalwareAlertsTopic: os.Getenv("KAFKA_MALWARE_ALERTS_TOPIC"),
+			}
+		}
+	}
+	if exportersConfig.KafkaExporterConfig != nil {
+		kafkaExp, err := InitKafkaExporter(*exportersConfig.KafkaExporterConfig)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to initialize Kafka exporter: %w", err))
+		} else {
+			addExporter(KafkaExporterKind, kafkaExp)
 		}
-		exporters = append(exporters, httpExp)
 	}
+	return exporters, errors.Join(errs...)
+}
 
+// Reload validates the given configuration, creates the exporters it describes and
+// atomically replaces the current exporters with them. On error, including when any of the configured exporters
+// can't be initialized, the current exporters are kept.
+func (e *ExporterBus) Reload(exportersConfig ExportersConfig) error {
+	if err := exportersConfig.Validate(); err != nil {
+		return err
+	}
+	exporters, err := createExporters(exportersConfig, e.k8sClient, e.dynamicClient)
+	if err != nil {
+		go closeExporters(exporters)
+		return err
+	}
 	if len(exporters) == 0 {
-		panic("no exporters were initialized")
+		return fmt.Errorf("no exporters were initialized")
 	}
-	log.Info("exporters initialized")
 
-	return ExporterBus{exporters: exporters}
+	e.exportersLock.Lock()
+	oldExporters := e.exporters
+	e.exporters = &exporterSet{exporters: exporters}
+	e.exportersLock.Unlock()
+
+	// Let the replaced exporters send their queued alerts and release their resources in the background,
+	// so a slow exporter doesn't hold the reload
+	go oldExporters.close()
+	log.Infof("exporters reloaded (%d active)", len(exporters))
+	return nil
+}
+
+// Close sends the queued alerts and closes all exporters.
+func (e *ExporterBus) Close() error {
+	e.exportersLock.Lock()
+	oldExporters := e.exporters
+	e.exporters = nil
+	e.exportersLock.Unlock()
+	oldExporters.close()
+	return nil
+}
+
+// closeExporters closes the exporters concurrently, so an exporter which hangs doesn't hold the others.
+func closeExporters(exporters []Exporter) {
+	var wg sync.WaitGroup
+	for _, exporter := range exporters {
+		closer, ok := exporter.(io.Closer)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := closer.Close(); err != nil {
+				log.WithError(err).Warn("failed to close exporter")
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 // ParseAlertManagerUrls parses the alert manager urls from the given string.
@@ -90,14 +279,51 @@ func parseAlertManagerUrls(urls string) []string {
 	return strings.Split(urls, AlertManagerSepartorDelimiter)
 }
 
+// acquireExporters returns the current exporters, which are not closed until the returned release function is called.
+func (e *ExporterBus) acquireExporters() ([]Exporter, func()) {
+	e.exportersLock.RLock()
+	defer e.exportersLock.RUnlock()
+	if e.exporters == nil {
+		return nil, func() {}
+	}
+	e.exporters.senders.Add(1)
+	return e.exporters.exporters, e.exporters.senders.Done
+}
+
+// SendRuleAlert queues the alert on every exporter, it doesn't wait for the exporters to send it.
 func (e *ExporterBus) SendRuleAlert(failedRule rule.RuleFailure) {
-	for _, exporter := range e.exporters {
+	e.recentAlerts.add(newRecentRuleAlert(failedRule))
+	exporters, release := e.acquireExporters()
+	defer release()
+	for _, exporter := range exporters {
 		exporter.SendRuleAlert(failedRule)
 	}
 }
 
+// SendMalwareAlert queues the alert on every exporter, it doesn't wait for the exporters to send it.
 func (e *ExporterBus) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
-	for _, exporter := range e.exporters {
+	e.recentAlerts.add(newRecentMalwareAlert(malwareDescription))
+	exporters, release := e.acquireExporters()
+	defer release()
+	for _, exporter := range exporters {
 		exporter.SendMalwareAlert(malwareDescription)
 	}
 }
+
+// Health returns the health of the current exporters.
+func (e *ExporterBus) Health() []ExporterHealth {
+	exporters, release := e.acquireExporters()
+	defer release()
+	health := make([]ExporterHealth, 0, len(exporters))
+	for _, exporter := range exporters {
+		if asyncExp, ok := exporter.(*asyncExporter); ok {
+			health = append(health, asyncExp.Health())
+		}
+	}
+	return health
+}
+
+// RecentAlerts returns the last alerts sent to the exporters, from the newest to the oldest.
+func (e *ExporterBus) RecentAlerts() []RecentAlert {
+	return e.recentAlerts.list()
+}
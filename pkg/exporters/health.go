@@ -0,0 +1,176 @@
+package exporters
+
+import (
+	"sync"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+)
+
+// Number of recent alerts kept by the exporter bus.
+const DefaultRecentAlertsSize = 100
+
+// ExporterHealth is the state of an exporter and of its queue.
+type ExporterHealth struct {
+	Name          string `json:"name"`
+	QueueDepth    int    `json:"queueDepth"`
+	QueueSize     int    `json:"queueSize"`
+	SentAlerts    uint64 `json:"sentAlerts"`
+	DroppedAlerts uint64 `json:"droppedAlerts"`
+	FailedAlerts  uint64 `json:"failedAlerts"`
+	// Time of the last alert sent and of the last alert which failed, with the reason of the failure
+	LastSent          *time.Time `json:"lastSent,omitempty"`
+	LastFailure       *time.Time `json:"lastFailure,omitempty"`
+	LastFailureReason string     `json:"lastFailureReason,omitempty"`
+	// False when the last alert failed or when the queue is full
+	Healthy bool `json:"healthy"`
+}
+
+// RecentAlert is an alert sent to the exporters, rule alerts have a rule name and malware alerts a malware name.
+type RecentAlert struct {
+	Time          time.Time `json:"time"`
+	RuleName      string    `json:"ruleName,omitempty"`
+	MalwareName   string    `json:"malwareName,omitempty"`
+	Priority      int       `json:"priority,omitempty"`
+	Message       string    `json:"message"`
+	FixSuggestion string    `json:"fixSuggestion,omitempty"`
+	Namespace     string    `json:"namespace,omitempty"`
+	PodName       string    `json:"podName,omitempty"`
+	ContainerName string    `json:"containerName,omitempty"`
+	ContainerID   string    `json:"containerID,omitempty"`
+	Pid           uint32    `json:"pid,omitempty"`
+	Comm          string    `json:"comm,omitempty"`
+	// Evidence of the rule alerts
+	Evidence rule.Evidence `json:"evidence,omitempty"`
+}
+
+// exporterHealthStats counts the alerts of an exporter, for its health.
+type exporterHealthStats struct {
+	mutex             sync.Mutex
+	sent              uint64
+	dropped           uint64
+	failed            uint64
+	lastSent          time.Time
+	lastFailure       time.Time
+	lastFailureReason string
+}
+
+func (stats *exporterHealthStats) reportSent() {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.sent++
+	stats.lastSent = time.Now()
+}
+
+func (stats *exporterHealthStats) reportDropped() {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.dropped++
+}
+
+func (stats *exporterHealthStats) reportFailed(reason string) {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	stats.failed++
+	stats.lastFailure = time.Now()
+	stats.lastFailureReason = reason
+}
+
+// health returns the health of the exporter with the state of its queue.
+func (stats *exporterHealthStats) health(name string, queueDepth, queueSize int) ExporterHealth {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+	health := ExporterHealth{
+		Name:              name,
+		QueueDepth:        queueDepth,
+		QueueSize:         queueSize,
+		SentAlerts:        stats.sent,
+		DroppedAlerts:     stats.dropped,
+		FailedAlerts:      stats.failed,
+		LastFailureReason: stats.lastFailureReason,
+		Healthy:           queueDepth < queueSize && !stats.lastFailure.After(stats.lastSent),
+	}
+	if !stats.lastSent.IsZero() {
+		lastSent := stats.lastSent
+		health.LastSent = &lastSent
+	}
+	if !stats.lastFailure.IsZero() {
+		lastFailure := stats.lastFailure
+		health.LastFailure = &lastFailure
+	}
+	return health
+}
+
+// recentAlerts keeps the last alerts sent to the exporters in a ring buffer.
+type recentAlerts struct {
+	mutex  sync.Mutex
+	alerts []RecentAlert
+	next   int
+	full   bool
+}
+
+func newRecentAlerts(size int) *recentAlerts {
+	return &recentAlerts{alerts: make([]RecentAlert, size)}
+}
+
+func (recent *recentAlerts) add(alert RecentAlert) {
+	if recent == nil || len(recent.alerts) == 0 {
+		return
+	}
+	recent.mutex.Lock()
+	defer recent.mutex.Unlock()
+	recent.alerts[recent.next] = alert
+	recent.next = (recent.next + 1) % len(recent.alerts)
+	if recent.next == 0 {
+		recent.full = true
+	}
+}
+
+// list returns the alerts from the newest to the oldest.
+func (recent *recentAlerts) list() []RecentAlert {
+	if recent == nil {
+		return nil
+	}
+	recent.mutex.Lock()
+	defer recent.mutex.Unlock()
+	count := recent.next
+	if recent.full {
+		count = len(recent.alerts)
+	}
+	alerts := make([]RecentAlert, 0, count)
+	for i := 1; i <= count; i++ {
+		alerts = append(alerts, recent.alerts[(recent.next-i+len(recent.alerts))%len(recent.alerts)])
+	}
+	return alerts
+}
+
+func newRecentRuleAlert(failedRule rule.RuleFailure) RecentAlert {
+	event := failedRule.Event()
+	return RecentAlert{
+		Time:          time.Now(),
+		RuleName:      failedRule.Name(),
+		Priority:      failedRule.Priority(),
+		Message:       failedRule.Error(),
+		FixSuggestion: failedRule.FixSuggestion(),
+		Namespace:     event.Namespace,
+		PodName:       event.PodName,
+		ContainerName: event.ContainerName,
+		ContainerID:   event.ContainerID,
+		Pid:           event.Pid,
+		Comm:          event.Comm,
+		Evidence:      failedRule.Evidence(),
+	}
+}
+
+func newRecentMalwareAlert(malwareDescription scan.MalwareDescription) RecentAlert {
+	return RecentAlert{
+		Time:          time.Now(),
+		MalwareName:   malwareDescription.Name,
+		Message:       malwareDescription.Description + " in " + malwareDescription.Path,
+		Namespace:     malwareDescription.Namespace,
+		PodName:       malwareDescription.PodName,
+		ContainerName: malwareDescription.ContainerName,
+		ContainerID:   malwareDescription.ContainerID,
+	}
+}
@@ -0,0 +1,58 @@
+package exporters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExporterBusHealth(t *testing.T) {
+	exporter := newBlockingExporter()
+	asyncExp := newAsyncExporter("test-health", exporter, ExporterQueueConfig{QueueSize: 1, OverflowPolicy: OverflowPolicyDropNewest, TimeoutSeconds: 10})
+	bus := ExporterBus{exporters: &exporterSet{exporters: []Exporter{asyncExp}}, recentAlerts: newRecentAlerts(2)}
+
+	// The first alert is taken by the exporter goroutine, the second one fills the queue and the third one is dropped
+	bus.SendRuleAlert(testRuleFailure("first"))
+	assert.Eventually(t, func() bool { return len(asyncExp.alerts) == 0 }, 1*time.Second, 10*time.Millisecond)
+	bus.SendRuleAlert(testRuleFailure("second"))
+	bus.SendMalwareAlert(scan.MalwareDescription{Name: "malware", PodName: "web"})
+
+	health := bus.Health()
+	if assert.Len(t, health, 1) {
+		assert.Equal(t, "test-health", health[0].Name)
+		assert.Equal(t, 1, health[0].QueueDepth)
+		assert.Equal(t, uint64(1), health[0].DroppedAlerts)
+		assert.False(t, health[0].Healthy)
+	}
+
+	close(exporter.release)
+	asyncExp.Close()
+	health = bus.Health()
+	assert.Equal(t, uint64(2), health[0].SentAlerts)
+	assert.NotNil(t, health[0].LastSent)
+	assert.True(t, health[0].Healthy)
+
+	// The recent alerts are kept even when the exporters drop them, the oldest ones are replaced
+	recent := bus.RecentAlerts()
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, "malware", recent[0].MalwareName)
+		assert.Equal(t, "web", recent[0].PodName)
+		assert.Equal(t, "second", recent[1].RuleName)
+	}
+}
+
+func TestExporterHealthFailure(t *testing.T) {
+	stats := exporterHealthStats{}
+	stats.reportSent()
+	stats.reportFailed(exporterFailureReasonTimeout)
+	health := stats.health("test-failure", 0, 10)
+	assert.False(t, health.Healthy)
+	assert.Equal(t, exporterFailureReasonTimeout, health.LastFailureReason)
+
+	// The exporter is healthy again once an alert is sent after the failure
+	time.Sleep(time.Millisecond)
+	stats.reportSent()
+	assert.True(t, stats.health("test-failure", 0, 10).Healthy)
+}
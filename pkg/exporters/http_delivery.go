@@ -0,0 +1,515 @@
+package exporters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+)
+
+const (
+	// Maximum time to wait between two retries of the same batch.
+	httpMaxRetryBackoff = 30 * time.Second
+	// Suffix of the batch files in the on-disk buffer.
+	httpBufferFileSuffix = ".json"
+	// Maximum number of buffered batches sent at once, so replaying the buffer doesn't hold the batching for long.
+	httpMaxBufferedBatchesPerPass = 10
+
+	// Reasons for dropping alerts, reported in the metrics.
+	httpDropReasonQueueFull      = "queue_full"
+	httpDropReasonRejected       = "rejected"
+	httpDropReasonDeliveryFailed = "delivery_failed"
+	httpDropReasonBufferFull     = "buffer_full"
+	httpDropReasonStopped        = "stopped"
+)
+
+// httpBufferDirLocks give a queue the exclusive use of its buffer directory while it runs, so when the exporters
+// are reloaded the replaced queue is done with the buffer before the new queue delivers or buffers batches.
+var (
+	httpBufferDirLocksLock sync.Mutex
+	httpBufferDirLocks     = map[string]chan struct{}{}
+)
+
+// httpBufferDirLock returns the lock of the buffer directory, it is held by sending to the channel.
+func httpBufferDirLock(bufferDir string) chan struct{} {
+	httpBufferDirLocksLock.Lock()
+	defer httpBufferDirLocksLock.Unlock()
+	bufferDir = filepath.Clean(bufferDir)
+	lock, ok := httpBufferDirLocks[bufferDir]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		httpBufferDirLocks[bufferDir] = lock
+	}
+	return lock
+}
+
+// httpDeliveryQueue delivers the alerts of the HTTP exporter in the background.
+// Alerts are accumulated into batches by size and time, failed batches are retried with exponential backoff
+// on timeouts and 5xx responses, and batches which could not be delivered are kept in a bounded on-disk buffer
+// (when configured) until the endpoint is reachable again.
+type httpDeliveryQueue struct {
+	config      HTTPExporterConfig
+	httpClient  *http.Client
+	alerts      chan HTTPAlert
+	stopChannel chan struct{}
+	// stopLock makes sure no alert is being enqueued once the queue is stopped, so every queued alert is delivered
+	stopLock      sync.RWMutex
+	stopped       bool
+	done          chan struct{}
+	promCollector *prometheusMetric
+	// retries are the batches waiting for a retry, oldest first. Batches formed while there are retries wait
+	// behind them, so the alerts are delivered in order.
+	retries       []*httpRetryBatch
+	retriesAlerts int
+	// endpointDown is set when a batch could not be delivered after all retries,
+	// from then on batches go straight to the buffer until a buffered batch is delivered.
+	endpointDown bool
+	// bufferedBatches is the number of batches in the on-disk buffer, the buffer is only read when there are some.
+	bufferedBatches int
+	// After a buffered batch failed to be delivered, the buffer is replayed again at nextBufferedAttempt,
+	// with the same exponential backoff as the retries.
+	bufferedBackoff     time.Duration
+	nextBufferedAttempt time.Time
+}
+
+// httpRetryBatch is a batch waiting to be sent again.
+type httpRetryBatch struct {
+	body        []byte
+	alertsCount int
+	attempts    int
+	backoff     time.Duration
+	nextAttempt time.Time
+}
+
+// httpSendError is an error in sending a batch, retryable errors are timeouts, connection errors and 5xx/429 responses.
+type httpSendError struct {
+	err       error
+	retryable bool
+}
+
+func (e *httpSendError) Error() string {
+	return e.err.Error()
+}
+
+func newHTTPDeliveryQueue(config HTTPExporterConfig, httpClient *http.Client) *httpDeliveryQueue {
+	queue := &httpDeliveryQueue{
+		config:        config,
+		httpClient:    httpClient,
+		alerts:        make(chan HTTPAlert, config.QueueSize),
+		stopChannel:   make(chan struct{}),
+		done:          make(chan struct{}),
+		promCollector: getPrometheusMetric(),
+	}
+	if config.BufferDir != "" {
+		if err := os.MkdirAll(config.BufferDir, 0755); err != nil {
+			log.Errorf("failed to create HTTP exporter buffer directory %s, failed batches will be dropped: %v", config.BufferDir, err)
+			queue.config.BufferDir = ""
+		}
+	}
+	go queue.run()
+	return queue
+}
+
+// enqueue adds an alert to the queue without blocking the caller, the alert is dropped if the queue is full.
+func (queue *httpDeliveryQueue) enqueue(alert HTTPAlert) {
+	queue.stopLock.RLock()
+	defer queue.stopLock.RUnlock()
+	if queue.stopped {
+		queue.promCollector.reportHTTPAlertsDropped(httpDropReasonStopped, 1)
+		return
+	}
+	select {
+	case queue.alerts <- alert:
+		queue.promCollector.reportHTTPAlertQueued()
+	default:
+		queue.promCollector.reportHTTPAlertsDropped(httpDropReasonQueueFull, 1)
+		log.Warnf("HTTP exporter queue is full (%d alerts), dropping alert %s", queue.config.QueueSize, alert.RuleName)
+	}
+}
+
+// stop delivers the queued alerts and stops the queue. Alerts which can't be delivered are buffered if possible.
+func (queue *httpDeliveryQueue) stop() {
+	queue.stopLock.Lock()
+	if !queue.stopped {
+		queue.stopped = true
+		close(queue.stopChannel)
+	}
+	queue.stopLock.Unlock()
+	<-queue.done
+}
+
+func (queue *httpDeliveryQueue) run() {
+	defer close(queue.done)
+	if queue.config.BufferDir != "" {
+		// The alerts wait in the queue until the queue this one replaces has stopped using the buffer
+		bufferDirLock := httpBufferDirLock(queue.config.BufferDir)
+		select {
+		case bufferDirLock <- struct{}{}:
+			defer func() { <-bufferDirLock }()
+			// Batches buffered by a previous run or by the replaced queue are delivered as well
+			queue.reportBufferedBatches(len(queue.bufferedBatchFiles()))
+			defer func() {
+				queue.reportBufferedBatches(-len(queue.bufferedBatchFiles()))
+			}()
+		case <-queue.stopChannel:
+			// Stopped before the buffer was released, the queued alerts are delivered without it
+			queue.config.BufferDir = ""
+		}
+	}
+	ticker := time.NewTicker(time.Duration(queue.config.BatchIntervalMilliseconds) * time.Millisecond)
+	defer ticker.Stop()
+
+	batch := make([]HTTPAlert, 0, queue.config.MaxAlertsPerBatch)
+	for {
+		select {
+		case <-queue.stopChannel:
+			// Deliver what is left in the queue
+			for {
+				select {
+				case alert := <-queue.alerts:
+					queue.promCollector.reportHTTPAlertDequeued()
+					batch = append(batch, alert)
+					if len(batch) >= queue.config.MaxAlertsPerBatch {
+						queue.deliver(batch)
+						batch = make([]HTTPAlert, 0, queue.config.MaxAlertsPerBatch)
+					}
+				default:
+					if len(batch) > 0 {
+						queue.deliver(batch)
+					}
+					// Don't hold the shutdown on retries
+					queue.bufferRetries()
+					return
+				}
+			}
+		case alert := <-queue.alerts:
+			queue.promCollector.reportHTTPAlertDequeued()
+			if alert.Severity == rule.RulePrioritySystemIssue {
+				// System alerts are not delayed by batching and are sent on their own
+				if len(batch) > 0 {
+					queue.deliver(batch)
+					batch = make([]HTTPAlert, 0, queue.config.MaxAlertsPerBatch)
+				}
+				queue.deliver([]HTTPAlert{alert})
+				continue
+			}
+			batch = append(batch, alert)
+			if len(batch) >= queue.config.MaxAlertsPerBatch {
+				queue.deliver(batch)
+				batch = make([]HTTPAlert, 0, queue.config.MaxAlertsPerBatch)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				queue.deliver(batch)
+				batch = make([]HTTPAlert, 0, queue.config.MaxAlertsPerBatch)
+			}
+			queue.deliverRetries(time.Now())
+			if len(queue.retries) == 0 {
+				queue.deliverBuffered(time.Now())
+			}
+		}
+	}
+}
+
+// deliver sends a batch of alerts, retrying on transient errors. Batches which could not be delivered are buffered.
+func (queue *httpDeliveryQueue) deliver(batch []HTTPAlert) {
+	body, err := json.Marshal(HTTPAlertsList{
+		Kind:       "RuntimeAlerts",
+		ApiVersion: "kubescape.io/v1",
+		Spec: HTTPAlertsListSpec{
+			Alerts: batch,
+		},
+	})
+	if err != nil {
+		log.Errorf("Error marshalling HTTPAlertsList: %v", err)
+		return
+	}
+
+	if queue.endpointDown {
+		// Keep the order of the alerts, the buffered batches are delivered first
+		queue.buffer(body, len(batch))
+		return
+	}
+
+	retry := &httpRetryBatch{
+		body:        body,
+		alertsCount: len(batch),
+		backoff:     time.Duration(queue.config.InitialBackoffMilliseconds) * time.Millisecond,
+	}
+	if len(queue.retries) > 0 {
+		// Keep the order of the alerts, the batches waiting for a retry are delivered first
+		queue.addRetry(retry)
+		return
+	}
+	if queue.attempt(retry) {
+		queue.addRetry(retry)
+	}
+}
+
+// attempt sends a batch once, it returns true if the batch should be retried later.
+// Batches which failed all their retries are buffered.
+func (queue *httpDeliveryQueue) attempt(retry *httpRetryBatch) bool {
+	sendErr := queue.send(retry.body)
+	if sendErr == nil {
+		queue.promCollector.reportHTTPAlertsSent(retry.alertsCount)
+		return false
+	}
+	if !sendErr.retryable {
+		log.Errorf("HTTP exporter endpoint rejected %d alerts: %v", retry.alertsCount, sendErr)
+		queue.promCollector.reportHTTPAlertsDropped(httpDropReasonRejected, retry.alertsCount)
+		return false
+	}
+	if retry.attempts >= *queue.config.MaxRetries {
+		log.Errorf("Failed to send %d alerts to the HTTP exporter endpoint after %d retries: %v", retry.alertsCount, retry.attempts, sendErr)
+		queue.endpointDown = queue.config.BufferDir != ""
+		queue.buffer(retry.body, retry.alertsCount)
+		return false
+	}
+	queue.promCollector.reportHTTPRetry()
+	retry.attempts++
+	retry.nextAttempt = time.Now().Add(retry.backoff)
+	retry.backoff *= 2
+	if retry.backoff > httpMaxRetryBackoff {
+		retry.backoff = httpMaxRetryBackoff
+	}
+	return true
+}
+
+// addRetry adds a batch to the batches waiting for a retry. When there are more alerts waiting for a retry
+// than the queue size, the endpoint is considered down and all the batches are buffered, in order.
+func (queue *httpDeliveryQueue) addRetry(retry *httpRetryBatch) {
+	if queue.retriesAlerts+retry.alertsCount > queue.config.QueueSize {
+		queue.endpointDown = queue.config.BufferDir != ""
+		queue.bufferRetries()
+		queue.buffer(retry.body, retry.alertsCount)
+		return
+	}
+	queue.retries = append(queue.retries, retry)
+	queue.retriesAlerts += retry.alertsCount
+}
+
+// deliverRetries sends the batches waiting for a retry whose backoff is over, oldest first,
+// until one of them has to wait again.
+func (queue *httpDeliveryQueue) deliverRetries(now time.Time) {
+	for len(queue.retries) > 0 {
+		retry := queue.retries[0]
+		if queue.endpointDown {
+			queue.bufferRetries()
+			return
+		}
+		if now.Before(retry.nextAttempt) || queue.attempt(retry) {
+			return
+		}
+		queue.retries = queue.retries[1:]
+		queue.retriesAlerts -= retry.alertsCount
+	}
+}
+
+// bufferRetries buffers the batches waiting for a retry.
+func (queue *httpDeliveryQueue) bufferRetries() {
+	for _, retry := range queue.retries {
+		queue.buffer(retry.body, retry.alertsCount)
+	}
+	queue.retries = nil
+	queue.retriesAlerts = 0
+}
+
+// send sends a single HTTP request with the given body.
+func (queue *httpDeliveryQueue) send(body []byte) *httpSendError {
+	req, err := http.NewRequest(queue.config.Method, queue.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return &httpSendError{err: fmt.Errorf("error creating HTTP request: %v", err), retryable: false}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range queue.config.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := queue.httpClient.Do(req)
+	if err != nil {
+		return &httpSendError{err: fmt.Errorf("error sending HTTP request: %v", err), retryable: true}
+	}
+	defer resp.Body.Close()
+
+	// discard the body
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		log.Debugf("Error clearing response body: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &httpSendError{
+			err:       fmt.Errorf("received non-2xx status code: %d", resp.StatusCode),
+			retryable: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+		}
+	}
+	return nil
+}
+
+// buffer stores a batch which could not be delivered in the on-disk buffer, dropping the oldest batches if it is full.
+func (queue *httpDeliveryQueue) buffer(body []byte, alertsCount int) {
+	if queue.config.BufferDir == "" {
+		queue.promCollector.reportHTTPAlertsDropped(httpDropReasonDeliveryFailed, alertsCount)
+		return
+	}
+
+	maxBufferSize := int64(queue.config.BufferMaxSizeMB) * 1024 * 1024
+	if int64(len(body)) > maxBufferSize {
+		queue.promCollector.reportHTTPAlertsDropped(httpDropReasonBufferFull, alertsCount)
+		return
+	}
+	files := queue.bufferedBatchFiles()
+	var bufferSize int64
+	for _, file := range files {
+		bufferSize += file.size
+	}
+	for len(files) > 0 && bufferSize+int64(len(body)) > maxBufferSize {
+		oldest := files[0]
+		files = files[1:]
+		if err := os.Remove(oldest.path); err != nil {
+			log.Errorf("Failed to remove buffered batch %s: %v", oldest.path, err)
+			continue
+		}
+		bufferSize -= oldest.size
+		queue.reportBufferedBatches(-1)
+		queue.promCollector.reportHTTPAlertsDropped(httpDropReasonBufferFull, oldest.alertsCount)
+	}
+
+	// Write to a temporary file first so a partially written batch is never picked up
+	name := fmt.Sprintf("%020d-%d%s", time.Now().UnixNano(), alertsCount, httpBufferFileSuffix)
+	tmpPath := filepath.Join(queue.config.BufferDir, "."+name)
+	if err := os.WriteFile(tmpPath, body, 0644); err != nil {
+		log.Errorf("Failed to buffer %d alerts: %v", alertsCount, err)
+		queue.promCollector.reportHTTPAlertsDropped(httpDropReasonDeliveryFailed, alertsCount)
+		return
+	}
+	if err := os.Rename(tmpPath, filepath.Join(queue.config.BufferDir, name)); err != nil {
+		log.Errorf("Failed to buffer %d alerts: %v", alertsCount, err)
+		os.Remove(tmpPath)
+		queue.promCollector.reportHTTPAlertsDropped(httpDropReasonDeliveryFailed, alertsCount)
+		return
+	}
+	queue.reportBufferedBatches(1)
+}
+
+// reportBufferedBatches counts the batches added to (or removed from, when negative) the on-disk buffer.
+func (queue *httpDeliveryQueue) reportBufferedBatches(delta int) {
+	queue.bufferedBatches += delta
+	queue.promCollector.reportHTTPBufferedBatches(delta)
+}
+
+// deliverBuffered sends the buffered batches, oldest first, up to httpMaxBufferedBatchesPerPass of them.
+// The pass ends at the first batch which fails, and the buffer is not replayed again before the backoff is over.
+func (queue *httpDeliveryQueue) deliverBuffered(now time.Time) {
+	if queue.config.BufferDir == "" {
+		return
+	}
+	if queue.bufferedBatches <= 0 {
+		queue.endpointDown = false
+		return
+	}
+	if now.Before(queue.nextBufferedAttempt) {
+		return
+	}
+	files := queue.bufferedBatchFiles()
+	for i, file := range files {
+		if i >= httpMaxBufferedBatchesPerPass {
+			return
+		}
+		body, err := os.ReadFile(file.path)
+		if err != nil {
+			log.Errorf("Failed to read buffered batch %s: %v", file.path, err)
+			queue.backoffBuffered(now)
+			return
+		}
+		sendErr := queue.send(body)
+		if sendErr != nil && sendErr.retryable {
+			queue.endpointDown = true
+			queue.backoffBuffered(now)
+			return
+		}
+		if err := os.Remove(file.path); err != nil {
+			log.Errorf("Failed to remove buffered batch %s: %v", file.path, err)
+			queue.backoffBuffered(now)
+			return
+		}
+		queue.reportBufferedBatches(-1)
+		if sendErr != nil {
+			log.Errorf("HTTP exporter endpoint rejected %d buffered alerts: %v", file.alertsCount, sendErr)
+			queue.promCollector.reportHTTPAlertsDropped(httpDropReasonRejected, file.alertsCount)
+			// The next buffered batches are sent at the next pass
+			return
+		}
+		queue.promCollector.reportHTTPAlertsSent(file.alertsCount)
+		queue.bufferedBackoff = 0
+	}
+	if len(files) == 0 {
+		// The buffer was emptied outside of the queue
+		queue.reportBufferedBatches(-queue.bufferedBatches)
+	}
+	queue.endpointDown = false
+}
+
+// backoffBuffered delays the next replay of the buffer, doubling the delay on every failed replay.
+func (queue *httpDeliveryQueue) backoffBuffered(now time.Time) {
+	if queue.bufferedBackoff == 0 {
+		queue.bufferedBackoff = time.Duration(queue.config.InitialBackoffMilliseconds) * time.Millisecond
+	} else {
+		queue.bufferedBackoff *= 2
+	}
+	if queue.bufferedBackoff > httpMaxRetryBackoff {
+		queue.bufferedBackoff = httpMaxRetryBackoff
+	}
+	queue.nextBufferedAttempt = now.Add(queue.bufferedBackoff)
+}
+
+type bufferedBatchFile struct {
+	path        string
+	size        int64
+	alertsCount int
+}
+
+// bufferedBatchFiles returns the batch files in the buffer, oldest first.
+func (queue *httpDeliveryQueue) bufferedBatchFiles() []bufferedBatchFile {
+	entries, err := os.ReadDir(queue.config.BufferDir)
+	if err != nil {
+		log.Errorf("Failed to list HTTP exporter buffer directory %s: %v", queue.config.BufferDir, err)
+		return nil
+	}
+	files := []bufferedBatchFile{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, httpBufferFileSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		// File name is <timestamp>-<number of alerts>.json
+		alertsCount := 0
+		parts := strings.Split(strings.TrimSuffix(name, httpBufferFileSuffix), "-")
+		if len(parts) == 2 {
+			alertsCount, _ = strconv.Atoi(parts[1])
+		}
+		files = append(files, bufferedBatchFile{
+			path:        filepath.Join(queue.config.BufferDir, name),
+			size:        info.Size(),
+			alertsCount: alertsCount,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	return files
+}
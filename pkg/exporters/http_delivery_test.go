@@ -0,0 +1,350 @@
+package exporters
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newAlertsServer creates a mock HTTP server which answers with the status returned by statusFunc
+// and forwards the received alert lists to the returned channel.
+func newAlertsServer(t *testing.T, statusFunc func(request int32) int) (*httptest.Server, chan HTTPAlertsList, *int32) {
+	var requests int32
+	alertsChan := make(chan HTTPAlertsList, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statusFunc(atomic.AddInt32(&requests, 1))
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read request body: %v", err)
+			return
+		}
+		alertsList := HTTPAlertsList{}
+		if err := json.Unmarshal(body, &alertsList); err != nil {
+			t.Errorf("Failed to unmarshal request body: %v", err)
+			return
+		}
+		alertsChan <- alertsList
+	}))
+	return server, alertsChan, &requests
+}
+
+func TestHTTPDeliveryQueueBatching(t *testing.T) {
+	server, alertsChan, requests := newAlertsServer(t, func(int32) int { return http.StatusOK })
+	defer server.Close()
+
+	exporter, err := InitHTTPExporter(HTTPExporterConfig{
+		URL:                       server.URL,
+		MaxAlertsPerBatch:         5,
+		BatchIntervalMilliseconds: 10000,
+	})
+	assert.NoError(t, err)
+	defer exporter.Close()
+
+	// Test case: batch is sent when it is full
+	for i := 0; i < 5; i++ {
+		exporter.sendInAlertList(HTTPAlert{RuleName: "testrule"})
+	}
+	select {
+	case alertsList := <-alertsChan:
+		assert.Len(t, alertsList.Spec.Alerts, 5)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for batch")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	// Test case: partial batch is sent on close
+	exporter.sendInAlertList(HTTPAlert{RuleName: "testrule"})
+	exporter.Close()
+	select {
+	case alertsList := <-alertsChan:
+		assert.Len(t, alertsList.Spec.Alerts, 1)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for partial batch")
+	}
+
+	// Test case: alerts enqueued after close are dropped
+	exporter.sendInAlertList(HTTPAlert{RuleName: "testrule"})
+	assert.Len(t, exporter.deliveryQueue.alerts, 0)
+}
+
+func TestHTTPDeliveryQueueRetry(t *testing.T) {
+	// Fail the first two requests with a 5xx, then succeed
+	server, alertsChan, requests := newAlertsServer(t, func(request int32) int {
+		if request <= 2 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	defer server.Close()
+
+	exporter, err := InitHTTPExporter(HTTPExporterConfig{
+		URL:                        server.URL,
+		BatchIntervalMilliseconds:  10,
+		InitialBackoffMilliseconds: 10,
+	})
+	assert.NoError(t, err)
+	defer exporter.Close()
+
+	exporter.sendInAlertList(HTTPAlert{RuleName: "testrule"})
+	select {
+	case alertsList := <-alertsChan:
+		assert.Equal(t, "testrule", alertsList.Spec.Alerts[0].RuleName)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for retried batch")
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+}
+
+func TestHTTPDeliveryQueueNoRetryOn4xx(t *testing.T) {
+	server, _, requests := newAlertsServer(t, func(int32) int { return http.StatusBadRequest })
+	defer server.Close()
+
+	exporter, err := InitHTTPExporter(HTTPExporterConfig{
+		URL:                        server.URL,
+		BatchIntervalMilliseconds:  10,
+		InitialBackoffMilliseconds: 10,
+	})
+	assert.NoError(t, err)
+
+	exporter.sendInAlertList(HTTPAlert{RuleName: "testrule"})
+	time.Sleep(200 * time.Millisecond)
+	exporter.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+func TestHTTPDeliveryQueueRetriesDisabled(t *testing.T) {
+	server, _, requests := newAlertsServer(t, func(int32) int { return http.StatusServiceUnavailable })
+	defer server.Close()
+
+	maxRetries := 0
+	exporter, err := InitHTTPExporter(HTTPExporterConfig{
+		URL:                        server.URL,
+		BatchIntervalMilliseconds:  10,
+		MaxRetries:                 &maxRetries,
+		InitialBackoffMilliseconds: 10,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, *exporter.config.MaxRetries)
+
+	exporter.sendInAlertList(HTTPAlert{RuleName: "testrule"})
+	time.Sleep(200 * time.Millisecond)
+	exporter.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+func TestHTTPDeliveryQueueBatchingWhileRetrying(t *testing.T) {
+	server, _, requests := newAlertsServer(t, func(int32) int { return http.StatusServiceUnavailable })
+	defer server.Close()
+
+	bufferDir := t.TempDir()
+	exporter, err := InitHTTPExporter(HTTPExporterConfig{
+		URL:                        server.URL,
+		MaxAlertsPerBatch:          1,
+		BatchIntervalMilliseconds:  10,
+		InitialBackoffMilliseconds: 10000,
+		BufferDir:                  bufferDir,
+	})
+	assert.NoError(t, err)
+
+	exporter.sendInAlertList(HTTPAlert{RuleName: "first"})
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(requests) == 1 }, 1*time.Second, 10*time.Millisecond)
+
+	// The alerts keep being batched while the first batch waits for its retry, and wait behind it
+	for _, name := range []string{"second", "third"} {
+		exporter.sendInAlertList(HTTPAlert{RuleName: name})
+		assert.Eventually(t, func() bool { return len(exporter.deliveryQueue.alerts) == 0 }, 1*time.Second, 10*time.Millisecond)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	// The batches waiting for a retry are buffered on close
+	start := time.Now()
+	exporter.Close()
+	assert.Less(t, time.Since(start), 1*time.Second)
+	assert.Len(t, exporter.deliveryQueue.bufferedBatchFiles(), 3)
+}
+
+func TestHTTPDeliveryQueueDiskBuffer(t *testing.T) {
+	var endpointUp atomic.Bool
+	server, alertsChan, _ := newAlertsServer(t, func(int32) int {
+		if endpointUp.Load() {
+			return http.StatusOK
+		}
+		return http.StatusInternalServerError
+	})
+	defer server.Close()
+
+	bufferDir := t.TempDir()
+	maxRetries := 1
+	exporter, err := InitHTTPExporter(HTTPExporterConfig{
+		URL:                        server.URL,
+		BatchIntervalMilliseconds:  10,
+		MaxRetries:                 &maxRetries,
+		InitialBackoffMilliseconds: 10,
+		BufferDir:                  bufferDir,
+	})
+	assert.NoError(t, err)
+	defer exporter.Close()
+
+	// The batch should end up in the buffer while the endpoint is down
+	exporter.sendInAlertList(HTTPAlert{RuleName: "first"})
+	assert.Eventually(t, func() bool {
+		return len(exporter.deliveryQueue.bufferedBatchFiles()) == 1
+	}, 1*time.Second, 10*time.Millisecond)
+	exporter.sendInAlertList(HTTPAlert{RuleName: "second"})
+	assert.Eventually(t, func() bool {
+		return len(exporter.deliveryQueue.bufferedBatchFiles()) == 2
+	}, 1*time.Second, 10*time.Millisecond)
+
+	// The buffered batches should be delivered in order once the endpoint is up
+	endpointUp.Store(true)
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case alertsList := <-alertsChan:
+			assert.Equal(t, expected, alertsList.Spec.Alerts[0].RuleName)
+		case <-time.After(1 * time.Second):
+			t.Fatalf("Timed out waiting for buffered batch %s", expected)
+		}
+	}
+	assert.Eventually(t, func() bool {
+		return len(exporter.deliveryQueue.bufferedBatchFiles()) == 0
+	}, 1*time.Second, 10*time.Millisecond)
+}
+
+func TestHTTPDeliveryQueueBufferedBackoff(t *testing.T) {
+	var endpointUp atomic.Bool
+	server, alertsChan, requests := newAlertsServer(t, func(int32) int {
+		if endpointUp.Load() {
+			return http.StatusOK
+		}
+		return http.StatusServiceUnavailable
+	})
+	defer server.Close()
+
+	bufferDir := t.TempDir()
+	body, err := json.Marshal(HTTPAlertsList{Spec: HTTPAlertsListSpec{Alerts: []HTTPAlert{{RuleName: "buffered"}}}})
+	assert.NoError(t, err)
+	for _, name := range []string{"00000000000000000001-1.json", "00000000000000000002-1.json", "00000000000000000003-1.json"} {
+		assert.NoError(t, os.WriteFile(bufferDir+"/"+name, body, 0644))
+	}
+	exporter, err := InitHTTPExporter(HTTPExporterConfig{
+		URL:                        server.URL,
+		BatchIntervalMilliseconds:  10,
+		InitialBackoffMilliseconds: 100,
+		BufferDir:                  bufferDir,
+	})
+	assert.NoError(t, err)
+	defer exporter.Close()
+
+	// While the endpoint is down, a replay stops at the first batch and the next replays back off
+	// (after 100ms, then 200ms), instead of sending every buffered batch at every tick
+	time.Sleep(250 * time.Millisecond)
+	assert.LessOrEqual(t, atomic.LoadInt32(requests), int32(3))
+
+	endpointUp.Store(true)
+	for i := 0; i < 3; i++ {
+		select {
+		case alertsList := <-alertsChan:
+			assert.Equal(t, "buffered", alertsList.Spec.Alerts[0].RuleName)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for buffered batch %d", i)
+		}
+	}
+	assert.Eventually(t, func() bool {
+		return len(exporter.deliveryQueue.bufferedBatchFiles()) == 0
+	}, 1*time.Second, 10*time.Millisecond)
+}
+
+func TestHTTPDeliveryQueueBufferHandover(t *testing.T) {
+	var endpointUp atomic.Bool
+	server, alertsChan, _ := newAlertsServer(t, func(int32) int {
+		if endpointUp.Load() {
+			return http.StatusOK
+		}
+		return http.StatusInternalServerError
+	})
+	defer server.Close()
+
+	bufferDir := t.TempDir()
+	maxRetries := 0
+	config := HTTPExporterConfig{
+		URL:                        server.URL,
+		BatchIntervalMilliseconds:  10,
+		MaxRetries:                 &maxRetries,
+		InitialBackoffMilliseconds: 10,
+		BufferDir:                  bufferDir,
+	}
+	oldExporter, err := InitHTTPExporter(config)
+	assert.NoError(t, err)
+	oldExporter.sendInAlertList(HTTPAlert{RuleName: "first"})
+	assert.Eventually(t, func() bool {
+		return len(oldExporter.deliveryQueue.bufferedBatchFiles()) == 1
+	}, 1*time.Second, 10*time.Millisecond)
+
+	// A reloaded exporter with the same buffer waits for the replaced one to stop using it,
+	// so the buffered batch is delivered once
+	newExporter, err := InitHTTPExporter(config)
+	assert.NoError(t, err)
+	defer newExporter.Close()
+	endpointUp.Store(true)
+	newExporter.sendInAlertList(HTTPAlert{RuleName: "second"})
+	select {
+	case alertsList := <-alertsChan:
+		assert.Equal(t, "first", alertsList.Spec.Alerts[0].RuleName)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for the buffered batch")
+	}
+	select {
+	case alertsList := <-alertsChan:
+		t.Fatalf("Unexpected batch %s before the replaced exporter is closed", alertsList.Spec.Alerts[0].RuleName)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	oldExporter.Close()
+	select {
+	case alertsList := <-alertsChan:
+		assert.Equal(t, "second", alertsList.Spec.Alerts[0].RuleName)
+	case <-time.After(1 * time.Second):
+		t.Fatalf("Timed out waiting for the batch of the new exporter")
+	}
+	select {
+	case alertsList := <-alertsChan:
+		t.Fatalf("Unexpected batch %s delivered twice", alertsList.Spec.Alerts[0].RuleName)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHTTPDeliveryQueueBufferLimit(t *testing.T) {
+	bufferDir := t.TempDir()
+	queue := &httpDeliveryQueue{
+		config:        HTTPExporterConfig{BufferDir: bufferDir, BufferMaxSizeMB: 1},
+		promCollector: getPrometheusMetric(),
+	}
+
+	// Test case: the oldest batch is dropped when the buffer is full
+	queue.buffer(make([]byte, 600*1024), 1)
+	queue.buffer(make([]byte, 600*1024), 2)
+	files := queue.bufferedBatchFiles()
+	assert.Len(t, files, 1)
+	assert.Equal(t, 2, files[0].alertsCount)
+
+	// Test case: a batch larger than the buffer is dropped
+	queue.buffer(make([]byte, 2*1024*1024), 3)
+	files = queue.bufferedBatchFiles()
+	assert.Len(t, files, 1)
+	assert.Equal(t, 2, files[0].alertsCount)
+
+	// Test case: temporary files are ignored
+	assert.NoError(t, os.WriteFile(bufferDir+"/.00000000000000000001-1.json", []byte("{}"), 0644))
+	assert.Len(t, queue.bufferedBatchFiles(), 1)
+}
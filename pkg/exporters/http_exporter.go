@@ -1,10 +1,7 @@
 package exporters
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"sync"
@@ -25,6 +22,20 @@ type HTTPExporterConfig struct {
 	// Method is the HTTP method to use for the HTTP request
 	Method             string `json:"method"`
 	MaxAlertsPerMinute int    `json:"maxAlertsPerMinute"`
+	// MaxAlertsPerBatch is the maximum number of alerts sent in a single HTTP request
+	MaxAlertsPerBatch int `json:"maxAlertsPerBatch"`
+	// BatchIntervalMilliseconds is the maximum time an alert waits for its batch to fill before it is sent
+	BatchIntervalMilliseconds int `json:"batchIntervalMilliseconds"`
+	// QueueSize is the maximum number of alerts waiting in memory to be sent, alerts are dropped when it is full
+	QueueSize int `json:"queueSize"`
+	// MaxRetries is the number of times a batch is retried on timeouts and 5xx responses, 3 if not set, 0 disables retries
+	MaxRetries *int `json:"maxRetries"`
+	// InitialBackoffMilliseconds is the time to wait before the first retry, it is doubled on every retry
+	InitialBackoffMilliseconds int `json:"initialBackoffMilliseconds"`
+	// BufferDir is the directory in which batches that could not be delivered are kept until the endpoint is reachable (disabled if empty)
+	BufferDir string `json:"bufferDir"`
+	// BufferMaxSizeMB is the maximum size of the on-disk buffer, the oldest batches are dropped when it is full
+	BufferMaxSizeMB int `json:"bufferMaxSizeMB"`
 }
 
 // we will have a CRD-like json struct to send in the HTTP request
@@ -37,6 +48,8 @@ type HTTPExporter struct {
 	alertCount      int
 	alertCountLock  sync.Mutex
 	alertCountStart time.Time
+	// deliveryQueue batches and sends the alerts in the background
+	deliveryQueue *httpDeliveryQueue
 }
 
 type HTTPAlertsList struct {
@@ -57,6 +70,17 @@ type RuleAlert struct {
 	PPID           uint32 `json:"ppid,omitempty"` //  Parent Process ID
 	UID            uint32 `json:"uid,omitempty"`  // User ID of the process
 	GID            uint32 `json:"gid,omitempty"`  // Group ID of the process
+	// MITRE ATT&CK tactic and technique IDs of the rule
+	MitreTactics    []string `json:"mitreTactics,omitempty"`
+	MitreTechniques []string `json:"mitreTechniques,omitempty"`
+	// The process of the alert, then its parent, up to the oldest known ancestor
+	ProcessAncestry []rule.ProcessInfo `json:"processAncestry,omitempty"`
+	// Structured data the rule failed on, like the path of an opened file or a requested domain
+	Evidence rule.Evidence `json:"evidence,omitempty"`
+	// Set when the alert summarizes suppressed repeats of the rule failure
+	SuppressedCount int        `json:"suppressedCount,omitempty"`
+	FirstSeen       *time.Time `json:"firstSeen,omitempty"`
+	LastSeen        *time.Time `json:"lastSeen,omitempty"`
 }
 
 type MalwareAlert struct {
@@ -104,6 +128,29 @@ func (config *HTTPExporterConfig) Validate() error {
 	if config.Headers == nil {
 		config.Headers = make(map[string]string)
 	}
+	if config.MaxAlertsPerBatch == 0 {
+		config.MaxAlertsPerBatch = 100
+	}
+	if config.BatchIntervalMilliseconds == 0 {
+		config.BatchIntervalMilliseconds = 500
+	}
+	if config.QueueSize == 0 {
+		config.QueueSize = 10000
+	}
+	if config.MaxRetries == nil {
+		maxRetries := 3
+		config.MaxRetries = &maxRetries
+	}
+	if config.InitialBackoffMilliseconds == 0 {
+		config.InitialBackoffMilliseconds = 500
+	}
+	if config.BufferMaxSizeMB == 0 {
+		config.BufferMaxSizeMB = 100
+	}
+	if config.MaxAlertsPerBatch < 0 || config.BatchIntervalMilliseconds < 0 || config.QueueSize < 0 ||
+		*config.MaxRetries < 0 || config.InitialBackoffMilliseconds < 0 || config.BufferMaxSizeMB < 0 {
+		return fmt.Errorf("batching, queue, retry and buffer settings must not be negative")
+	}
 	if config.URL == "" {
 		return fmt.Errorf("URL is required")
 	}
@@ -116,14 +163,22 @@ func InitHTTPExporter(config HTTPExporterConfig) (*HTTPExporter, error) {
 		return nil, err
 	}
 
+	httpClient := &http.Client{
+		Timeout: time.Duration(config.TimeoutSeconds) * time.Second,
+	}
 	return &HTTPExporter{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: time.Duration(config.TimeoutSeconds) * time.Second,
-		},
+		config:        config,
+		httpClient:    httpClient,
+		deliveryQueue: newHTTPDeliveryQueue(config, httpClient),
 	}, nil
 }
 
+// Close sends the alerts which are still queued and stops the exporter.
+func (exporter *HTTPExporter) Close() error {
+	exporter.deliveryQueue.stop()
+	return nil
+}
+
 func (exporter *HTTPExporter) sendAlertLimitReached() {
 	httpAlert := HTTPAlert{
 		Message:  "Alert limit reached",
@@ -156,63 +211,32 @@ func (exporter *HTTPExporter) SendRuleAlert(failedRule rule.RuleFailure) {
 		HostName:      exporter.Host,
 		NodeName:      exporter.NodeName,
 		RuleAlert: RuleAlert{
-			Severity:       failedRule.Priority(),
-			FixSuggestions: failedRule.FixSuggestion(),
-			PID:            failedRule.Event().Pid,
-			PPID:           failedRule.Event().Ppid,
-			ProcessName:    failedRule.Event().Comm,
-			UID:            failedRule.Event().Uid,
-			GID:            failedRule.Event().Gid,
+			Severity:        failedRule.Priority(),
+			FixSuggestions:  failedRule.FixSuggestion(),
+			PID:             failedRule.Event().Pid,
+			PPID:            failedRule.Event().Ppid,
+			ProcessName:     failedRule.Event().Comm,
+			UID:             failedRule.Event().Uid,
+			GID:             failedRule.Event().Gid,
+			ProcessAncestry: rule.GetProcessAncestry(failedRule),
+			Evidence:        failedRule.Evidence(),
 		},
 	}
+	if mitre := rule.GetMitreAttack(failedRule); !mitre.IsEmpty() {
+		httpAlert.MitreTactics = mitre.Tactics
+		httpAlert.MitreTechniques = mitre.Techniques
+	}
+	if suppressed := rule.GetSuppressedRuleFailure(failedRule); suppressed != nil {
+		httpAlert.SuppressedCount = suppressed.SuppressedCount
+		httpAlert.FirstSeen = &suppressed.FirstSeen
+		httpAlert.LastSeen = &suppressed.LastSeen
+	}
 	exporter.sendInAlertList(httpAlert)
 }
 
 func (exporter *HTTPExporter) sendInAlertList(httpAlert HTTPAlert) {
-	// create the HTTPAlertsListSpec struct
-	// TODO: accumulate alerts and send them in a batch
-	httpAlertsListSpec := HTTPAlertsListSpec{
-		Alerts: []HTTPAlert{httpAlert},
-	}
-	// create the HTTPAlertsList struct
-	httpAlertsList := HTTPAlertsList{
-		Kind:       "RuntimeAlerts",
-		ApiVersion: "kubescape.io/v1",
-		Spec:       httpAlertsListSpec,
-	}
-
-	// create the JSON representation of the HTTPAlertsList struct
-	bodyBytes, err := json.Marshal(httpAlertsList)
-	if err != nil {
-		fmt.Printf("Error marshalling HTTPAlertsList: %v\n", err)
-		return
-	}
-	bodyReader := bytes.NewReader(bodyBytes)
-
-	// send the HTTP request
-	req, err := http.NewRequest(exporter.config.Method, exporter.config.URL, bodyReader)
-	if err != nil {
-		fmt.Printf("Error creating HTTP request: %v\n", err)
-		return
-	}
-	for key, value := range exporter.config.Headers {
-		req.Header.Set(key, value)
-	}
-	resp, err := exporter.httpClient.Do(req)
-	if err != nil {
-		fmt.Printf("Error sending HTTP request: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		fmt.Printf("Received non-2xx status code: %d\n", resp.StatusCode)
-		return
-	}
-
-	// discard the body
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-		fmt.Printf("Error clearing response body: %v\n", err)
-	}
+	// the alert is sent in a batch by the delivery queue, without blocking the caller
+	exporter.deliveryQueue.enqueue(httpAlert)
 }
 
 func (exporter *HTTPExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
@@ -40,11 +40,12 @@ func TestSendRuleAlert(t *testing.T) {
 		RuleName:     "testrule",
 		Err:          "Application profile is missing",
 		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
-			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"}},
+			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"},
+			PathName: "/bin/ls", Args: []string{"ls", "-l"}},
 	}
 
 	// Call SendRuleAlert
-	exporter.SendRuleAlert(failedRule)
+	exporter.SendRuleAlert(rule.WithProcessAncestry(failedRule, []rule.ProcessInfo{{Pid: 20, Ppid: 10, Path: "/bin/sh"}, {Pid: 10}}))
 
 	// Assert that the HTTP request was sent correctly
 	alertsList := HTTPAlertsList{}
@@ -67,6 +68,12 @@ func TestSendRuleAlert(t *testing.T) {
 	assert.Equal(t, "testcontainer", alert.ContainerName)
 	assert.Equal(t, "testnamespace", alert.PodNamespace)
 	assert.Equal(t, "testpodname", alert.PodName)
+	assert.Equal(t, []rule.ProcessInfo{{Pid: 20, Ppid: 10, Path: "/bin/sh"}, {Pid: 10}}, alert.ProcessAncestry)
+	assert.Equal(t, "/bin/ls", alert.Evidence.String(rule.EvidencePath))
+	assert.Equal(t, []interface{}{"ls", "-l"}, alert.Evidence[rule.EvidenceArgs])
+	// the rule name is not a builtin rule, so there is no MITRE ATT&CK mapping
+	assert.Empty(t, alert.MitreTactics)
+	assert.Empty(t, alert.MitreTechniques)
 }
 
 func TestSendRuleAlertRateReached(t *testing.T) {
@@ -204,6 +211,7 @@ func TestValidateHTTPExporterConfig(t *testing.T) {
 	assert.Equal(t, 1, exp.config.TimeoutSeconds)
 	assert.Equal(t, 10000, exp.config.MaxAlertsPerMinute)
 	assert.Equal(t, map[string]string{}, exp.config.Headers)
+	assert.Equal(t, 3, *exp.config.MaxRetries)
 
 	// Test case: Method is PUT
 	exp, err = InitHTTPExporter(HTTPExporterConfig{
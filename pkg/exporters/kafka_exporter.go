@@ -0,0 +1,400 @@
+package exporters
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// KafkaAlertSchemaVersion is the version of the JSON schema of the Kafka messages.
+// Fields may be added to a version, it is incremented when fields are removed or change their meaning.
+const KafkaAlertSchemaVersion = 1
+
+// Kinds of the Kafka messages
+const (
+	KafkaRuleAlertKind    = "RuleAlert"
+	KafkaMalwareAlertKind = "MalwareAlert"
+)
+
+// Headers of the Kafka messages, so consumers can route the messages without decoding them
+const (
+	KafkaSchemaVersionHeader = "kubecop-schema-version"
+	KafkaAlertKindHeader     = "kubecop-alert-kind"
+)
+
+// Acks, compressions and SASL mechanisms of the Kafka exporter
+const (
+	KafkaAcksAll           = "all"
+	KafkaAcksLeader        = "leader"
+	KafkaAcksNone          = "none"
+	KafkaCompressionNone   = "none"
+	KafkaCompressionGzip   = "gzip"
+	KafkaCompressionSnappy = "snappy"
+	KafkaCompressionLZ4    = "lz4"
+	KafkaCompressionZstd   = "zstd"
+	KafkaSASLPlain         = "PLAIN"
+	KafkaSASLScramSHA256   = "SCRAM-SHA-256"
+	KafkaSASLScramSHA512   = "SCRAM-SHA-512"
+)
+
+const (
+	kafkaDefaultClientID = "kubecop"
+	// Environment variable of the SASL password, so it can be set from a secret
+	kafkaSASLPasswordEnv = "KAFKA_SASL_PASSWORD"
+)
+
+type KafkaExporterConfig struct {
+	// Brokers are the host:port of the seed brokers of the cluster
+	Brokers []string `json:"brokers"`
+	// RuleAlertsTopic is the topic of the rule alerts, kubecop-rule-alerts if not set
+	RuleAlertsTopic string `json:"ruleAlertsTopic"`
+	// MalwareAlertsTopic is the topic of the malware alerts, kubecop-malware-alerts if not set
+	MalwareAlertsTopic string `json:"malwareAlertsTopic"`
+	// ClientID is the client ID sent to the brokers, kubecop if not set
+	ClientID string `json:"clientID"`
+	// Acks is the number of acknowledgements the brokers must give before an alert is sent: all (default), leader or none
+	Acks string `json:"acks"`
+	// Compression is the compression of the batches: none, gzip, snappy (default), lz4 or zstd
+	Compression string `json:"compression"`
+	// BatchIntervalMilliseconds is the maximum time an alert waits for its batch to fill before it is sent, 100 if not set
+	BatchIntervalMilliseconds int `json:"batchIntervalMilliseconds"`
+	// QueueSize is the maximum number of alerts waiting in memory to be sent, alerts are dropped when it is full, 10000 if not set
+	QueueSize int `json:"queueSize"`
+	// TimeoutSeconds is the time an alert is retried before it is dropped, 30 if not set
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// SASL enables the SASL authentication
+	SASL *KafkaSASLConfig `json:"sasl"`
+	// TLS enables TLS, with the system CAs if no CA file is set
+	TLS *KafkaTLSConfig `json:"tls"`
+}
+
+type KafkaSASLConfig struct {
+	// Mechanism is PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+	Mechanism string `json:"mechanism"`
+	Username  string `json:"username"`
+	// Password is read from the KAFKA_SASL_PASSWORD environment variable if not set
+	Password string `json:"password"`
+}
+
+type KafkaTLSConfig struct {
+	// CAFile is the PEM file of the CAs of the brokers
+	CAFile string `json:"caFile"`
+	// CertFile and KeyFile are the PEM files of the client certificate, for mutual TLS
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// InsecureSkipVerify disables the verification of the certificates of the brokers
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+}
+
+// KafkaAlert is the JSON value of the Kafka messages.
+type KafkaAlert struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	Kind          string    `json:"kind"`
+	Timestamp     time.Time `json:"timestamp"`
+	RuleID        string    `json:"ruleID,omitempty"`
+	RuleName      string    `json:"ruleName"`
+	// SeverityName is the name of the severity, like high or critical
+	SeverityName  string `json:"severityName"`
+	Message       string `json:"message"`
+	ContainerID   string `json:"containerID,omitempty"`
+	ContainerName string `json:"containerName,omitempty"`
+	PodNamespace  string `json:"podNamespace,omitempty"`
+	PodName       string `json:"podName,omitempty"`
+	NodeName      string `json:"nodeName"`
+	RuleAlert     `json:",inline"`
+	MalwareAlert  `json:",inline"`
+}
+
+// KafkaExporter produces the alerts as JSON messages to Kafka topics, keyed by the namespace and pod of the alert,
+// so the alerts of a pod are kept in order in a single partition.
+// The alerts are batched and retried in the background by the Kafka client.
+type KafkaExporter struct {
+	config   KafkaExporterConfig
+	client   *kgo.Client
+	nodeName string
+}
+
+func (config *KafkaExporterConfig) Validate() error {
+	if config.RuleAlertsTopic == "" {
+		config.RuleAlertsTopic = "kubecop-rule-alerts"
+	}
+	if config.MalwareAlertsTopic == "" {
+		config.MalwareAlertsTopic = "kubecop-malware-alerts"
+	}
+	if config.ClientID == "" {
+		config.ClientID = kafkaDefaultClientID
+	}
+	if config.Acks == "" {
+		config.Acks = KafkaAcksAll
+	} else if config.Acks != KafkaAcksAll && config.Acks != KafkaAcksLeader && config.Acks != KafkaAcksNone {
+		return fmt.Errorf("acks must be %s, %s or %s", KafkaAcksAll, KafkaAcksLeader, KafkaAcksNone)
+	}
+	if config.Compression == "" {
+		config.Compression = KafkaCompressionSnappy
+	} else if _, err := kafkaCompressionCodec(config.Compression); err != nil {
+		return err
+	}
+	if config.BatchIntervalMilliseconds == 0 {
+		config.BatchIntervalMilliseconds = 100
+	}
+	if config.QueueSize == 0 {
+		config.QueueSize = 10000
+	}
+	if config.TimeoutSeconds == 0 {
+		config.TimeoutSeconds = 30
+	}
+	if config.BatchIntervalMilliseconds < 0 || config.QueueSize < 0 || config.TimeoutSeconds < 0 {
+		return fmt.Errorf("batching, queue and timeout settings must not be negative")
+	}
+	if len(config.Brokers) == 0 {
+		return fmt.Errorf("brokers are required")
+	}
+	if config.SASL != nil {
+		switch config.SASL.Mechanism {
+		case KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512:
+		default:
+			return fmt.Errorf("SASL mechanism must be %s, %s or %s", KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512)
+		}
+		if config.SASL.Username == "" {
+			return fmt.Errorf("SASL username is required")
+		}
+	}
+	if config.TLS != nil && (config.TLS.CertFile == "") != (config.TLS.KeyFile == "") {
+		return fmt.Errorf("TLS certFile and keyFile must be set together")
+	}
+	return nil
+}
+
+// InitKafkaExporter initializes a KafkaExporter, the brokers are connected to in the background.
+func InitKafkaExporter(config KafkaExporterConfig) (*KafkaExporter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	compression, err := kafkaCompressionCodec(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(config.Brokers...),
+		kgo.ClientID(config.ClientID),
+		kgo.ProducerBatchCompression(compression),
+		kgo.ProducerLinger(time.Duration(config.BatchIntervalMilliseconds) * time.Millisecond),
+		kgo.MaxBufferedRecords(config.QueueSize),
+		kgo.RecordDeliveryTimeout(time.Duration(config.TimeoutSeconds) * time.Second),
+	}
+	switch config.Acks {
+	case KafkaAcksLeader:
+		// idempotent writes require the acknowledgement of all the in-sync replicas
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	case KafkaAcksNone:
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite())
+	default:
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	}
+	if config.SASL != nil {
+		opts = append(opts, kgo.SASL(kafkaSASLMechanism(*config.SASL)))
+	}
+	if config.TLS != nil {
+		tlsConfig, err := kafkaTLSConfig(*config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaExporter{
+		config:   config,
+		client:   client,
+		nodeName: os.Getenv("NODE_NAME"),
+	}, nil
+}
+
+// Close sends the queued alerts and stops the exporter.
+func (exporter *KafkaExporter) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(exporter.config.TimeoutSeconds)*time.Second)
+	defer cancel()
+	err := exporter.client.Flush(ctx)
+	exporter.client.Close()
+	return err
+}
+
+func (exporter *KafkaExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	event := failedRule.Event()
+	timestamp := time.Now()
+	if event.Timestamp != 0 {
+		timestamp = time.Unix(0, event.Timestamp)
+	}
+	kafkaAlert := KafkaAlert{
+		SchemaVersion: KafkaAlertSchemaVersion,
+		Kind:          KafkaRuleAlertKind,
+		Timestamp:     timestamp.UTC(),
+		RuleID:        rule.GetRuleIDByName(failedRule.Name()),
+		RuleName:      failedRule.Name(),
+		SeverityName:  PriorityToStatus(failedRule.Priority()),
+		Message:       failedRule.Error(),
+		ContainerID:   event.ContainerID,
+		ContainerName: event.ContainerName,
+		PodNamespace:  event.Namespace,
+		PodName:       event.PodName,
+		NodeName:      exporter.nodeName,
+		RuleAlert: RuleAlert{
+			Severity:        failedRule.Priority(),
+			FixSuggestions:  failedRule.FixSuggestion(),
+			PID:             event.Pid,
+			PPID:            event.Ppid,
+			ProcessName:     event.Comm,
+			UID:             event.Uid,
+			GID:             event.Gid,
+			ProcessAncestry: rule.GetProcessAncestry(failedRule),
+			Evidence:        failedRule.Evidence(),
+		},
+	}
+	if mitre := rule.GetMitreAttack(failedRule); !mitre.IsEmpty() {
+		kafkaAlert.MitreTactics = mitre.Tactics
+		kafkaAlert.MitreTechniques = mitre.Techniques
+	}
+	if suppressed := rule.GetSuppressedRuleFailure(failedRule); suppressed != nil {
+		kafkaAlert.SuppressedCount = suppressed.SuppressedCount
+		kafkaAlert.FirstSeen = &suppressed.FirstSeen
+		kafkaAlert.LastSeen = &suppressed.LastSeen
+	}
+	exporter.produce(exporter.config.RuleAlertsTopic, kafkaAlert)
+}
+
+func (exporter *KafkaExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
+	exporter.produce(exporter.config.MalwareAlertsTopic, KafkaAlert{
+		SchemaVersion: KafkaAlertSchemaVersion,
+		Kind:          KafkaMalwareAlertKind,
+		Timestamp:     time.Now().UTC(),
+		RuleName:      "KubeCopMalwareDetected",
+		SeverityName:  PriorityToStatus(rule.RulePriorityCritical),
+		Message: fmt.Sprintf("Malware '%s' detected in namespace '%s' pod '%s' description '%s' path '%s'",
+			malwareDescription.Name, malwareDescription.Namespace, malwareDescription.PodName, malwareDescription.Description, malwareDescription.Path),
+		ContainerID:   malwareDescription.ContainerID,
+		ContainerName: malwareDescription.ContainerName,
+		PodNamespace:  malwareDescription.Namespace,
+		PodName:       malwareDescription.PodName,
+		NodeName:      exporter.nodeName,
+		MalwareAlert: MalwareAlert{
+			MalwareName:        malwareDescription.Name,
+			MalwareDescription: malwareDescription.Description,
+			Path:               malwareDescription.Path,
+			Hash:               malwareDescription.Hash,
+			Size:               malwareDescription.Size,
+			IsPartOfImage:      malwareDescription.IsPartOfImage,
+			Resource:           malwareDescription.Resource,
+			ContainerImage:     malwareDescription.ContainerImage,
+		},
+	})
+}
+
+// produce queues the alert without blocking the caller, the alert is dropped if the queue is full.
+func (exporter *KafkaExporter) produce(topic string, kafkaAlert KafkaAlert) {
+	value, err := json.Marshal(kafkaAlert)
+	if err != nil {
+		log.Errorf("failed to encode the Kafka alert: %v", err)
+		return
+	}
+	// the record keeps its produce time, the client measures the delivery timeout of the records from their timestamp
+	record := &kgo.Record{
+		Topic: topic,
+		Key:   kafkaAlertKey(kafkaAlert.PodNamespace, kafkaAlert.PodName),
+		Value: value,
+		Headers: []kgo.RecordHeader{
+			{Key: KafkaSchemaVersionHeader, Value: []byte(strconv.Itoa(KafkaAlertSchemaVersion))},
+			{Key: KafkaAlertKindHeader, Value: []byte(kafkaAlert.Kind)},
+		},
+	}
+	exporter.client.TryProduce(context.Background(), record, func(record *kgo.Record, err error) {
+		if err == nil {
+			return
+		}
+		if err == kgo.ErrMaxBuffered {
+			log.Warnf("Kafka exporter queue is full (%d alerts), dropping alert", exporter.config.QueueSize)
+		} else {
+			log.Errorf("failed to send an alert to Kafka topic %s, dropping it: %v", record.Topic, err)
+		}
+	})
+}
+
+// kafkaAlertKey returns the namespace/pod key of an alert, or no key for the alerts without a pod,
+// which are spread over the partitions.
+func kafkaAlertKey(namespace, podName string) []byte {
+	if podName == "" {
+		return nil
+	}
+	return []byte(namespace + "/" + podName)
+}
+
+func kafkaCompressionCodec(compression string) (kgo.CompressionCodec, error) {
+	switch compression {
+	case KafkaCompressionNone:
+		return kgo.NoCompression(), nil
+	case KafkaCompressionGzip:
+		return kgo.GzipCompression(), nil
+	case KafkaCompressionSnappy:
+		return kgo.SnappyCompression(), nil
+	case KafkaCompressionLZ4:
+		return kgo.Lz4Compression(), nil
+	case KafkaCompressionZstd:
+		return kgo.ZstdCompression(), nil
+	default:
+		return kgo.CompressionCodec{}, fmt.Errorf("compression must be %s, %s, %s, %s or %s",
+			KafkaCompressionNone, KafkaCompressionGzip, KafkaCompressionSnappy, KafkaCompressionLZ4, KafkaCompressionZstd)
+	}
+}
+
+func kafkaSASLMechanism(config KafkaSASLConfig) sasl.Mechanism {
+	password := config.Password
+	if password == "" {
+		password = os.Getenv(kafkaSASLPasswordEnv)
+	}
+	switch config.Mechanism {
+	case KafkaSASLScramSHA256:
+		return scram.Auth{User: config.Username, Pass: password}.AsSha256Mechanism()
+	case KafkaSASLScramSHA512:
+		return scram.Auth{User: config.Username, Pass: password}.AsSha512Mechanism()
+	default:
+		return plain.Auth{User: config.Username, Pass: password}.AsMechanism()
+	}
+}
+
+func kafkaTLSConfig(config KafkaTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the Kafka CA file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in the Kafka CA file %s", config.CAFile)
+		}
+	}
+	if config.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the Kafka client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,183 @@
+package exporters
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+)
+
+// consumeKafkaRecords reads the records of the topics from their start, until count records are read or the timeout.
+func consumeKafkaRecords(t *testing.T, brokers []string, count int, topics ...string) []*kgo.Record {
+	opts := []kgo.Opt{kgo.SeedBrokers(brokers...), kgo.ConsumeTopics(topics...), kgo.ConsumeResetOffset(kgo.NewOffset().AtStart())}
+	return consumeKafkaRecordsWithOpts(t, opts, count)
+}
+
+func consumeKafkaRecordsWithOpts(t *testing.T, opts []kgo.Opt, count int) []*kgo.Record {
+	consumer, err := kgo.NewClient(opts...)
+	assert.NoError(t, err)
+	defer consumer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var records []*kgo.Record
+	for len(records) < count && ctx.Err() == nil {
+		fetches := consumer.PollFetches(ctx)
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func kafkaRecordHeaders(record *kgo.Record) map[string]string {
+	headers := map[string]string{}
+	for _, header := range record.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	return headers
+}
+
+func TestKafkaExporter(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, "kubecop-rule-alerts", "kubecop-malware-alerts"))
+	assert.NoError(t, err)
+	defer cluster.Close()
+
+	t.Setenv("NODE_NAME", "testnode")
+	exporter, err := InitKafkaExporter(KafkaExporterConfig{Brokers: cluster.ListenAddrs(), BatchIntervalMilliseconds: 10})
+	assert.NoError(t, err)
+
+	// the timestamps of the events are in nanoseconds
+	timestamp := int64(1700000000123456789)
+
+	for _, podName := range []string{"testpod1", "testpod2", "testpod1"} {
+		exporter.SendRuleAlert(&rule.R0001UnexpectedProcessLaunchedFailure{
+			RuleName:     rule.R0001UnexpectedProcessLaunchedRuleName,
+			RulePriority: rule.RulePriorityHigh,
+			Err:          "Unexpected process launched: /bin/sh",
+			FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
+				ProcessDetails: tracing.ProcessDetails{Pid: 20, Comm: "sh"},
+				ContainerName:  "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: podName,
+				Timestamp: timestamp},
+				PathName: "/bin/sh"},
+		})
+	}
+	exporter.SendMalwareAlert(scan.MalwareDescription{
+		Name:          "testmalware",
+		Description:   "testdescription",
+		Path:          "/tmp/testmalware",
+		Namespace:     "testnamespace",
+		PodName:       "testpod1",
+		ContainerName: "testcontainer",
+	})
+	// the queued alerts are sent on close
+	assert.NoError(t, exporter.Close())
+
+	records := consumeKafkaRecords(t, cluster.ListenAddrs(), 4, "kubecop-rule-alerts", "kubecop-malware-alerts")
+	assert.Len(t, records, 4)
+	partitions := map[string]int32{}
+	for _, record := range records {
+		kafkaAlert := KafkaAlert{}
+		assert.NoError(t, json.Unmarshal(record.Value, &kafkaAlert))
+		assert.Equal(t, KafkaAlertSchemaVersion, kafkaAlert.SchemaVersion)
+		assert.Equal(t, "testnamespace/"+kafkaAlert.PodName, string(record.Key))
+		assert.Equal(t, "testnode", kafkaAlert.NodeName)
+		assert.Equal(t, map[string]string{KafkaSchemaVersionHeader: "1", KafkaAlertKindHeader: kafkaAlert.Kind}, kafkaRecordHeaders(record))
+
+		switch record.Topic {
+		case "kubecop-rule-alerts":
+			assert.Equal(t, KafkaRuleAlertKind, kafkaAlert.Kind)
+			assert.Equal(t, "R0001", kafkaAlert.RuleID)
+			assert.Equal(t, "high", kafkaAlert.SeverityName)
+			assert.Equal(t, uint32(20), kafkaAlert.PID)
+			assert.Equal(t, "/bin/sh", kafkaAlert.Evidence[rule.EvidencePath])
+			assert.Equal(t, timestamp, kafkaAlert.Timestamp.UnixNano())
+			// the alerts of a pod are in the same partition
+			if partition, ok := partitions[kafkaAlert.PodName]; ok {
+				assert.Equal(t, partition, record.Partition)
+			}
+			partitions[kafkaAlert.PodName] = record.Partition
+		case "kubecop-malware-alerts":
+			assert.Equal(t, KafkaMalwareAlertKind, kafkaAlert.Kind)
+			assert.Equal(t, "critical", kafkaAlert.SeverityName)
+			assert.Equal(t, "testmalware", kafkaAlert.MalwareName)
+			assert.Equal(t, "/tmp/testmalware", kafkaAlert.Path)
+		default:
+			t.Errorf("unexpected topic %s", record.Topic)
+		}
+	}
+}
+
+func TestKafkaExporterSASL(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.EnableSASL(), kfake.Superuser(KafkaSASLPlain, "kubecop", "testpassword"),
+		kfake.SeedTopics(1, "alerts"))
+	assert.NoError(t, err)
+	defer cluster.Close()
+
+	// the password is read from the environment
+	t.Setenv("KAFKA_SASL_PASSWORD", "testpassword")
+	exporter, err := InitKafkaExporter(KafkaExporterConfig{
+		Brokers:                   cluster.ListenAddrs(),
+		RuleAlertsTopic:           "alerts",
+		Acks:                      KafkaAcksLeader,
+		Compression:               KafkaCompressionZstd,
+		BatchIntervalMilliseconds: 10,
+		SASL:                      &KafkaSASLConfig{Mechanism: KafkaSASLPlain, Username: "kubecop"},
+	})
+	assert.NoError(t, err)
+	exporter.SendRuleAlert(&rule.R0001UnexpectedProcessLaunchedFailure{
+		RuleName:     rule.R0001UnexpectedProcessLaunchedRuleName,
+		FailureEvent: &tracing.ExecveEvent{},
+	})
+	assert.NoError(t, exporter.Close())
+
+	records := consumeKafkaRecordsWithOpts(t, []kgo.Opt{
+		kgo.SeedBrokers(cluster.ListenAddrs()...),
+		kgo.SASL(plain.Auth{User: "kubecop", Pass: "testpassword"}.AsMechanism()),
+		kgo.ConsumeTopics("alerts"),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	}, 1)
+	assert.Len(t, records, 1)
+	// alerts without a pod have no key
+	assert.Nil(t, records[0].Key)
+}
+
+func TestValidateKafkaExporterConfig(t *testing.T) {
+	config := KafkaExporterConfig{Brokers: []string{"kafka:9092"}}
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, KafkaExporterConfig{
+		Brokers:                   []string{"kafka:9092"},
+		RuleAlertsTopic:           "kubecop-rule-alerts",
+		MalwareAlertsTopic:        "kubecop-malware-alerts",
+		ClientID:                  "kubecop",
+		Acks:                      KafkaAcksAll,
+		Compression:               KafkaCompressionSnappy,
+		BatchIntervalMilliseconds: 100,
+		QueueSize:                 10000,
+		TimeoutSeconds:            30,
+	}, config)
+
+	tests := []struct {
+		name   string
+		config KafkaExporterConfig
+	}{
+		{name: "no brokers", config: KafkaExporterConfig{}},
+		{name: "unknown acks", config: KafkaExporterConfig{Brokers: []string{"kafka:9092"}, Acks: "2"}},
+		{name: "unknown compression", config: KafkaExporterConfig{Brokers: []string{"kafka:9092"}, Compression: "brotli"}},
+		{name: "negative queue size", config: KafkaExporterConfig{Brokers: []string{"kafka:9092"}, QueueSize: -1}},
+		{name: "unknown SASL mechanism", config: KafkaExporterConfig{Brokers: []string{"kafka:9092"}, SASL: &KafkaSASLConfig{Mechanism: "GSSAPI", Username: "kubecop"}}},
+		{name: "SASL without username", config: KafkaExporterConfig{Brokers: []string{"kafka:9092"}, SASL: &KafkaSASLConfig{Mechanism: KafkaSASLPlain}}},
+		{name: "TLS certificate without key", config: KafkaExporterConfig{Brokers: []string{"kafka:9092"}, TLS: &KafkaTLSConfig{CertFile: "/etc/kafka/tls.crt"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Error(t, test.config.Validate())
+		})
+	}
+}
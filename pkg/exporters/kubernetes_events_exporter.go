@@ -0,0 +1,140 @@
+package exporters
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// Component of the source of the events
+	KubernetesEventsComponent = "kubecop"
+	// Reasons of the events of the alerts of rules without an ID and of the malware alerts
+	KubernetesEventsRuleAlertReason    = "RuleAlert"
+	KubernetesEventsMalwareAlertReason = "MalwareDetected"
+	// Maximum length of the message of an event
+	kubernetesEventsMaxMessageLength = 1024
+	// Maximum number of pod UIDs kept, the cache is emptied when it is full
+	kubernetesEventsMaxPodUIDs = 1000
+)
+
+// KubernetesEventsExporter records a Warning event on the pod of each alert, so the alerts are shown by
+// kubectl describe pod. The event recorder aggregates similar events and rate limits the events of each pod,
+// so alert storms don't flood etcd.
+type KubernetesEventsExporter struct {
+	k8sClient   kubernetes.Interface
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+	// UIDs of the pods the events were recorded on, the events are matched to their pod by UID
+	podUIDsLock sync.Mutex
+	podUIDs     map[string]types.UID
+}
+
+// InitKubernetesEventsExporter initializes a new KubernetesEventsExporter, it is disabled by default.
+func InitKubernetesEventsExporter(enabled *bool, k8sClient kubernetes.Interface) *KubernetesEventsExporter {
+	if enabled == nil {
+		enabled = new(bool)
+		*enabled = os.Getenv("KUBERNETES_EVENTS_ENABLED") == "true"
+	}
+	if !*enabled {
+		return nil
+	}
+	if k8sClient == nil {
+		log.Warn("no Kubernetes client, alerts will not be exported as Kubernetes events")
+		return nil
+	}
+
+	// The default correlator aggregates the similar events and rate limits the events of each pod
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: k8sClient.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: KubernetesEventsComponent, Host: os.Getenv("NODE_NAME")})
+
+	return &KubernetesEventsExporter{
+		k8sClient:   k8sClient,
+		broadcaster: broadcaster,
+		recorder:    recorder,
+		podUIDs:     make(map[string]types.UID),
+	}
+}
+
+// Close records the pending events and stops the exporter.
+func (exporter *KubernetesEventsExporter) Close() error {
+	exporter.broadcaster.Shutdown()
+	return nil
+}
+
+func (exporter *KubernetesEventsExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	event := failedRule.Event()
+	if event.PodName == "" || event.Namespace == "" {
+		return
+	}
+	reason := rule.GetRuleIDByName(failedRule.Name())
+	if reason == "" {
+		reason = KubernetesEventsRuleAlertReason
+	}
+	message := failedRule.Error()
+	if event.ContainerName != "" {
+		message = fmt.Sprintf("%s (container %s)", message, event.ContainerName)
+	}
+	exporter.recordEvent(event.Namespace, event.PodName, reason, message)
+}
+
+func (exporter *KubernetesEventsExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
+	if malwareDescription.PodName == "" || malwareDescription.Namespace == "" {
+		return
+	}
+	message := fmt.Sprintf("Malware %s detected in %s (container %s): %s",
+		malwareDescription.Name, malwareDescription.Path, malwareDescription.ContainerName, malwareDescription.Description)
+	exporter.recordEvent(malwareDescription.Namespace, malwareDescription.PodName, KubernetesEventsMalwareAlertReason, message)
+}
+
+func (exporter *KubernetesEventsExporter) recordEvent(namespace, podName, reason, message string) {
+	if len(message) > kubernetesEventsMaxMessageLength {
+		message = message[:kubernetesEventsMaxMessageLength-3] + "..."
+	}
+	pod := &corev1.ObjectReference{
+		Kind:       "Pod",
+		APIVersion: "v1",
+		Namespace:  namespace,
+		Name:       podName,
+		UID:        exporter.podUID(namespace, podName),
+	}
+	exporter.recorder.Event(pod, corev1.EventTypeWarning, reason, message)
+}
+
+// podUID returns the UID of the pod, the event is recorded without it if the pod can't be read.
+func (exporter *KubernetesEventsExporter) podUID(namespace, podName string) types.UID {
+	key := namespace + "/" + podName
+	exporter.podUIDsLock.Lock()
+	uid, ok := exporter.podUIDs[key]
+	exporter.podUIDsLock.Unlock()
+	if ok {
+		return uid
+	}
+
+	pod, err := exporter.k8sClient.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		log.Debugf("failed to get pod %s for its Kubernetes event: %v", key, err)
+		return ""
+	}
+	exporter.podUIDsLock.Lock()
+	defer exporter.podUIDsLock.Unlock()
+	if len(exporter.podUIDs) >= kubernetesEventsMaxPodUIDs {
+		exporter.podUIDs = make(map[string]types.UID)
+	}
+	exporter.podUIDs[key] = pod.UID
+	return pod.UID
+}
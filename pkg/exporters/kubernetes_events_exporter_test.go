@@ -0,0 +1,84 @@
+package exporters
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInitKubernetesEventsExporter(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+
+	// disabled by default
+	os.Unsetenv("KUBERNETES_EVENTS_ENABLED")
+	assert.Nil(t, InitKubernetesEventsExporter(nil, clientset))
+
+	os.Setenv("KUBERNETES_EVENTS_ENABLED", "true")
+	defer os.Unsetenv("KUBERNETES_EVENTS_ENABLED")
+	exporter := InitKubernetesEventsExporter(nil, clientset)
+	assert.NotNil(t, exporter)
+	exporter.Close()
+
+	// no exporter without a Kubernetes client
+	enabled := true
+	assert.Nil(t, InitKubernetesEventsExporter(&enabled, nil))
+}
+
+func TestKubernetesEventsExporter(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "testpodname", Namespace: "testnamespace", UID: "testpoduid"}})
+	enabled := true
+	exporter := InitKubernetesEventsExporter(&enabled, clientset)
+	assert.NotNil(t, exporter)
+	defer exporter.Close()
+
+	exporter.SendRuleAlert(&rule.R0001UnexpectedProcessLaunchedFailure{
+		RuleName: rule.R0001UnexpectedProcessLaunchedRuleName,
+		Err:      "Unexpected process launched: /bin/sh",
+		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
+			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"}},
+	})
+	exporter.SendMalwareAlert(scan.MalwareDescription{
+		Name:          "testmalware",
+		Description:   "testdescription",
+		Path:          "/tmp/testmalware",
+		Namespace:     "testnamespace",
+		PodName:       "testpodname",
+		ContainerName: "testcontainer",
+	})
+	// alerts without a pod are not recorded
+	exporter.SendRuleAlert(&rule.R0001UnexpectedProcessLaunchedFailure{
+		RuleName:     rule.R0001UnexpectedProcessLaunchedRuleName,
+		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{ContainerID: "testcontainerid"}},
+	})
+
+	var events []corev1.Event
+	assert.Eventually(t, func() bool {
+		eventList, err := clientset.CoreV1().Events("testnamespace").List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return false
+		}
+		events = eventList.Items
+		return len(events) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	reasons := map[string]corev1.Event{}
+	for _, event := range events {
+		reasons[event.Reason] = event
+		assert.Equal(t, corev1.EventTypeWarning, event.Type)
+		assert.Equal(t, "Pod", event.InvolvedObject.Kind)
+		assert.Equal(t, "testpodname", event.InvolvedObject.Name)
+		assert.Equal(t, "testpoduid", string(event.InvolvedObject.UID))
+		assert.Equal(t, KubernetesEventsComponent, event.Source.Component)
+	}
+	assert.Equal(t, "Unexpected process launched: /bin/sh (container testcontainer)", reasons["R0001"].Message)
+	assert.Contains(t, reasons[KubernetesEventsMalwareAlertReason].Message, "Malware testmalware detected in /tmp/testmalware")
+}
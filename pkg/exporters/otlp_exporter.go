@@ -0,0 +1,511 @@
+package exporters
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Protocols of the OTLP exporter
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http"
+)
+
+const (
+	// Name of the service and of the instrumentation scope of the log records
+	otlpServiceName = "kubecop"
+	// Maximum time to wait between two retries of the same batch
+	otlpMaxRetryBackoff = 30 * time.Second
+)
+
+type OTLPExporterConfig struct {
+	// Endpoint is the host:port of the OTLP receiver for grpc, or the URL of its logs endpoint for http (like http://otel-collector:4318/v1/logs)
+	Endpoint string `json:"endpoint"`
+	// Protocol is grpc or http (protobuf over HTTP), grpc if not set
+	Protocol string `json:"protocol"`
+	// Insecure disables TLS for grpc, for http the scheme of the URL is used
+	Insecure bool `json:"insecure"`
+	// Headers are sent with every request, like an authorization header
+	Headers map[string]string `json:"headers"`
+	// TimeoutSeconds is the timeout of a request, 10 if not set
+	TimeoutSeconds int `json:"timeoutSeconds"`
+	// MaxAlertsPerBatch is the maximum number of alerts sent in a single request, 100 if not set
+	MaxAlertsPerBatch int `json:"maxAlertsPerBatch"`
+	// BatchIntervalMilliseconds is the maximum time an alert waits for its batch to fill before it is sent, 1000 if not set
+	BatchIntervalMilliseconds int `json:"batchIntervalMilliseconds"`
+	// QueueSize is the maximum number of alerts waiting in memory to be sent, alerts are dropped when it is full, 10000 if not set
+	QueueSize int `json:"queueSize"`
+	// MaxRetries is the number of times a batch is retried on transient errors, 3 if not set, 0 disables retries
+	MaxRetries *int `json:"maxRetries"`
+	// InitialBackoffMilliseconds is the time to wait before the first retry, it is doubled on every retry, 500 if not set
+	InitialBackoffMilliseconds int `json:"initialBackoffMilliseconds"`
+}
+
+// otlpLogsClient sends the log records to the OTLP receiver.
+type otlpLogsClient interface {
+	export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error)
+	close() error
+}
+
+// otlpExportError is an error in sending a batch, retryable errors are the transient errors of the OTLP specification.
+type otlpExportError struct {
+	err       error
+	retryable bool
+}
+
+func (e *otlpExportError) Error() string {
+	return e.err.Error()
+}
+
+// otlpLogRecord is an alert waiting to be sent, with the attributes of the resource it is about.
+type otlpLogRecord struct {
+	resource []*commonpb.KeyValue
+	record   *logspb.LogRecord
+}
+
+// OTLPExporter sends the alerts as OpenTelemetry log records to an OTLP receiver, like an OpenTelemetry Collector.
+// The alerts are sent in the background in batches, and the batches are retried with exponential backoff on transient errors.
+type OTLPExporter struct {
+	config      OTLPExporterConfig
+	client      otlpLogsClient
+	nodeName    string
+	records     chan otlpLogRecord
+	stopChannel chan struct{}
+	// stopLock makes sure no alert is being enqueued once the exporter is stopped, so every queued alert is sent
+	stopLock sync.RWMutex
+	stopped  bool
+	done     chan struct{}
+}
+
+func (config *OTLPExporterConfig) Validate() error {
+	if config.Protocol == "" {
+		config.Protocol = OTLPProtocolGRPC
+	} else if config.Protocol != OTLPProtocolGRPC && config.Protocol != OTLPProtocolHTTP {
+		return fmt.Errorf("protocol must be %s or %s", OTLPProtocolGRPC, OTLPProtocolHTTP)
+	}
+	if config.TimeoutSeconds == 0 {
+		config.TimeoutSeconds = 10
+	}
+	if config.MaxAlertsPerBatch == 0 {
+		config.MaxAlertsPerBatch = 100
+	}
+	if config.BatchIntervalMilliseconds == 0 {
+		config.BatchIntervalMilliseconds = 1000
+	}
+	if config.QueueSize == 0 {
+		config.QueueSize = 10000
+	}
+	if config.MaxRetries == nil {
+		maxRetries := 3
+		config.MaxRetries = &maxRetries
+	}
+	if config.InitialBackoffMilliseconds == 0 {
+		config.InitialBackoffMilliseconds = 500
+	}
+	if config.TimeoutSeconds < 0 || config.MaxAlertsPerBatch < 0 || config.BatchIntervalMilliseconds < 0 || config.QueueSize < 0 ||
+		*config.MaxRetries < 0 || config.InitialBackoffMilliseconds < 0 {
+		return fmt.Errorf("timeout, batching, queue and retry settings must not be negative")
+	}
+	if config.Endpoint == "" {
+		return fmt.Errorf("endpoint is required")
+	}
+	if config.Protocol == OTLPProtocolHTTP && !strings.HasPrefix(config.Endpoint, "http://") && !strings.HasPrefix(config.Endpoint, "https://") {
+		return fmt.Errorf("endpoint must be an http:// or https:// URL for the http protocol")
+	}
+	return nil
+}
+
+// InitOTLPExporter initializes an OTLPExporter and starts sending the alerts in the background.
+func InitOTLPExporter(config OTLPExporterConfig) (*OTLPExporter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	var client otlpLogsClient
+	if config.Protocol == OTLPProtocolHTTP {
+		client = &otlpHTTPLogsClient{endpoint: config.Endpoint, headers: config.Headers, httpClient: &http.Client{}}
+	} else {
+		transportCredentials := insecure.NewCredentials()
+		if !config.Insecure {
+			transportCredentials = credentials.NewTLS(&tls.Config{})
+		}
+		// the connection is established in the background and re-established on failures
+		conn, err := grpc.Dial(config.Endpoint, grpc.WithTransportCredentials(transportCredentials))
+		if err != nil {
+			return nil, err
+		}
+		client = &otlpGRPCLogsClient{conn: conn, client: collogspb.NewLogsServiceClient(conn), headers: config.Headers}
+	}
+
+	exporter := &OTLPExporter{
+		config:      config,
+		client:      client,
+		nodeName:    os.Getenv("NODE_NAME"),
+		records:     make(chan otlpLogRecord, config.QueueSize),
+		stopChannel: make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go exporter.run()
+	return exporter, nil
+}
+
+// Close sends the queued alerts and stops the exporter.
+func (exporter *OTLPExporter) Close() error {
+	exporter.stopLock.Lock()
+	if !exporter.stopped {
+		exporter.stopped = true
+		close(exporter.stopChannel)
+	}
+	exporter.stopLock.Unlock()
+	<-exporter.done
+	return exporter.client.close()
+}
+
+func (exporter *OTLPExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	event := failedRule.Event()
+	severity := PriorityToStatus(failedRule.Priority())
+	attributes := []*commonpb.KeyValue{
+		otlpStringAttribute("kubecop.rule.name", failedRule.Name()),
+		otlpStringAttribute("kubecop.fix_suggestion", failedRule.FixSuggestion()),
+		otlpIntAttribute("process.parent_pid", int64(event.Ppid)),
+		otlpIntAttribute("process.user.id", int64(event.Uid)),
+		otlpIntAttribute("process.group.id", int64(event.Gid)),
+	}
+	if ruleID := rule.GetRuleIDByName(failedRule.Name()); ruleID != "" {
+		attributes = append(attributes, otlpStringAttribute("kubecop.rule.id", ruleID))
+	}
+	if event.Cwd != "" {
+		attributes = append(attributes, otlpStringAttribute("kubecop.process.cwd", event.Cwd))
+	}
+	evidence := failedRule.Evidence()
+	for _, key := range evidence.Keys() {
+		if value := evidence.String(key); value != "" {
+			attributes = append(attributes, otlpStringAttribute("kubecop.evidence."+key, value))
+		}
+	}
+	if mitre := rule.GetMitreAttack(failedRule); !mitre.IsEmpty() {
+		attributes = append(attributes,
+			otlpStringArrayAttribute("kubecop.mitre.tactics", mitre.Tactics),
+			otlpStringArrayAttribute("kubecop.mitre.techniques", mitre.Techniques))
+	}
+	if ancestry := rule.GetProcessAncestry(failedRule); len(ancestry) > 0 {
+		attributes = append(attributes, otlpStringAttribute("kubecop.process_ancestry", FormatProcessAncestry(ancestry)))
+	}
+	if suppressed := rule.GetSuppressedRuleFailure(failedRule); suppressed != nil {
+		attributes = append(attributes,
+			otlpIntAttribute("kubecop.suppressed_count", int64(suppressed.SuppressedCount)),
+			otlpStringAttribute("kubecop.first_seen", suppressed.FirstSeen.UTC().Format(time.RFC3339)),
+			otlpStringAttribute("kubecop.last_seen", suppressed.LastSeen.UTC().Format(time.RFC3339)))
+	}
+
+	timestamp := time.Now()
+	if event.Timestamp != 0 {
+		timestamp = time.Unix(0, event.Timestamp)
+	}
+	exporter.enqueue(otlpLogRecord{
+		resource: exporter.resourceAttributes(event.Namespace, event.PodName, event.ContainerName, event.ContainerID, int64(event.Pid), event.Comm),
+		record: &logspb.LogRecord{
+			TimeUnixNano:         uint64(timestamp.UnixNano()),
+			ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+			SeverityNumber:       otlpSeverityNumber(severity),
+			SeverityText:         severity,
+			Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: failedRule.Error()}},
+			Attributes:           attributes,
+		},
+	})
+}
+
+func (exporter *OTLPExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
+	severity := PriorityToStatus(rule.RulePriorityCritical)
+	now := uint64(time.Now().UnixNano())
+	resource := exporter.resourceAttributes(malwareDescription.Namespace, malwareDescription.PodName,
+		malwareDescription.ContainerName, malwareDescription.ContainerID, 0, "")
+	if malwareDescription.ContainerImage != "" {
+		resource = append(resource, otlpStringAttribute("container.image.name", malwareDescription.ContainerImage))
+	}
+	exporter.enqueue(otlpLogRecord{
+		resource: resource,
+		record: &logspb.LogRecord{
+			TimeUnixNano:         now,
+			ObservedTimeUnixNano: now,
+			SeverityNumber:       otlpSeverityNumber(severity),
+			SeverityText:         severity,
+			Body: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprintf("Malware '%s' detected in namespace '%s' pod '%s' description '%s' path '%s'",
+				malwareDescription.Name, malwareDescription.Namespace, malwareDescription.PodName, malwareDescription.Description, malwareDescription.Path)}},
+			Attributes: []*commonpb.KeyValue{
+				otlpStringAttribute("kubecop.rule.name", "KubeCopMalwareDetected"),
+				otlpStringAttribute("kubecop.malware.name", malwareDescription.Name),
+				otlpStringAttribute("kubecop.malware.description", malwareDescription.Description),
+				otlpStringAttribute("file.path", malwareDescription.Path),
+				otlpStringAttribute("kubecop.malware.hash", malwareDescription.Hash),
+				otlpStringAttribute("kubecop.malware.size", malwareDescription.Size),
+				otlpBoolAttribute("kubecop.malware.is_part_of_image", malwareDescription.IsPartOfImage),
+			},
+		},
+	})
+}
+
+// resourceAttributes returns the semantic convention attributes of the resource of an alert, the empty ones are not set.
+func (exporter *OTLPExporter) resourceAttributes(namespace, podName, containerName, containerID string, pid int64, comm string) []*commonpb.KeyValue {
+	attributes := []*commonpb.KeyValue{otlpStringAttribute("service.name", otlpServiceName)}
+	for _, attribute := range []struct{ key, value string }{
+		{"k8s.node.name", exporter.nodeName},
+		{"k8s.namespace.name", namespace},
+		{"k8s.pod.name", podName},
+		{"k8s.container.name", containerName},
+		{"container.id", containerID},
+		{"process.executable.name", comm},
+	} {
+		if attribute.value != "" {
+			attributes = append(attributes, otlpStringAttribute(attribute.key, attribute.value))
+		}
+	}
+	if pid != 0 {
+		attributes = append(attributes, otlpIntAttribute("process.pid", pid))
+	}
+	return attributes
+}
+
+// enqueue adds an alert to the queue without blocking the caller, the alert is dropped if the queue is full.
+func (exporter *OTLPExporter) enqueue(record otlpLogRecord) {
+	exporter.stopLock.RLock()
+	defer exporter.stopLock.RUnlock()
+	if exporter.stopped {
+		return
+	}
+	select {
+	case exporter.records <- record:
+	default:
+		log.Warnf("OTLP exporter queue is full (%d alerts), dropping alert", exporter.config.QueueSize)
+	}
+}
+
+func (exporter *OTLPExporter) run() {
+	defer close(exporter.done)
+	ticker := time.NewTicker(time.Duration(exporter.config.BatchIntervalMilliseconds) * time.Millisecond)
+	defer ticker.Stop()
+
+	batch := make([]otlpLogRecord, 0, exporter.config.MaxAlertsPerBatch)
+	add := func(record otlpLogRecord) {
+		batch = append(batch, record)
+		if len(batch) >= exporter.config.MaxAlertsPerBatch {
+			exporter.deliver(batch)
+			batch = make([]otlpLogRecord, 0, exporter.config.MaxAlertsPerBatch)
+		}
+	}
+	for {
+		select {
+		case <-exporter.stopChannel:
+			// Send what is left in the queue
+			for {
+				select {
+				case record := <-exporter.records:
+					add(record)
+				default:
+					if len(batch) > 0 {
+						exporter.deliver(batch)
+					}
+					return
+				}
+			}
+		case record := <-exporter.records:
+			add(record)
+		case <-ticker.C:
+			if len(batch) > 0 {
+				exporter.deliver(batch)
+				batch = make([]otlpLogRecord, 0, exporter.config.MaxAlertsPerBatch)
+			}
+		}
+	}
+}
+
+// deliver sends a batch of alerts, retrying on transient errors. The batch is dropped if it could not be sent.
+func (exporter *OTLPExporter) deliver(batch []otlpLogRecord) {
+	request := otlpExportRequest(batch)
+	backoff := time.Duration(exporter.config.InitialBackoffMilliseconds) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(exporter.config.TimeoutSeconds)*time.Second)
+		response, err := exporter.client.export(ctx, request)
+		cancel()
+		if err == nil {
+			if partialSuccess := response.GetPartialSuccess(); partialSuccess.GetRejectedLogRecords() > 0 {
+				log.Warnf("OTLP receiver rejected %d of %d alerts: %s", partialSuccess.GetRejectedLogRecords(), len(batch), partialSuccess.GetErrorMessage())
+			}
+			return
+		}
+		exportErr, ok := err.(*otlpExportError)
+		if !ok || !exportErr.retryable || attempt >= *exporter.config.MaxRetries {
+			log.Errorf("failed to send %d alerts to the OTLP receiver, dropping them: %v", len(batch), err)
+			return
+		}
+		log.Warnf("failed to send %d alerts to the OTLP receiver, retrying in %s: %v", len(batch), backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > otlpMaxRetryBackoff {
+			backoff = otlpMaxRetryBackoff
+		}
+	}
+}
+
+// otlpExportRequest groups the log records of the batch by resource, keeping their order.
+func otlpExportRequest(batch []otlpLogRecord) *collogspb.ExportLogsServiceRequest {
+	request := &collogspb.ExportLogsServiceRequest{}
+	scopeLogsByResource := map[string]*logspb.ScopeLogs{}
+	for _, record := range batch {
+		key := otlpResourceKey(record.resource)
+		scopeLogs, ok := scopeLogsByResource[key]
+		if !ok {
+			scopeLogs = &logspb.ScopeLogs{Scope: &commonpb.InstrumentationScope{Name: otlpServiceName}}
+			scopeLogsByResource[key] = scopeLogs
+			request.ResourceLogs = append(request.ResourceLogs, &logspb.ResourceLogs{
+				Resource:  &resourcepb.Resource{Attributes: record.resource},
+				ScopeLogs: []*logspb.ScopeLogs{scopeLogs},
+			})
+		}
+		scopeLogs.LogRecords = append(scopeLogs.LogRecords, record.record)
+	}
+	return request
+}
+
+func otlpResourceKey(attributes []*commonpb.KeyValue) string {
+	parts := make([]string, 0, len(attributes))
+	for _, attribute := range attributes {
+		parts = append(parts, attribute.Key+"="+attribute.Value.String())
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// otlpSeverityNumber maps the severity of an alert to the OpenTelemetry severity number.
+func otlpSeverityNumber(severity string) logspb.SeverityNumber {
+	switch severity {
+	case "medium", "system_issue":
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case "high":
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case "critical":
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	default:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	}
+}
+
+func otlpStringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func otlpIntAttribute(key string, value int64) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: value}}}
+}
+
+func otlpBoolAttribute(key string, value bool) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: value}}}
+}
+
+func otlpStringArrayAttribute(key string, values []string) *commonpb.KeyValue {
+	array := &commonpb.ArrayValue{}
+	for _, value := range values {
+		array.Values = append(array.Values, &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}})
+	}
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: array}}}
+}
+
+// otlpGRPCLogsClient sends the log records with OTLP/gRPC.
+type otlpGRPCLogsClient struct {
+	conn    *grpc.ClientConn
+	client  collogspb.LogsServiceClient
+	headers map[string]string
+}
+
+func (client *otlpGRPCLogsClient) export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	if len(client.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(client.headers))
+	}
+	response, err := client.client.Export(ctx, request)
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+			return nil, &otlpExportError{err: err, retryable: true}
+		default:
+			return nil, &otlpExportError{err: err}
+		}
+	}
+	return response, nil
+}
+
+func (client *otlpGRPCLogsClient) close() error {
+	return client.conn.Close()
+}
+
+// otlpHTTPLogsClient sends the log records with OTLP/HTTP in the binary protobuf encoding.
+type otlpHTTPLogsClient struct {
+	endpoint   string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+func (client *otlpHTTPLogsClient) export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return nil, &otlpExportError{err: err}
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, client.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, &otlpExportError{err: err}
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-protobuf")
+	for key, value := range client.headers {
+		httpRequest.Header.Set(key, value)
+	}
+	httpResponse, err := client.httpClient.Do(httpRequest)
+	if err != nil {
+		// timeouts and connection errors
+		return nil, &otlpExportError{err: err, retryable: true}
+	}
+	defer httpResponse.Body.Close()
+	responseBody, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return nil, &otlpExportError{err: err, retryable: true}
+	}
+	switch {
+	case httpResponse.StatusCode >= 200 && httpResponse.StatusCode < 300:
+		response := &collogspb.ExportLogsServiceResponse{}
+		if err := proto.Unmarshal(responseBody, response); err != nil {
+			// the alerts were accepted, the response is only informative
+			log.Debugf("failed to decode the OTLP response: %v", err)
+		}
+		return response, nil
+	case httpResponse.StatusCode == http.StatusTooManyRequests || httpResponse.StatusCode == http.StatusBadGateway ||
+		httpResponse.StatusCode == http.StatusServiceUnavailable || httpResponse.StatusCode == http.StatusGatewayTimeout:
+		return nil, &otlpExportError{err: fmt.Errorf("OTLP receiver responded %s", httpResponse.Status), retryable: true}
+	default:
+		return nil, &otlpExportError{err: fmt.Errorf("OTLP receiver responded %s", httpResponse.Status)}
+	}
+}
+
+func (client *otlpHTTPLogsClient) close() error {
+	client.httpClient.CloseIdleConnections()
+	return nil
+}
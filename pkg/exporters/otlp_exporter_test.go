@@ -0,0 +1,232 @@
+package exporters
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpTestReceiver is an in-process OTLP/gRPC logs receiver, it fails the first requests with Unavailable.
+type otlpTestReceiver struct {
+	collogspb.UnimplementedLogsServiceServer
+	lock        sync.Mutex
+	failures    int
+	attempts    int
+	authHeaders []string
+	requests    []*collogspb.ExportLogsServiceRequest
+}
+
+func (receiver *otlpTestReceiver) Export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	receiver.attempts++
+	if receiver.attempts <= receiver.failures {
+		return nil, status.Error(codes.Unavailable, "receiver is starting")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	receiver.authHeaders = append(receiver.authHeaders, md.Get("authorization")...)
+	receiver.requests = append(receiver.requests, request)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func otlpAttributes(attributes []*commonpb.KeyValue) map[string]*commonpb.AnyValue {
+	values := map[string]*commonpb.AnyValue{}
+	for _, attribute := range attributes {
+		values[attribute.Key] = attribute.Value
+	}
+	return values
+}
+
+// otlpTestTimestamp is the time of the event of otlpTestRuleFailure, in nanoseconds like the events of the tracer.
+const otlpTestTimestamp = int64(1700000000123456789)
+
+func otlpTestRuleFailure() rule.RuleFailure {
+	return &rule.R0001UnexpectedProcessLaunchedFailure{
+		RuleName:     rule.R0001UnexpectedProcessLaunchedRuleName,
+		RulePriority: rule.RulePriorityHigh,
+		Err:          "Unexpected process launched: /bin/sh",
+		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
+			ProcessDetails: tracing.ProcessDetails{Pid: 20, Ppid: 10, Comm: "sh"},
+			ContainerName:  "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname",
+			Timestamp: otlpTestTimestamp},
+			PathName: "/bin/sh"},
+	}
+}
+
+func TestOTLPExporterGRPC(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	receiver := &otlpTestReceiver{failures: 1}
+	collogspb.RegisterLogsServiceServer(server, receiver)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	t.Setenv("NODE_NAME", "testnode")
+	exporter, err := InitOTLPExporter(OTLPExporterConfig{
+		Endpoint:                   listener.Addr().String(),
+		Insecure:                   true,
+		Headers:                    map[string]string{"authorization": "Bearer testtoken"},
+		InitialBackoffMilliseconds: 10,
+	})
+	assert.NoError(t, err)
+
+	exporter.SendRuleAlert(otlpTestRuleFailure())
+	exporter.SendMalwareAlert(scan.MalwareDescription{
+		Name:          "testmalware",
+		Description:   "testdescription",
+		Path:          "/tmp/testmalware",
+		Namespace:     "testnamespace",
+		PodName:       "testpodname",
+		ContainerName: "testcontainer",
+		ContainerID:   "testcontainerid",
+	})
+	// the queued alerts are sent on close, after a retry of the unavailable receiver
+	assert.NoError(t, exporter.Close())
+
+	receiver.lock.Lock()
+	defer receiver.lock.Unlock()
+	assert.Equal(t, 2, receiver.attempts)
+	assert.Equal(t, []string{"Bearer testtoken"}, receiver.authHeaders)
+	assert.Len(t, receiver.requests, 1)
+	// the alerts have different resources, since the malware alert has no process
+	resourceLogs := receiver.requests[0].ResourceLogs
+	assert.Len(t, resourceLogs, 2)
+
+	resource := otlpAttributes(resourceLogs[0].Resource.Attributes)
+	assert.Equal(t, "kubecop", resource["service.name"].GetStringValue())
+	assert.Equal(t, "testnode", resource["k8s.node.name"].GetStringValue())
+	assert.Equal(t, "testnamespace", resource["k8s.namespace.name"].GetStringValue())
+	assert.Equal(t, "testpodname", resource["k8s.pod.name"].GetStringValue())
+	assert.Equal(t, "testcontainer", resource["k8s.container.name"].GetStringValue())
+	assert.Equal(t, "testcontainerid", resource["container.id"].GetStringValue())
+	assert.Equal(t, "sh", resource["process.executable.name"].GetStringValue())
+	assert.Equal(t, int64(20), resource["process.pid"].GetIntValue())
+
+	record := resourceLogs[0].ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, record.SeverityNumber)
+	assert.Equal(t, "high", record.SeverityText)
+	assert.Equal(t, "Unexpected process launched: /bin/sh", record.Body.GetStringValue())
+	assert.Equal(t, uint64(otlpTestTimestamp), record.TimeUnixNano)
+	attributes := otlpAttributes(record.Attributes)
+	assert.Equal(t, "R0001", attributes["kubecop.rule.id"].GetStringValue())
+	assert.Equal(t, int64(10), attributes["process.parent_pid"].GetIntValue())
+	assert.Equal(t, "/bin/sh", attributes["kubecop.evidence."+rule.EvidencePath].GetStringValue())
+
+	malwareRecord := resourceLogs[1].ScopeLogs[0].LogRecords[0]
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_FATAL, malwareRecord.SeverityNumber)
+	assert.Equal(t, "/tmp/testmalware", otlpAttributes(malwareRecord.Attributes)["file.path"].GetStringValue())
+}
+
+func TestOTLPExporterHTTP(t *testing.T) {
+	var lock sync.Mutex
+	attempts := 0
+	var requests []*collogspb.ExportLogsServiceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		request := &collogspb.ExportLogsServiceRequest{}
+		assert.NoError(t, proto.Unmarshal(body, request))
+		requests = append(requests, request)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	exporter, err := InitOTLPExporter(OTLPExporterConfig{
+		Endpoint:                   server.URL + "/v1/logs",
+		Protocol:                   OTLPProtocolHTTP,
+		MaxAlertsPerBatch:          2,
+		InitialBackoffMilliseconds: 10,
+	})
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		exporter.SendRuleAlert(otlpTestRuleFailure())
+	}
+	assert.NoError(t, exporter.Close())
+
+	lock.Lock()
+	defer lock.Unlock()
+	// a full batch of 2 alerts and the remaining alert sent on close
+	assert.Len(t, requests, 2)
+	assert.Len(t, requests[0].ResourceLogs, 1)
+	assert.Len(t, requests[0].ResourceLogs[0].ScopeLogs[0].LogRecords, 2)
+	assert.Len(t, requests[1].ResourceLogs[0].ScopeLogs[0].LogRecords, 1)
+}
+
+func TestOTLPExporterHTTPPermanentError(t *testing.T) {
+	var lock sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	exporter, err := InitOTLPExporter(OTLPExporterConfig{Endpoint: server.URL, Protocol: OTLPProtocolHTTP, InitialBackoffMilliseconds: 10})
+	assert.NoError(t, err)
+	exporter.SendRuleAlert(otlpTestRuleFailure())
+	assert.NoError(t, exporter.Close())
+
+	lock.Lock()
+	defer lock.Unlock()
+	// bad requests are not retried
+	assert.Equal(t, 1, attempts)
+}
+
+func TestValidateOTLPExporterConfig(t *testing.T) {
+	config := OTLPExporterConfig{Endpoint: "otel-collector:4317"}
+	assert.NoError(t, config.Validate())
+	maxRetries := 3
+	assert.Equal(t, OTLPExporterConfig{
+		Endpoint:                   "otel-collector:4317",
+		Protocol:                   OTLPProtocolGRPC,
+		TimeoutSeconds:             10,
+		MaxAlertsPerBatch:          100,
+		BatchIntervalMilliseconds:  1000,
+		QueueSize:                  10000,
+		MaxRetries:                 &maxRetries,
+		InitialBackoffMilliseconds: 500,
+	}, config)
+
+	tests := []struct {
+		name   string
+		config OTLPExporterConfig
+	}{
+		{name: "no endpoint", config: OTLPExporterConfig{}},
+		{name: "unknown protocol", config: OTLPExporterConfig{Endpoint: "otel-collector:4317", Protocol: "udp"}},
+		{name: "http endpoint without scheme", config: OTLPExporterConfig{Endpoint: "otel-collector:4318", Protocol: OTLPProtocolHTTP}},
+		{name: "negative queue size", config: OTLPExporterConfig{Endpoint: "otel-collector:4317", QueueSize: -1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Error(t, test.config.Validate())
+		})
+	}
+}
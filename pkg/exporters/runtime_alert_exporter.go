@@ -0,0 +1,459 @@
+package exporters
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/kubescape/kapprofiler/pkg/collector"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	RuntimeAlertKind   = "RuntimeAlert"
+	RuntimeAlertPlural = "runtimealerts"
+	// Rule name of the RuntimeAlerts of the malware alerts
+	RuntimeAlertMalwareRuleName = "KubeCopMalwareDetected"
+)
+
+// Labels of the RuntimeAlert objects, to select them with kubectl get -l
+const (
+	RuntimeAlertRuleIDLabel   = "kubecop.kubescape.io/rule-id"
+	RuntimeAlertSeverityLabel = "kubecop.kubescape.io/severity"
+	RuntimeAlertNodeLabel     = "kubecop.kubescape.io/node"
+	RuntimeAlertPodLabel      = "kubecop.kubescape.io/pod"
+)
+
+// The garbage collection of the RuntimeAlerts runs in this interval
+const runtimeAlertsGCInterval = 5 * time.Minute
+
+var RuntimeAlertGvr schema.GroupVersionResource = schema.GroupVersionResource{
+	Group:    collector.ApplicationProfileGroup,
+	Version:  collector.ApplicationProfileVersion,
+	Resource: RuntimeAlertPlural,
+}
+
+type RuntimeAlertExporterConfig struct {
+	// TTLHours is the time after its last occurrence a RuntimeAlert is deleted, 168 (7 days) if not set
+	TTLHours int `json:"ttlHours"`
+	// MaxPerNamespace is the maximum number of RuntimeAlerts kept in a namespace, the least recently seen are
+	// deleted first, 1000 if not set
+	MaxPerNamespace int `json:"maxPerNamespace"`
+	// FlushIntervalSeconds is the interval in which the new alerts and the counts of the repeated alerts are written, 10 if not set
+	FlushIntervalSeconds int `json:"flushIntervalSeconds"`
+}
+
+// RuntimeAlert is an alert stored in the namespace of its pod. The repeats of an alert (same rule, pod,
+// container and fingerprint) update the count and the last time of the same object.
+type RuntimeAlert struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              RuntimeAlertSpec `json:"spec,omitempty"`
+}
+
+type RuntimeAlertSpec struct {
+	RuleID        string `json:"ruleID,omitempty"`
+	RuleName      string `json:"ruleName"`
+	Severity      string `json:"severity"`
+	Message       string `json:"message"`
+	FixSuggestion string `json:"fixSuggestion,omitempty"`
+	NodeName      string `json:"nodeName,omitempty"`
+	PodName       string `json:"podName"`
+	ContainerName string `json:"containerName,omitempty"`
+	ContainerID   string `json:"containerID,omitempty"`
+	// The process of the last occurrence of the alert
+	Process RuntimeAlertProcess `json:"process,omitempty"`
+	// Evidence of the last occurrence of the alert, the lists are joined with commas
+	Evidence        map[string]string `json:"evidence,omitempty"`
+	MitreTactics    []string          `json:"mitreTactics,omitempty"`
+	MitreTechniques []string          `json:"mitreTechniques,omitempty"`
+	Fingerprint     string            `json:"fingerprint,omitempty"`
+	// Number of occurrences of the alert, and times of the first and the last of them
+	Count     int64       `json:"count"`
+	FirstSeen metav1.Time `json:"firstSeen"`
+	LastSeen  metav1.Time `json:"lastSeen"`
+}
+
+// RuntimeAlertProcess is the process of an alert, the numbers are int64 as in all unstructured objects.
+type RuntimeAlertProcess struct {
+	Pid  int64  `json:"pid,omitempty"`
+	Ppid int64  `json:"ppid,omitempty"`
+	Comm string `json:"comm,omitempty"`
+	Cwd  string `json:"cwd,omitempty"`
+	Uid  int64  `json:"uid,omitempty"`
+	Gid  int64  `json:"gid,omitempty"`
+}
+
+// runtimeAlertOccurrences is the count and the first time of an alert, as written in its object.
+type runtimeAlertOccurrences struct {
+	count     int64
+	firstSeen metav1.Time
+}
+
+// RuntimeAlertExporter writes the alerts as RuntimeAlert objects in the namespace of their pod. The alerts are
+// written in the background every flush interval, so a storm of repeated alerts is a single update of their object.
+type RuntimeAlertExporter struct {
+	config        RuntimeAlertExporterConfig
+	dynamicClient dynamic.Interface
+	nodeName      string
+	// Alerts received since the last flush, by namespace/name of their object
+	pendingLock sync.Mutex
+	pending     map[string]*RuntimeAlert
+	// Occurrences written in the objects, only used by the background goroutine
+	written     map[string]runtimeAlertOccurrences
+	stopChannel chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+func (config *RuntimeAlertExporterConfig) Validate() error {
+	if config.TTLHours == 0 {
+		config.TTLHours = 168
+	}
+	if config.MaxPerNamespace == 0 {
+		config.MaxPerNamespace = 1000
+	}
+	if config.FlushIntervalSeconds == 0 {
+		config.FlushIntervalSeconds = 10
+	}
+	if config.TTLHours < 0 || config.MaxPerNamespace < 0 || config.FlushIntervalSeconds < 0 {
+		return fmt.Errorf("ttlHours, maxPerNamespace and flushIntervalSeconds must not be negative")
+	}
+	return nil
+}
+
+// InitRuntimeAlertExporter initializes a RuntimeAlertExporter and starts writing the alerts in the background.
+func InitRuntimeAlertExporter(config RuntimeAlertExporterConfig, dynamicClient dynamic.Interface) (*RuntimeAlertExporter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if dynamicClient == nil {
+		return nil, fmt.Errorf("no Kubernetes client")
+	}
+	exporter := newRuntimeAlertExporter(config, dynamicClient)
+	go exporter.run()
+	return exporter, nil
+}
+
+func newRuntimeAlertExporter(config RuntimeAlertExporterConfig, dynamicClient dynamic.Interface) *RuntimeAlertExporter {
+	return &RuntimeAlertExporter{
+		config:        config,
+		dynamicClient: dynamicClient,
+		nodeName:      os.Getenv("NODE_NAME"),
+		pending:       make(map[string]*RuntimeAlert),
+		written:       make(map[string]runtimeAlertOccurrences),
+		stopChannel:   make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Close writes the pending alerts and stops the exporter.
+func (exporter *RuntimeAlertExporter) Close() error {
+	exporter.closeOnce.Do(func() {
+		close(exporter.stopChannel)
+		<-exporter.done
+	})
+	return nil
+}
+
+func (exporter *RuntimeAlertExporter) run() {
+	defer close(exporter.done)
+	flushTicker := time.NewTicker(time.Duration(exporter.config.FlushIntervalSeconds) * time.Second)
+	defer flushTicker.Stop()
+	gcTicker := time.NewTicker(runtimeAlertsGCInterval)
+	defer gcTicker.Stop()
+	for {
+		select {
+		case <-exporter.stopChannel:
+			exporter.flush()
+			return
+		case <-flushTicker.C:
+			exporter.flush()
+		case <-gcTicker.C:
+			exporter.collectGarbage()
+		}
+	}
+}
+
+func (exporter *RuntimeAlertExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	event := failedRule.Event()
+	if event.PodName == "" || event.Namespace == "" {
+		return
+	}
+	now := metav1.Now()
+	mitre := rule.GetMitreAttack(failedRule)
+	failureEvidence := failedRule.Evidence()
+	evidence := make(map[string]string, len(failureEvidence))
+	for _, key := range failureEvidence.Keys() {
+		if value := failureEvidence.String(key); value != "" {
+			evidence[key] = value
+		}
+	}
+	// a summary of suppressed failures stands for all the suppressed occurrences
+	count, firstSeen, lastSeen := int64(1), now, now
+	if suppressed := rule.GetSuppressedRuleFailure(failedRule); suppressed != nil {
+		count, firstSeen, lastSeen = int64(suppressed.SuppressedCount), metav1.NewTime(suppressed.FirstSeen), metav1.NewTime(suppressed.LastSeen)
+	}
+	ruleID := rule.GetRuleIDByName(failedRule.Name())
+	exporter.add(event.Namespace, ruleID, RuntimeAlertSpec{
+		RuleID:        ruleID,
+		RuleName:      failedRule.Name(),
+		Severity:      PriorityToStatus(failedRule.Priority()),
+		Message:       failedRule.Error(),
+		FixSuggestion: failedRule.FixSuggestion(),
+		NodeName:      exporter.nodeName,
+		PodName:       event.PodName,
+		ContainerName: event.ContainerName,
+		ContainerID:   event.ContainerID,
+		Process: RuntimeAlertProcess{
+			Pid:  int64(event.Pid),
+			Ppid: int64(event.Ppid),
+			Comm: event.Comm,
+			Cwd:  event.Cwd,
+			Uid:  int64(event.Uid),
+			Gid:  int64(event.Gid),
+		},
+		Evidence:        evidence,
+		MitreTactics:    mitre.Tactics,
+		MitreTechniques: mitre.Techniques,
+		Fingerprint:     failedRule.Fingerprint(),
+		Count:           count,
+		FirstSeen:       firstSeen,
+		LastSeen:        lastSeen,
+	})
+}
+
+func (exporter *RuntimeAlertExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
+	if malwareDescription.PodName == "" || malwareDescription.Namespace == "" {
+		return
+	}
+	now := metav1.Now()
+	exporter.add(malwareDescription.Namespace, "malware", RuntimeAlertSpec{
+		RuleName:      RuntimeAlertMalwareRuleName,
+		Severity:      PriorityToStatus(rule.RulePriorityCritical),
+		Message:       fmt.Sprintf("Malware %s detected in %s: %s", malwareDescription.Name, malwareDescription.Path, malwareDescription.Description),
+		FixSuggestion: "Remove the malware from the container",
+		NodeName:      exporter.nodeName,
+		PodName:       malwareDescription.PodName,
+		ContainerName: malwareDescription.ContainerName,
+		ContainerID:   malwareDescription.ContainerID,
+		Evidence:      map[string]string{rule.EvidencePath: malwareDescription.Path, "hash": malwareDescription.Hash},
+		Fingerprint:   malwareDescription.Name + ":" + malwareDescription.Path,
+		Count:         1,
+		FirstSeen:     now,
+		LastSeen:      now,
+	})
+}
+
+// add queues the alert for the next flush, the repeats of a pending alert increase its count.
+func (exporter *RuntimeAlertExporter) add(namespace, namePrefix string, spec RuntimeAlertSpec) {
+	name := runtimeAlertName(namePrefix, spec)
+	alert := &RuntimeAlert{
+		TypeMeta:   metav1.TypeMeta{APIVersion: RuntimeAlertGvr.GroupVersion().String(), Kind: RuntimeAlertKind},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: runtimeAlertLabels(spec)},
+		Spec:       spec,
+	}
+	exporter.pendingLock.Lock()
+	defer exporter.pendingLock.Unlock()
+	exporter.addPending(alert)
+}
+
+func (exporter *RuntimeAlertExporter) addPending(alert *RuntimeAlert) {
+	key := alert.Namespace + "/" + alert.Name
+	if pending, ok := exporter.pending[key]; ok {
+		// the last occurrence describes the alert
+		alert.Spec.Count += pending.Spec.Count
+		if pending.Spec.FirstSeen.Before(&alert.Spec.FirstSeen) {
+			alert.Spec.FirstSeen = pending.Spec.FirstSeen
+		}
+		if alert.Spec.LastSeen.Before(&pending.Spec.LastSeen) {
+			alert.Spec.LastSeen = pending.Spec.LastSeen
+		}
+	}
+	exporter.pending[key] = alert
+}
+
+// flush writes the pending alerts, the alerts which could not be written are retried on the next flush.
+func (exporter *RuntimeAlertExporter) flush() {
+	exporter.pendingLock.Lock()
+	pending := exporter.pending
+	exporter.pending = make(map[string]*RuntimeAlert)
+	exporter.pendingLock.Unlock()
+
+	failed := []*RuntimeAlert{}
+	for key, alert := range pending {
+		if err := exporter.write(key, alert); err != nil {
+			log.Errorf("failed to write RuntimeAlert %s: %v", key, err)
+			failed = append(failed, alert)
+		}
+	}
+	if len(failed) > 0 {
+		exporter.pendingLock.Lock()
+		defer exporter.pendingLock.Unlock()
+		for _, alert := range failed {
+			exporter.addPending(alert)
+		}
+	}
+}
+
+// write creates the object of the alert, or adds the occurrences of the alert to its existing object.
+func (exporter *RuntimeAlertExporter) write(key string, alert *RuntimeAlert) error {
+	ctx := context.Background()
+	resource := exporter.dynamicClient.Resource(RuntimeAlertGvr).Namespace(alert.Namespace)
+	written, ok := exporter.written[key]
+	if !ok {
+		object, err := runtimeAlertToUnstructured(alert)
+		if err != nil {
+			return err
+		}
+		_, err = resource.Create(ctx, object, metav1.CreateOptions{})
+		if err == nil {
+			exporter.written[key] = runtimeAlertOccurrences{count: alert.Spec.Count, firstSeen: alert.Spec.FirstSeen}
+			return nil
+		}
+		if !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		// written before a restart of the agent, its occurrences are read from the object
+		existing, err := resource.Get(ctx, alert.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if written, err = runtimeAlertOccurrencesFromUnstructured(existing); err != nil {
+			return err
+		}
+	}
+
+	merged := *alert
+	merged.Spec.Count += written.count
+	if written.firstSeen.Before(&merged.Spec.FirstSeen) {
+		merged.Spec.FirstSeen = written.firstSeen
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": merged.Labels},
+		"spec":     merged.Spec,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := resource.Patch(ctx, alert.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		if k8serrors.IsNotFound(err) {
+			// deleted by the garbage collection or by a user, the alert starts over
+			delete(exporter.written, key)
+			return exporter.write(key, alert)
+		}
+		return err
+	}
+	exporter.written[key] = runtimeAlertOccurrences{count: merged.Spec.Count, firstSeen: merged.Spec.FirstSeen}
+	return nil
+}
+
+// collectGarbage deletes the RuntimeAlerts not seen during the TTL, then the least recently seen RuntimeAlerts of the
+// namespaces with more than the maximum. Every agent collects all the RuntimeAlerts, deleting an object twice is harmless.
+func (exporter *RuntimeAlertExporter) collectGarbage() {
+	ctx := context.Background()
+	list, err := exporter.dynamicClient.Resource(RuntimeAlertGvr).Namespace(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("failed to list RuntimeAlerts for their garbage collection: %v", err)
+		return
+	}
+	type seenAlert struct {
+		namespace string
+		name      string
+		lastSeen  time.Time
+	}
+	expiry := time.Now().Add(-time.Duration(exporter.config.TTLHours) * time.Hour)
+	expired := []seenAlert{}
+	byNamespace := map[string][]seenAlert{}
+	for _, item := range list.Items {
+		alert := seenAlert{namespace: item.GetNamespace(), name: item.GetName(), lastSeen: item.GetCreationTimestamp().Time}
+		if lastSeen, found, _ := unstructured.NestedString(item.Object, "spec", "lastSeen"); found {
+			if parsed, err := time.Parse(time.RFC3339, lastSeen); err == nil {
+				alert.lastSeen = parsed
+			}
+		}
+		if alert.lastSeen.Before(expiry) {
+			expired = append(expired, alert)
+			continue
+		}
+		byNamespace[alert.namespace] = append(byNamespace[alert.namespace], alert)
+	}
+	for _, alerts := range byNamespace {
+		if len(alerts) <= exporter.config.MaxPerNamespace {
+			continue
+		}
+		sort.Slice(alerts, func(i, j int) bool { return alerts[i].lastSeen.After(alerts[j].lastSeen) })
+		expired = append(expired, alerts[exporter.config.MaxPerNamespace:]...)
+	}
+
+	for _, alert := range expired {
+		err := exporter.dynamicClient.Resource(RuntimeAlertGvr).Namespace(alert.namespace).Delete(ctx, alert.name, metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Errorf("failed to delete RuntimeAlert %s/%s: %v", alert.namespace, alert.name, err)
+			continue
+		}
+		delete(exporter.written, alert.namespace+"/"+alert.name)
+	}
+	if len(expired) > 0 {
+		log.Debugf("deleted %d RuntimeAlerts", len(expired))
+	}
+}
+
+// runtimeAlertName returns the name of the object of the alert, the same for all its repeats.
+func runtimeAlertName(prefix string, spec RuntimeAlertSpec) string {
+	if prefix == "" {
+		prefix = "alert"
+	}
+	hash := sha256.Sum256([]byte(strings.Join([]string{spec.RuleName, spec.PodName, spec.ContainerName, spec.Fingerprint}, "\x00")))
+	return strings.ToLower(prefix) + "-" + hex.EncodeToString(hash[:8])
+}
+
+func runtimeAlertLabels(spec RuntimeAlertSpec) map[string]string {
+	labels := map[string]string{}
+	for key, value := range map[string]string{
+		RuntimeAlertRuleIDLabel:   spec.RuleID,
+		RuntimeAlertSeverityLabel: spec.Severity,
+		RuntimeAlertNodeLabel:     spec.NodeName,
+		RuntimeAlertPodLabel:      spec.PodName,
+	} {
+		// the values which are not valid label values, like long pod names, are not set
+		if value != "" && len(validation.IsValidLabelValue(value)) == 0 {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+func runtimeAlertToUnstructured(alert *RuntimeAlert) (*unstructured.Unstructured, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(alert)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: object}, nil
+}
+
+func runtimeAlertOccurrencesFromUnstructured(object *unstructured.Unstructured) (runtimeAlertOccurrences, error) {
+	alert := RuntimeAlert{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, &alert); err != nil {
+		return runtimeAlertOccurrences{}, err
+	}
+	return runtimeAlertOccurrences{count: alert.Spec.Count, firstSeen: alert.Spec.FirstSeen}, nil
+}
@@ -0,0 +1,144 @@
+package exporters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/armosec/kubecop/pkg/scan"
+	"github.com/kubescape/kapprofiler/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newFakeRuntimeAlertClient() *dfake.FakeDynamicClient {
+	return dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{RuntimeAlertGvr: "RuntimeAlertList"})
+}
+
+func listRuntimeAlerts(t *testing.T, dynamicClient *dfake.FakeDynamicClient, namespace string) map[string]RuntimeAlert {
+	list, err := dynamicClient.Resource(RuntimeAlertGvr).Namespace(namespace).List(context.Background(), metav1.ListOptions{})
+	assert.NoError(t, err)
+	alerts := map[string]RuntimeAlert{}
+	for _, item := range list.Items {
+		alert := RuntimeAlert{}
+		assert.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &alert))
+		alerts[alert.Spec.RuleName] = alert
+	}
+	return alerts
+}
+
+func processLaunchedFailure(path string) rule.RuleFailure {
+	return &rule.R0001UnexpectedProcessLaunchedFailure{
+		RuleName:     rule.R0001UnexpectedProcessLaunchedRuleName,
+		RulePriority: rule.RulePriorityCritical,
+		Err:          "Unexpected process launched: " + path,
+		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
+			ProcessDetails: tracing.ProcessDetails{Pid: 20, Comm: "sh"},
+			ContainerName:  "testcontainer", ContainerID: "testcontainerid", Namespace: "payments", PodName: "testpodname"},
+			PathName: path},
+	}
+}
+
+func TestRuntimeAlertExporter(t *testing.T) {
+	dynamicClient := newFakeRuntimeAlertClient()
+	config := RuntimeAlertExporterConfig{}
+	assert.NoError(t, config.Validate())
+	exporter := newRuntimeAlertExporter(config, dynamicClient)
+
+	// the repeats of an alert are written in the same object
+	for i := 0; i < 3; i++ {
+		exporter.SendRuleAlert(processLaunchedFailure("/bin/sh"))
+	}
+	exporter.SendMalwareAlert(scan.MalwareDescription{Name: "testmalware", Path: "/tmp/testmalware", Namespace: "payments", PodName: "testpodname"})
+	// alerts without a pod are not written
+	exporter.SendRuleAlert(&rule.R0001UnexpectedProcessLaunchedFailure{FailureEvent: &tracing.ExecveEvent{}})
+	exporter.flush()
+
+	alerts := listRuntimeAlerts(t, dynamicClient, "payments")
+	assert.Len(t, alerts, 2)
+	alert := alerts[rule.R0001UnexpectedProcessLaunchedRuleName]
+	assert.Equal(t, int64(3), alert.Spec.Count)
+	assert.Equal(t, "R0001", alert.Spec.RuleID)
+	assert.Equal(t, "critical", alert.Spec.Severity)
+	assert.Equal(t, "testpodname", alert.Spec.PodName)
+	assert.Equal(t, "sh", alert.Spec.Process.Comm)
+	assert.Equal(t, "/bin/sh", alert.Spec.Evidence[rule.EvidencePath])
+	assert.Equal(t, map[string]string{RuntimeAlertRuleIDLabel: "R0001", RuntimeAlertSeverityLabel: "critical", RuntimeAlertPodLabel: "testpodname"}, alert.Labels)
+	assert.Equal(t, "/tmp/testmalware", alerts[RuntimeAlertMalwareRuleName].Spec.Evidence[rule.EvidencePath])
+	firstSeen := alert.Spec.FirstSeen
+
+	// a repeat updates the count of the object
+	exporter.SendRuleAlert(processLaunchedFailure("/bin/sh"))
+	exporter.flush()
+	alert = listRuntimeAlerts(t, dynamicClient, "payments")[rule.R0001UnexpectedProcessLaunchedRuleName]
+	assert.Equal(t, int64(4), alert.Spec.Count)
+	assert.Equal(t, firstSeen, alert.Spec.FirstSeen)
+
+	// after a restart, the count is read from the object
+	exporter = newRuntimeAlertExporter(config, dynamicClient)
+	exporter.SendRuleAlert(processLaunchedFailure("/bin/sh"))
+	exporter.flush()
+	alerts = listRuntimeAlerts(t, dynamicClient, "payments")
+	assert.Len(t, alerts, 2)
+	assert.Equal(t, int64(5), alerts[rule.R0001UnexpectedProcessLaunchedRuleName].Spec.Count)
+}
+
+func TestRuntimeAlertExporterSuppressedFailures(t *testing.T) {
+	dynamicClient := newFakeRuntimeAlertClient()
+	exporter := newRuntimeAlertExporter(RuntimeAlertExporterConfig{}, dynamicClient)
+
+	// a summary of suppressed failures counts all the suppressed occurrences, even when wrapped
+	firstSeen := time.Now().Add(-time.Minute).Truncate(time.Second)
+	exporter.SendRuleAlert(processLaunchedFailure("/bin/sh"))
+	exporter.SendRuleAlert(rule.WithProcessAncestry(&rule.SuppressedRuleFailure{
+		RuleFailure:     processLaunchedFailure("/bin/sh"),
+		SuppressedCount: 5,
+		FirstSeen:       firstSeen,
+		LastSeen:        firstSeen.Add(30 * time.Second),
+	}, []rule.ProcessInfo{{Pid: 20}}))
+	exporter.flush()
+
+	alert := listRuntimeAlerts(t, dynamicClient, "payments")[rule.R0001UnexpectedProcessLaunchedRuleName]
+	assert.Equal(t, int64(6), alert.Spec.Count)
+	assert.True(t, alert.Spec.FirstSeen.Time.Equal(firstSeen))
+}
+
+func TestRuntimeAlertExporterGarbageCollection(t *testing.T) {
+	dynamicClient := newFakeRuntimeAlertClient()
+	config := RuntimeAlertExporterConfig{TTLHours: 1, MaxPerNamespace: 1}
+	assert.NoError(t, config.Validate())
+	exporter := newRuntimeAlertExporter(config, dynamicClient)
+
+	now := time.Now()
+	for name, lastSeen := range map[string]time.Time{"expired": now.Add(-2 * time.Hour), "older": now.Add(-time.Minute), "newer": now} {
+		alert := &RuntimeAlert{
+			TypeMeta:   metav1.TypeMeta{APIVersion: RuntimeAlertGvr.GroupVersion().String(), Kind: RuntimeAlertKind},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "payments"},
+			Spec:       RuntimeAlertSpec{RuleName: name, Count: 1, FirstSeen: metav1.NewTime(lastSeen), LastSeen: metav1.NewTime(lastSeen)},
+		}
+		object, err := runtimeAlertToUnstructured(alert)
+		assert.NoError(t, err)
+		_, err = dynamicClient.Resource(RuntimeAlertGvr).Namespace("payments").Create(context.Background(), object, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	// the expired alert and the alerts over the maximum of the namespace are deleted, the least recently seen first
+	exporter.collectGarbage()
+	alerts := listRuntimeAlerts(t, dynamicClient, "payments")
+	assert.Len(t, alerts, 1)
+	assert.Contains(t, alerts, "newer")
+}
+
+func TestValidateRuntimeAlertExporterConfig(t *testing.T) {
+	config := RuntimeAlertExporterConfig{}
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, RuntimeAlertExporterConfig{TTLHours: 168, MaxPerNamespace: 1000, FlushIntervalSeconds: 10}, config)
+
+	config = RuntimeAlertExporterConfig{MaxPerNamespace: -1}
+	assert.Error(t, config.Validate())
+}
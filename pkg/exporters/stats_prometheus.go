@@ -0,0 +1,146 @@
+package exporters
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Exporters are re-created when the exporters configuration is reloaded,
+// so their metrics are registered once per process and shared between instances.
+var (
+	exportersPromCollector     *prometheusMetric
+	exportersPromCollectorOnce sync.Once
+)
+
+type prometheusMetric struct {
+	httpQueueDepth      prometheus.Gauge
+	httpBufferedBatches prometheus.Gauge
+	httpSentAlerts      prometheus.Counter
+	httpRetries         prometheus.Counter
+	httpDroppedAlerts   *prometheus.CounterVec
+	// Per exporter queue metrics
+	exporterQueueDepth    *prometheus.GaugeVec
+	exporterSentAlerts    *prometheus.CounterVec
+	exporterDroppedAlerts *prometheus.CounterVec
+	exporterFailedAlerts  *prometheus.CounterVec
+}
+
+func getPrometheusMetric() *prometheusMetric {
+	exportersPromCollectorOnce.Do(func() {
+		exportersPromCollector = createPrometheusMetric()
+	})
+	return exportersPromCollector
+}
+
+func createPrometheusMetric() *prometheusMetric {
+	httpQueueDepth := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kubecop_http_exporter_queue_depth",
+		Help: "The number of alerts waiting in memory to be sent by the HTTP exporter",
+	})
+	prometheus.MustRegister(httpQueueDepth)
+
+	httpBufferedBatches := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kubecop_http_exporter_buffered_batches",
+		Help: "The number of alert batches waiting in the on-disk buffer of the HTTP exporter",
+	})
+	prometheus.MustRegister(httpBufferedBatches)
+
+	httpSentAlerts := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kubecop_http_exporter_sent_alerts_counter",
+		Help: "The total number of alerts delivered by the HTTP exporter",
+	})
+	prometheus.MustRegister(httpSentAlerts)
+
+	httpRetries := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kubecop_http_exporter_retries_counter",
+		Help: "The total number of HTTP requests retried by the HTTP exporter",
+	})
+	prometheus.MustRegister(httpRetries)
+
+	httpDroppedAlerts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubecop_http_exporter_dropped_alerts_counter",
+		Help: "The total number of alerts dropped by the HTTP exporter, by reason",
+	}, []string{"reason"})
+	prometheus.MustRegister(httpDroppedAlerts)
+
+	exporterQueueDepth := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubecop_exporter_queue_depth",
+		Help: "The number of alerts waiting in the queue of each exporter",
+	}, []string{"exporter"})
+	prometheus.MustRegister(exporterQueueDepth)
+
+	exporterSentAlerts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubecop_exporter_sent_alerts_counter",
+		Help: "The total number of alerts sent by each exporter",
+	}, []string{"exporter"})
+	prometheus.MustRegister(exporterSentAlerts)
+
+	exporterDroppedAlerts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubecop_exporter_dropped_alerts_counter",
+		Help: "The total number of alerts dropped because the queue of the exporter was full",
+	}, []string{"exporter"})
+	prometheus.MustRegister(exporterDroppedAlerts)
+
+	exporterFailedAlerts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubecop_exporter_failed_alerts_counter",
+		Help: "The total number of alerts each exporter failed to send, by reason",
+	}, []string{"exporter", "reason"})
+	prometheus.MustRegister(exporterFailedAlerts)
+
+	return &prometheusMetric{
+		httpQueueDepth:        httpQueueDepth,
+		httpBufferedBatches:   httpBufferedBatches,
+		httpSentAlerts:        httpSentAlerts,
+		httpRetries:           httpRetries,
+		httpDroppedAlerts:     httpDroppedAlerts,
+		exporterQueueDepth:    exporterQueueDepth,
+		exporterSentAlerts:    exporterSentAlerts,
+		exporterDroppedAlerts: exporterDroppedAlerts,
+		exporterFailedAlerts:  exporterFailedAlerts,
+	}
+}
+
+func (p *prometheusMetric) reportHTTPAlertQueued() {
+	p.httpQueueDepth.Inc()
+}
+
+func (p *prometheusMetric) reportHTTPAlertDequeued() {
+	p.httpQueueDepth.Dec()
+}
+
+func (p *prometheusMetric) reportHTTPBufferedBatches(delta int) {
+	p.httpBufferedBatches.Add(float64(delta))
+}
+
+func (p *prometheusMetric) reportHTTPAlertsSent(count int) {
+	p.httpSentAlerts.Add(float64(count))
+}
+
+func (p *prometheusMetric) reportHTTPRetry() {
+	p.httpRetries.Inc()
+}
+
+func (p *prometheusMetric) reportHTTPAlertsDropped(reason string, count int) {
+	p.httpDroppedAlerts.WithLabelValues(reason).Add(float64(count))
+}
+
+func (p *prometheusMetric) reportExporterAlertQueued(exporter string) {
+	p.exporterQueueDepth.WithLabelValues(exporter).Inc()
+}
+
+func (p *prometheusMetric) reportExporterAlertDequeued(exporter string) {
+	p.exporterQueueDepth.WithLabelValues(exporter).Dec()
+}
+
+func (p *prometheusMetric) reportExporterAlertSent(exporter string) {
+	p.exporterSentAlerts.WithLabelValues(exporter).Inc()
+}
+
+func (p *prometheusMetric) reportExporterAlertDropped(exporter string) {
+	p.exporterDroppedAlerts.WithLabelValues(exporter).Inc()
+}
+
+func (p *prometheusMetric) reportExporterAlertFailed(exporter, reason string) {
+	p.exporterFailedAlerts.WithLabelValues(exporter, reason).Inc()
+}
@@ -2,6 +2,7 @@ package exporters
 
 import (
 	"os"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -32,11 +33,20 @@ func InitStdoutExporter(useStdout *bool) *StdoutExporter {
 }
 
 func (exporter *StdoutExporter) SendRuleAlert(failedRule rule.RuleFailure) {
-	exporter.logger.WithFields(log.Fields{
-		"severity": failedRule.Priority(),
-		"message":  failedRule.Error(),
-		"event":    failedRule.Event(),
-	}).Error(failedRule.Name())
+	fields := log.Fields{
+		"severity":        failedRule.Priority(),
+		"message":         failedRule.Error(),
+		"event":           failedRule.Event(),
+		"processAncestry": rule.GetProcessAncestry(failedRule),
+		"mitreAttack":     rule.GetMitreAttack(failedRule),
+		"evidence":        failedRule.Evidence(),
+	}
+	if suppressed := rule.GetSuppressedRuleFailure(failedRule); suppressed != nil {
+		fields["suppressedCount"] = suppressed.SuppressedCount
+		fields["firstSeen"] = suppressed.FirstSeen.UTC().Format(time.RFC3339)
+		fields["lastSeen"] = suppressed.LastSeen.UTC().Format(time.RFC3339)
+	}
+	exporter.logger.WithFields(fields).Error(failedRule.Name())
 }
 
 func (exporter *StdoutExporter) SendMalwareAlert(malwareDescription scan.MalwareDescription) {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/syslog"
 	"os"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -44,8 +45,14 @@ func InitSyslogExporter(syslogHost string) *SyslogExporter {
 	}
 }
 
+// Close closes the connection to the syslog server
+func (se *SyslogExporter) Close() error {
+	return se.writer.Close()
+}
+
 // SendRuleAlert sends an alert to syslog (RFC 5424) - https://tools.ietf.org/html/rfc5424
 func (se *SyslogExporter) SendRuleAlert(failedRule rule.RuleFailure) {
+	mitre := rule.GetMitreAttack(failedRule)
 	message := rfc5424.Message{
 		Priority:  rfc5424.Error,
 		Timestamp: time.Unix(failedRule.Event().Timestamp, 0),
@@ -108,11 +115,37 @@ func (se *SyslogExporter) SendRuleAlert(failedRule rule.RuleFailure) {
 						Name:  "cwd",
 						Value: failedRule.Event().Cwd,
 					},
+					{
+						Name:  "process_ancestry",
+						Value: FormatProcessAncestry(rule.GetProcessAncestry(failedRule)),
+					},
+					{
+						Name:  "mitre_tactics",
+						Value: strings.Join(mitre.Tactics, ","),
+					},
+					{
+						Name:  "mitre_techniques",
+						Value: strings.Join(mitre.Techniques, ","),
+					},
 				},
 			},
 		},
 		Message: []byte(failedRule.Error()),
 	}
+	evidence := failedRule.Evidence()
+	for _, key := range evidence.Keys() {
+		if value := evidence.String(key); value != "" {
+			message.StructuredData[0].Parameters = append(message.StructuredData[0].Parameters,
+				rfc5424.SDParam{Name: "evidence_" + key, Value: value})
+		}
+	}
+	if suppressed := rule.GetSuppressedRuleFailure(failedRule); suppressed != nil {
+		message.StructuredData[0].Parameters = append(message.StructuredData[0].Parameters,
+			rfc5424.SDParam{Name: "suppressed_count", Value: fmt.Sprintf("%d", suppressed.SuppressedCount)},
+			rfc5424.SDParam{Name: "first_seen", Value: suppressed.FirstSeen.UTC().Format(time.RFC3339)},
+			rfc5424.SDParam{Name: "last_seen", Value: suppressed.LastSeen.UTC().Format(time.RFC3339)},
+		)
+	}
 
 	_, err := message.WriteTo(se.writer)
 	if err != nil {
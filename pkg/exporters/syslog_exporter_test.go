@@ -75,7 +75,8 @@ func TestSyslogExporter(t *testing.T) {
 		RuleName: "testrule",
 		Err:      "Application profile is missing",
 		FailureEvent: &tracing.ExecveEvent{GeneralEvent: tracing.GeneralEvent{
-			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"}},
+			ContainerName: "testcontainer", ContainerID: "testcontainerid", Namespace: "testnamespace", PodName: "testpodname"},
+			PathName: "/bin/sh", Args: []string{"sh", "-c", "id"}},
 	})
 
 	syslogExp.SendMalwareAlert(scan.MalwareDescription{
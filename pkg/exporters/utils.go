@@ -1,6 +1,11 @@
 package exporters
 
-import "github.com/armosec/kubecop/pkg/engine/rule"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+)
 
 func PriorityToStatus(priority int) string {
 	switch priority {
@@ -27,3 +32,20 @@ func PriorityToStatus(priority int) string {
 		return "unknown"
 	}
 }
+
+// FormatProcessAncestry formats the process ancestry of an alert on one line, from the process of the alert
+// to its oldest known ancestor, like "[20] /bin/sh -c id <- [10] /usr/sbin/nginx <- [1]".
+func FormatProcessAncestry(ancestry []rule.ProcessInfo) string {
+	processes := make([]string, 0, len(ancestry))
+	for _, process := range ancestry {
+		command := process.Path
+		if command == "" {
+			command = process.Comm
+		}
+		if command != "" && len(process.Args) > 1 {
+			command += " " + strings.Join(process.Args[1:], " ")
+		}
+		processes = append(processes, strings.TrimSpace(fmt.Sprintf("[%d] %s", process.Pid, command)))
+	}
+	return strings.Join(processes, " <- ")
+}
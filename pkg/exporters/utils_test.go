@@ -71,3 +71,18 @@ func TestPriorityToStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatProcessAncestry(t *testing.T) {
+	ancestry := []rule.ProcessInfo{
+		{Pid: 20, Ppid: 10, Comm: "sh", Path: "/bin/sh", Args: []string{"sh", "-c", "id"}},
+		{Pid: 10, Ppid: 1, Comm: "nginx"},
+		{Pid: 1},
+	}
+	expected := "[20] /bin/sh -c id <- [10] nginx <- [1]"
+	if got := FormatProcessAncestry(ancestry); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got := FormatProcessAncestry(nil); got != "" {
+		t.Errorf("Expected an empty string, got %q", got)
+	}
+}
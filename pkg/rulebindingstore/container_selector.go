@@ -0,0 +1,56 @@
+package rulebindingstore
+
+// selectsContainer returns true if the container selectors of the binding select the container.
+func (spec *RuntimeAlertRuleBindingSpec) selectsContainer(container ContainerInfo) bool {
+	return spec.ContainerNames.matches(container.Name) && spec.ContainerImages.matches(container.Image)
+}
+
+// matches returns true if the value matches one of the included patterns, or if there are none, and none of the
+// excluded patterns. Nil globs match any value.
+func (globs *ContainerGlobs) matches(value string) bool {
+	if globs == nil {
+		return true
+	}
+	for _, pattern := range globs.Exclude {
+		if globMatch(pattern, value) {
+			return false
+		}
+	}
+	if len(globs.Include) == 0 {
+		return true
+	}
+	for _, pattern := range globs.Include {
+		if globMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch matches the whole value against the pattern, * matches any sequence of characters including
+// slashes, so image patterns like gcr.io/project/* match the images of the subpaths too, and ? matches any character.
+func globMatch(pattern, value string) bool {
+	patternIndex, valueIndex := 0, 0
+	// Position of the last * in the pattern and of the value it is matched from, to backtrack to
+	starIndex, starValueIndex := -1, 0
+	for valueIndex < len(value) {
+		switch {
+		case patternIndex < len(pattern) && (pattern[patternIndex] == '?' || pattern[patternIndex] == value[valueIndex]):
+			patternIndex++
+			valueIndex++
+		case patternIndex < len(pattern) && pattern[patternIndex] == '*':
+			starIndex, starValueIndex = patternIndex, valueIndex
+			patternIndex++
+		case starIndex >= 0:
+			// the last * matches one more character
+			starValueIndex++
+			patternIndex, valueIndex = starIndex+1, starValueIndex
+		default:
+			return false
+		}
+	}
+	for patternIndex < len(pattern) && pattern[patternIndex] == '*' {
+		patternIndex++
+	}
+	return patternIndex == len(pattern)
+}
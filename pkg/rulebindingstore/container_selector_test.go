@@ -0,0 +1,66 @@
+package rulebindingstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		value    string
+		expected bool
+	}{
+		{"istio-proxy", "istio-proxy", true},
+		{"istio-proxy", "istio-proxy2", false},
+		{"istio-*", "istio-proxy", true},
+		{"istio-*", "istio-", true},
+		{"*-proxy", "istio-proxy", true},
+		{"*", "", true},
+		{"", "", true},
+		{"", "app", false},
+		{"app-?", "app-1", true},
+		{"app-?", "app-12", false},
+		{"docker.io/istio/*", "docker.io/istio/proxyv2:1.20.0", true},
+		{"*/proxyv2:*", "docker.io/istio/proxyv2:1.20.0", true},
+		{"*proxy*v2*", "docker.io/istio/proxyv2:1.20.0", true},
+		{"*fluent-bit", "fluent/fluent-bit:2.2", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, globMatch(tt.pattern, tt.value), "pattern %q value %q", tt.pattern, tt.value)
+	}
+}
+
+func TestSelectsContainer(t *testing.T) {
+	app := ContainerInfo{Name: "app", Image: "registry.example.com/payments/api:2.1"}
+	sidecar := ContainerInfo{Name: "istio-proxy", Image: "docker.io/istio/proxyv2:1.20.0"}
+	shipper := ContainerInfo{Name: "log-shipper", Image: "fluent/fluent-bit:2.2"}
+
+	// Without selectors all the containers are selected
+	spec := RuntimeAlertRuleBindingSpec{}
+	assert.True(t, spec.selectsContainer(app))
+	assert.True(t, spec.selectsContainer(sidecar))
+
+	// Excluded names
+	spec = RuntimeAlertRuleBindingSpec{ContainerNames: &ContainerGlobs{Exclude: []string{"istio-*", "log-shipper"}}}
+	assert.True(t, spec.selectsContainer(app))
+	assert.False(t, spec.selectsContainer(sidecar))
+	assert.False(t, spec.selectsContainer(shipper))
+
+	// Included images
+	spec = RuntimeAlertRuleBindingSpec{ContainerImages: &ContainerGlobs{Include: []string{"registry.example.com/*"}}}
+	assert.True(t, spec.selectsContainer(app))
+	assert.False(t, spec.selectsContainer(sidecar))
+
+	// Both the names and the images must select the container, the exclusions win over the inclusions
+	spec = RuntimeAlertRuleBindingSpec{
+		ContainerNames:  &ContainerGlobs{Include: []string{"*"}},
+		ContainerImages: &ContainerGlobs{Include: []string{"*"}, Exclude: []string{"*/istio/*"}},
+	}
+	assert.True(t, spec.selectsContainer(app))
+	assert.False(t, spec.selectsContainer(sidecar))
+	assert.True(t, spec.selectsContainer(shipper))
+}
@@ -0,0 +1,158 @@
+package rulebindingstore
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EffectiveRule is a rule applied to a pod after merging all the rule bindings selecting the pod.
+type EffectiveRule struct {
+	RuntimeAlertRuleBindingRule `json:",inline"`
+	// Bindings the rule comes from, from the highest precedence to the lowest
+	Bindings []string `json:"bindings"`
+}
+
+// mergeRuleBindings merges the rules of the bindings selecting the same pod into a single rule per rule name.
+// Bindings with a higher spec.priority take precedence, then bindings with a more specific pod selector,
+// then bindings with a more specific namespace selector. The parameters of a rule are merged key by key and
+// the severity, the response actions and the missing application profile policy are taken from the binding with
+// the highest precedence which sets them.
+func mergeRuleBindings(ruleBindings []RuntimeAlertRuleBinding) []EffectiveRule {
+	// sort from the lowest precedence to the highest, so higher precedence bindings override lower ones
+	sortedBindings := make([]RuntimeAlertRuleBinding, len(ruleBindings))
+	copy(sortedBindings, ruleBindings)
+	sort.SliceStable(sortedBindings, func(i, j int) bool {
+		return compareRuleBindingsPrecedence(&sortedBindings[i], &sortedBindings[j]) < 0
+	})
+
+	effectiveRules := map[string]*EffectiveRule{}
+	for _, ruleBinding := range sortedBindings {
+		bindingName := ruleBindingName(&ruleBinding)
+		for _, bindingRule := range ruleBinding.Spec.Rules {
+			ruleNames := resolveRuleNames(bindingRule)
+			if len(ruleNames) == 0 {
+				log.Warnf("Rule binding %s has a rule which matches no known rule (name %q, id %q, tags %v, MITRE tactics %v, MITRE techniques %v)\n", bindingName, bindingRule.RuleName, bindingRule.RuleID, bindingRule.RuleTags, bindingRule.MitreTactics, bindingRule.MitreTechniques)
+				continue
+			}
+			for _, ruleName := range ruleNames {
+				effectiveRule, ok := effectiveRules[ruleName]
+				if !ok {
+					effectiveRule = &EffectiveRule{RuntimeAlertRuleBindingRule: RuntimeAlertRuleBindingRule{RuleName: ruleName}}
+					effectiveRules[ruleName] = effectiveRule
+				}
+				if bindingRule.Severity != "" {
+					effectiveRule.Severity = bindingRule.Severity
+				}
+				// an empty list of actions disables the actions of the bindings with a lower precedence
+				if bindingRule.Actions != nil {
+					effectiveRule.Actions = bindingRule.Actions
+				}
+				if ruleBinding.Spec.MissingApplicationProfile != nil {
+					effectiveRule.MissingApplicationProfile = ruleBinding.Spec.MissingApplicationProfile
+				}
+				if bindingRule.Parameters != nil {
+					if effectiveRule.Parameters == nil {
+						effectiveRule.Parameters = map[string]interface{}{}
+					}
+					for key, value := range bindingRule.Parameters {
+						effectiveRule.Parameters[key] = value
+					}
+				}
+				if len(effectiveRule.Bindings) == 0 || effectiveRule.Bindings[0] != bindingName {
+					effectiveRule.Bindings = append([]string{bindingName}, effectiveRule.Bindings...)
+				}
+			}
+		}
+	}
+
+	// keep the order of the rule descriptors, so the result is stable
+	mergedRules := make([]EffectiveRule, 0, len(effectiveRules))
+	for _, ruleDesc := range rule.GetAllRuleDescriptors() {
+		if effectiveRule, ok := effectiveRules[ruleDesc.Name]; ok {
+			mergedRules = append(mergedRules, *effectiveRule)
+		}
+	}
+	return mergedRules
+}
+
+// resolveRuleNames returns the names of the rules selected by a rule of a binding, by name, ID, tags or MITRE ATT&CK.
+func resolveRuleNames(bindingRule RuntimeAlertRuleBindingRule) []string {
+	var ruleNames []string
+	for _, ruleDesc := range rule.GetAllRuleDescriptors() {
+		switch {
+		case bindingRule.RuleName != "":
+			if ruleDesc.Name == bindingRule.RuleName {
+				ruleNames = append(ruleNames, ruleDesc.Name)
+			}
+		case bindingRule.RuleID != "":
+			if ruleDesc.ID == bindingRule.RuleID {
+				ruleNames = append(ruleNames, ruleDesc.Name)
+			}
+		case len(bindingRule.RuleTags) > 0:
+			if ruleDesc.HasTags(bindingRule.RuleTags) {
+				ruleNames = append(ruleNames, ruleDesc.Name)
+			}
+		case len(bindingRule.MitreTactics) > 0 || len(bindingRule.MitreTechniques) > 0:
+			if ruleDesc.Mitre.Matches(bindingRule.MitreTactics, bindingRule.MitreTechniques) {
+				ruleNames = append(ruleNames, ruleDesc.Name)
+			}
+		}
+	}
+	return ruleNames
+}
+
+// unresolvedRules returns the rules of the binding which select no registered rule. Names and tags of custom rules
+// cannot be validated by the binding schema, a rule may also be unresolved until its RuntimeRule is created.
+func unresolvedRules(binding *RuntimeAlertRuleBinding) []RuntimeAlertRuleBindingRule {
+	var unresolved []RuntimeAlertRuleBindingRule
+	for _, bindingRule := range binding.Spec.Rules {
+		if len(resolveRuleNames(bindingRule)) == 0 {
+			unresolved = append(unresolved, bindingRule)
+		}
+	}
+	return unresolved
+}
+
+func warnUnresolvedRules(binding *RuntimeAlertRuleBinding) {
+	for _, bindingRule := range unresolvedRules(binding) {
+		log.Warnf("Rule binding %s selects no rule: name %q, ID %q, tags %v, MITRE ATT&CK tactics %v and techniques %v\n", ruleBindingName(binding), bindingRule.RuleName, bindingRule.RuleID, bindingRule.RuleTags, bindingRule.MitreTactics, bindingRule.MitreTechniques)
+	}
+}
+
+// compareRuleBindingsPrecedence returns a negative number if a has a lower precedence than b,
+// a positive number if it has a higher precedence and 0 if they have the same precedence.
+func compareRuleBindingsPrecedence(a, b *RuntimeAlertRuleBinding) int {
+	if a.Spec.Priority != b.Spec.Priority {
+		return a.Spec.Priority - b.Spec.Priority
+	}
+	if diff := labelSelectorSpecificity(&a.Spec.PodSelector) - labelSelectorSpecificity(&b.Spec.PodSelector); diff != 0 {
+		return diff
+	}
+	if diff := labelSelectorSpecificity(&a.Spec.NamespaceSelector) - labelSelectorSpecificity(&b.Spec.NamespaceSelector); diff != 0 {
+		return diff
+	}
+	// same precedence, order by name so the result does not depend on the listing order
+	nameA, nameB := ruleBindingName(a), ruleBindingName(b)
+	if nameA > nameB {
+		return -1
+	} else if nameA < nameB {
+		return 1
+	}
+	return 0
+}
+
+// labelSelectorSpecificity is the number of requirements of the selector, an empty selector selects everything.
+func labelSelectorSpecificity(selector *metav1.LabelSelector) int {
+	return len(selector.MatchLabels) + len(selector.MatchExpressions)
+}
+
+func ruleBindingName(ruleBinding *RuntimeAlertRuleBinding) string {
+	if ruleBinding.Namespace != "" {
+		return ruleBinding.Namespace + "/" + ruleBinding.Name
+	}
+	return ruleBinding.Name
+}
@@ -0,0 +1,193 @@
+package rulebindingstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeRuleBindings(t *testing.T) {
+	allPods := RuntimeAlertRuleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "all-pods"},
+		Spec: RuntimeAlertRuleBindingSpec{
+			Rules: []RuntimeAlertRuleBindingRule{
+				{RuleName: rule.R0002UnexpectedFileAccessRuleName, Severity: "high", Parameters: map[string]interface{}{"ignoreMounts": true, "ignorePrefixes": []interface{}{"/proc"}}},
+				{RuleID: rule.R0001ID},
+			},
+		},
+	}
+	nginxPods := RuntimeAlertRuleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx-pods"},
+		Spec: RuntimeAlertRuleBindingSpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}},
+			Rules: []RuntimeAlertRuleBindingRule{
+				{RuleName: rule.R0002UnexpectedFileAccessRuleName, Severity: "low", Parameters: map[string]interface{}{"ignorePrefixes": []interface{}{"/tmp"}}},
+				{RuleName: rule.R0001UnexpectedProcessLaunchedRuleName},
+				{RuleName: "Unknown rule"},
+			},
+		},
+	}
+
+	// Test case: the more specific pod selector wins, whatever the order of the bindings
+	for _, bindings := range [][]RuntimeAlertRuleBinding{{allPods, nginxPods}, {nginxPods, allPods}} {
+		effectiveRules := mergeRuleBindings(bindings)
+		assert.Len(t, effectiveRules, 2)
+		assert.Equal(t, rule.R0001UnexpectedProcessLaunchedRuleName, effectiveRules[0].RuleName)
+		assert.Equal(t, []string{"nginx-pods", "all-pods"}, effectiveRules[0].Bindings)
+		assert.Equal(t, rule.R0002UnexpectedFileAccessRuleName, effectiveRules[1].RuleName)
+		assert.Equal(t, "low", effectiveRules[1].Severity)
+		assert.Equal(t, map[string]interface{}{"ignoreMounts": true, "ignorePrefixes": []interface{}{"/tmp"}}, effectiveRules[1].Parameters)
+	}
+
+	// Test case: an explicit binding priority wins over the pod selector
+	allPods.Spec.Priority = 10
+	effectiveRules := mergeRuleBindings([]RuntimeAlertRuleBinding{allPods, nginxPods})
+	assert.Len(t, effectiveRules, 2)
+	assert.Equal(t, []string{"all-pods", "nginx-pods"}, effectiveRules[1].Bindings)
+	assert.Equal(t, "high", effectiveRules[1].Severity)
+	assert.Equal(t, map[string]interface{}{"ignoreMounts": true, "ignorePrefixes": []interface{}{"/proc"}}, effectiveRules[1].Parameters)
+
+	// Test case: rules selected by tags are expanded and merged with the rules selected by name
+	byTags := RuntimeAlertRuleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "by-tags"},
+		Spec: RuntimeAlertRuleBindingSpec{
+			Rules: []RuntimeAlertRuleBindingRule{{RuleTags: rule.R0002UnexpectedFileAccessRuleDescriptor.Tags}},
+		},
+	}
+	effectiveRules = mergeRuleBindings([]RuntimeAlertRuleBinding{byTags, nginxPods})
+	ruleNames := map[string]int{}
+	for _, effectiveRule := range effectiveRules {
+		ruleNames[effectiveRule.RuleName]++
+	}
+	for _, ruleDesc := range rule.GetAllRuleDescriptors() {
+		if ruleDesc.HasTags(rule.R0002UnexpectedFileAccessRuleDescriptor.Tags) {
+			assert.Equal(t, 1, ruleNames[ruleDesc.Name], "rule %s should be bound once", ruleDesc.Name)
+		}
+	}
+}
+
+func TestMergeRuleBindingsByMitre(t *testing.T) {
+	byMitre := RuntimeAlertRuleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "by-mitre"},
+		Spec: RuntimeAlertRuleBindingSpec{
+			Rules: []RuntimeAlertRuleBindingRule{
+				{MitreTactics: []string{rule.MitreTacticImpact}, Severity: "low"},
+				{MitreTechniques: []string{"t1021"}},
+			},
+		},
+	}
+	effectiveRules := mergeRuleBindings([]RuntimeAlertRuleBinding{byMitre})
+	assert.Len(t, effectiveRules, 2)
+	assert.Equal(t, rule.R1003MaliciousSSHConnectionRuleName, effectiveRules[0].RuleName)
+	assert.Equal(t, []string{"by-mitre"}, effectiveRules[0].Bindings)
+	assert.Equal(t, rule.R1007CryptoMinersRuleName, effectiveRules[1].RuleName)
+	assert.Equal(t, "low", effectiveRules[1].Severity)
+
+	// Test case: a technique mapped to no rule selects nothing
+	byMitre.Spec.Rules = []RuntimeAlertRuleBindingRule{{MitreTechniques: []string{"T9999"}}}
+	assert.Empty(t, mergeRuleBindings([]RuntimeAlertRuleBinding{byMitre}))
+}
+
+func TestUnresolvedRules(t *testing.T) {
+	binding := RuntimeAlertRuleBinding{
+		Spec: RuntimeAlertRuleBindingSpec{
+			Rules: []RuntimeAlertRuleBindingRule{
+				{RuleName: rule.R1003MaliciousSSHConnectionRuleName},
+				{RuleName: "No such rule"},
+				{RuleID: "C9999"},
+				{RuleTags: []string{"no such tag"}},
+				{RuleTags: []string{"ssh"}},
+			},
+		},
+	}
+	assert.Equal(t, []RuntimeAlertRuleBindingRule{
+		{RuleName: "No such rule"},
+		{RuleID: "C9999"},
+		{RuleTags: []string{"no such tag"}},
+	}, unresolvedRules(&binding))
+}
+
+func TestMergeRuleBindingsMissingApplicationProfile(t *testing.T) {
+	alertOnce := RuntimeAlertRuleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "alert-once"},
+		Spec: RuntimeAlertRuleBindingSpec{
+			Rules:                     []RuntimeAlertRuleBindingRule{{RuleName: rule.R0001UnexpectedProcessLaunchedRuleName}},
+			MissingApplicationProfile: &MissingApplicationProfilePolicy{Policy: MissingApplicationProfilePolicyAlertOnce},
+		},
+	}
+	nginxPods := RuntimeAlertRuleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx-pods"},
+		Spec: RuntimeAlertRuleBindingSpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}},
+			Rules:       []RuntimeAlertRuleBindingRule{{RuleName: rule.R0001UnexpectedProcessLaunchedRuleName}},
+		},
+	}
+
+	// Test case: the policy is kept when the binding with the higher precedence does not set one
+	effectiveRules := mergeRuleBindings([]RuntimeAlertRuleBinding{alertOnce, nginxPods})
+	assert.Len(t, effectiveRules, 1)
+	assert.Equal(t, alertOnce.Spec.MissingApplicationProfile, effectiveRules[0].MissingApplicationProfile)
+
+	// Test case: the policy of the binding with the higher precedence wins
+	nginxPods.Spec.MissingApplicationProfile = &MissingApplicationProfilePolicy{Policy: MissingApplicationProfilePolicyIgnore}
+	effectiveRules = mergeRuleBindings([]RuntimeAlertRuleBinding{alertOnce, nginxPods})
+	assert.Equal(t, nginxPods.Spec.MissingApplicationProfile, effectiveRules[0].MissingApplicationProfile)
+}
+
+func TestMergeRuleBindingsActions(t *testing.T) {
+	allPods := RuntimeAlertRuleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "all-pods"},
+		Spec: RuntimeAlertRuleBindingSpec{
+			Rules: []RuntimeAlertRuleBindingRule{{RuleName: rule.R1007CryptoMinersRuleName, Actions: []string{ResponseActionKillProcess}}},
+		},
+	}
+	nginxPods := RuntimeAlertRuleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx-pods"},
+		Spec: RuntimeAlertRuleBindingSpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}},
+			Rules:       []RuntimeAlertRuleBindingRule{{RuleName: rule.R1007CryptoMinersRuleName, Severity: "critical"}},
+		},
+	}
+
+	// Test case: the actions are kept when the binding with the higher precedence does not set them
+	effectiveRules := mergeRuleBindings([]RuntimeAlertRuleBinding{allPods, nginxPods})
+	assert.Len(t, effectiveRules, 1)
+	assert.Equal(t, []string{ResponseActionKillProcess}, effectiveRules[0].Actions)
+
+	// Test case: the actions of the binding with the higher precedence win, an empty list disables them
+	nginxPods.Spec.Rules[0].Actions = []string{ResponseActionDeletePod, ResponseActionIsolatePod}
+	effectiveRules = mergeRuleBindings([]RuntimeAlertRuleBinding{allPods, nginxPods})
+	assert.Equal(t, []string{ResponseActionDeletePod, ResponseActionIsolatePod}, effectiveRules[0].Actions)
+	nginxPods.Spec.Rules[0].Actions = []string{}
+	effectiveRules = mergeRuleBindings([]RuntimeAlertRuleBinding{allPods, nginxPods})
+	assert.Empty(t, effectiveRules[0].Actions)
+}
+
+func TestMissingApplicationProfilePolicyAlertDelay(t *testing.T) {
+	tests := []struct {
+		policy  MissingApplicationProfilePolicy
+		alert   bool
+		after   time.Duration
+		wantErr bool
+	}{
+		{policy: MissingApplicationProfilePolicy{}},
+		{policy: MissingApplicationProfilePolicy{Policy: MissingApplicationProfilePolicyIgnore}},
+		{policy: MissingApplicationProfilePolicy{Policy: MissingApplicationProfilePolicyAlertOnce}, alert: true},
+		{policy: MissingApplicationProfilePolicy{Policy: MissingApplicationProfilePolicyAlertAfter, After: "10m"}, alert: true, after: 10 * time.Minute},
+		{policy: MissingApplicationProfilePolicy{Policy: MissingApplicationProfilePolicyAlertAfter}, wantErr: true},
+		{policy: MissingApplicationProfilePolicy{Policy: "alertAlways"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		alert, after, err := tt.policy.AlertDelay()
+		if tt.wantErr {
+			assert.Error(t, err, "policy %v", tt.policy)
+			continue
+		}
+		assert.NoError(t, err, "policy %v", tt.policy)
+		assert.Equal(t, tt.alert, alert, "policy %v", tt.policy)
+		assert.Equal(t, tt.after, after, "policy %v", tt.policy)
+	}
+}
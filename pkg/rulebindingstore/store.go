@@ -5,10 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/kubescape/kapprofiler/pkg/collector"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -33,6 +41,11 @@ type RuleBindingK8sStore struct {
 	informerStopChannel chan struct{}
 	nodeName            string
 	storeNamespace      string
+	// Caches of the rule bindings, of the namespaces and of the pods of the node, the bindings are resolved
+	// against them so resolving the rules of a container makes no API calls
+	ruleBindingInformer cache.SharedIndexInformer
+	namespaceInformer   cache.SharedIndexInformer
+	podInformer         cache.SharedIndexInformer
 	// functions to call upon a change in a rule binding
 	callBacks []RuleBindingChangedHandler
 }
@@ -52,27 +65,55 @@ func NewRuleBindingK8sStore(dynamicClient dynClient, coreV1Client v1.CoreV1Inter
 		storeNamespace:      storeNamespace,
 	}
 	ruleBindingStore.StartController()
+	if !cache.WaitForCacheSync(stopCh, ruleBindingStore.ruleBindingInformer.HasSynced, ruleBindingStore.namespaceInformer.HasSynced, ruleBindingStore.podInformer.HasSynced) {
+		return nil, fmt.Errorf("failed to sync the rule binding, namespace and pod caches")
+	}
 	return &ruleBindingStore, nil
 }
 
 func (store *RuleBindingK8sStore) getAllRuleBindings() ([]RuntimeAlertRuleBinding, error) {
-	ruleBindingList, err := store.dynamicClient.Resource(RuleBindingAlertGvr).List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		return nil, err
+	var ruleBindings []RuntimeAlertRuleBinding
+	for _, obj := range store.ruleBindingInformer.GetStore().List() {
+		ruleBinding, err := getRuntimeAlertRuleBindingFromObj(obj)
+		if err != nil {
+			return nil, err
+		}
+		ruleBindings = append(ruleBindings, *ruleBinding)
 	}
+	return ruleBindings, nil
+}
 
-	ruleBindingListBytes, err := ruleBindingList.MarshalJSON()
-	if err != nil {
-		return nil, err
+// getNamespace returns the namespace from the cache, or from the API server if the cache does not have it yet.
+// It returns nil if the namespace does not exist.
+func (store *RuleBindingK8sStore) getNamespace(name string) (*corev1.Namespace, error) {
+	if obj, ok, err := store.namespaceInformer.GetIndexer().GetByKey(name); err == nil && ok {
+		return obj.(*corev1.Namespace), nil
 	}
-
-	var ruleBindingListObj *RuntimeAlertRuleBindingList
-	err = json.Unmarshal(ruleBindingListBytes, &ruleBindingListObj)
-	if err != nil {
-		return nil, err
+	ns, err := store.coreV1Client.Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
 	}
+	return ns, err
+}
 
-	return ruleBindingListObj.Items, nil
+// getPod returns the pod of the node from the cache, or from the API server if the cache does not have it yet.
+// It returns nil if the pod does not run on the node.
+func (store *RuleBindingK8sStore) getPod(podName, namespace string) (*corev1.Pod, error) {
+	var pod *corev1.Pod
+	if obj, ok, err := store.podInformer.GetIndexer().GetByKey(namespace + "/" + podName); err == nil && ok {
+		pod = obj.(*corev1.Pod)
+	} else {
+		pod, err = store.coreV1Client.Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if pod.Spec.NodeName != store.nodeName {
+		return nil, nil
+	}
+	return pod, nil
 }
 
 func (store *RuleBindingK8sStore) getRuleBindingsForPod(podName, namespace string) ([]RuntimeAlertRuleBinding, error) {
@@ -81,70 +122,118 @@ func (store *RuleBindingK8sStore) getRuleBindingsForPod(podName, namespace strin
 		return nil, err
 	}
 
+	// the namespace and the pod are fetched once, when a selector needs them
+	var ns *corev1.Namespace
+	var pod *corev1.Pod
+	nsFetched, podFetched := false, false
 	var ruleBindingsForPod []RuntimeAlertRuleBinding
 	for _, ruleBinding := range allBindings {
 		// check the namespace selector fits the pod namespace
-		nsLabelSelector := ruleBinding.Spec.NamespaceSelector
-		if nsLabelSelector.MatchLabels == nil {
-			nsLabelSelector.MatchLabels = make(map[string]string)
-		} else if ns, ok := nsLabelSelector.MatchLabels["kubernetes.io/metadata.name"]; ok && ns != namespace {
-			// namespace selector doesn't match the pod namespace
-			continue
+		nsSelector, err := metav1.LabelSelectorAsSelector(&ruleBinding.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse namespace selector of rule binding %s: %v", ruleBindingName(&ruleBinding), err)
+		}
+		if !nsSelector.Empty() {
+			if !nsFetched {
+				if ns, err = store.getNamespace(namespace); err != nil {
+					return nil, fmt.Errorf("failed to get namespace %s: %v", namespace, err)
+				}
+				nsFetched = true
+			}
+			if ns == nil {
+				continue
+			}
+			// according to https://kubernetes.io/docs/concepts/services-networking/network-policies/#targeting-a-namespace-by-its-name
+			// the namespace is selected by its name with the kubernetes.io/metadata.name label
+			nsLabels := labels.Merge(ns.Labels, labels.Set{"kubernetes.io/metadata.name": namespace})
+			if !nsSelector.Matches(nsLabels) {
+				continue
+			}
 		}
-		// according to https://kubernetes.io/docs/concepts/services-networking/network-policies/#targeting-a-namespace-by-its-name this should do the job
-		nsLabelSelector.MatchLabels["kubernetes.io/metadata.name"] = namespace
-		selectorString := metav1.FormatLabelSelector(&nsLabelSelector)
 
-		nss, err := store.coreV1Client.Namespaces().List(context.Background(), metav1.ListOptions{LabelSelector: selectorString, Limit: 1})
+		podSelector, err := metav1.LabelSelectorAsSelector(&ruleBinding.Spec.PodSelector)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get namespaces for selector %s: %v", selectorString, err)
-		}
-		if len(nss.Items) == 0 {
-			continue
+			return nil, fmt.Errorf("failed to parse pod selector of rule binding %s: %v", ruleBindingName(&ruleBinding), err)
 		}
-		selectorString = metav1.FormatLabelSelector(&ruleBinding.Spec.PodSelector)
-		if selectorString == "<none>" {
+		if podSelector.Empty() {
 			// This rule binding applies to all pods in the namespace
 			ruleBindingsForPod = append(ruleBindingsForPod, ruleBinding)
 			continue
-		} else if selectorString == "<error>" {
-			return nil, fmt.Errorf("failed to parse pod selector %s", selectorString)
-		}
-		pods, err := store.coreV1Client.Pods(namespace).List(context.Background(), metav1.ListOptions{
-			LabelSelector: selectorString,
-			FieldSelector: "spec.nodeName=" + store.nodeName})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get pods for selector %s: %v", selectorString, err)
-		}
-		if len(pods.Items) == 0 {
-			continue
 		}
-		for _, pod := range pods.Items {
-			if pod.Name == podName {
-				ruleBindingsForPod = append(ruleBindingsForPod, ruleBinding)
-				break
+		if !podFetched {
+			if pod, err = store.getPod(podName, namespace); err != nil {
+				return nil, fmt.Errorf("failed to get pod %s/%s: %v", namespace, podName, err)
 			}
+			podFetched = true
+		}
+		// no pod selector selects a pod which is not on the node
+		if pod != nil && podSelector.Matches(labels.Set(pod.Labels)) {
+			ruleBindingsForPod = append(ruleBindingsForPod, ruleBinding)
 		}
 	}
 
 	return ruleBindingsForPod, nil
 }
 
-func (store *RuleBindingK8sStore) GetRulesForPod(podName, namespace string) ([]RuntimeAlertRuleBindingRule, error) {
-	// TODO: change to support parameters of rule + custom priority
-	ruleBindingsForPod, err := store.getRuleBindingsForPod(podName, namespace)
+// GetPodLabels returns the labels of the pod of the node and the labels of its namespace, including the
+// kubernetes.io/metadata.name label. The labels of the pod are nil if the pod does not run on the node.
+func (store *RuleBindingK8sStore) GetPodLabels(podName, namespace string) (labels.Set, labels.Set, error) {
+	var podLabels, nsLabels labels.Set
+	pod, err := store.getPod(podName, namespace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get pod %s/%s: %v", namespace, podName, err)
+	}
+	if pod != nil {
+		podLabels = labels.Set(pod.Labels)
+	}
+	ns, err := store.getNamespace(namespace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get namespace %s: %v", namespace, err)
+	}
+	if ns != nil {
+		nsLabels = labels.Merge(ns.Labels, labels.Set{"kubernetes.io/metadata.name": namespace})
+	}
+	return podLabels, nsLabels, nil
+}
+
+// GetRulesForContainer returns the rules applied to the container of the pod, one per rule, after merging the
+// bindings selecting the pod and the container.
+func (store *RuleBindingK8sStore) GetRulesForContainer(podName, namespace string, container ContainerInfo) ([]RuntimeAlertRuleBindingRule, error) {
+	// the parameters and the severity of the rules are applied by the engine when the rules are created
+	effectiveRules, err := store.GetEffectiveRulesForContainer(podName, namespace, container)
 	if err != nil {
 		return nil, err
 	}
 
-	// we may have here duplications of rules, this should be handled by the caller logic
-	// Issue in GH: https://github.com/armosec/kubecop/issues/30
-	var rulesSlice []RuntimeAlertRuleBindingRule
+	rulesSlice := make([]RuntimeAlertRuleBindingRule, 0, len(effectiveRules))
+	for _, effectiveRule := range effectiveRules {
+		rulesSlice = append(rulesSlice, effectiveRule.RuntimeAlertRuleBindingRule)
+	}
+	return rulesSlice, nil
+}
+
+// GetEffectiveRulesForContainer returns the rules applied to the container of the pod, one per rule, after merging
+// the bindings selecting the pod and the container.
+func (store *RuleBindingK8sStore) GetEffectiveRulesForContainer(podName, namespace string, container ContainerInfo) ([]EffectiveRule, error) {
+	ruleBindingsForPod, err := store.getRuleBindingsForPod(podName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var ruleBindingsForContainer []RuntimeAlertRuleBinding
 	for _, ruleBinding := range ruleBindingsForPod {
-		rulesSlice = append(rulesSlice, ruleBinding.Spec.Rules...)
+		if ruleBinding.Spec.selectsContainer(container) {
+			ruleBindingsForContainer = append(ruleBindingsForContainer, ruleBinding)
+		}
 	}
 
-	return rulesSlice, nil
+	// several bindings may select the container with the same rules, they are merged into one rule per rule name
+	effectiveRules := mergeRuleBindings(ruleBindingsForContainer)
+	for _, effectiveRule := range effectiveRules {
+		if len(effectiveRule.Bindings) > 1 {
+			log.Debugf("Rule %q for container %s of pod %s/%s is merged from bindings %v: severity %q, parameters %v\n", effectiveRule.RuleName, container.Name, namespace, podName, effectiveRule.Bindings, effectiveRule.Severity, effectiveRule.Parameters)
+		}
+	}
+	return effectiveRules, nil
 }
 
 func (store *RuleBindingK8sStore) Destroy() {
@@ -154,10 +243,11 @@ func (store *RuleBindingK8sStore) Destroy() {
 func (store *RuleBindingK8sStore) ruleBindingAddedHandler(obj interface{}) {
 	bindObj, err := getRuntimeAlertRuleBindingFromObj(obj)
 	if err != nil {
-		fmt.Println("Error getting rule binding from obj: ", err)
+		log.Errorf("Error getting rule binding from obj: %v\n", err)
 		return
 	}
-	fmt.Println("Rule binding added: ", bindObj)
+	log.Infof("Rule binding added: %v\n", bindObj)
+	warnUnresolvedRules(bindObj)
 	for _, callBack := range store.callBacks {
 		callBack(*bindObj)
 	}
@@ -172,10 +262,10 @@ func (store *RuleBindingK8sStore) ruleBindingUpdatedHandler(oldObj, newObj inter
 func (store *RuleBindingK8sStore) ruleBindingDeletedHandler(obj interface{}) {
 	bindObj, err := getRuntimeAlertRuleBindingFromObj(obj)
 	if err != nil {
-		fmt.Println("Error getting rule binding from obj: ", err)
+		log.Errorf("Error getting rule binding from obj: %v\n", err)
 		return
 	}
-	fmt.Println("Rule binding deleted: ", bindObj)
+	log.Infof("Rule binding deleted: %v\n", bindObj)
 	for _, callBack := range store.callBacks {
 		callBack(*bindObj)
 	}
@@ -199,17 +289,40 @@ func getRuntimeAlertRuleBindingFromObj(obj interface{}) (*RuntimeAlertRuleBindin
 func (store *RuleBindingK8sStore) StartController() {
 
 	// Initialize factory and informer
-	informer := dynamicinformer.NewFilteredDynamicSharedInformerFactory(store.dynamicClient, 0, store.storeNamespace, nil).ForResource(RuleBindingAlertGvr).Informer()
+	store.ruleBindingInformer = dynamicinformer.NewFilteredDynamicSharedInformerFactory(store.dynamicClient, 0, store.storeNamespace, nil).ForResource(RuleBindingAlertGvr).Informer()
 
 	// Add event handlers to informer
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	store.ruleBindingInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    store.ruleBindingAddedHandler,
 		UpdateFunc: store.ruleBindingUpdatedHandler,
 		DeleteFunc: store.ruleBindingDeletedHandler,
 	})
 
-	// Run the informer
-	go informer.Run(store.informerStopChannel)
+	// The namespaces and the pods of the node are only cached, the bindings are resolved when the containers start
+	store.namespaceInformer = cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return store.coreV1Client.Namespaces().List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return store.coreV1Client.Namespaces().Watch(context.Background(), options)
+		},
+	}, &corev1.Namespace{}, 0, cache.Indexers{})
+	nodeSelector := fields.OneTermEqualSelector("spec.nodeName", store.nodeName).String()
+	store.podInformer = cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = nodeSelector
+			return store.coreV1Client.Pods(metav1.NamespaceAll).List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = nodeSelector
+			return store.coreV1Client.Pods(metav1.NamespaceAll).Watch(context.Background(), options)
+		},
+	}, &corev1.Pod{}, 0, cache.Indexers{})
+
+	// Run the informers
+	go store.ruleBindingInformer.Run(store.informerStopChannel)
+	go store.namespaceInformer.Run(store.informerStopChannel)
+	go store.podInformer.Run(store.informerStopChannel)
 }
 
 func (store *RuleBindingK8sStore) SetRuleBindingChangedHandlers(handlers []RuleBindingChangedHandler) {
@@ -1,10 +1,13 @@
 package rulebindingstore
 
 import (
+	"context"
 	"embed"
+	"fmt"
 	"io/fs"
 	"sort"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	dfake "k8s.io/client-go/dynamic/fake"
@@ -17,6 +20,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestNewRuleBindingK8sStore(t *testing.T) {
@@ -76,7 +80,7 @@ func TestRuleBindingK8sStore_getRuleBindingsForPod(t *testing.T) {
 	assert.Equal(t, number_of_all_rules, len(ruleBindings[0].Spec.Rules))
 }
 
-func TestRuleBindingK8sStore_GetRulesForPod(t *testing.T) {
+func TestRuleBindingK8sStore_GetRulesForContainer(t *testing.T) {
 	// Create a fake dynamic client
 	dynamicClient := dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
 		map[schema.GroupVersionResource]string{RuleBindingAlertGvr: RuntimeRuleBindingAlertPlural + "List"}, ruleBindingsObjects()...)
@@ -86,13 +90,135 @@ func TestRuleBindingK8sStore_GetRulesForPod(t *testing.T) {
 	store, err := NewRuleBindingK8sStore(dynamicClient, coreClient, "test-node", "")
 	assert.NoError(t, err)
 	defer store.Destroy()
-	// Call the GetRulesForPod function
-	rules, err := store.GetRulesForPod("test-pod", "test-namespace")
+	// Call the GetRulesForContainer function
+	rules, err := store.GetRulesForContainer("test-pod", "test-namespace", ContainerInfo{Name: "test-container", Image: "nginx:1.25"})
 	assert.NoError(t, err)
 	number_of_all_rules := len(rule.GetAllRuleDescriptors())
 	assert.Len(t, rules, number_of_all_rules)
 }
 
+func testPod(name, namespace, nodeName string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: nodeName},
+	}
+}
+
+// apiReads counts the get and list calls, the watches of the informers are not counted.
+func apiReads(actions []k8stesting.Action) int {
+	reads := 0
+	for _, action := range actions {
+		if action.GetVerb() == "get" || action.GetVerb() == "list" {
+			reads++
+		}
+	}
+	return reads
+}
+
+func TestRuleBindingK8sStoreResolvesFromCaches(t *testing.T) {
+	dynamicClient := dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{RuleBindingAlertGvr: RuntimeRuleBindingAlertPlural + "List"}, ruleBindingsObjects()...)
+	clientset := fake.NewSimpleClientset(coreV1Objects()...)
+	store, err := NewRuleBindingK8sStore(dynamicClient, clientset.CoreV1(), "test-node", "")
+	assert.NoError(t, err)
+	defer store.Destroy()
+	reads := apiReads(clientset.Actions()) + apiReads(dynamicClient.Actions())
+
+	// Pods started after the store are cached by the informer
+	for name, labels := range map[string]map[string]string{"nginx": {"app": "nginx"}, "other": {"app": "other"}} {
+		_, err := clientset.CoreV1().Pods("default").Create(context.Background(), testPod(name, "default", "test-node", labels), metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	_, err = clientset.CoreV1().Pods("default").Create(context.Background(), testPod("remote", "default", "other-node", map[string]string{"app": "nginx"}), metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, ok, _ := store.podInformer.GetIndexer().GetByKey("default/other")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	tests := []struct {
+		podName  string
+		bindings int
+	}{
+		// all the bindings select the nginx pods of the default namespace
+		{podName: "nginx", bindings: 6},
+		// only the bindings without a pod selector select the other pods
+		{podName: "other", bindings: 2},
+	}
+	for i := 0; i < 10; i++ {
+		for _, tt := range tests {
+			ruleBindings, err := store.getRuleBindingsForPod(tt.podName, "default")
+			assert.NoError(t, err)
+			assert.Len(t, ruleBindings, tt.bindings, tt.podName)
+		}
+	}
+
+	// The pods of other nodes are not selected by the pod selectors
+	ruleBindings, err := store.getRuleBindingsForPod("remote", "default")
+	assert.NoError(t, err)
+	assert.Len(t, ruleBindings, 2)
+	assert.Equal(t, reads, apiReads(clientset.Actions())+apiReads(dynamicClient.Actions()), "Expected no API calls to resolve the bindings")
+
+	// A pod missing from the cache is fetched from the API server
+	ruleBindings, err = store.getRuleBindingsForPod("unknown", "default")
+	assert.NoError(t, err)
+	assert.Len(t, ruleBindings, 2)
+	assert.Equal(t, reads+1, apiReads(clientset.Actions())+apiReads(dynamicClient.Actions()))
+
+	// the labels of the pods of the node and of their namespace come from the caches too
+	podLabels, nsLabels, err := store.GetPodLabels("nginx", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "nginx", podLabels["app"])
+	assert.Equal(t, "default", nsLabels["kubernetes.io/metadata.name"])
+	podLabels, _, err = store.GetPodLabels("remote", "default")
+	assert.NoError(t, err)
+	assert.Nil(t, podLabels)
+}
+
+func BenchmarkGetRulesForContainer(b *testing.B) {
+	dynamicClient := dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{RuleBindingAlertGvr: RuntimeRuleBindingAlertPlural + "List"}, ruleBindingsObjects()...)
+	objects := coreV1Objects()
+	for i := 0; i < 100; i++ {
+		objects = append(objects, testPod(fmt.Sprintf("nginx-%d", i), "default", "test-node", map[string]string{"app": "nginx"}))
+	}
+	clientset := fake.NewSimpleClientset(objects...)
+	store, err := NewRuleBindingK8sStore(dynamicClient, clientset.CoreV1(), "test-node", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Destroy()
+	container := ContainerInfo{Name: "nginx", Image: "nginx:1.25"}
+
+	b.ResetTimer()
+	reads := apiReads(clientset.Actions()) + apiReads(dynamicClient.Actions())
+	for i := 0; i < b.N; i++ {
+		// A pod is replaced on every iteration while the rules of the containers are resolved, the churn is not timed
+		b.StopTimer()
+		churned := testPod(fmt.Sprintf("churn-%d", i), "default", "test-node", map[string]string{"app": "nginx"})
+		if _, err := clientset.CoreV1().Pods("default").Create(context.Background(), churned, metav1.CreateOptions{}); err != nil {
+			b.Fatal(err)
+		}
+		// the fake watches have a bounded buffer, wait for the informer to catch up
+		for _, ok, _ := store.podInformer.GetIndexer().GetByKey("default/" + churned.Name); !ok; _, ok, _ = store.podInformer.GetIndexer().GetByKey("default/" + churned.Name) {
+			time.Sleep(time.Millisecond)
+		}
+		b.StartTimer()
+		if _, err := store.GetRulesForContainer(fmt.Sprintf("nginx-%d", i%100), "default", container); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := store.GetRulesForContainer(churned.Name, "default", container); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		if err := clientset.CoreV1().Pods("default").Delete(context.Background(), churned.Name, metav1.DeleteOptions{}); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(apiReads(clientset.Actions())+apiReads(dynamicClient.Actions())-reads)/float64(b.N), "apireads/op")
+}
+
 //go:embed testdata/rulebindingsfiles/*.yaml
 var rulebindingsfiles embed.FS
 
@@ -1,9 +1,49 @@
 package rulebindingstore
 
 import (
+	"fmt"
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// Ignore containers without an application profile (default)
+	MissingApplicationProfilePolicyIgnore = "ignore"
+	// Alert once per container as soon as an event cannot be checked because the application profile is missing
+	MissingApplicationProfilePolicyAlertOnce = "alertOnce"
+	// Alert once per container when the container runs without an application profile for longer than the duration
+	MissingApplicationProfilePolicyAlertAfter = "alertAfter"
+)
+
+const (
+	// Kill the process of the alert with SIGKILL
+	ResponseActionKillProcess = "killProcess"
+	// Pause the process of the alert with SIGSTOP
+	ResponseActionStopProcess = "stopProcess"
+	// Delete the pod of the alert, its controller restarts it
+	ResponseActionDeletePod = "deletePod"
+	// Evict the pod of the alert, the eviction respects the disruption budget of the pod
+	ResponseActionEvictPod = "evictPod"
+	// Label the pod of the alert with the ResponseLabel label
+	ResponseActionLabelPod = "labelPod"
+	// Cordon the node of the pod of the alert
+	ResponseActionCordonNode = "cordonNode"
+	// Deny the ingress and egress traffic of the pod of the alert with a NetworkPolicy
+	ResponseActionIsolatePod = "isolatePod"
+)
+
+// ResponseActions are the response actions a binding may set on its rules.
+var ResponseActions = []string{
+	ResponseActionKillProcess,
+	ResponseActionStopProcess,
+	ResponseActionDeletePod,
+	ResponseActionEvictPod,
+	ResponseActionLabelPod,
+	ResponseActionCordonNode,
+	ResponseActionIsolatePod,
+}
+
 type RuntimeAlertRuleBindingList struct {
 	metav1.TypeMeta `json:",inline"`
 	metav1.ListMeta `json:"metadata,omitempty"`
@@ -22,6 +62,34 @@ type RuntimeAlertRuleBindingSpec struct {
 	Rules             []RuntimeAlertRuleBindingRule `json:"rules" yaml:"rules"`
 	PodSelector       metav1.LabelSelector          `json:"podSelector" yaml:"podSelector"`
 	NamespaceSelector metav1.LabelSelector          `json:"namespaceSelector" yaml:"namespaceSelector"`
+	// Priority of the binding when several bindings select the same pod, the higher wins
+	Priority int `json:"priority" yaml:"priority"`
+	// What to do when rules which need an application profile run in a container without one
+	MissingApplicationProfile *MissingApplicationProfilePolicy `json:"missingApplicationProfile,omitempty" yaml:"missingApplicationProfile,omitempty"`
+	// Containers of the selected pods the rules apply to, by name and by image, all the containers if not set
+	ContainerNames  *ContainerGlobs `json:"containerNames,omitempty" yaml:"containerNames,omitempty"`
+	ContainerImages *ContainerGlobs `json:"containerImages,omitempty" yaml:"containerImages,omitempty"`
+}
+
+// ContainerGlobs selects containers with glob patterns, where * matches any sequence of characters and ? any character.
+type ContainerGlobs struct {
+	// The container is selected if it matches one of the patterns, any container if empty
+	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
+	// The container is not selected if it matches one of the patterns
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+}
+
+// ContainerInfo is the container the rules of the bindings are bound to.
+type ContainerInfo struct {
+	Name  string
+	Image string
+}
+
+type MissingApplicationProfilePolicy struct {
+	// One of ignore, alertOnce or alertAfter
+	Policy string `json:"policy" yaml:"policy"`
+	// Duration for the alertAfter policy, like 10m
+	After string `json:"after,omitempty" yaml:"after,omitempty"`
 }
 
 type RuntimeAlertRuleBindingRule struct {
@@ -30,6 +98,32 @@ type RuntimeAlertRuleBindingRule struct {
 	RuleTags   []string               `json:"ruleTags" yaml:"ruleTags"`
 	Severity   string                 `json:"severity" yaml:"severity"`
 	Parameters map[string]interface{} `json:"parameters" yaml:"parameters"`
+	// Select the rules mapped to one of the MITRE ATT&CK tactics or techniques
+	MitreTactics    []string `json:"mitreTactics,omitempty" yaml:"mitreTactics,omitempty"`
+	MitreTechniques []string `json:"mitreTechniques,omitempty" yaml:"mitreTechniques,omitempty"`
+	// Response actions taken on every alert of the rule, one of the ResponseActions
+	Actions []string `json:"actions,omitempty" yaml:"actions,omitempty"`
+	// Set from the binding when the bindings selecting a pod are merged
+	MissingApplicationProfile *MissingApplicationProfilePolicy `json:"-" yaml:"-"`
 }
 
 type RuleBindingChangedHandler func(ruleBinding RuntimeAlertRuleBinding)
+
+// AlertDelay returns whether to alert on a missing application profile and how long the container
+// may run without one before the alert.
+func (policy *MissingApplicationProfilePolicy) AlertDelay() (bool, time.Duration, error) {
+	switch policy.Policy {
+	case "", MissingApplicationProfilePolicyIgnore:
+		return false, 0, nil
+	case MissingApplicationProfilePolicyAlertOnce:
+		return true, 0, nil
+	case MissingApplicationProfilePolicyAlertAfter:
+		after, err := time.ParseDuration(policy.After)
+		if err != nil || after < 0 {
+			return false, 0, fmt.Errorf("invalid duration %q for the %s policy", policy.After, MissingApplicationProfilePolicyAlertAfter)
+		}
+		return true, after, nil
+	default:
+		return false, 0, fmt.Errorf("unknown missing application profile policy %q", policy.Policy)
+	}
+}
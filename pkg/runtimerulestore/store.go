@@ -0,0 +1,222 @@
+package runtimerulestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/kubescape/kapprofiler/pkg/collector"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const RuntimeRulePlural = "runtimerules"
+
+// RuntimeRuleIDPattern is the pattern of the IDs of the runtime rules, it is distinct from the IDs of the builtin
+// rules so the bindings can validate the rule IDs with a schema.
+const RuntimeRuleIDPattern = "^C[0-9]{4}$"
+
+var runtimeRuleIDRegexp = regexp.MustCompile(RuntimeRuleIDPattern)
+
+var RuntimeRuleGvr schema.GroupVersionResource = schema.GroupVersionResource{
+	Group:    collector.ApplicationProfileGroup,
+	Version:  collector.ApplicationProfileVersion,
+	Resource: RuntimeRulePlural,
+}
+
+type dynClient interface {
+	Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface
+}
+
+// RuntimeRuleK8sStore watches the RuntimeRule objects and registers their rules in the rule factory.
+type RuntimeRuleK8sStore struct {
+	dynamicClient       dynClient
+	informerStopChannel chan struct{}
+	storeNamespace      string
+	// Rule registered by each RuntimeRule object, by namespace/name
+	registeredRules     map[string]rule.RuleDesciptor
+	registeredRulesLock sync.Mutex
+	// functions to call upon a change in a runtime rule
+	callBacks []RuntimeRuleChangedHandler
+}
+
+func NewRuntimeRuleK8sStore(dynamicClient dynClient, storeNamespace string) (*RuntimeRuleK8sStore, error) {
+	if storeNamespace == "" {
+		storeNamespace = metav1.NamespaceNone
+	}
+
+	runtimeRuleStore := RuntimeRuleK8sStore{
+		dynamicClient:       dynamicClient,
+		informerStopChannel: make(chan struct{}),
+		storeNamespace:      storeNamespace,
+		registeredRules:     make(map[string]rule.RuleDesciptor),
+	}
+	runtimeRuleStore.StartController()
+	return &runtimeRuleStore, nil
+}
+
+func (store *RuntimeRuleK8sStore) Destroy() {
+	close(store.informerStopChannel)
+}
+
+func (store *RuntimeRuleK8sStore) SetRuntimeRuleChangedHandlers(handlers []RuntimeRuleChangedHandler) {
+	store.callBacks = handlers
+}
+
+func (store *RuntimeRuleK8sStore) StartController() {
+	informer := dynamicinformer.NewFilteredDynamicSharedInformerFactory(store.dynamicClient, 0, store.storeNamespace, nil).ForResource(RuntimeRuleGvr).Informer()
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    store.runtimeRuleAddedHandler,
+		UpdateFunc: store.runtimeRuleUpdatedHandler,
+		DeleteFunc: store.runtimeRuleDeletedHandler,
+	})
+
+	go informer.Run(store.informerStopChannel)
+}
+
+func (store *RuntimeRuleK8sStore) runtimeRuleAddedHandler(obj interface{}) {
+	runtimeRule, err := getRuntimeRuleFromObj(obj)
+	if err != nil {
+		log.Errorf("Error getting runtime rule from obj: %v\n", err)
+		return
+	}
+	if err := store.registerRuntimeRule(runtimeRule); err != nil {
+		log.Errorf("Failed to register runtime rule %s: %v\n", runtimeRuleName(runtimeRule), err)
+		return
+	}
+	log.Infof("Runtime rule %s registered as rule %s (%s)\n", runtimeRuleName(runtimeRule), runtimeRule.Spec.RuleID, runtimeRule.Spec.RuleName)
+	store.notify(*runtimeRule)
+}
+
+func (store *RuntimeRuleK8sStore) runtimeRuleUpdatedHandler(oldObj, newObj interface{}) {
+	// the rule of the new object replaces the rule of the old one, registerRuntimeRule handles an ID change
+	store.runtimeRuleAddedHandler(newObj)
+}
+
+func (store *RuntimeRuleK8sStore) runtimeRuleDeletedHandler(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	runtimeRule, err := getRuntimeRuleFromObj(obj)
+	if err != nil {
+		log.Errorf("Error getting runtime rule from obj: %v\n", err)
+		return
+	}
+	if !store.unregisterRuntimeRule(runtimeRule) {
+		return
+	}
+	log.Infof("Runtime rule %s unregistered\n", runtimeRuleName(runtimeRule))
+	store.notify(*runtimeRule)
+}
+
+func (store *RuntimeRuleK8sStore) notify(runtimeRule RuntimeRule) {
+	for _, callBack := range store.callBacks {
+		callBack(runtimeRule)
+	}
+}
+
+// registerRuntimeRule compiles the rule of the object and registers it, replacing the rule previously
+// registered by the object. An invalid rule leaves the previously registered rule in place.
+func (store *RuntimeRuleK8sStore) registerRuntimeRule(runtimeRule *RuntimeRule) error {
+	descriptor, err := NewRuleDescriptor(runtimeRule)
+	if err != nil {
+		return err
+	}
+
+	store.registeredRulesLock.Lock()
+	defer store.registeredRulesLock.Unlock()
+	name := runtimeRuleName(runtimeRule)
+	for otherName, otherDescriptor := range store.registeredRules {
+		if otherName != name && otherDescriptor.ID == descriptor.ID {
+			return fmt.Errorf("rule ID %s is already used by runtime rule %s", descriptor.ID, otherName)
+		}
+	}
+	previous, hasPrevious := store.registeredRules[name]
+	if hasPrevious && previous.ID != descriptor.ID {
+		// the ID changed, the previous rule goes away first so it does not conflict with its replacement
+		rule.UnregisterRuleDescriptor(previous.ID)
+	}
+	if err := rule.RegisterRuleDescriptor(descriptor); err != nil {
+		if hasPrevious && previous.ID != descriptor.ID {
+			if restoreErr := rule.RegisterRuleDescriptor(previous); restoreErr != nil {
+				log.Errorf("Failed to restore rule %s of runtime rule %s: %v\n", previous.ID, name, restoreErr)
+				delete(store.registeredRules, name)
+			}
+		}
+		return err
+	}
+	store.registeredRules[name] = descriptor
+	return nil
+}
+
+// unregisterRuntimeRule removes the rule registered by the object, it returns false if there is none.
+func (store *RuntimeRuleK8sStore) unregisterRuntimeRule(runtimeRule *RuntimeRule) bool {
+	store.registeredRulesLock.Lock()
+	defer store.registeredRulesLock.Unlock()
+	name := runtimeRuleName(runtimeRule)
+	descriptor, ok := store.registeredRules[name]
+	if !ok {
+		return false
+	}
+	delete(store.registeredRules, name)
+	return rule.UnregisterRuleDescriptor(descriptor.ID)
+}
+
+// NewRuleDescriptor compiles the rule of the RuntimeRule object into a descriptor of the rule factory.
+func NewRuleDescriptor(runtimeRule *RuntimeRule) (rule.RuleDesciptor, error) {
+	if !runtimeRuleIDRegexp.MatchString(runtimeRule.Spec.RuleID) {
+		return rule.RuleDesciptor{}, fmt.Errorf("rule ID %q does not match %s", runtimeRule.Spec.RuleID, RuntimeRuleIDPattern)
+	}
+	priority := rule.RulePriorityMed
+	if runtimeRule.Spec.Priority != nil {
+		var err error
+		if priority, err = rule.ParsePriority(runtimeRule.Spec.Priority.String()); err != nil {
+			return rule.RuleDesciptor{}, err
+		}
+	}
+	return rule.NewCELRuleDescriptor(rule.CELRuleDefinition{
+		ID:            runtimeRule.Spec.RuleID,
+		Name:          runtimeRule.Spec.RuleName,
+		Description:   runtimeRule.Spec.Description,
+		Priority:      priority,
+		Tags:          runtimeRule.Spec.Tags,
+		Mitre:         rule.MitreAttack{Tactics: runtimeRule.Spec.MitreTactics, Techniques: runtimeRule.Spec.MitreTechniques},
+		EventTypes:    runtimeRule.Spec.EventTypes,
+		Expression:    runtimeRule.Spec.Expression,
+		Message:       runtimeRule.Spec.Message,
+		FixSuggestion: runtimeRule.Spec.FixSuggestion,
+	})
+}
+
+func getRuntimeRuleFromObj(obj interface{}) (*RuntimeRule, error) {
+	typedObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+	bytes, err := typedObj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var runtimeRuleObj *RuntimeRule
+	if err := json.Unmarshal(bytes, &runtimeRuleObj); err != nil {
+		return nil, err
+	}
+	return runtimeRuleObj, nil
+}
+
+func runtimeRuleName(runtimeRule *RuntimeRule) string {
+	if runtimeRule.Namespace != "" {
+		return runtimeRule.Namespace + "/" + runtimeRule.Name
+	}
+	return runtimeRule.Name
+}
@@ -0,0 +1,111 @@
+package runtimerulestore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/armosec/kubecop/pkg/engine/rule"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dfake "k8s.io/client-go/dynamic/fake"
+)
+
+func runtimeRuleObject(name, ruleID, ruleName, expression string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": RuntimeRuleGvr.GroupVersion().String(),
+		"kind":       "RuntimeRule",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"ruleID":     ruleID,
+			"ruleName":   ruleName,
+			"priority":   "high",
+			"tags":       []interface{}{"custom"},
+			"eventTypes": []interface{}{"exec"},
+			"expression": expression,
+		},
+	}}
+}
+
+func TestRuntimeRuleK8sStore(t *testing.T) {
+	dynamicClient := dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{RuntimeRuleGvr: "RuntimeRuleList"},
+		runtimeRuleObject("curl-in-payments", "C0001", "Curl in payments", `event.path == "/usr/bin/curl" && pod.namespace == "payments"`),
+		runtimeRuleObject("invalid", "C0002", "Invalid", `event.path ==`))
+
+	var mutex sync.Mutex
+	changed := []string{}
+	store, err := NewRuntimeRuleK8sStore(dynamicClient, "")
+	assert.NoError(t, err)
+	defer store.Destroy()
+	store.SetRuntimeRuleChangedHandlers([]RuntimeRuleChangedHandler{func(runtimeRule RuntimeRule) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		changed = append(changed, runtimeRule.Name)
+	}})
+
+	// the valid rule is registered, the invalid one is not
+	assert.Eventually(t, func() bool { return rule.CreateRuleByID("C0001") != nil }, 5*time.Second, 10*time.Millisecond)
+	assert.NotNil(t, rule.CreateRuleByName("Curl in payments"))
+	assert.Len(t, rule.CreateRulesByTags([]string{"custom"}), 1)
+	assert.Nil(t, rule.CreateRuleByID("C0002"))
+	for _, ruleDesc := range rule.GetAllRuleDescriptors() {
+		if ruleDesc.ID == "C0001" {
+			assert.Equal(t, rule.RulePriorityHigh, ruleDesc.Priority)
+		}
+	}
+
+	// changing the ID of the rule replaces the registered rule
+	_, err = dynamicClient.Resource(RuntimeRuleGvr).Update(context.Background(),
+		runtimeRuleObject("curl-in-payments", "C0003", "Curl in payments", `event.path == "/usr/bin/curl"`), metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return rule.CreateRuleByID("C0003") != nil }, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, rule.CreateRuleByID("C0001"))
+
+	// deleting the object unregisters the rule
+	err = dynamicClient.Resource(RuntimeRuleGvr).Delete(context.Background(), "curl-in-payments", metav1.DeleteOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return rule.CreateRuleByID("C0003") == nil }, 5*time.Second, 10*time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Contains(t, changed, "curl-in-payments")
+}
+
+func TestNewRuleDescriptor(t *testing.T) {
+	runtimeRule, err := getRuntimeRuleFromObj(runtimeRuleObject("test", "C0004", "Test", "true"))
+	assert.NoError(t, err)
+	descriptor, err := NewRuleDescriptor(runtimeRule)
+	assert.NoError(t, err)
+	assert.Equal(t, "C0004", descriptor.ID)
+	assert.Equal(t, rule.RulePriorityHigh, descriptor.Priority)
+
+	// the priority defaults to medium and may be a number
+	runtimeRule.Spec.Priority = nil
+	descriptor, err = NewRuleDescriptor(runtimeRule)
+	assert.NoError(t, err)
+	assert.Equal(t, rule.RulePriorityMed, descriptor.Priority)
+	runtimeRule, err = getRuntimeRuleFromObj(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "test"},
+		"spec":     map[string]interface{}{"ruleID": "C0004", "ruleName": "Test", "priority": int64(3), "eventTypes": []interface{}{"dns"}, "expression": "true"},
+	}})
+	assert.NoError(t, err)
+	descriptor, err = NewRuleDescriptor(runtimeRule)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, descriptor.Priority)
+
+	// the ID must not look like the ID of a builtin rule
+	runtimeRule.Spec.RuleID = "R0004"
+	_, err = NewRuleDescriptor(runtimeRule)
+	assert.Error(t, err)
+
+	runtimeRule.Spec.RuleID = "C0004"
+	runtimeRule.Spec.Priority = nil
+	runtimeRule.Spec.EventTypes = nil
+	_, err = NewRuleDescriptor(runtimeRule)
+	assert.Error(t, err)
+}
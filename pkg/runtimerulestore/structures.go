@@ -0,0 +1,41 @@
+package runtimerulestore
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type RuntimeRuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	// Items is the list of RuntimeRule
+	Items []RuntimeRule `json:"items"`
+}
+
+type RuntimeRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the custom rule
+	Spec RuntimeRuleSpec `json:"spec,omitempty"`
+}
+
+type RuntimeRuleSpec struct {
+	RuleID      string `json:"ruleID" yaml:"ruleID"`
+	RuleName    string `json:"ruleName" yaml:"ruleName"`
+	Description string `json:"description" yaml:"description"`
+	// One of none, low, medium, high, critical or a number between 0 and 10, medium if not set
+	Priority *intstr.IntOrString `json:"priority,omitempty" yaml:"priority,omitempty"`
+	Tags     []string            `json:"tags" yaml:"tags"`
+	// MITRE ATT&CK tactic and technique IDs of the rule, like TA0002 and T1059
+	MitreTactics    []string `json:"mitreTactics,omitempty" yaml:"mitreTactics,omitempty"`
+	MitreTechniques []string `json:"mitreTechniques,omitempty" yaml:"mitreTechniques,omitempty"`
+	// Event types the expression is evaluated on: exec, open, capabilities, dns, network, syscall
+	EventTypes []string `json:"eventTypes" yaml:"eventTypes"`
+	// CEL expression over the event, pod and params variables, an alert is sent when it is true
+	Expression string `json:"expression" yaml:"expression"`
+	// Optional CEL expression building the alert message
+	Message       string `json:"message,omitempty" yaml:"message,omitempty"`
+	FixSuggestion string `json:"fixSuggestion,omitempty" yaml:"fixSuggestion,omitempty"`
+}
+
+type RuntimeRuleChangedHandler func(runtimeRule RuntimeRule)